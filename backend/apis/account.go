@@ -107,6 +107,16 @@ func SetupPolygonAccount(user *models.User) error {
 	return nil
 }
 
+// Encrypt seals a secret with the platform ENCRYPTION_KEY for storage
+func Encrypt(plainText string) (string, error) {
+	return encrypt(plainText)
+}
+
+// Decrypt opens a secret sealed with Encrypt
+func Decrypt(cipherTextB64 string) (string, error) {
+	return decrypt(cipherTextB64)
+}
+
 func encrypt(plainText string) (string, error) {
 	encryptionKey, err := base64.StdEncoding.DecodeString(state.AppConfig.EncryptionKey)
 	if err != nil {
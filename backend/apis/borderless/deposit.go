@@ -3,7 +3,6 @@ package borderless
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"time"
 
 	"github.com/google/uuid"
@@ -35,7 +34,9 @@ type Deposit struct {
 	FeeAmount             string      `json:"feeAmount"`
 }
 
-func (hc Borderless) MakeDeposit(amount, asset, country, fiat string) (Deposit, error) {
+// MakeDeposit opens a wire deposit. Borderless runs a deposit once per
+// idempotency key, an empty key is replaced by a fresh one.
+func (hc Borderless) MakeDeposit(amount, asset, country, fiat, idempotencyKey string) (Deposit, error) {
 	requestData := map[string]interface{}{
 		"accountId":     hc.accountID,
 		"amount":        amount,
@@ -45,20 +46,14 @@ func (hc Borderless) MakeDeposit(amount, asset, country, fiat string) (Deposit,
 		"paymentMethod": "Wire",
 	}
 
-	idempotencyKey := uuid.New()
-
-	log.Println("Idempotency Key: ", idempotencyKey)
-	hc.Headers["idempotency-key"] = idempotencyKey.String()
-
 	// Make the request
-	response, err := hc.MakeRequest(
+	response, err := hc.MakeRequestWithHeaders(
 		"POST",
 		fmt.Sprintf("%s/deposits", hc.BaseUrl),
 		requestData,
+		map[string]string{"idempotency-key": keyOrNew(idempotencyKey)},
 	)
 
-	log.Println("Response: ", response)
-
 	if err != nil {
 		return Deposit{}, err
 	}
@@ -76,6 +71,8 @@ func (hc Borderless) MakeDeposit(amount, asset, country, fiat string) (Deposit,
 	return depositResponse, nil
 }
 
+// MobileMoneyDeposit opens a mobile money deposit, once per idempotency key
+// like MakeDeposit
 func (hc Borderless) MobileMoneyDeposit(
 	accountId string,
 	fiat string,
@@ -83,6 +80,7 @@ func (hc Borderless) MobileMoneyDeposit(
 	asset string,
 	amount string,
 	paymentMethod string,
+	idempotencyKey string,
 ) (Deposit, error) {
 
 	// by default use greybox account ID but if an account ID is provided, use that instead
@@ -101,14 +99,13 @@ func (hc Borderless) MobileMoneyDeposit(
 	}
 
 	// Make the request
-	response, err := hc.MakeRequest(
+	response, err := hc.MakeRequestWithHeaders(
 		"POST",
 		fmt.Sprintf("%s/deposits", hc.BaseUrl),
 		requestData,
+		map[string]string{"idempotency-key": keyOrNew(idempotencyKey)},
 	)
 
-	log.Println("Response: ", response)
-
 	if err != nil {
 		return Deposit{}, err
 	}
@@ -125,3 +122,10 @@ func (hc Borderless) MobileMoneyDeposit(
 
 	return depositResponse, nil
 }
+
+func keyOrNew(idempotencyKey string) string {
+	if idempotencyKey == "" {
+		return uuid.NewString()
+	}
+	return idempotencyKey
+}
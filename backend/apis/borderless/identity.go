@@ -39,14 +39,14 @@ func (hc Borderless) CreateCustomerIdentity(identity models.BorderlessIdentity)
 	return response, nil
 }
 
-func (hc Borderless) UploadCustomerIdentityDocument(identityId string, kyc models.KYC, kycData models.KYCData) (map[string]interface{}, error) {
+func (hc Borderless) UploadCustomerIdentityDocument(identityId string, kyc models.KYC, imageFront, imageBack string) (map[string]interface{}, error) {
 	requestData := map[string]interface{}{
 		"issuingCountry": "US",
 		"type":           kyc.IDType,
 		"issuedDate":     kyc.IssueDate,
 		"expiryDate":     kyc.ExpiryDate,
-		"imageFront":     kycData.FrontPhoto,
-		"imageBack":      kycData.BackPhoto,
+		"imageFront":     imageFront,
+		"imageBack":      imageBack,
 	}
 
 	response, err := hc.MakeRequest(
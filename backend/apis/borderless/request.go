@@ -1,11 +1,14 @@
 package borderless
 
 import (
+	"backend/apis"
+	"backend/utils/apperrors"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"time"
 )
@@ -20,20 +23,40 @@ type Borderless struct {
 	Client       *http.Client
 	Headers      map[string]interface{}
 	Timeout      time.Duration
+	ctx          context.Context
+}
+
+// WithContext returns a copy of the client whose requests carry ctx, so they
+// are cancelled with it and logged with its request ID
+func (hc Borderless) WithContext(ctx context.Context) *Borderless {
+	hc.ctx = ctx
+	return &hc
+}
+
+func (hc *Borderless) context() context.Context {
+	if hc.ctx == nil {
+		return context.Background()
+	}
+	return hc.ctx
 }
 
 // MakeRequest sends an HTTP request with retries and handles JSON responses.
 func (hc *Borderless) MakeRequest(method, url string, data map[string]interface{}) (map[string]interface{}, error) {
-	start := time.Now()
+	return hc.MakeRequestWithHeaders(method, url, data, nil)
+}
 
-	req, err := hc.buildRequest(method, url, data)
+// MakeRequestWithHeaders is MakeRequest with headers for this request only,
+// e.g. an idempotency key. hc.Headers is shared by every copy of the client
+// and is never written to.
+func (hc *Borderless) MakeRequestWithHeaders(method, url string, data map[string]interface{}, headers map[string]string) (map[string]interface{}, error) {
+	req, err := hc.buildRequest(method, url, data, headers)
 	if err != nil {
 		return nil, err
 	}
 
 	resp, err := hc.doWithRetry(req, 3)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return nil, apperrors.Provider(apperrors.ProviderBorderless, 0, fmt.Errorf("request failed: %w", err))
 	}
 	defer resp.Body.Close()
 
@@ -51,12 +74,11 @@ func (hc *Borderless) MakeRequest(method, url string, data map[string]interface{
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
-	log.Printf("✅ %s %s succeeded in %v", method, url, time.Since(start))
 	return result, nil
 }
 
 // buildRequest prepares an HTTP request with headers and optional JSON body.
-func (hc *Borderless) buildRequest(method, url string, data map[string]interface{}) (*http.Request, error) {
+func (hc *Borderless) buildRequest(method, url string, data map[string]interface{}, headers map[string]string) (*http.Request, error) {
 	var body []byte
 	var err error
 
@@ -67,7 +89,7 @@ func (hc *Borderless) buildRequest(method, url string, data map[string]interface
 		}
 	}
 
-	req, err := http.NewRequest(method, url, bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(hc.context(), method, url, bytes.NewBuffer(body))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -77,6 +99,9 @@ func (hc *Borderless) buildRequest(method, url string, data map[string]interface
 			req.Header.Set(key, strVal)
 		}
 	}
+	for key, val := range headers {
+		req.Header.Set(key, val)
+	}
 	return req, nil
 }
 
@@ -96,7 +121,7 @@ func (hc *Borderless) doWithRetry(req *http.Request, maxRetries int) (*http.Resp
 		}
 
 		if attempt < maxRetries {
-			log.Printf("⚠️ Retry %d/%d after error: %v", attempt, maxRetries-1, err)
+			slog.WarnContext(req.Context(), "retrying borderless request", "attempt", attempt, "retries", maxRetries-1, "error", err)
 			time.Sleep(2 * time.Second)
 		}
 	}
@@ -111,10 +136,8 @@ func (hc *Borderless) handleErrorResponse(body []byte, statusCode int) (map[stri
 		if m, ok := errResp["message"].(string); ok {
 			msg = m
 		}
-		log.Printf("❌ %d error: %s", statusCode, msg)
-		return errResp, fmt.Errorf("HTTP %d error: %s", statusCode, msg)
+		return errResp, apperrors.Provider(apperrors.ProviderBorderless, statusCode, apis.NotFoundIf(statusCode, fmt.Errorf("HTTP %d error: %s", statusCode, msg)))
 	}
 
-	log.Printf("❌ %d error with unparseable body: %s", statusCode, string(body))
-	return nil, fmt.Errorf("HTTP %d error: %s", statusCode, string(body))
+	return nil, apperrors.Provider(apperrors.ProviderBorderless, statusCode, apis.NotFoundIf(statusCode, fmt.Errorf("HTTP %d error: %s", statusCode, string(body))))
 }
@@ -5,8 +5,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
 	"strings"
 	"time"
 )
@@ -55,22 +56,22 @@ func NewBorderless() *Borderless {
 		}
 		response, err := borderless.MakeRequest(method, url, data)
 		if err != nil {
-			log.Println("Error making request:", err)
-			log.Fatal(err)
+			slog.Error("borderless token request failed", "error", err)
+			os.Exit(1)
 		}
 
 		jsonData, err := json.Marshal(response)
 		if err != nil {
-			log.Println("Error marshalling map:", err)
-			log.Fatal(err)
+			slog.Error("borderless token response did not encode", "error", err)
+			os.Exit(1)
 		}
 
 		// Unmarshal JSON to struct
 		var token TokenResponse
 		err = json.Unmarshal(jsonData, &token)
 		if err != nil {
-			log.Println("Error unmarshalling JSON:", err)
-			log.Fatal(err)
+			slog.Error("borderless token response did not decode", "error", err)
+			os.Exit(1)
 		}
 
 		accessToken = token.AccessToken
@@ -94,9 +95,7 @@ func (hc Borderless) GetAvailableCountries(entity string) ([]string, error) {
 	}
 
 	// Check if the response is of the expected type
-	fmt.Printf("Countries Response: %v", response)
 	responseBytes, err := json.Marshal(response)
-	fmt.Printf("Response Bytes: %v", responseBytes)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal response: %w", err)
 	}
@@ -123,9 +122,7 @@ func (hc Borderless) GetDepositOrWithdrawalOption(
 	}
 
 	// Check if the response is of the expected type
-	fmt.Printf("Country Options Response: %v", response)
 	responseBytes, err := json.Marshal(response)
-	fmt.Printf("Response Bytes: %v", responseBytes)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal response: %w", err)
 	}
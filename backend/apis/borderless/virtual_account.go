@@ -20,11 +20,11 @@ func (hc Borderless) CreateBorderlessVirtualAccount(
 	}
 
 	idempotencyKey := uuid.New()
-	hc.Headers["idempotency-key"] = idempotencyKey.String()
-	response, err := hc.MakeRequest(
+	response, err := hc.MakeRequestWithHeaders(
 		"POST",
 		fmt.Sprintf("%s/accounts/%s/virtual-accounts", hc.BaseUrl, accountId),
 		requestData,
+		map[string]string{"idempotency-key": idempotencyKey.String()},
 	)
 
 	if err != nil {
@@ -3,6 +3,8 @@ package borderless
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
+	"strconv"
 	"time"
 )
 
@@ -50,6 +52,30 @@ func (hc Borderless) GetTransaction(txId string) (map[string]interface{}, error)
 
 }
 
+// ListTransactions returns a page of the account's transactions, newest
+// first, after the transaction startingAfter when set, and whether more
+// follow
+func (hc Borderless) ListTransactions(startingAfter string, limit int) ([]map[string]interface{}, bool, error) {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(limit))
+	if startingAfter != "" {
+		query.Set("startingAfter", startingAfter)
+	}
+	response, err := hc.MakeRequest("GET", fmt.Sprintf("%s/transactions?%s", hc.BaseUrl, query.Encode()), nil)
+	if err != nil {
+		return nil, false, err
+	}
+	entries, _ := response["data"].([]interface{})
+	transactions := make([]map[string]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		if transaction, ok := entry.(map[string]interface{}); ok {
+			transactions = append(transactions, transaction)
+		}
+	}
+	hasMore, _ := response["hasMore"].(bool)
+	return transactions, hasMore, nil
+}
+
 func (hc Borderless) MakeWithdrawal(request WithdrawalRequest) (WithdrawalResponse, error) {
 	data, err := StructToMap(request)
 	if err != nil {
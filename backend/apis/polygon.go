@@ -2,11 +2,13 @@ package apis
 
 import (
 	"backend/state"
+	"backend/utils/apperrors"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"time"
 )
@@ -91,6 +93,14 @@ type TatumPolygon struct {
 	BaseUrl string
 	Client  *http.Client
 	Headers map[string]interface{}
+	ctx     context.Context
+}
+
+// WithContext returns a copy of the client whose requests carry ctx, so they
+// are cancelled with it and logged with its request ID
+func (hc TatumPolygon) WithContext(ctx context.Context) *TatumPolygon {
+	hc.ctx = ctx
+	return &hc
 }
 
 type CreateWalletResponse struct {
@@ -108,7 +118,10 @@ type GeneratePrivateKeyResponse struct {
 
 // MakeRequest makes an HTTP request with retry logic and error handling
 func (hc *TatumPolygon) MakeRequest(method, url string, data map[string]interface{}) (map[string]interface{}, error) {
-	start := time.Now()
+	ctx := hc.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
 
 	// Marshal request body if data is present
 	var requestBody []byte
@@ -121,7 +134,7 @@ func (hc *TatumPolygon) MakeRequest(method, url string, data map[string]interfac
 	}
 
 	// Create HTTP request
-	req, err := http.NewRequest(method, url, bytes.NewBuffer(requestBody))
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(requestBody))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -133,8 +146,6 @@ func (hc *TatumPolygon) MakeRequest(method, url string, data map[string]interfac
 		}
 	}
 
-	log.Printf("Making %s request to %s with data: %v", method, url, data)
-
 	// Attempt with retries
 	const maxRetries = 3
 	var resp *http.Response
@@ -144,13 +155,13 @@ func (hc *TatumPolygon) MakeRequest(method, url string, data map[string]interfac
 			break
 		}
 		if attempt < maxRetries {
-			log.Printf("Retrying request to %s (attempt %d)", url, attempt+1)
+			slog.WarnContext(ctx, "retrying tatum request", "attempt", attempt, "retries", maxRetries-1, "error", err)
 			time.Sleep(2 * time.Second)
 		}
 	}
 
 	if err != nil {
-		return nil, fmt.Errorf("request failed after %d attempts: %w", maxRetries, err)
+		return nil, apperrors.Provider(apperrors.ProviderTatum, 0, fmt.Errorf("request failed after %d attempts: %w", maxRetries, err))
 	}
 	defer resp.Body.Close()
 
@@ -163,15 +174,13 @@ func (hc *TatumPolygon) MakeRequest(method, url string, data map[string]interfac
 	if resp.StatusCode >= 400 {
 		var errorResponse map[string]interface{}
 		if jsonErr := json.Unmarshal(body, &errorResponse); jsonErr == nil {
-			log.Printf("HTTP %d error response: %v", resp.StatusCode, errorResponse)
 			msg := "HTTP error"
 			if m, ok := errorResponse["message"].(string); ok {
 				msg = m
 			}
-			return errorResponse, fmt.Errorf("HTTP error: %s", msg)
+			return errorResponse, apperrors.Provider(apperrors.ProviderTatum, resp.StatusCode, NotFoundIf(resp.StatusCode, fmt.Errorf("HTTP error: %s", msg)))
 		}
-		log.Printf("Unparseable HTTP %d error body: %s", resp.StatusCode, string(body))
-		return nil, fmt.Errorf("HTTP error: %s", string(body))
+		return nil, apperrors.Provider(apperrors.ProviderTatum, resp.StatusCode, NotFoundIf(resp.StatusCode, fmt.Errorf("HTTP error: %s", string(body))))
 	}
 
 	// Parse success response
@@ -179,8 +188,6 @@ func (hc *TatumPolygon) MakeRequest(method, url string, data map[string]interfac
 	if err := json.Unmarshal(body, &result); err != nil {
 		return nil, fmt.Errorf("failed to parse response JSON: %w", err)
 	}
-
-	log.Printf("Successfully completed %s request to %s in %v", method, url, time.Since(start))
 	return result, nil
 }
 
@@ -3,14 +3,18 @@ package apis
 import (
 	"backend/serializers"
 	"backend/state"
+	"backend/utils/apperrors"
 	"backend/utils/tokens"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 )
 
 type TransactionRequest struct {
@@ -79,13 +83,15 @@ type HurupayErrorResponse struct {
 	Success bool          `json:"success"`
 }
 
-func GetUserTransactions(chain, walletAddress, category string, pageSize uint64) (map[string]interface{}, error) {
+// GetUserTransactions returns a page of the wallet's transactions, newest
+// first, skipping the offset newest ones
+func GetUserTransactions(chain, walletAddress, category string, offset, pageSize uint64) (map[string]interface{}, error) {
 	url := ""
 	switch category {
 	case "":
-		url = fmt.Sprintf("https://api.tatum.io/v4/data/transactions?chain=%s&addresses=%s&pageSize=%d", chain, walletAddress, pageSize)
+		url = fmt.Sprintf("https://api.tatum.io/v4/data/transactions?chain=%s&addresses=%s&offset=%d&pageSize=%d", chain, walletAddress, offset, pageSize)
 	default:
-		url = fmt.Sprintf("https://api.tatum.io/v4/data/transactions?chain=%s&addresses=%s&transactionSubtype=%s&pageSize=%d", chain, walletAddress, category, pageSize)
+		url = fmt.Sprintf("https://api.tatum.io/v4/data/transactions?chain=%s&addresses=%s&transactionSubtype=%s&offset=%d&pageSize=%d", chain, walletAddress, category, offset, pageSize)
 	}
 
 	req, err := http.NewRequest("GET", url, nil)
@@ -99,6 +105,7 @@ func GetUserTransactions(chain, walletAddress, category string, pageSize uint64)
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
 		return nil, errors.New("failed to get user transactions")
@@ -148,7 +155,7 @@ func GetTransactionByHash(chain, hash string) ([]map[string]interface{}, error)
 	return result, nil
 }
 
-func PerformTransactionCelo(amount, accountAddress, privKey string, isNative bool) (string, int, error) {
+func PerformTransactionCelo(ctx context.Context, amount, accountAddress, privKey string, isNative bool) (string, int, error) {
 	url := "https://api.tatum.io/v3/celo/transaction"
 	client := &http.Client{}
 
@@ -168,7 +175,7 @@ func PerformTransactionCelo(amount, accountAddress, privKey string, isNative boo
 	if err != nil {
 		return "", 500, err
 	}
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return "", 500, err
 	}
@@ -177,7 +184,7 @@ func PerformTransactionCelo(amount, accountAddress, privKey string, isNative boo
 	req.Header.Set("Content-Type", "application/json")
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", 500, err
+		return "", 500, apperrors.Provider(apperrors.ProviderTatum, 0, err)
 	}
 	defer resp.Body.Close()
 	var errMsg string
@@ -192,8 +199,6 @@ func PerformTransactionCelo(amount, accountAddress, privKey string, isNative boo
 		if err = json.Unmarshal(body, &result); err != nil {
 			return "", 500, err
 		}
-		log.Println("result:", result)
-
 		errMsg = "failed to perform transaction. most likely insufficient funds"
 
 	case 400:
@@ -215,8 +220,11 @@ func PerformTransactionCelo(amount, accountAddress, privKey string, isNative boo
 		// Handle any other status codes if needed
 	}
 
+	if resp.StatusCode == 403 {
+		return "", resp.StatusCode, apperrors.InsufficientFunds(apperrors.ProviderTatum, errors.New(errMsg))
+	}
 	if errMsg != "" {
-		return "", resp.StatusCode, errors.New(errMsg)
+		return "", resp.StatusCode, apperrors.Provider(apperrors.ProviderTatum, resp.StatusCode, errors.New(errMsg))
 	}
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -268,7 +276,7 @@ func CalculateEstimatedFeeCelo(amount, to, from string) (map[string]interface{},
 	return result, nil
 }
 
-func PerformTransactionXLM(data serializers.TransferXLM) (map[string]string, int, error) {
+func PerformTransactionXLM(ctx context.Context, data serializers.TransferXLM) (map[string]string, int, error) {
 	apiUrl := "https://api.tatum.io/v3/xlm/transaction"
 	client := &http.Client{}
 
@@ -277,7 +285,7 @@ func PerformTransactionXLM(data serializers.TransferXLM) (map[string]string, int
 		if err != nil {
 			return nil, err
 		}
-		req, err := http.NewRequest("POST", apiUrl, bytes.NewBuffer(requestData))
+		req, err := http.NewRequestWithContext(ctx, "POST", apiUrl, bytes.NewBuffer(requestData))
 		if err != nil {
 			return nil, err
 		}
@@ -289,7 +297,7 @@ func PerformTransactionXLM(data serializers.TransferXLM) (map[string]string, int
 
 	resp, err := performRequest(data)
 	if err != nil {
-		return nil, 500, err
+		return nil, 500, apperrors.Provider(apperrors.ProviderTatum, 0, err)
 	}
 	defer resp.Body.Close()
 
@@ -297,7 +305,7 @@ func PerformTransactionXLM(data serializers.TransferXLM) (map[string]string, int
 		data.Initialize = !data.Initialize
 		resp, err = performRequest(data)
 		if err != nil {
-			return nil, 500, err
+			return nil, 500, apperrors.Provider(apperrors.ProviderTatum, 0, err)
 		}
 		defer resp.Body.Close()
 
@@ -311,7 +319,7 @@ func PerformTransactionXLM(data serializers.TransferXLM) (map[string]string, int
 			mesage := map[string]string{
 				"message": errorMessage,
 			}
-			return mesage, resp.StatusCode, fmt.Errorf(errMsg)
+			return mesage, resp.StatusCode, apperrors.InsufficientFunds(apperrors.ProviderTatum, fmt.Errorf("%s: %s", errMsg, errorMessage))
 		}
 	}
 
@@ -325,13 +333,13 @@ func PerformTransactionXLM(data serializers.TransferXLM) (map[string]string, int
 		return respData, resp.StatusCode, nil
 	case 400:
 		errMsg = "validation error making request"
-		return nil, 400, fmt.Errorf(errMsg)
+		return nil, 400, apperrors.Provider(apperrors.ProviderTatum, resp.StatusCode, errors.New(errMsg))
 	case 401:
 		errMsg = "subscription not active anymore"
-		return nil, 401, fmt.Errorf(errMsg)
+		return nil, 401, apperrors.Provider(apperrors.ProviderTatum, resp.StatusCode, errors.New(errMsg))
 	default:
 		errMsg = "internal server error from Third Party application"
-		return nil, 500, fmt.Errorf(errMsg)
+		return nil, 500, apperrors.Provider(apperrors.ProviderTatum, resp.StatusCode, errors.New(errMsg))
 	}
 }
 
@@ -352,7 +360,6 @@ func GetUserTransactionXLM(address, pagination string) ([]serializers.Transactio
 	}
 	defer resp.Body.Close()
 
-	log.Println("status code: ", resp.StatusCode)
 	switch resp.StatusCode {
 	case 200:
 		respData := []serializers.TransactionXLM{}
@@ -417,6 +424,9 @@ func GetTransactionByHashXLM(hash string) (serializers.TransactionXLM, error) {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return serializers.TransactionXLM{}, NotFound(errors.New("failed to get user transactions"))
+	}
 	if resp.StatusCode != 200 {
 		return serializers.TransactionXLM{}, errors.New("failed to get user transactions")
 	}
@@ -452,17 +462,16 @@ type MobilePayoutResponse struct {
 	} `json:"data"`
 }
 
-func OnRampMobileMoney(data serializers.Payment) (MobileMoneyResponse, error) {
+func OnRampMobileMoney(ctx context.Context, data serializers.Payment) (MobileMoneyResponse, error) {
 	apiUrl := "https://api.hurupay.com/v1/collections/mobile/initialize_transaction"
 	client := &http.Client{}
-	log.Println("onramp data: ", data)
 
 	jsonData, err := json.Marshal(data)
 	if err != nil {
 		return MobileMoneyResponse{}, err
 	}
 
-	req, err := http.NewRequest("POST", apiUrl, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", apiUrl, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return MobileMoneyResponse{}, err
 	}
@@ -471,17 +480,16 @@ func OnRampMobileMoney(data serializers.Payment) (MobileMoneyResponse, error) {
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return MobileMoneyResponse{}, err
+		return MobileMoneyResponse{}, apperrors.Provider(apperrors.ProviderHurupay, 0, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
 		errorResponse := HurupayErrorResponse{}
 		if err := json.NewDecoder(resp.Body).Decode(&errorResponse); err != nil {
-			return MobileMoneyResponse{}, err
+			return MobileMoneyResponse{}, apperrors.Provider(apperrors.ProviderHurupay, resp.StatusCode, err)
 		}
-		log.Println("error response: ", errorResponse)
-		return MobileMoneyResponse{}, errors.New(errorResponse.Message)
+		return MobileMoneyResponse{}, apperrors.Provider(apperrors.ProviderHurupay, resp.StatusCode, errors.New(errorResponse.Message))
 	}
 
 	respData := MobileMoneyResponse{}
@@ -492,7 +500,7 @@ func OnRampMobileMoney(data serializers.Payment) (MobileMoneyResponse, error) {
 	return respData, nil
 }
 
-func OffRampMobileMoney(data serializers.TransactionRequest) (PayoutResponse, error) {
+func OffRampMobileMoney(ctx context.Context, data serializers.TransactionRequest) (PayoutResponse, error) {
 	apiUrl := "https://api.hurupay.com/v1/payouts/mobile/initialize_transaction/request"
 	client := &http.Client{}
 
@@ -501,7 +509,7 @@ func OffRampMobileMoney(data serializers.TransactionRequest) (PayoutResponse, er
 		return PayoutResponse{}, err
 	}
 
-	req, err := http.NewRequest("POST", apiUrl, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", apiUrl, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return PayoutResponse{}, err
 	}
@@ -510,19 +518,16 @@ func OffRampMobileMoney(data serializers.TransactionRequest) (PayoutResponse, er
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return PayoutResponse{}, err
+		return PayoutResponse{}, apperrors.Provider(apperrors.ProviderHurupay, 0, err)
 	}
 	defer resp.Body.Close()
 
-	log.Println("status code: ", resp.StatusCode)
 	if resp.StatusCode != 200 && resp.StatusCode != 201 {
 		errorResponse := HurupayErrorResponse{}
 		if err := json.NewDecoder(resp.Body).Decode(&errorResponse); err != nil {
-			log.Println("error response: ", errorResponse)
-			return PayoutResponse{}, err
+			return PayoutResponse{}, apperrors.Provider(apperrors.ProviderHurupay, resp.StatusCode, err)
 		}
-		log.Println("error response: ", errorResponse)
-		return PayoutResponse{}, errors.New(errorResponse.Message)
+		return PayoutResponse{}, apperrors.Provider(apperrors.ProviderHurupay, resp.StatusCode, errors.New(errorResponse.Message))
 	}
 
 	respData := PayoutResponse{}
@@ -533,17 +538,16 @@ func OffRampMobileMoney(data serializers.TransactionRequest) (PayoutResponse, er
 	return respData, nil
 }
 
-func OffRampMobileFinalize(data serializers.TransactionDetails) (MobilePayoutResponse, error) {
+func OffRampMobileFinalize(ctx context.Context, data serializers.TransactionDetails) (MobilePayoutResponse, error) {
 	apiUrl := "https://api.hurupay.com/v1/payouts/mobile/initialize_transaction"
 	client := &http.Client{}
 
-	log.Println("data: ", data)
 	jsonData, err := json.Marshal(data)
 	if err != nil {
 		return MobilePayoutResponse{}, err
 	}
 
-	req, err := http.NewRequest("POST", apiUrl, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", apiUrl, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return MobilePayoutResponse{}, err
 	}
@@ -552,17 +556,16 @@ func OffRampMobileFinalize(data serializers.TransactionDetails) (MobilePayoutRes
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return MobilePayoutResponse{}, err
+		return MobilePayoutResponse{}, apperrors.Provider(apperrors.ProviderHurupay, 0, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 && resp.StatusCode != 201 {
 		errorResponse := map[string]interface{}{}
 		if err := json.NewDecoder(resp.Body).Decode(&errorResponse); err != nil {
-			return MobilePayoutResponse{}, err
+			return MobilePayoutResponse{}, apperrors.Provider(apperrors.ProviderHurupay, resp.StatusCode, err)
 		}
-		log.Println("error response finalize: ", errorResponse)
-		return MobilePayoutResponse{}, errors.New("failed to perform transaction")
+		return MobilePayoutResponse{}, apperrors.Provider(apperrors.ProviderHurupay, resp.StatusCode, errors.New("failed to perform transaction"))
 	}
 	var output MobilePayoutResponse
 	if err := json.NewDecoder(resp.Body).Decode(&output); err != nil {
@@ -571,3 +574,80 @@ func OffRampMobileFinalize(data serializers.TransactionDetails) (MobilePayoutRes
 
 	return output, nil
 }
+
+// ListMobileMoneyTransactions returns a page, counted from 1, of the Hurupay
+// collections or payouts created in [from, to) and whether more follow
+func ListMobileMoneyTransactions(ctx context.Context, payout bool, from, to time.Time, page, limit int) ([]map[string]interface{}, bool, error) {
+	apiUrl := "https://api.hurupay.com/v1/collections/mobile/transactions"
+	if payout {
+		apiUrl = "https://api.hurupay.com/v1/payouts/mobile/transactions"
+	}
+	query := url.Values{}
+	query.Set("startDate", from.UTC().Format(time.RFC3339))
+	query.Set("endDate", to.UTC().Format(time.RFC3339))
+	query.Set("page", strconv.Itoa(page))
+	query.Set("limit", strconv.Itoa(limit))
+	req, err := http.NewRequestWithContext(ctx, "GET", apiUrl+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", state.AppConfig.HurupayApiKey))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		errorResponse := HurupayErrorResponse{}
+		if err := json.NewDecoder(resp.Body).Decode(&errorResponse); err != nil || errorResponse.Message == "" {
+			errorResponse.Message = fmt.Sprintf("hurupay returned status %d", resp.StatusCode)
+		}
+		return nil, false, apperrors.Provider(apperrors.ProviderHurupay, resp.StatusCode, errors.New(errorResponse.Message))
+	}
+
+	var result struct {
+		Data []map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, false, err
+	}
+	return result.Data, len(result.Data) == limit, nil
+}
+
+// GetMobileMoneyTransaction fetches a Hurupay collection or payout by the
+// request ID Hurupay returned when it was initialized
+func GetMobileMoneyTransaction(ctx context.Context, payout bool, requestID string) (map[string]interface{}, error) {
+	apiUrl := fmt.Sprintf("https://api.hurupay.com/v1/collections/mobile/transactions/%s", requestID)
+	if payout {
+		apiUrl = fmt.Sprintf("https://api.hurupay.com/v1/payouts/mobile/transactions/%s", requestID)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", apiUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", state.AppConfig.HurupayApiKey))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		errorResponse := HurupayErrorResponse{}
+		if err := json.NewDecoder(resp.Body).Decode(&errorResponse); err != nil || errorResponse.Message == "" {
+			errorResponse.Message = fmt.Sprintf("hurupay returned status %d", resp.StatusCode)
+		}
+		return nil, NotFoundIf(resp.StatusCode, errors.New(errorResponse.Message))
+	}
+
+	var result struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return result.Data, nil
+}
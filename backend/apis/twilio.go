@@ -0,0 +1,56 @@
+package apis
+
+import (
+	"backend/state"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const twilioBaseUrl = "https://api.twilio.com/2010-04-01"
+
+// twilioClient bounds each send so a slow Twilio does not hold up the
+// notification worker
+var twilioClient = &http.Client{Timeout: 10 * time.Second}
+
+// twilioError is the body Twilio returns for rejected messages
+type twilioError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// SendTwilioMessage sends an SMS, or a WhatsApp message when both numbers
+// carry the "whatsapp:" prefix
+func SendTwilioMessage(from, to, body string) error {
+	apiUrl := fmt.Sprintf("%s/Accounts/%s/Messages.json", twilioBaseUrl, state.AppConfig.TwilioAccountSid)
+	form := url.Values{
+		"From": {from},
+		"To":   {to},
+		"Body": {body},
+	}
+
+	req, err := http.NewRequest("POST", apiUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(state.AppConfig.TwilioAccountSid, state.AppConfig.TwilioAuthToken)
+	req.Header.Set("content-type", "application/x-www-form-urlencoded")
+
+	resp, err := twilioClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var twilioErr twilioError
+		if json.NewDecoder(resp.Body).Decode(&twilioErr) == nil && twilioErr.Message != "" {
+			return fmt.Errorf("twilio error %d: %s", twilioErr.Code, twilioErr.Message)
+		}
+		return fmt.Errorf("failed to perform request which ended with status code: %d", resp.StatusCode)
+	}
+	return nil
+}
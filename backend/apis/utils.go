@@ -3,8 +3,8 @@ package apis
 import (
 	"backend/state"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"time"
 )
@@ -45,7 +45,6 @@ func GetExchangeRate(fiatCurrency, cryptoCurrency string, resultChan chan<- stri
 		return
 	}
 	defer resp.Body.Close()
-	log.Println("status code", resp.StatusCode)
 	if resp.StatusCode != http.StatusOK {
 		errChan <- fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 		return
@@ -95,3 +94,26 @@ func GetMobileMoneyExhangeRate(fiatCurrency string, resultChan chan<- string, er
 	}
 
 }
+
+// ErrNotFound marks provider errors for records the provider does not have,
+// the error message stays the provider's
+var ErrNotFound = errors.New("record not found at provider")
+
+type notFoundError struct{ error }
+
+func (e notFoundError) Is(target error) bool { return target == ErrNotFound }
+
+func (e notFoundError) Unwrap() error { return e.error }
+
+// NotFound wraps err so errors.Is(err, ErrNotFound) holds
+func NotFound(err error) error {
+	return notFoundError{err}
+}
+
+// NotFoundIf wraps err with NotFound when the provider answered 404
+func NotFoundIf(statusCode int, err error) error {
+	if statusCode == http.StatusNotFound {
+		return NotFound(err)
+	}
+	return err
+}
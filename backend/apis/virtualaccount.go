@@ -8,7 +8,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"strconv"
 )
@@ -298,10 +297,6 @@ func FetchAccountBalance(id string, apiKey string) (map[string]interface{}, erro
 		if !ok {
 			return nil, fmt.Errorf("failed to extract error message from json response")
 		}
-		errorData, ok := errData["data"].([]interface{})
-		if ok {
-			log.Println("error data: ", errorData)
-		}
 		// Return the error message
 		return errData, fmt.Errorf(errorMessage)
 	}
@@ -471,7 +466,6 @@ func FetchAccountBalanceXLM(address string) (float32, error) {
 
 	defer resp.Body.Close()
 	respData := serializers.Account{}
-	log.Println("code: ", resp.StatusCode)
 	if err := json.NewDecoder(resp.Body).Decode(&respData); err != nil {
 		return 0, err
 	}
@@ -490,7 +484,6 @@ func FetchAccountBalanceXLM(address string) (float32, error) {
 	default:
 		amount := 0.0
 		for _, balance := range respData.Balances {
-			log.Println("balance: ", balance.Balance, balance.AssetType)
 			if balance.AssetType != "native" {
 				a, _ := strconv.ParseFloat(balance.Balance, 32)
 				amount += a
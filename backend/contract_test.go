@@ -0,0 +1,302 @@
+package main
+
+import (
+	"backend/controllers"
+	"backend/models"
+	"backend/state"
+	"backend/utils/openapi"
+	"backend/utils/tokens"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// boundTypes records the struct types the handler of the current request
+// bound its input to, through gin's validator which every binding calls
+type boundTypes struct {
+	binding.StructValidator
+	types []reflect.Type
+}
+
+func (b *boundTypes) ValidateStruct(obj interface{}) error {
+	if t := reflect.TypeOf(obj); t != nil {
+		b.types = append(b.types, indirect(t))
+	}
+	return b.StructValidator.ValidateStruct(obj)
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// offline answers every outbound call as an unavailable provider, the test
+// never leaves the machine
+type offline struct{}
+
+func (offline) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusServiceUnavailable,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"message":"offline"}`)),
+		Request:    req,
+	}, nil
+}
+
+var pathParam = regexp.MustCompile(`[:*][A-Za-z]+`)
+
+// TestAPIContract runs every documented route against a seeded database and
+// checks each response with the spec: success responses against the route's
+// status and schema, errors against the Error schema. It also fails on
+// routes without an annotation, annotations no route serves, and handlers
+// that bind a different request type than their annotation documents.
+func TestAPIContract(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupContractFixtures(t)
+
+	r := newRouter()
+	routes := r.Routes()
+	doc, undocumented := openapi.Build(routes, controllers.APIDocs)
+	for _, route := range undocumented {
+		t.Errorf("route is not documented: %s", route)
+	}
+	for _, handler := range controllers.APIDocs.Unrouted(routes) {
+		t.Errorf("annotation of %s matches no route", handler)
+	}
+	openapi.Load(routes, controllers.APIDocs)
+
+	recorder := &boundTypes{StructValidator: binding.Validator}
+	binding.Validator = recorder
+	defer func() { binding.Validator = recorder.StructValidator }()
+
+	token, err := tokens.GenerateToken(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// reads first and deletes last, so each route finds the fixtures
+	order := map[string]int{http.MethodGet: 0, http.MethodPost: 1, http.MethodPut: 2, http.MethodPatch: 3, http.MethodDelete: 4}
+	sort.SliceStable(routes, func(i, j int) bool {
+		if order[routes[i].Method] != order[routes[j].Method] {
+			return order[routes[i].Method] < order[routes[j].Method]
+		}
+		return routes[i].Path < routes[j].Path
+	})
+	succeeded := 0
+	for _, route := range routes {
+		op, _ := controllers.APIDocs.Lookup(route.Handler)
+		if op.Hidden || op.Produces == "text/event-stream" || op.Status == http.StatusSwitchingProtocols {
+			continue
+		}
+
+		var body io.Reader
+		contentType := ""
+		switch {
+		case op.Request != nil:
+			schema := doc.Paths[specPath(route.Path)][strings.ToLower(route.Method)].RequestBody.Content["application/json"].Schema
+			raw, _ := json.Marshal(example(doc, schema))
+			body, contentType = bytes.NewReader(raw), "application/json"
+		case len(op.Form) == 0 && route.Method != http.MethodGet && route.Method != http.MethodDelete:
+			body, contentType = strings.NewReader("{}"), "application/json"
+		}
+
+		req := httptest.NewRequest(route.Method, "http://localhost:8080"+pathParam.ReplaceAllString(route.Path, "1"), body)
+		req.Host = "localhost:8080"
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Idempotency-Key", route.Method+route.Path)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		recorder.types = nil
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		name := route.Method + " " + route.Path
+		for _, problem := range doc.Validate(route.Method, route.Path, w.Code, w.Header().Get("Content-Type"), w.Body.Bytes()) {
+			t.Errorf("%s answered %d: %s", name, w.Code, problem)
+		}
+		if w.Code < 300 {
+			succeeded++
+		}
+		checkBinding(t, name, op, recorder.types)
+	}
+	t.Logf("%d of %d routes answered with success", succeeded, len(routes))
+}
+
+// checkBinding fails when the handler bound a JSON body of another type than
+// the documented Request, or bound one without documenting it
+func checkBinding(t *testing.T, name string, op openapi.Operation, bound []reflect.Type) {
+	if len(bound) == 0 || len(op.Form) > 0 {
+		return
+	}
+	expected := map[reflect.Type]bool{}
+	for _, value := range []interface{}{op.Request, op.QueryStruct} {
+		if value != nil {
+			expected[indirect(reflect.TypeOf(value))] = true
+		}
+	}
+	for _, t2 := range bound {
+		if !expected[t2] {
+			t.Errorf("%s binds %s, its annotation documents %v", name, t2, op.Request)
+		}
+	}
+}
+
+// specPath turns gin path parameters into OpenAPI ones
+func specPath(path string) string {
+	return pathParam.ReplaceAllStringFunc(path, func(param string) string { return "{" + param[1:] + "}" })
+}
+
+// example is the smallest value the schema accepts
+func example(doc *openapi.Document, s *openapi.Schema) interface{} {
+	if s == nil {
+		return nil
+	}
+	if s.Ref != "" {
+		return example(doc, doc.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")])
+	}
+	if len(s.AllOf) > 0 {
+		merged := map[string]interface{}{}
+		for _, part := range s.AllOf {
+			if object, ok := example(doc, part).(map[string]interface{}); ok {
+				for k, v := range object {
+					merged[k] = v
+				}
+			}
+		}
+		return merged
+	}
+	if len(s.Enum) > 0 {
+		return s.Enum[0]
+	}
+	switch s.Type {
+	case "object":
+		object := map[string]interface{}{}
+		for _, name := range s.Required {
+			object[name] = example(doc, s.Properties[name])
+		}
+		return object
+	case "array":
+		return []interface{}{}
+	case "boolean":
+		return true
+	case "integer", "number":
+		n := 1.0
+		if s.Minimum != nil {
+			n = *s.Minimum
+			if s.ExclusiveMinimum {
+				n++
+			}
+		}
+		if s.Maximum != nil && n > *s.Maximum {
+			n = *s.Maximum
+		}
+		return n
+	}
+	switch s.Format {
+	case "date-time":
+		return time.Now().UTC().Format(time.RFC3339)
+	case "date":
+		return "2000-01-01"
+	case "email":
+		return "contract@example.com"
+	}
+	text := "1"
+	if s.MinLength != nil && *s.MinLength > 1 {
+		text = strings.Repeat("1", *s.MinLength)
+	}
+	return text
+}
+
+// setupContractFixtures runs the API on SQLite in a temporary directory with
+// an admin who has a verified email and a wallet, an approved KYC and some
+// history
+func setupContractFixtures(t *testing.T) {
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32))
+	state.AppConfig = &state.Config{
+		AppEnv:                   "test",
+		ApiSecret:                "contract-api-secret",
+		TokenExpirationInMinutes: 60,
+		EncryptionKey:            key,
+		PartnerKeySecret:         "contract-partner-secret",
+		DocumentStorage:          "local",
+		DocumentDir:              "documents",
+		DocumentEncryptionKey:    key,
+		DocumentSigningKey:       "contract-document-secret",
+		StatementDir:             "statements",
+		SanctionsDir:             "sanctions",
+		ScreeningThreshold:       0.88,
+		RecordRetentionYears:     7,
+		BeneficiaryCoolingHours:  24,
+		AllowedHosts:             []string{"localhost:8080"},
+	}
+	state.ApiSecret = []byte(state.AppConfig.ApiSecret)
+
+	transport := http.DefaultTransport
+	http.DefaultTransport = offline{}
+	t.Cleanup(func() { http.DefaultTransport = transport })
+
+	db := models.InitializeDB()
+	if err := models.Migrate(db, models.Tables()...); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	userID := uint(1)
+	admin := models.User{
+		FirstName: "Ada", LastName: "Admin", Email: "admin@example.com",
+		Country: "Nigeria", CountryCode: "NG", Currency: "NGN", CryptoCurrency: "CELO",
+		Role: "Admin", EmailVerified: true, EmailVerifiedAt: &now, Status: models.UserActive,
+		AccountAddress: "0x00000000000000000000000000000000000000a1",
+	}
+	fixtures := []interface{}{
+		&admin,
+		&models.KYC{UserID: 1, Status: models.Approved, DateOfBirth: "1990-01-01", Country: "Nigeria"},
+		&models.KYCLevel{UserID: 1, Tier: models.KYCTier1, Status: models.Approved, SubmittedAt: now},
+		&models.KYCLevel{UserID: 1, Tier: models.KYCTier2, Status: models.Approved, SubmittedAt: now},
+		&models.Transaction{UserID: 1, Amount: "10", Asset: "USDC", Status: "Completed", Hash: "0x1"},
+		&models.MasterWallet{WalletChain: "CELO", PublicAddress: "0x0000000000000000000000000000000000000001"},
+		&models.Notification{UserID: 1, Type: "deposit", Title: "Deposit received"},
+		&models.Beneficiary{UserID: 1, Kind: models.BeneficiaryCrypto, Label: "cold wallet", DestinationKey: "celo:0x00000000000000000000000000000000000000b1", Chain: "CELO", Address: "0x00000000000000000000000000000000000000b1"},
+		&models.WebhookEndpoint{UserID: &userID, URL: "https://hooks.example.com", EventTypes: "*", Active: true},
+		&models.WebhookDelivery{EndpointID: 1, EventID: "evt_1", EventType: models.WebhookDepositApproved, Payload: "{}", NextAttemptAt: now},
+		&models.Tenant{Name: "Acme", Slug: "acme"},
+		&models.Partner{Name: "Acme", Status: models.PartnerActive},
+		&models.ComplianceCase{UserID: &userID, Source: "manual", Title: "Review", Priority: "low", Status: "open", DueAt: now.Add(time.Hour)},
+		&models.ReconciliationRun{Source: "borderless", From: now.Add(-time.Hour), To: now},
+		&models.ReconciliationItem{RunID: 1, Source: "borderless", Classification: "matched", Status: "open", Reference: "ref-1"},
+		&models.Screening{UserID: 1, Subject: models.ScreeningSubjectUser, SubjectKey: "user:1", Context: models.ScreeningKYCSubmission, Name: "Ada Admin", Status: models.ScreeningReview},
+		&models.StatementExport{RequestedByID: 1, UserID: &userID, Format: "csv", From: now.Add(-time.Hour), To: now},
+		&models.LimitRule{Rail: models.RailBank, Direction: "deposit"},
+		&models.LimitOverride{UserID: 1, Rail: models.RailBank, Direction: "deposit", Reason: "vip", CreatedByID: 1},
+		&models.MonitoringRule{Name: "velocity", Type: "velocity", Priority: "low"},
+		&models.KYCDocument{UserID: 1, Type: models.DocumentSelfie, Backend: "local", StorageKey: "kyc/1/selfie/x", ContentType: "image/png"},
+	}
+	for _, fixture := range fixtures {
+		if err := db.Create(fixture).Error; err != nil {
+			t.Fatalf("seeding %T: %v", fixture, err)
+		}
+	}
+}
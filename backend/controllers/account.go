@@ -7,12 +7,17 @@ import (
 	"backend/serializers"
 	"backend/state"
 	"backend/utils"
+	"backend/utils/apperrors"
 	"backend/utils/mails"
+	"backend/utils/ratelimit"
+	"backend/utils/tenancy"
 	"backend/utils/tokens"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -31,6 +36,13 @@ func CreateAccountV2(c *gin.Context) {
 		return
 	}
 
+	chain := strings.ToUpper(input.Chain)
+	if !isSupportedChain(chain) || !tenancy.ChainEnabled(c, chain) {
+		utils.BadRequest(c, fmt.Errorf("unsupported chain: %s", input.Chain), "unsupported chain type")
+		return
+	}
+
+	// the wallet is provisioned once the email address has been verified
 	user := models.User{
 		FirstName:      input.FirstName,
 		LastName:       input.LastName,
@@ -39,36 +51,148 @@ func CreateAccountV2(c *gin.Context) {
 		Country:        input.Country,
 		Currency:       input.Currency,
 		CountryCode:    input.CountryCode,
-		CryptoCurrency: input.Chain,
+		CryptoCurrency: chain,
+		TenantID:       tenancy.TenantIDPtr(c),
+		Locale:         models.ResolveLocale(input.Locale, input.CountryCode),
+	}
+
+	if err := user.SaveUser(); err != nil {
+		utils.BadRequest(c, err, "creating user failed")
+		return
+	}
+
+	if err := sendVerificationMail(&user); err != nil {
+		slog.WarnContext(c.Request.Context(), "verification mail not sent", "user_id", user.ID, "error", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "created account successfully, check your email to verify your account"})
+}
+
+func isSupportedChain(chain string) bool {
+	switch chain {
+	case serializers.Chains.Celo, serializers.Chains.Stellar, serializers.Chains.Polygon:
+		return true
 	}
+	return false
+}
 
-	switch strings.ToUpper(input.Chain) {
+// provisionWallet sets up the on-chain account for the user's chosen chain
+func provisionWallet(user *models.User) error {
+	switch strings.ToUpper(user.CryptoCurrency) {
 	case serializers.Chains.Celo:
-		if err := apis.SetupCeloAccount(&user); err != nil {
-			utils.BadRequest(c, err, "celo wallet setup failed")
-			return
+		if err := apis.SetupCeloAccount(user); err != nil {
+			return fmt.Errorf("celo wallet setup failed: %w", err)
 		}
 	case serializers.Chains.Stellar:
-		if err := apis.SetupStellarAccount(&user); err != nil {
-			utils.BadRequest(c, err, "stellar account setup failed")
-			return
+		if err := apis.SetupStellarAccount(user); err != nil {
+			return fmt.Errorf("stellar account setup failed: %w", err)
 		}
 	case serializers.Chains.Polygon:
-		if err := apis.SetupPolygonAccount(&user); err != nil {
-			utils.BadRequest(c, err, "polygon account setup failed")
-			return
+		if err := apis.SetupPolygonAccount(user); err != nil {
+			return fmt.Errorf("polygon account setup failed: %w", err)
 		}
 	default:
-		utils.BadRequest(c, fmt.Errorf("unsupported chain: %s", input.Chain), "unsupported chain type")
+		return fmt.Errorf("unsupported chain: %s", user.CryptoCurrency)
+	}
+	return nil
+}
+
+func sendVerificationMail(user *models.User) error {
+	token, err := tokens.GenerateEmailVerificationToken(user.ID, user.Email)
+	if err != nil {
+		return err
+	}
+	if err := mails.SendEmailVerificationMail(tenancy.MailRecipient(user), token); err != nil {
+		return err
+	}
+	return user.TouchVerificationSent()
+}
+
+func VerifyEmail(c *gin.Context) {
+	var input serializers.VerifyEmail
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apperrors.Respond(c, apperrors.Invalid(err, "invalid request body"))
 		return
 	}
 
-	if err := user.SaveUser(); err != nil {
-		utils.BadRequest(c, err, "creating user failed")
+	userId, email, err := tokens.ParseEmailVerificationToken(input.Token)
+	if err != nil {
+		apperrors.Respond(c, apperrors.Wrap(apperrors.CodeUnauthorized, err, "invalid or expired verification token"))
+		return
+	}
+
+	user, err := models.GetUserByID(userId)
+	if err != nil || !strings.EqualFold(user.Email, email) {
+		apperrors.Respond(c, apperrors.Wrap(apperrors.CodeUnauthorized, err, "invalid or expired verification token"))
+		return
+	}
+
+	if user.EmailVerified {
+		c.JSON(http.StatusOK, gin.H{"message": "email already verified"})
+		return
+	}
+	if user.HasWallet() {
+		if err := user.MarkEmailVerified(); err != nil {
+			apperrors.Respond(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "email verified successfully"})
+		return
+	}
+
+	// a second click while the first is still provisioning must not create
+	// another wallet, the claim runs out if that request never finishes
+	claimed, err := user.ClaimWalletProvisioning()
+	if err != nil {
+		apperrors.Respond(c, err)
+		return
+	}
+	if !claimed {
+		apperrors.Respond(c, apperrors.New(apperrors.CodeConflict, "email verification is already in progress, please try again shortly").
+			WithReason("email_verification_in_progress"))
+		return
+	}
+
+	// the token stays valid if provisioning fails so the link can be retried
+	if err := provisionWallet(&user); err != nil {
+		if err := user.ReleaseWalletProvisioning(); err != nil {
+			slog.ErrorContext(c.Request.Context(), "wallet claim not released", "user_id", user.ID, "error", err)
+		}
+		apperrors.Respond(c, apperrors.Wrap(apperrors.CodeProviderRejected, err, "wallet setup failed, please try the link again").
+			WithReason("wallet_setup_failed"))
+		return
+	}
+
+	if err := user.CompleteEmailVerification(); err != nil {
+		apperrors.Respond(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "email verified successfully"})
+}
+
+func ResendVerificationEmail(c *gin.Context) {
+	var input serializers.ResendVerification
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// the response is the same whether or not the account exists
+	response := gin.H{"message": "if the account exists and is unverified, a verification email has been sent"}
+
+	// requests inside the cooldown window are dropped silently
+	user, ok := models.FindUserByEmail(input.Email)
+	if !ok || !tenancy.SameTenant(c, user.TenantID) || user.EmailVerified || !user.CanResendVerification() {
+		c.JSON(http.StatusOK, response)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "created account successfully"})
+	if err := sendVerificationMail(&user); err != nil {
+		slog.WarnContext(c.Request.Context(), "verification mail not sent", "user_id", user.ID, "error", err)
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 func FetchAuthenticatedUserToken(c *gin.Context) {
@@ -77,16 +201,59 @@ func FetchAuthenticatedUserToken(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": err.Error(),
 		})
+		return
 	}
-	email := input.Email
-	password := input.Password
-	token, err := models.LoginCheck(email, password)
+
+	user, err := models.Authenticate(input.Email, input.Password)
+
+	// users of another tenant don't exist as far as this domain is concerned
+	if user.ID != 0 && !tenancy.SameTenant(c, user.TenantID) {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": models.ErrInvalidCredentials.Error(),
+		})
+		return
+	}
+
+	switch {
+	case errors.Is(err, models.ErrAccountLocked):
+		// answered like bad credentials so the lockout doesn't reveal the
+		// email, the owner is told by mail instead
+		recordSecurityEvent(c, models.EventLoginBlocked, &user, input.Email, "login attempted while locked")
+		notifyLockedLogin(c, user)
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": models.ErrInvalidCredentials.Error(),
+		})
+		return
+	case err != nil:
+		handleFailedLogin(c, user, input.Email)
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": models.ErrInvalidCredentials.Error(),
+		})
+		return
+	}
+
+	if !user.CanSignIn() {
+		recordSecurityEvent(c, models.EventLoginBlocked, &user, input.Email, "login attempted while "+user.CurrentStatus())
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "This account is " + user.CurrentStatus() + ", please contact support",
+			"code":  "account_" + user.CurrentStatus(),
+		})
+		return
+	}
+
+	if err := user.ResetFailedLogins(); err != nil {
+		slog.ErrorContext(c.Request.Context(), "login attempts not reset", "user_id", user.ID, "error", err)
+	}
+
+	token, err := tokens.GenerateToken(user.ID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
+		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
+	recordSecurityEvent(c, models.EventLoginSucceeded, &user, user.Email, "")
+
 	data := map[string]string{
 		"access_token": token,
 	}
@@ -98,84 +265,198 @@ func FetchAuthenticatedUserToken(c *gin.Context) {
 
 }
 
+// handleFailedLogin records the failed attempt and, when it trips the
+// lockout, mails the user an unlock link
+func handleFailedLogin(c *gin.Context, user models.User, email string) {
+	if user.ID == 0 {
+		recordSecurityEvent(c, models.EventLoginFailed, nil, email, "unknown email")
+		return
+	}
+
+	locked, err := user.RegisterFailedLogin()
+	if err != nil {
+		recordSecurityEvent(c, models.EventLoginFailed, &user, email, "attempt not counted")
+		slog.ErrorContext(c.Request.Context(), "failed login not recorded", "user_id", user.ID, "error", err)
+		return
+	}
+	attempt := user.FailedLoginAttempts
+	if locked {
+		attempt = models.MaxFailedLogins
+	}
+	recordSecurityEvent(c, models.EventLoginFailed, &user, email, fmt.Sprintf("attempt %d", attempt))
+	if !locked {
+		return
+	}
+
+	recordSecurityEvent(c, models.EventAccountLocked, &user, email, fmt.Sprintf("locked until %s", user.LockedUntil.Format(time.RFC3339)))
+	sendUnlockMail(c, user)
+}
+
+// notifyLockedLogin mails the owner of a locked account an unlock link when
+// someone tries to sign in, at most once an hour
+func notifyLockedLogin(c *gin.Context, user models.User) {
+	key := fmt.Sprintf("locked-login-mail:account:%d", user.ID)
+	if allowed, _ := ratelimit.Allow(key, 1, time.Hour); !allowed {
+		return
+	}
+	sendUnlockMail(c, user)
+}
+
+// sendUnlockMail mails the user a link to unlock their account
+func sendUnlockMail(c *gin.Context, user models.User) {
+	ctx := c.Request.Context()
+	go func() {
+		token, err := models.GenerateUnlockToken(user.ID)
+		if err != nil {
+			slog.ErrorContext(ctx, "unlock token not generated", "user_id", user.ID, "error", err)
+			return
+		}
+		if err := mails.SendAccountUnlockMail(tenancy.MailRecipient(&user), token.Token, *user.LockedUntil); err != nil {
+			slog.WarnContext(ctx, "unlock mail not sent", "user_id", user.ID, "error", err)
+		}
+	}()
+}
+
+func recordSecurityEvent(c *gin.Context, event string, user *models.User, email, detail string) {
+	securityEvent := models.SecurityEvent{
+		Email:     strings.ToLower(email),
+		Event:     event,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Detail:    detail,
+	}
+	if user != nil && user.ID != 0 {
+		securityEvent.UserID = &user.ID
+	}
+	models.LogSecurityEvent(securityEvent)
+}
+
 func ForgetPassword(c *gin.Context) {
 	// Parse the request JSON
-	var requestData struct {
-		Email string `json:"email"`
-	}
+	var requestData serializers.ForgetPassword
 	if err := c.ShouldBindJSON(&requestData); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Check if the email exists in the database
+	// The response is identical whether or not the email exists
+	response := gin.H{"message": "If an account exists for this email, a password reset link has been sent"}
+
 	user, ok := models.FindUserByEmail(requestData.Email)
-	if !ok {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	if !ok || !tenancy.SameTenant(c, user.TenantID) {
+		c.JSON(http.StatusOK, response)
 		return
 	}
 
+	// Requests over the per-account limit are dropped silently
+	key := fmt.Sprintf("forget-password:account:%d", user.ID)
+	if allowed, _ := ratelimit.Allow(key, 3, time.Hour); !allowed {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	recordSecurityEvent(c, models.EventPasswordResetRequested, &user, user.Email, "")
+
 	// Generate a unique token
 	token, err := models.GenerateRecoveryToken(user.ID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		slog.ErrorContext(c.Request.Context(), "recovery token not generated", "user_id", user.ID, "error", err)
+		c.JSON(http.StatusOK, response)
 		return
 	}
-	receiver := []string{
-		user.Email,
-	}
-
-	if err := mails.SendForgetPasswordMail(receiver, user.FirstName, token.Token); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
-		return
+	if err := mails.SendForgetPasswordMail(tenancy.MailRecipient(&user), token.Token); err != nil {
+		slog.WarnContext(c.Request.Context(), "password reset mail not sent", "user_id", user.ID, "error", err)
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Password reset email sent"})
+	c.JSON(http.StatusOK, response)
 }
 
 func ResetPassword(c *gin.Context) {
 	// Parse the request JSON
-	var requestData struct {
-		Token    string `json:"token"`
-		Password string `json:"password"`
-	}
+	var requestData serializers.ResetPassword
 	if err := c.ShouldBindJSON(&requestData); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	token, err := models.CheckTokenValid(requestData.Token)
+	token, err := models.CheckTokenValid(requestData.Token, models.TokenPurposePasswordReset)
 	// Check if the token exists and is valid
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": err.Error(),
+			"error": "invalid or expired token",
 		})
 		return
 	}
 
-	// Update the user's password in the database
 	user, err := models.GetUserByID(token.UserID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
 		return
 	}
-	user.Password = requestData.Password
-	if err := user.HashPassword(); err != nil {
+
+	if !models.ValidatePassword(requestData.Password) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "password must be at least 8 characters long, contain a digit, and an uppercase letter",
+		})
+		return
+	}
+
+	// Reset tokens are single use
+	if err := token.Consume(); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
+		return
+	}
+
+	// Changing the password also signs the user out everywhere
+	if err := user.ChangePassword(requestData.Password); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":   err.Error(),
 			"message": "Password reset failed",
 		})
+		return
 	}
 
-	user.UpdateUser()
+	if err := user.Unlock(); err != nil {
+		slog.ErrorContext(c.Request.Context(), "account not unlocked after password reset", "user_id", user.ID, "error", err)
+	}
+	recordSecurityEvent(c, models.EventPasswordReset, &user, user.Email, "")
 
 	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
 }
 
+func UnlockAccount(c *gin.Context) {
+	var requestData serializers.UnlockAccount
+	if err := c.ShouldBindJSON(&requestData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := models.CheckTokenValid(requestData.Token, models.TokenPurposeAccountUnlock)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
+		return
+	}
+
+	user, err := models.GetUserByID(token.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
+		return
+	}
+
+	if err := token.Consume(); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
+		return
+	}
+
+	if err := user.Unlock(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	recordSecurityEvent(c, models.EventAccountUnlocked, &user, user.Email, "unlocked via email link")
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account unlocked successfully"})
+}
+
 func GetAuthenticatedUser(c *gin.Context) {
 	userId, err := tokens.ExtractUserID(c)
 	if err != nil {
@@ -193,45 +474,21 @@ func GetAuthenticatedUser(c *gin.Context) {
 		return
 	}
 
-	balanceChan := make(chan float32)
-	errorChan := make(chan error)
-	switch user.CryptoCurrency {
-	case serializers.Chains.Celo:
-		go func() {
-			balance, err := apis.FetchAccountBalanceCUSD(user.AccountAddress)
-			if err != nil {
-				errorChan <- err
-				return
-			}
-			balanceChan <- balance
-		}()
-	case serializers.Chains.Stellar:
-		go func() {
-			balance, err := apis.FetchAccountBalanceXLM(user.AccountAddress)
-			if err != nil {
-				errorChan <- err
-				return
-			}
-			balanceChan <- balance
-		}()
-	case serializers.Chains.Polygon:
-		go func() {
-			balance, err := apis.FetchWalletBalance(user.AccountAddress, "polygon", 10)
-			if err != nil {
-				errorChan <- err
-				return
-			}
-			balanceChan <- balance
-		}()
-	}
-
-	// Wait for balance or error
-	var balance float32
-	select {
-	case balance = <-balanceChan:
-		// Balance successfully retrieved
-	case err = <-errorChan:
-		// Error occurred while fetching balance
+	// wallets are only provisioned after the email has been verified
+	if !user.HasWallet() {
+		c.JSON(http.StatusOK, gin.H{
+			"status": "fetch authenticated user details",
+			"errors": false,
+			"data": map[string]interface{}{
+				"personal_details": user,
+				"wallet_details":   map[string]float32{"balance": 0},
+			},
+		})
+		return
+	}
+
+	balance, err := walletBalance(user)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": err.Error(),
 		})
@@ -312,7 +569,7 @@ func GenerateMasterWallet(c *gin.Context) {
 		masterWallet.PublicAddress = address
 		masterWallet.PrivateKey = secret
 	case serializers.Chains.Polygon:
-		polygon := apis.NewTatumPolygon()
+		polygon := apis.NewTatumPolygon().WithContext(c.Request.Context())
 		walletResponse, err := polygon.CreateWallet()
 		if err != nil {
 			c.JSON(400, gin.H{
@@ -449,7 +706,7 @@ func CreateBorderlessVirtualAccount(c *gin.Context) {
 		return
 	}
 
-	borderless := borderless.NewBorderless()
+	borderless := borderless.NewBorderless().WithContext(c.Request.Context())
 	userAccountId, err := models.GenerateAccountId()
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -464,7 +721,7 @@ func CreateBorderlessVirtualAccount(c *gin.Context) {
 	}
 
 	// check if user is allowed to create a virtual account in their country
-	if utils.IsBlockedCountry(user.CountryCode) {
+	if tenancy.CountryBlocked(c, user.CountryCode) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Virtual accounts are not available in user's country"})
 		return
 	}
@@ -531,19 +788,47 @@ func GetUserAccounts(c *gin.Context) {
 
 func FilterUserAccounts(c *gin.Context) {
 	var filters serializers.UserAccountsFilter
-	if err := c.ShouldBindJSON(&filters); err != nil {
+	if err := c.ShouldBindQuery(&filters); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	lq, ok := listQuery(c)
+	if !ok {
+		return
+	}
 
-	accounts, err := models.FilterUserAccounts(filters)
+	accounts, info, err := models.FilterUserAccounts(tenancy.TenantID(c), filters, lq)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		listError(c, err)
 		return
 	}
+	respondList(c, "Filtered user accounts successfully", accounts, info)
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"status": "Filtered user accounts successfully",
-		"data":   accounts,
-	})
+// UpdateUserLocale sets the language the user receives mails and
+// notifications in
+func UpdateUserLocale(c *gin.Context) {
+	var input serializers.UpdateLocale
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequest(c, err, "invalid request payload")
+		return
+	}
+
+	userId, err := tokens.ExtractUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	user, err := models.GetUserByID(userId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	user.Locale = input.Locale
+	if err := user.UpdateUserWithErrors(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "updated locale", "data": gin.H{"locale": user.Locale}, "errors": false})
 }
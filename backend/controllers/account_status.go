@@ -0,0 +1,244 @@
+package controllers
+
+import (
+	"backend/apis"
+	"backend/models"
+	"backend/serializers"
+	"backend/state"
+	"backend/utils"
+	"backend/utils/tokens"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// walletBalance returns the stablecoin balance of the user's wallet
+func walletBalance(user models.User) (float32, error) {
+	switch user.CryptoCurrency {
+	case serializers.Chains.Celo:
+		return apis.FetchAccountBalanceCUSD(user.AccountAddress)
+	case serializers.Chains.Stellar:
+		return apis.FetchAccountBalanceXLM(user.AccountAddress)
+	case serializers.Chains.Polygon:
+		return apis.FetchWalletBalance(user.AccountAddress, "polygon", 10)
+	}
+	return 0, fmt.Errorf("unsupported chain %s", user.CryptoCurrency)
+}
+
+// sweepWallet sends the amount from the user's wallet to the address and
+// returns the transaction hash
+func sweepWallet(ctx context.Context, user models.User, to string, amount float32) (string, error) {
+	value := strconv.FormatFloat(float64(amount), 'f', -1, 32)
+	switch user.CryptoCurrency {
+	case serializers.Chains.Celo:
+		hash, _, err := apis.PerformTransactionCelo(ctx, value, to, user.PrivateKey, false)
+		return hash, err
+	case serializers.Chains.Stellar:
+		txData, _, err := apis.PerformTransactionXLM(ctx, serializers.TransferXLM{
+			Amount:        value,
+			To:            to,
+			FromSecret:    user.PrivateKey,
+			Token:         "USDC",
+			IssuerAccount: user.AccountAddress,
+			FromAccount:   user.AccountAddress,
+		})
+		return txData["txId"], err
+	case serializers.Chains.Polygon:
+		response, err := apis.NewTatumPolygon().WithContext(ctx).PerformTransaction(to, value, user.PrivateKey, apis.USDC_MATIC)
+		return response.TxId, err
+	}
+	return "", fmt.Errorf("unsupported chain %s", user.CryptoCurrency)
+}
+
+// statusUser loads the user named by the id parameter within the admin's
+// tenant
+func statusUser(c *gin.Context) (models.User, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, err, "invalid user id")
+		return models.User{}, false
+	}
+	if !inTenant(c, uint(id)) {
+		return models.User{}, false
+	}
+	user, err := models.GetUserByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return models.User{}, false
+	}
+	return user, true
+}
+
+// GetUserStatus returns the user's status with its history, and the closure
+// record of a closed user
+func GetUserStatus(c *gin.Context) {
+	user, ok := statusUser(c)
+	if !ok {
+		return
+	}
+	history, err := models.GetUserStatusChanges(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	closure, err := models.GetAccountClosure(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "fetched user status", "errors": false, "data": gin.H{
+		"status":            user.CurrentStatus(),
+		"reason":            user.StatusReason,
+		"status_changed_at": user.StatusChangedAt,
+		"history":           history,
+		"closure":           closure,
+	}})
+}
+
+// UpdateUserStatus freezes, suspends or reactivates a user. Freezing and
+// suspending sign the user out everywhere.
+func UpdateUserStatus(c *gin.Context) {
+	adminID, err := tokens.ExtractUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	var input serializers.UpdateUserStatus
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequest(c, err, "invalid status")
+		return
+	}
+	user, ok := statusUser(c)
+	if !ok {
+		return
+	}
+	if user.ID == adminID {
+		utils.BadRequest(c, errors.New("own account"), "admins cannot change their own status")
+		return
+	}
+
+	if err := user.SetStatus(input.Status, input.Reason, &adminID); err != nil {
+		if errors.Is(err, models.ErrUserClosed) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Status != models.UserActive {
+		if err := user.RevokeSessions(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	recordSecurityEvent(c, models.EventStatusChanged, &user, user.Email, input.Status+": "+input.Reason)
+	c.JSON(http.StatusOK, gin.H{"status": "user status updated", "data": user, "errors": false})
+}
+
+// sweepDestination is the designated address a closed account's balance is
+// swept to, the platform's master wallet of the chain or a confirmed crypto
+// beneficiary of the user
+func sweepDestination(user models.User, input serializers.CloseUser) (string, error) {
+	switch input.SweepTo {
+	case serializers.SweepToPlatform:
+		masterWallet, err := models.FetchMasterWallet(user.CryptoCurrency)
+		if err != nil {
+			return "", err
+		}
+		return masterWallet.PublicAddress, nil
+	case serializers.SweepToBeneficiary:
+		beneficiary, err := models.GetUserBeneficiary(user.ID, input.BeneficiaryID)
+		if err != nil {
+			return "", errors.New("beneficiary not found")
+		}
+		if beneficiary.Kind != models.BeneficiaryCrypto || !strings.EqualFold(beneficiary.Chain, user.CryptoCurrency) {
+			return "", fmt.Errorf("beneficiary is not a %s address", user.CryptoCurrency)
+		}
+		if !beneficiary.Usable(time.Now()) {
+			return "", errors.New("beneficiary is not confirmed or still cooling")
+		}
+		return beneficiary.Address, nil
+	}
+	return "", errors.New("sweep_to is required")
+}
+
+// CloseUserAccount closes a user for good. The wallet must be empty, or its
+// balance is swept to a designated address first, see sweepDestination.
+// Sessions are revoked and records kept for RecordRetentionYears.
+func CloseUserAccount(c *gin.Context) {
+	adminID, err := tokens.ExtractUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	var input serializers.CloseUser
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequest(c, err, "invalid closure")
+		return
+	}
+	user, ok := statusUser(c)
+	if !ok {
+		return
+	}
+	if user.CurrentStatus() == models.UserClosed {
+		c.JSON(http.StatusConflict, gin.H{"error": models.ErrUserClosed.Error()})
+		return
+	}
+	if user.ID == adminID {
+		utils.BadRequest(c, errors.New("own account"), "admins cannot close their own account")
+		return
+	}
+
+	closure := &models.AccountClosure{
+		Reason:      input.Reason,
+		ClosedByID:  adminID,
+		Chain:       user.CryptoCurrency,
+		RetainUntil: time.Now().AddDate(state.AppConfig.RecordRetentionYears, 0, 0),
+	}
+	if user.HasWallet() {
+		balance, err := walletBalance(user)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "could not fetch wallet balance: " + err.Error()})
+			return
+		}
+		if balance > 0 {
+			if input.SweepTo == "" {
+				c.JSON(http.StatusConflict, gin.H{
+					"error": "the wallet still holds funds, give sweep_to to move them before closing",
+					"code":  "balance_not_zero",
+					"data":  gin.H{"balance": balance, "chain": user.CryptoCurrency},
+				})
+				return
+			}
+			sweepAddress, err := sweepDestination(user, input)
+			if err != nil {
+				utils.BadRequest(c, err, "no designated address to sweep the wallet to")
+				return
+			}
+			// freeze first so nothing else leaves the wallet while it is swept
+			if err := user.SetStatus(models.UserFrozen, "Closing: "+input.Reason, &adminID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			hash, err := sweepWallet(context.WithoutCancel(c.Request.Context()), user, sweepAddress, balance)
+			if err != nil {
+				c.JSON(http.StatusBadGateway, gin.H{"error": "could not sweep wallet: " + err.Error()})
+				return
+			}
+			closure.SweptAmount, closure.SweepAddress, closure.SweepTxHash = balance, sweepAddress, hash
+		}
+	}
+
+	if err := user.Close(closure); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	recordSecurityEvent(c, models.EventStatusChanged, &user, user.Email, "closed: "+input.Reason)
+	c.JSON(http.StatusOK, gin.H{"status": "user account closed", "data": closure, "errors": false})
+}
@@ -0,0 +1,374 @@
+package controllers
+
+import (
+	"backend/models"
+	"backend/serializers"
+	"backend/state"
+	"backend/utils"
+	"backend/utils/apperrors"
+	"backend/utils/mails"
+	"backend/utils/tenancy"
+	"backend/utils/tokens"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var phoneNumberFormat = regexp.MustCompile(`^\+?[0-9]{7,15}$`)
+
+func beneficiaryCooling() time.Duration {
+	return time.Duration(state.AppConfig.BeneficiaryCoolingHours) * time.Hour
+}
+
+func beneficiaryUser(c *gin.Context) (models.User, bool) {
+	userID, err := tokens.ExtractUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return models.User{}, false
+	}
+	user, err := models.GetUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return models.User{}, false
+	}
+	return user, true
+}
+
+func userBeneficiary(c *gin.Context, userID uint) (*models.Beneficiary, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, err, "invalid beneficiary id")
+		return nil, false
+	}
+	beneficiary, err := models.GetUserBeneficiary(userID, uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return nil, false
+	}
+	return beneficiary, true
+}
+
+// newBeneficiary checks the fields of the input's kind and returns the
+// beneficiary to save
+func newBeneficiary(userID uint, input serializers.Beneficiary) (*models.Beneficiary, error) {
+	b := &models.Beneficiary{UserID: userID, Kind: input.Kind, Label: strings.TrimSpace(input.Label)}
+	switch input.Kind {
+	case models.BeneficiaryCrypto:
+		b.Chain, b.Address = strings.ToUpper(input.Chain), strings.TrimSpace(input.Address)
+		if !slices.Contains([]string{serializers.ChainCelo, serializers.ChainStellar, serializers.ChainPolygon}, b.Chain) {
+			return nil, fmt.Errorf("unsupported chain %q", input.Chain)
+		}
+		if !serializers.ValidAddress(b.Chain, b.Address) {
+			return nil, fmt.Errorf("address is not a valid %s address", b.Chain)
+		}
+
+	case models.BeneficiaryBank:
+		if input.BankID == nil {
+			return nil, errors.New("bank_id is required")
+		}
+		bank, err := models.GetBankData(int(*input.BankID))
+		if err != nil {
+			return nil, errors.New("unknown bank_id")
+		}
+		b.BankID, b.Bank = &bank.ID, bank
+		b.AccountNumber = strings.TrimSpace(input.AccountNumber)
+		b.AccountName = strings.TrimSpace(input.AccountName)
+		b.AccountType = input.AccountType
+		if b.AccountNumber == "" || b.AccountName == "" {
+			return nil, errors.New("account_number and account_name are required")
+		}
+
+	case models.BeneficiaryMobileMoney:
+		b.CountryCode = strings.ToUpper(strings.TrimSpace(input.CountryCode))
+		b.PhoneNumber = strings.NewReplacer(" ", "", "-", "").Replace(input.PhoneNumber)
+		b.MobileNetwork = input.MobileNetwork
+		b.AccountName = strings.TrimSpace(input.AccountName)
+		if b.CountryCode == "" || b.AccountName == "" {
+			return nil, errors.New("country_code and account_name are required")
+		}
+		if !phoneNumberFormat.MatchString(b.PhoneNumber) {
+			return nil, errors.New("phone_number is not a valid phone number")
+		}
+	}
+	return b, nil
+}
+
+// describeBeneficiary names the beneficiary in mails without showing the
+// whole destination
+func describeBeneficiary(b *models.Beneficiary) string {
+	mask := func(s string) string {
+		if len(s) <= 4 {
+			return s
+		}
+		return "****" + s[len(s)-4:]
+	}
+	var destination string
+	switch b.Kind {
+	case models.BeneficiaryCrypto:
+		destination = fmt.Sprintf("%s address %s…%s", b.Chain, b.Address[:6], b.Address[len(b.Address)-4:])
+	case models.BeneficiaryBank:
+		bank := ""
+		if b.Bank != nil {
+			bank = " at " + b.Bank.Name
+		}
+		destination = fmt.Sprintf("%s, account %s%s", b.AccountName, mask(b.AccountNumber), bank)
+	case models.BeneficiaryMobileMoney:
+		destination = fmt.Sprintf("%s, mobile money %s", b.AccountName, mask(b.PhoneNumber))
+	}
+	if b.Label != "" {
+		return b.Label + " (" + destination + ")"
+	}
+	return destination
+}
+
+func sendBeneficiaryConfirmation(ctx context.Context, user models.User, b *models.Beneficiary) {
+	token, err := models.GenerateBeneficiaryToken(user.ID, b.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "beneficiary token not generated", "beneficiary_id", b.ID, "error", err)
+		return
+	}
+	if err := mails.SendBeneficiaryConfirmMail(tenancy.MailRecipient(&user), token.Token, describeBeneficiary(b), state.AppConfig.BeneficiaryCoolingHours); err != nil {
+		slog.WarnContext(ctx, "beneficiary confirmation mail not sent", "beneficiary_id", b.ID, "error", err)
+	}
+}
+
+// GetBeneficiaries lists the user's saved beneficiaries, filtered by kind
+func GetBeneficiaries(c *gin.Context) {
+	user, ok := beneficiaryUser(c)
+	if !ok {
+		return
+	}
+	kind := c.Query("kind")
+	if kind != "" && !slices.Contains(models.BeneficiaryKinds, kind) {
+		utils.BadRequest(c, errors.New("unknown kind: "+kind), "unknown kind")
+		return
+	}
+	beneficiaries, err := models.GetUserBeneficiaries(user.ID, kind)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": "fetched beneficiaries",
+		"data": gin.H{
+			"beneficiaries":        beneficiaries,
+			"allowlist_only":       user.AllowlistOnly,
+			"allowlist_release_at": user.AllowlistReleaseAt,
+		},
+		"errors": false,
+	})
+}
+
+// CreateBeneficiary saves a beneficiary and mails the user a link to confirm
+// it
+func CreateBeneficiary(c *gin.Context) {
+	user, ok := beneficiaryUser(c)
+	if !ok {
+		return
+	}
+	var input serializers.Beneficiary
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequest(c, err, "invalid beneficiary")
+		return
+	}
+	beneficiary, err := newBeneficiary(user.ID, input)
+	if err != nil {
+		utils.BadRequest(c, err, err.Error())
+		return
+	}
+	if err := beneficiary.CreateBeneficiary(); err != nil {
+		if errors.Is(err, models.ErrBeneficiaryExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	recordSecurityEvent(c, models.EventBeneficiaryAdded, &user, user.Email, describeBeneficiary(beneficiary))
+	go sendBeneficiaryConfirmation(c.Request.Context(), user, beneficiary)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status": "beneficiary saved, confirm it through the link sent to your email",
+		"data":   beneficiary,
+		"errors": false,
+	})
+}
+
+// ResendBeneficiaryConfirmation mails a new confirmation link, the previous
+// one stops working
+func ResendBeneficiaryConfirmation(c *gin.Context) {
+	user, ok := beneficiaryUser(c)
+	if !ok {
+		return
+	}
+	beneficiary, ok := userBeneficiary(c, user.ID)
+	if !ok {
+		return
+	}
+	if beneficiary.Status == models.BeneficiaryConfirmed {
+		c.JSON(http.StatusConflict, gin.H{"error": "beneficiary is already confirmed"})
+		return
+	}
+	go sendBeneficiaryConfirmation(c.Request.Context(), user, beneficiary)
+	c.JSON(http.StatusOK, gin.H{"status": "confirmation link sent", "errors": false})
+}
+
+// ConfirmBeneficiary confirms a beneficiary with the mailed token, it can be
+// paid once the cooling period has passed
+func ConfirmBeneficiary(c *gin.Context) {
+	user, ok := beneficiaryUser(c)
+	if !ok {
+		return
+	}
+	var input serializers.ConfirmBeneficiary
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequest(c, err, "token is required")
+		return
+	}
+
+	token, err := models.CheckTokenValid(input.Token, models.TokenPurposeBeneficiary)
+	if err != nil || token.UserID != user.ID {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
+		return
+	}
+	beneficiary, err := models.GetUserBeneficiary(user.ID, token.SubjectID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
+		return
+	}
+	if err := token.Consume(); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
+		return
+	}
+
+	if err := beneficiary.Confirm(beneficiaryCooling()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	recordSecurityEvent(c, models.EventBeneficiaryConfirmed, &user, user.Email, describeBeneficiary(beneficiary))
+
+	c.JSON(http.StatusOK, gin.H{"status": "beneficiary confirmed", "data": beneficiary, "errors": false})
+}
+
+func DeleteBeneficiary(c *gin.Context) {
+	user, ok := beneficiaryUser(c)
+	if !ok {
+		return
+	}
+	beneficiary, ok := userBeneficiary(c, user.ID)
+	if !ok {
+		return
+	}
+	if err := beneficiary.DeleteBeneficiary(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	recordSecurityEvent(c, models.EventBeneficiaryRemoved, &user, user.Email, describeBeneficiary(beneficiary))
+	c.JSON(http.StatusOK, gin.H{"status": "beneficiary deleted", "errors": false})
+}
+
+// UpdateAllowlist turns allowlist-only payouts on at once, turning them off
+// takes effect after the cooling period
+func UpdateAllowlist(c *gin.Context) {
+	user, ok := beneficiaryUser(c)
+	if !ok {
+		return
+	}
+	var input serializers.Allowlist
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequest(c, err, "enabled is required")
+		return
+	}
+	wasOn := user.AllowlistOnly
+	if err := user.SetAllowlistOnly(*input.Enabled, beneficiaryCooling()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if wasOn != user.AllowlistOnly {
+		detail := "allowlist-only payouts turned on"
+		if !user.AllowlistOnly {
+			detail = "allowlist-only payouts turned off from " + user.AllowlistReleaseAt.Format(time.RFC3339)
+		}
+		recordSecurityEvent(c, models.EventAllowlistChanged, &user, user.Email, detail)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "allowlist updated",
+		"data": gin.H{
+			"allowlist_only":       user.AllowlistOnly,
+			"allowlist_release_at": user.AllowlistReleaseAt,
+		},
+		"errors": false,
+	})
+}
+
+// payoutBeneficiary answers the request and returns false when the payout
+// may not go out. A payout to a saved beneficiary, by id, needs it to be
+// confirmed and past its cooling period. For users on the allowlist the
+// destination, identified by key, must be such a beneficiary. The saved
+// beneficiary is returned when there is one.
+func payoutBeneficiary(c *gin.Context, user models.User, kind string, id *uint, key string) (*models.Beneficiary, bool) {
+	var beneficiary *models.Beneficiary
+	var err error
+	switch {
+	case id != nil:
+		beneficiary, err = models.GetUserBeneficiary(user.ID, *id)
+		if err != nil {
+			apperrors.Respond(c, apperrors.Wrap(apperrors.CodeNotFound, err, "beneficiary not found"))
+			return nil, false
+		}
+		if beneficiary.Kind != kind {
+			utils.BadRequest(c, errors.New("beneficiary is a "+beneficiary.Kind+" beneficiary"), "beneficiary cannot be paid on this rail")
+			return nil, false
+		}
+	case user.AllowlistEnforced(time.Now()):
+		beneficiary, err = models.FindUserBeneficiary(user.ID, key)
+		if err != nil {
+			apperrors.Respond(c, err)
+			return nil, false
+		}
+		if beneficiary == nil {
+			apperrors.Respond(c, apperrors.New(apperrors.CodeBeneficiaryRefused, "payouts are restricted to your saved beneficiaries").
+				WithReason("beneficiary_not_allowlisted"))
+			return nil, false
+		}
+	default:
+		return nil, true
+	}
+
+	if beneficiary.Kind == models.BeneficiaryBank && beneficiary.Bank == nil {
+		apperrors.Respond(c, apperrors.New(apperrors.CodeConflict, "the beneficiary's bank is no longer supported"))
+		return nil, false
+	}
+	if beneficiary.Status != models.BeneficiaryConfirmed {
+		apperrors.Respond(c, apperrors.New(apperrors.CodeBeneficiaryRefused, "confirm the beneficiary through the link sent to your email first").
+			WithReason("beneficiary_unconfirmed"))
+		return nil, false
+	}
+	if !beneficiary.Usable(time.Now()) {
+		apperrors.Respond(c, apperrors.New(apperrors.CodeBeneficiaryRefused, "the beneficiary can be paid from "+beneficiary.UsableAt.Format(time.RFC3339)).
+			WithReason("beneficiary_cooling").
+			WithData(gin.H{"usable_at": beneficiary.UsableAt}))
+		return nil, false
+	}
+	return beneficiary, true
+}
+
+// bankBeneficiaryKey identifies an account at a bank given by name, empty
+// when the bank is unknown
+func bankBeneficiaryKey(bankName, accountNumber string) string {
+	bank, err := models.GetBankByName(bankName)
+	if err != nil {
+		return ""
+	}
+	return models.BankBeneficiaryKey(bank.ID, accountNumber)
+}
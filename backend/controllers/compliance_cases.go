@@ -0,0 +1,319 @@
+package controllers
+
+import (
+	"backend/models"
+	"backend/serializers"
+	"backend/utils"
+	"backend/utils/cases"
+	"backend/utils/documents"
+	"backend/utils/tokens"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxCaseAttachmentSize = 10 << 20
+
+// queryID parses an optional id filter, zero when it is not given
+func queryID(c *gin.Context, name string) (uint, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, true
+	}
+	parsed, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		utils.BadRequest(c, err, "invalid "+name)
+		return 0, false
+	}
+	return uint(parsed), true
+}
+
+// complianceCase loads the case named by the id parameter, answering the
+// request when it cannot
+func complianceCase(c *gin.Context) (*models.ComplianceCase, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, err, "invalid case id")
+		return nil, false
+	}
+	cc, err := models.GetComplianceCase(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return nil, false
+	}
+	return cc, true
+}
+
+func caseError(c *gin.Context, err error) {
+	if errors.Is(err, models.ErrCaseClosed) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// GetComplianceCases lists cases, filtered by user_id, transaction_id,
+// assignee_id, source, priority, overdue and status (open, investigating or
+// closed)
+func GetComplianceCases(c *gin.Context) {
+	lq, ok := listQuery(c)
+	if !ok {
+		return
+	}
+	var filter models.CaseFilter
+	if filter.UserID, ok = queryID(c, "user_id"); !ok {
+		return
+	}
+	if filter.TransactionID, ok = queryID(c, "transaction_id"); !ok {
+		return
+	}
+	if filter.AssigneeID, ok = queryID(c, "assignee_id"); !ok {
+		return
+	}
+	filter.Source = c.Query("source")
+	if filter.Source != "" && !slices.Contains(models.CaseSources, filter.Source) {
+		utils.BadRequest(c, errors.New("unknown source: "+filter.Source), "source must be one of manual, screening, limit_breach, reconciliation or monitoring")
+		return
+	}
+	filter.Priority = c.Query("priority")
+	if _, known := models.CaseSLAs[filter.Priority]; filter.Priority != "" && !known {
+		utils.BadRequest(c, errors.New("unknown priority: "+filter.Priority), "priority must be one of low, medium, high or critical")
+		return
+	}
+	filter.Overdue = c.Query("overdue") == "true"
+
+	list, info, err := models.FilterComplianceCases(filter, lq)
+	if err != nil {
+		listError(c, err)
+		return
+	}
+	respondList(c, "fetched compliance cases", list, info)
+}
+
+func CreateComplianceCase(c *gin.Context) {
+	adminID, err := tokens.ExtractUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	var input serializers.CreateCase
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequest(c, err, "invalid case")
+		return
+	}
+	blocking := input.Blocking == nil || *input.Blocking
+	priority := input.Priority
+	if priority == "" {
+		priority = models.CasePriorityMedium
+	}
+
+	if input.ReconciliationItemID != nil {
+		item, err := models.GetReconciliationItem(*input.ReconciliationItemID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "reconciliation item not found"})
+			return
+		}
+		cc, err := cases.FromReconciliationItem(item, adminID, priority, blocking)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"status": "compliance case opened", "data": cc, "errors": false})
+		return
+	}
+
+	if input.UserID == nil && input.TransactionID == nil {
+		utils.BadRequest(c, errors.New("missing subject"), "a case is about a user, a transaction or a reconciliation item")
+		return
+	}
+	if input.UserID != nil {
+		if _, err := models.GetUserByID(*input.UserID); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+	}
+	cc := &models.ComplianceCase{
+		UserID:        input.UserID,
+		TransactionID: input.TransactionID,
+		Source:        models.CaseManual,
+		Title:         input.Title,
+		Description:   input.Description,
+		Priority:      priority,
+		Blocking:      blocking && input.UserID != nil,
+		OpenedByID:    &adminID,
+	}
+	if err := cc.Open(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"status": "compliance case opened", "data": cc, "errors": false})
+}
+
+func GetComplianceCaseByID(c *gin.Context) {
+	cc, ok := complianceCase(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "fetched compliance case", "data": cc, "errors": false})
+}
+
+func AssignComplianceCase(c *gin.Context) {
+	var input serializers.AssignCase
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequest(c, err, "invalid assignee")
+		return
+	}
+	cc, ok := complianceCase(c)
+	if !ok {
+		return
+	}
+	assignee, err := models.GetUserByID(input.AssigneeID)
+	if err != nil || assignee.Role != "Admin" {
+		utils.BadRequest(c, errors.New("assignee is not an admin"), "cases are assigned to admins")
+		return
+	}
+	if err := cc.Assign(assignee.ID); err != nil {
+		caseError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "compliance case assigned", "data": cc, "errors": false})
+}
+
+func AddComplianceCaseNote(c *gin.Context) {
+	adminID, err := tokens.ExtractUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	var input serializers.AddCaseNote
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequest(c, err, "invalid note")
+		return
+	}
+	cc, ok := complianceCase(c)
+	if !ok {
+		return
+	}
+	note, err := cc.AddNote(&adminID, input.Body)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"status": "note added", "data": note, "errors": false})
+}
+
+// UploadCaseAttachment stores the multipart file as evidence, encrypted like
+// KYC documents
+func UploadCaseAttachment(c *gin.Context) {
+	adminID, err := tokens.ExtractUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	cc, ok := complianceCase(c)
+	if !ok {
+		return
+	}
+	file, err := c.FormFile("file")
+	if err != nil {
+		utils.BadRequest(c, err, "file is required")
+		return
+	}
+	if file.Size > maxCaseAttachmentSize {
+		utils.BadRequest(c, errors.New("file too large"), "attachments are at most 10MB")
+		return
+	}
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer src.Close()
+	content, err := io.ReadAll(src)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	blob, err := documents.Put(fmt.Sprintf("cases/%d", cc.ID), content)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	attachment := &models.CaseAttachment{
+		UploadedByID: adminID,
+		FileName:     filepath.Base(file.Filename),
+		ContentType:  http.DetectContentType(content),
+		Size:         blob.Size,
+		SHA256:       blob.SHA256,
+		Backend:      blob.Backend,
+		StorageKey:   blob.Key,
+	}
+	if err := cc.AddAttachment(attachment); err != nil {
+		_ = documents.Remove(blob)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"status": "attachment added", "data": attachment, "errors": false})
+}
+
+func DownloadCaseAttachment(c *gin.Context) {
+	cc, ok := complianceCase(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("attachmentId"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, err, "invalid attachment id")
+		return
+	}
+	attachment, err := models.GetCaseAttachment(cc.ID, uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return
+	}
+	content, err := documents.Get(documents.Blob{
+		Backend: attachment.Backend,
+		Key:     attachment.StorageKey,
+		Size:    attachment.Size,
+		SHA256:  attachment.SHA256,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName}))
+	c.Data(http.StatusOK, attachment.ContentType, content)
+}
+
+// DecideComplianceCase closes the case as clear, freeze or report
+func DecideComplianceCase(c *gin.Context) {
+	adminID, err := tokens.ExtractUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	var input serializers.DecideCase
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequest(c, err, "invalid decision")
+		return
+	}
+	cc, ok := complianceCase(c)
+	if !ok {
+		return
+	}
+	if err := cases.Decide(cc, adminID, input.Decision, input.Note, input.ReportReference); err != nil {
+		caseError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "compliance case decided", "data": cc, "errors": false})
+}
@@ -0,0 +1,31 @@
+package controllers
+
+import (
+	"backend/utils/openapi"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// specURL is where GetAPISpec is served, the Swagger UI loads it from there
+const specURL = "/api/docs/openapi.json"
+
+// GetAPISpec serves the OpenAPI document built from the routes at startup
+func GetAPISpec(c *gin.Context) {
+	doc := openapi.Current()
+	if doc == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "the API specification is not loaded yet"})
+		return
+	}
+	c.JSON(http.StatusOK, doc)
+}
+
+// GetAPIDocs serves Swagger UI for the spec
+func GetAPIDocs(c *gin.Context) {
+	page, err := openapi.UI(specURL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", page)
+}
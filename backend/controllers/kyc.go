@@ -5,11 +5,15 @@ import (
 	"backend/models"
 	"backend/serializers"
 	"backend/utils"
+	"backend/utils/documents"
+	"backend/utils/notifications"
+	"backend/utils/tenancy"
 	"backend/utils/tokens"
-	"encoding/base64"
+	"backend/utils/webhooks"
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"strconv"
 	"strings"
@@ -17,65 +21,151 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func GetUserKYC(c *gin.Context) {
-	userId, err := tokens.ExtractUserID(c)
+// Max KYC document size: 5MB
+const maxKYCDocumentSize = 5 << 20
+
+// kycDocumentTypes are the accepted uploads per document, bills and bank
+// statements are often PDFs
+var kycDocumentTypes = map[string]map[string]bool{
+	"front_photo":              {"image/jpeg": true, "image/png": true},
+	"back_photo":               {"image/jpeg": true, "image/png": true},
+	"selfie":                   {"image/jpeg": true, "image/png": true},
+	"proof_of_address":         {"image/jpeg": true, "image/png": true, "application/pdf": true},
+	"source_of_funds_document": {"image/jpeg": true, "image/png": true, "application/pdf": true},
+}
+
+var kycDocumentLabels = map[string]string{
+	"front_photo":              "Front photo",
+	"back_photo":               "Back photo",
+	"selfie":                   "Selfie",
+	"proof_of_address":         "Proof of address",
+	"source_of_funds_document": "Source of funds document",
+}
+
+// kycUpload is a KYC document read from the form, not yet stored
+type kycUpload struct {
+	content     []byte
+	contentType string
+}
+
+// readKYCDocument returns the uploaded document, false when it was not
+// uploaded
+func readKYCDocument(c *gin.Context, name string) (kycUpload, bool, error) {
+	file, err := c.FormFile(name)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+		return kycUpload{}, false, nil
+	}
+	label := kycDocumentLabels[name]
+	if file.Size > maxKYCDocumentSize {
+		return kycUpload{}, true, fmt.Errorf("%s exceeds 5MB limit", label)
 	}
 
-	user, err := models.GetUserByID(userId)
+	src, err := file.Open()
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-		return
+		return kycUpload{}, true, fmt.Errorf("error processing %s", strings.ToLower(label))
+	}
+	defer src.Close()
+	content, err := io.ReadAll(src)
+	if err != nil {
+		return kycUpload{}, true, fmt.Errorf("error reading %s", strings.ToLower(label))
 	}
 
-	includePhotos := c.GetHeader("include_photos") == "true"
-
-	if includePhotos {
-		// Struct returned includes photo data
-		kycWithPhotos, err := models.GetKycByUserIDWithPhotos(user.ID)
-		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-			return
+	mimeType := http.DetectContentType(content)
+	if !kycDocumentTypes[name][mimeType] {
+		if kycDocumentTypes[name]["application/pdf"] {
+			return kycUpload{}, true, fmt.Errorf("%s must be JPG, JPEG, PNG or PDF", label)
 		}
+		return kycUpload{}, true, fmt.Errorf("%s must be JPG, JPEG, or PNG", label)
+	}
+	return kycUpload{content: content, contentType: mimeType}, true, nil
+}
 
-		kycResponse := serializers.KYC{
-			ID:              kycWithPhotos.Kyc.ID,
-			IDType:          kycWithPhotos.Kyc.IDType,
-			IssueDate:       kycWithPhotos.Kyc.IssueDate,
-			ExpiryDate:      kycWithPhotos.Kyc.ExpiryDate,
-			TaxId:           kycWithPhotos.Kyc.TaxId,
-			IdNumber:        kycWithPhotos.Kyc.IdNumber,
-			DateOfBirth:     kycWithPhotos.Kyc.DateOfBirth,
-			StreetAddress:   kycWithPhotos.Kyc.StreetAddress,
-			City:            kycWithPhotos.Kyc.City,
-			State:           kycWithPhotos.Kyc.State,
-			PostalCode:      kycWithPhotos.Kyc.PostalCode,
-			Country:         kycWithPhotos.Kyc.Country,
-			Phone:           kycWithPhotos.Kyc.Phone,
-			Status:          serializers.KYCStatus(kycWithPhotos.Kyc.Status),
-			RejectionReason: kycWithPhotos.Kyc.RejectionReason,
-			CreatedAt:       kycWithPhotos.Kyc.CreatedAt,
-			UpdatedAt:       kycWithPhotos.Kyc.UpdatedAt,
-			RejectedAt:      kycWithPhotos.Kyc.RejectedAt,
-			ApprovedAt:      kycWithPhotos.Kyc.ApprovedAt,
-			FrontPhoto:      kycWithPhotos.FrontPhoto,
-			BackPhoto:       kycWithPhotos.BackPhoto,
+// applyKYCForm copies the fields of the tiers found in the form onto the
+// KYC, leaving the rest as they were, and returns the documents uploaded
+func applyKYCForm(c *gin.Context, kyc *models.KYC, tiers ...int) (map[string]kycUpload, error) {
+	uploads := map[string]kycUpload{}
+	for _, tier := range tiers {
+		requirement, _ := models.GetKYCTierRequirement(tier)
+		for _, name := range requirement.Fields {
+			if value := strings.TrimSpace(c.PostForm(name)); value != "" {
+				*kyc.Field(name) = value
+			}
 		}
+		for _, name := range requirement.Documents {
+			upload, uploaded, err := readKYCDocument(c, name)
+			if err != nil {
+				return nil, err
+			}
+			if uploaded {
+				uploads[name] = upload
+			}
+		}
+	}
 
-		c.JSON(http.StatusOK, gin.H{"kyc": kycResponse})
-		return
+	// Make sure country is a valid ISO 3166-1 alpha-2 code
+	if kyc.Country != "" && !utils.CreateValidCountryCodes()[strings.ToUpper(kyc.Country)] {
+		return nil, errors.New("Invalid country code, must be a valid alpha-2 country code")
 	}
+	return uploads, nil
+}
 
-	// Fallback: basic KYC struct
-	kyc, err := models.GetKYCByUserID(user.ID)
+// kycDocumentsPresent returns the documents the user already has along with
+// those being uploaded
+func kycDocumentsPresent(userID uint, uploads map[string]kycUpload) (map[string]bool, error) {
+	present, err := models.PresentKYCDocuments(userID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-		return
+		return nil, err
+	}
+	for name := range uploads {
+		present[name] = true
+	}
+	return present, nil
+}
+
+// stageKYCUploads encrypts the uploads into document storage, they are
+// recorded along with the KYC by models.SubmitKYC
+func stageKYCUploads(userID uint, uploads map[string]kycUpload) ([]*models.KYCDocument, error) {
+	staged := make([]*models.KYCDocument, 0, len(uploads))
+	for name, upload := range uploads {
+		document, err := documents.Stage(userID, models.KYCDocumentFields[name], upload.content, upload.contentType)
+		if err != nil {
+			documents.Discard(staged...)
+			return nil, fmt.Errorf("error storing %s: %w", strings.ToLower(kycDocumentLabels[name]), err)
+		}
+		staged = append(staged, document)
 	}
+	return staged, nil
+}
 
-	kycResponse := serializers.KYC{
+// submitKYC records the KYC, its staged uploads and the tiers in one go,
+// the uploads are discarded when that fails
+func submitKYC(kyc *models.KYC, staged []*models.KYCDocument, tiers []int) error {
+	replaced, err := models.SubmitKYC(kyc, staged, tiers)
+	if err != nil {
+		documents.Discard(staged...)
+		return err
+	}
+	documents.Purge(replaced)
+	return nil
+}
+
+func kycLevels(levels []models.KYCLevel) []serializers.KYCLevel {
+	result := make([]serializers.KYCLevel, 0, len(levels))
+	for _, level := range levels {
+		result = append(result, serializers.KYCLevel{
+			Tier:            level.Tier,
+			Status:          serializers.KYCStatus(level.Status),
+			RejectionReason: level.RejectionReason,
+			SubmittedAt:     level.SubmittedAt,
+			ApprovedAt:      level.ApprovedAt,
+			RejectedAt:      level.RejectedAt,
+		})
+	}
+	return result
+}
+
+func kycResponse(kyc models.KYC, levels []models.KYCLevel) serializers.KYC {
+	return serializers.KYC{
 		ID:              kyc.ID,
 		IDType:          kyc.IDType,
 		IssueDate:       kyc.IssueDate,
@@ -89,15 +179,121 @@ func GetUserKYC(c *gin.Context) {
 		PostalCode:      kyc.PostalCode,
 		Country:         kyc.Country,
 		Phone:           kyc.Phone,
+		SourceOfFunds:   kyc.SourceOfFunds,
 		Status:          serializers.KYCStatus(kyc.Status),
+		Tier:            models.TierFromLevels(levels),
+		Levels:          kycLevels(levels),
 		RejectionReason: kyc.RejectionReason,
 		CreatedAt:       kyc.CreatedAt,
 		UpdatedAt:       kyc.UpdatedAt,
 		RejectedAt:      kyc.RejectedAt,
 		ApprovedAt:      kyc.ApprovedAt,
 	}
+}
 
-	c.JSON(http.StatusOK, gin.H{"kyc": kycResponse})
+// kycTiers lists every tier's requirements with the user's review of it and
+// what is still missing
+func kycTiers(kyc *models.KYC, present map[string]bool, levels []models.KYCLevel) []serializers.KYCTier {
+	statuses := make(map[int]models.KYCLevel, len(levels))
+	for _, level := range levels {
+		statuses[level.Tier] = level
+	}
+
+	tiers := make([]serializers.KYCTier, 0, len(models.KYCTierRequirements))
+	for _, requirement := range models.KYCTierRequirements {
+		tier := serializers.KYCTier{
+			Tier:      requirement.Tier,
+			Name:      requirement.Name,
+			Fields:    requirement.Fields,
+			Documents: requirement.Documents,
+			Missing:   models.MissingKYCRequirements(requirement.Tier, kyc, present),
+		}
+		if level, ok := statuses[requirement.Tier]; ok {
+			status := serializers.KYCStatus(level.Status)
+			tier.Status = &status
+			tier.RejectionReason = level.RejectionReason
+		}
+		tiers = append(tiers, tier)
+	}
+	return tiers
+}
+
+func GetUserKYC(c *gin.Context) {
+	userId, err := tokens.ExtractUserID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := models.GetUserByID(userId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	levels, err := models.GetKYCLevels(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	kyc, err := models.GetKYCByUserID(user.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	response := kycResponse(*kyc, levels)
+
+	// Photos come as short lived signed URLs
+	if c.GetHeader("include_photos") == "true" {
+		photos, err := kycPhotos(c, user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		response.FrontPhoto = photos["front_photo"]
+		response.BackPhoto = photos["back_photo"]
+		response.Selfie = photos["selfie"]
+		response.ProofOfAddress = photos["proof_of_address"]
+		response.SourceOfFundsDocument = photos["source_of_funds_document"]
+	}
+
+	c.JSON(http.StatusOK, gin.H{"kyc": response})
+}
+
+// GetKYCTiers shows the user every tier's requirements, their review and
+// what is missing to upgrade
+func GetKYCTiers(c *gin.Context) {
+	userId, err := tokens.ExtractUserID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// users who never submitted KYC are tier 0 and miss everything
+	kyc, err := models.GetKYCByUserID(userId)
+	if err != nil {
+		kyc = &models.KYC{}
+	}
+	present, err := models.PresentKYCDocuments(userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	levels, err := models.GetKYCLevels(userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "fetched kyc tiers",
+		"data": gin.H{
+			"current_tier": models.TierFromLevels(levels),
+			"tiers":        kycTiers(kyc, present, levels),
+		},
+		"errors": false,
+	})
 }
 
 func GetKYCS(c *gin.Context) {
@@ -125,64 +321,42 @@ func GetKYCS(c *gin.Context) {
 		request.UserID = &user.ID
 	}
 
-	m_kycs, err := models.FilterKYC(request)
+	lq, ok := listQuery(c)
+	if !ok {
+		return
+	}
+	m_kycs, info, err := models.FilterKYC(tenancy.TenantID(c), request, lq)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+		listError(c, err)
+		return
+	}
+
+	userIDs := make([]uint, 0, len(m_kycs))
+	for _, kyc := range m_kycs {
+		userIDs = append(userIDs, kyc.UserID)
+	}
+	levels, err := models.GetKYCLevelsByUser(userIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	// convert kyc models to serializers
-	var kycs []serializers.KYC
+	kycs := make([]serializers.KYC, 0, len(m_kycs))
 	for _, kyc := range m_kycs {
-		kycs = append(kycs, serializers.KYC{
-			ID:              kyc.ID,
-			IDType:          kyc.IDType,
-			IssueDate:       kyc.IssueDate,
-			ExpiryDate:      kyc.ExpiryDate,
-			TaxId:           kyc.TaxId,
-			IdNumber:        kyc.IdNumber,
-			DateOfBirth:     kyc.DateOfBirth,
-			StreetAddress:   kyc.StreetAddress,
-			City:            kyc.City,
-			State:           kyc.State,
-			PostalCode:      kyc.PostalCode,
-			Country:         kyc.Country,
-			Phone:           kyc.Phone,
-			Status:          serializers.KYCStatus(kyc.Status),
-			RejectionReason: kyc.RejectionReason,
-			CreatedAt:       kyc.CreatedAt,
-			UpdatedAt:       kyc.UpdatedAt,
-			RejectedAt:      kyc.RejectedAt,
-			ApprovedAt:      kyc.ApprovedAt,
-		})
+		kycs = append(kycs, kycResponse(kyc, levels[kyc.UserID]))
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"kycs": kycs,
-	})
+	respondList(c, "fetched kycs", kycs, info)
 }
 
+// CreateKYC submits tier 1, and tier 2 when its ID document, photos and
+// selfie come along
 func CreateKYC(c *gin.Context) {
-	// Max file size: 5MB
-	const maxSize = 5 << 20
-
-	// Accepted MIME types
-	validMimeTypes := map[string]bool{
-		"image/jpeg": true,
-		"image/png":  true,
-		"image/gif":  true,
-	}
-
 	// Bind form data
 	var request struct {
 		Email         string `form:"email" binding:"required,email"`
-		IDType        string `form:"id_type" binding:"required"`
-		IssueDate     string `form:"issue_date" binding:"required"`
-		ExpiryDate    string `form:"expiry_date" binding:"required"`
 		TaxId         string `form:"tax_id" binding:"required"`
-		IdNumber      string `form:"id_number" binding:"required"`
 		DateOfBirth   string `form:"date_of_birth" binding:"required"`
 		Phone         string `form:"phone" binding:"required"`
 		StreetAddress string `form:"street_address" binding:"required"`
@@ -199,217 +373,72 @@ func CreateKYC(c *gin.Context) {
 		return
 	}
 
-	// Make sure country is a valid ISO 3166-1 alpha-2 code
-	validCodes := utils.CreateValidCountryCodes()
-	country := strings.ToUpper(request.Country)
-
-	if !validCodes[country] {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": "Invalid country code, must be a valid alpha-2 country code",
+	// Check if the user exists in the database
+	user, ok := models.FindUserByEmail(request.Email)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "User not found",
 		})
 		return
 	}
 
-	// Handle front photo
-	frontFile, err := c.FormFile("front_photo")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Front photo is required",
+	// Check if the user has already submitted a KYC request
+	if _, err := models.GetKYCByUserID(user.ID); err == nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "KYC request already submitted",
 		})
 		return
 	}
 
-	// Handle back photo
-	backFile, err := c.FormFile("back_photo")
+	kyc := &models.KYC{UserID: user.ID, Status: models.Pending}
+	uploads, err := applyKYCForm(c, kyc, models.KYCTier1, models.KYCTier2)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Back photo is required",
-		})
-		return
-	}
-
-	// Validate front photo
-	if frontFile.Size > maxSize {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Front photo exceeds 5MB limit",
-		})
-		return
-	}
-
-	// Validate back photo
-	if backFile.Size > maxSize {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Back photo exceeds 5MB limit",
+			"error": err.Error(),
 		})
 		return
 	}
-
-	// Open and validate front photo
-	frontSrc, err := frontFile.Open()
+	present, err := kycDocumentsPresent(user.ID, uploads)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error processing front photo",
-		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	defer frontSrc.Close()
 
-	// Detect front photo MIME type
-	frontBuffer := make([]byte, 512)
-	_, err = frontSrc.Read(frontBuffer)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error reading front photo",
-		})
-		return
-	}
-	frontMimeType := http.DetectContentType(frontBuffer)
-	if !validMimeTypes[frontMimeType] {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Front photo must be JPG, JPEG, or PNG",
-		})
-		return
+	tiers := []int{models.KYCTier1}
+	if len(models.MissingKYCRequirements(models.KYCTier2, kyc, present)) == 0 {
+		tiers = append(tiers, models.KYCTier2)
 	}
 
-	// Open and validate back photo
-	backSrc, err := backFile.Open()
+	staged, err := stageKYCUploads(user.ID, uploads)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error processing back photo",
-		})
-		return
-	}
-	defer backSrc.Close()
-
-	// Detect back photo MIME type
-	backBuffer := make([]byte, 512)
-	_, err = backSrc.Read(backBuffer)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error reading back photo",
-		})
-		return
-	}
-	backMimeType := http.DetectContentType(backBuffer)
-	if !validMimeTypes[backMimeType] {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Back photo must be JPG, JPEG, or PNG",
-		})
-		return
-	}
-
-	// Convert front photo to base64
-	frontSrc.Seek(0, 0)
-	frontBytes, err := io.ReadAll(frontSrc)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error processing front photo",
-		})
-		return
-	}
-	frontBase64 := fmt.Sprintf("data:%s;base64,%s", frontMimeType, base64.StdEncoding.EncodeToString(frontBytes))
-
-	// Convert back photo to base64
-	backSrc.Seek(0, 0)
-	backBytes, err := io.ReadAll(backSrc)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error processing back photo",
-		})
-		return
-	}
-	backBase64 := fmt.Sprintf("data:%s;base64,%s", backMimeType, base64.StdEncoding.EncodeToString(backBytes))
-
-	// Check if the user exists in the database
-	user, ok := models.FindUserByEmail(request.Email)
-	if !ok {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "User not found",
-		})
-		return
-	}
-
-	log.Printf("Found user data: %+v", user)
-
-	// Check if the user has already submitted a KYC request
-	_, err = models.GetKYCByUserID(user.ID)
-
-	if err == nil {
-		c.JSON(http.StatusConflict, gin.H{
-			"error": "KYC request already submitted",
-		})
-		return
-	}
-
-	// Construct KYC model object
-	kyc := &models.KYC{
-		UserID:        user.ID,
-		IDType:        request.IDType,
-		IssueDate:     request.IssueDate,
-		ExpiryDate:    request.ExpiryDate,
-		Status:        models.Pending,
-		IdNumber:      request.IdNumber,
-		TaxId:         request.TaxId,
-		DateOfBirth:   request.DateOfBirth,
-		Phone:         request.Phone,
-		StreetAddress: request.StreetAddress,
-		City:          request.City,
-		State:         request.State,
-		PostalCode:    request.PostalCode,
-		Country:       request.Country,
-	}
-
-	// Construct KYCdata model object
-	kycData := &models.KYCData{
-		UserID:     user.ID,
-		FrontPhoto: frontBase64,
-		BackPhoto:  backBase64,
-	}
-
-	if err := kyc.CreateKYC(); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
 
-	if err := kycData.CreateKYCData(); err != nil {
+	if err := submitKYC(kyc, staged, tiers); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": err.Error(),
 		})
+		return
 	}
+	go screenKYC(c.Request.Context(), kyc)
 
+	levels, _ := models.GetKYCLevels(user.ID)
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "KYC request submitted successfully",
+		"data":    kycTiers(kyc, present, levels),
 	})
 }
 
+// UpdateKYC submits the tier in the form, to upgrade or to fix a rejected
+// tier. Without a tier every rejected tier is resubmitted.
 func UpdateKYC(c *gin.Context) {
-	// Max file size: 5MB
-	const maxSize = 5 << 20
-
-	// Accepted MIME types
-	validMimeTypes := map[string]bool{
-		"image/jpeg": true,
-		"image/jpg":  true,
-		"image/png":  true,
-	}
-
 	// Bind form data
 	var request struct {
-		Email         string `form:"email" binding:"required,email"`
-		IDType        string `form:"id_type" binding:"required"`
-		IssueDate     string `form:"issue_date" binding:"required"`
-		ExpiryDate    string `form:"expiry_date" binding:"required"`
-		TaxId         string `form:"tax_id" binding:"required"`
-		IdNumber      string `form:"id_number" binding:"required"`
-		DateOfBirth   string `form:"date_of_birth" binding:"required"`
-		Phone         string `form:"phone" binding:"required"`
-		StreetAddress string `form:"street_address" binding:"required"`
-		City          string `form:"city" binding:"required"`
-		State         string `form:"state" binding:"required"`
-		PostalCode    string `form:"postal_code" binding:"required"`
-		Country       string `form:"country" binding:"required"`
+		Email string `form:"email" binding:"required,email"`
+		Tier  *int   `form:"tier" binding:"omitempty,min=1,max=3"`
 	}
 
 	if err := c.ShouldBind(&request); err != nil {
@@ -437,228 +466,149 @@ func UpdateKYC(c *gin.Context) {
 		return
 	}
 
-	// Find corresponding KYC Data
-	existingKycData, err := models.GetKYCDataByUserId(user.ID)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "KYC Data Not Found",
-		})
-		return
-	}
-
-	// Initialize update data with existing values
-	frontBase64 := existingKycData.FrontPhoto
-	backBase64 := existingKycData.BackPhoto
-
-	// Process front photo if provided
-	if frontFile, err := c.FormFile("front_photo"); err == nil {
-		if frontFile.Size > maxSize {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "Front photo exceeds 5MB limit",
-			})
-			return
-		}
-
-		frontSrc, err := frontFile.Open()
+	var tiers []int
+	if request.Tier != nil {
+		tiers = []int{*request.Tier}
+	} else {
+		levels, err := models.GetKYCLevels(user.ID)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Error processing front photo",
-			})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		defer frontSrc.Close()
-
-		// Detect MIME type
-		frontBuffer := make([]byte, 512)
-		_, err = frontSrc.Read(frontBuffer)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Error reading front photo",
-			})
-			return
+		for _, level := range levels {
+			if level.Status == models.Rejected {
+				tiers = append(tiers, level.Tier)
+			}
 		}
-		frontMimeType := http.DetectContentType(frontBuffer)
-		if !validMimeTypes[frontMimeType] {
+		if len(tiers) == 0 {
 			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "Front photo must be JPG, JPEG, or PNG",
+				"error": "KYC can only be updated when status is rejected",
 			})
 			return
 		}
-
-		// Convert to base64
-		frontSrc.Seek(0, 0)
-		frontBytes, err := io.ReadAll(frontSrc)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Error processing front photo",
-			})
-			return
-		}
-		frontBase64 = fmt.Sprintf("data:%s;base64,%s", frontMimeType, base64.StdEncoding.EncodeToString(frontBytes))
 	}
 
-	// Process back photo if provided
-	if backFile, err := c.FormFile("back_photo"); err == nil {
-		if backFile.Size > maxSize {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "Back photo exceeds 5MB limit",
-			})
-			return
-		}
+	if err := models.CheckKYCSubmission(user.ID, tiers); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
 
-		backSrc, err := backFile.Open()
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Error processing back photo",
-			})
-			return
-		}
-		defer backSrc.Close()
+	uploads, err := applyKYCForm(c, existingKyc, tiers...)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	present, err := kycDocumentsPresent(user.ID, uploads)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
-		// Detect MIME type
-		backBuffer := make([]byte, 512)
-		_, err = backSrc.Read(backBuffer)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Error reading back photo",
-			})
-			return
-		}
-		backMimeType := http.DetectContentType(backBuffer)
-		if !validMimeTypes[backMimeType] {
+	for _, tier := range tiers {
+		if missing := models.MissingKYCRequirements(tier, existingKyc, present); len(missing) > 0 {
 			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "Back photo must be JPG, JPEG, or PNG",
+				"error": fmt.Sprintf("KYC tier %d is missing %s", tier, strings.Join(missing, ", ")),
+				"data":  gin.H{"tier": tier, "missing": missing},
 			})
 			return
 		}
-
-		// Convert to base64
-		backSrc.Seek(0, 0)
-		backBytes, err := io.ReadAll(backSrc)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Error processing back photo",
-			})
-			return
-		}
-		backBase64 = fmt.Sprintf("data:%s;base64,%s", backMimeType, base64.StdEncoding.EncodeToString(backBytes))
 	}
 
-	// Construct updated KYC model
-	updatedKyc := models.KYCRequest{
-		IDType:        request.IDType,
-		IssueDate:     request.IssueDate,
-		ExpiryDate:    request.ExpiryDate,
-		IdNumber:      request.IdNumber,
-		TaxId:         request.TaxId,
-		DateOfBirth:   request.DateOfBirth,
-		Phone:         request.Phone,
-		StreetAddress: request.StreetAddress,
-		City:          request.City,
-		State:         request.State,
-		PostalCode:    request.PostalCode,
-		Country:       request.Country,
-	}
-
-	// Construct updated KYCData model
-	updatedKycData := models.KYCDataRequest{
-		FrontPhoto: frontBase64,
-		BackPhoto:  backBase64,
-	}
-
-	if err := existingKyc.UpdateKYC(updatedKyc); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
+	staged, err := stageKYCUploads(user.ID, uploads)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
 
-	if err := existingKycData.UpdateKYCData(existingKyc.Status, updatedKycData); err != nil {
+	if err := submitKYC(existingKyc, staged, tiers); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
+	go screenKYC(c.Request.Context(), existingKyc)
 
+	levels, _ := models.GetKYCLevels(user.ID)
 	c.JSON(http.StatusOK, gin.H{
 		"message": "KYC request updated successfully",
+		"data":    kycTiers(existingKyc, present, levels),
 	})
 }
 
-func ApproveKYC(c *gin.Context) {
-	id := c.Param("id")
-	idUint64, err := strconv.ParseUint(id, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid ID format - must be a positive integer",
-		})
-		return
-	}
-	idUint := uint(idUint64)
-
-	// Find the KYC
-	existingKyc, err := models.GetKYCByID(idUint)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "KYC Not Found",
-		})
-		return
+// kycLevelsToReview returns the tier named in the query, or without one
+// every pending tier in order
+func kycLevelsToReview(c *gin.Context, userID uint) ([]models.KYCLevel, bool) {
+	if value := c.Query("tier"); value != "" {
+		tier, err := strconv.Atoi(value)
+		if err != nil || tier < models.KYCTier1 || tier > models.MaxKYCTier {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid tier - must be 1, 2 or 3",
+			})
+			return nil, false
+		}
+		level, err := models.GetKYCLevel(userID, tier)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": fmt.Sprintf("KYC tier %d has not been submitted", tier),
+			})
+			return nil, false
+		}
+		return []models.KYCLevel{*level}, true
 	}
 
-	// Find kyc data
-	existingKycData, err := models.GetKYCDataByUserId(existingKyc.UserID)
+	levels, err := models.GetKYCLevels(userID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "KYC Data Not Found",
-		})
-		return
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
 	}
-
-	// Find the user this kyc belongs to
-	user, err := models.GetUserByID(existingKyc.UserID)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": err.Error(),
-		})
-		return
+	var pending []models.KYCLevel
+	for _, level := range levels {
+		if level.Status == models.Pending {
+			pending = append(pending, level)
+		}
 	}
-
-	// if user is verified, return error
-	if user.IsVerified {
+	if len(pending) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "User is already verified",
+			"error": models.ErrKYCLevelNotPending.Error(),
 		})
-		return
+		return nil, false
 	}
+	return pending, true
+}
 
-	// make call to Borderless to create individual identity
-	borderless := borderless.NewBorderless()
+// createBorderlessIdentity creates or finds the user's Borderless identity
+// and uploads their ID document to it
+func createBorderlessIdentity(ctx context.Context, user models.User, kyc *models.KYC) (string, error) {
+	borderless := borderless.NewBorderless().WithContext(ctx)
 
 	borderlessIdentityAddress := models.BorderlessIdentityAddress{
-		Street1:    existingKyc.StreetAddress,
-		City:       existingKyc.City,
-		State:      existingKyc.State,
-		PostalCode: existingKyc.PostalCode,
-		Country:    existingKyc.Country,
+		Street1:    kyc.StreetAddress,
+		City:       kyc.City,
+		State:      kyc.State,
+		PostalCode: kyc.PostalCode,
+		Country:    kyc.Country,
 	}
 
 	borderlessIdentity := models.BorderlessIdentity{
 		FirstName:   user.FirstName,
 		LastName:    user.LastName,
 		Email:       user.Email,
-		Phone:       existingKyc.Phone,
-		TaxId:       existingKyc.TaxId,
-		DateOfBirth: existingKyc.DateOfBirth,
+		Phone:       kyc.Phone,
+		TaxId:       kyc.TaxId,
+		DateOfBirth: kyc.DateOfBirth,
 		Address:     borderlessIdentityAddress,
 	}
 
 	// first we try to check if the customer already has an identity
 	response, err := borderless.GetCustomerIdentity(borderlessIdentity.Email, borderlessIdentity.LastName)
 	if err != nil {
-		c.JSON(http.StatusBadGateway, gin.H{
-			"error": err.Error(),
-		})
-		return
+		return "", err
 	}
 
 	var borderlessID string
@@ -668,10 +618,7 @@ func ApproveKYC(c *gin.Context) {
 	if len(response["data"].([]interface{})) < 1 {
 		response, err := borderless.CreateCustomerIdentity(borderlessIdentity)
 		if err != nil {
-			c.JSON(http.StatusBadGateway, gin.H{
-				"error": err.Error(),
-			})
-			return
+			return "", err
 		}
 
 		borderlessID = response["id"].(string)
@@ -680,47 +627,127 @@ func ApproveKYC(c *gin.Context) {
 	}
 
 	// Upload documents to Borderless Identity
-	response, err = borderless.UploadCustomerIdentityDocument(borderlessID, *existingKyc, *existingKycData)
+	imageFront, err := documents.KYCImage(user.ID, "front_photo")
 	if err != nil {
-		c.JSON(http.StatusBadGateway, gin.H{
-			"error": err.Error(),
-		})
-		return
+		return "", err
+	}
+	imageBack, err := documents.KYCImage(user.ID, "back_photo")
+	if err != nil {
+		return "", err
+	}
+	response, err = borderless.UploadCustomerIdentityDocument(borderlessID, *kyc, imageFront, imageBack)
+	if err != nil {
+		return "", err
 	}
 
 	// Extract id field from the response
 	borderlessID, ok := response["id"].(string)
 	if !ok {
+		return "", errors.New("Invalid response format")
+	}
+	return borderlessID, nil
+}
+
+// ApproveKYC approves the tier in the query, or every pending tier in order.
+// Approving tier 2 creates the user's Borderless identity.
+func ApproveKYC(c *gin.Context) {
+	adminId, err := tokens.ExtractUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	id := c.Param("id")
+	idUint64, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid response format",
+			"error": "Invalid ID format - must be a positive integer",
 		})
 		return
 	}
+	idUint := uint(idUint64)
 
-	// set user to isVerified only if not already verified
-	user.IsVerified = true
-	if err := user.UpdateUserWithErrors(); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
+	// Find the KYC
+	existingKyc, err := models.GetKYCByID(idUint)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "KYC Not Found",
 		})
 		return
 	}
 
-	// Update the KYC request
-	if err := existingKyc.ApproveKYC(borderlessID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
+	// Find the user this kyc belongs to
+	user, err := models.GetUserByID(existingKyc.UserID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
 
+	levels, ok := kycLevelsToReview(c, existingKyc.UserID)
+	if !ok {
+		return
+	}
+
+	// watchlist hits are reviewed before anything is approved
+	if !kycScreeningClear(c, user, existingKyc) {
+		return
+	}
+
+	for i := range levels {
+		level := &levels[i]
+		if err := level.CanApprove(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		if level.Tier != models.KYCTier2 {
+			if err := level.Approve(adminId); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			continue
+		}
+
+		borderlessID, err := createBorderlessIdentity(c.Request.Context(), user, existingKyc)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		if err := level.ApproveWithIdentity(adminId, existingKyc, borderlessID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+	}
+
+	existingKyc, _ = models.GetKYCByID(idUint)
+	go notifications.KYC(models.WebhookKYCApproved, existingKyc)
+	go webhooks.PublishKYC(models.WebhookKYCApproved, existingKyc)
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "KYC request approved successfully",
+		"data":    kycLevels(levels),
 	})
 
 }
 
+// RejectKYC rejects the tier in the query, or every pending tier
 func RejectKYC(c *gin.Context) {
+	adminId, err := tokens.ExtractUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
 	id := c.Param("id")
 	idUint64, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
@@ -731,9 +758,7 @@ func RejectKYC(c *gin.Context) {
 	}
 	idUint := uint(idUint64)
 
-	var request struct {
-		RejectionReason string `json:"rejection_reason"`
-	}
+	var request serializers.RejectKYC
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -751,15 +776,27 @@ func RejectKYC(c *gin.Context) {
 		return
 	}
 
-	// Update the KYC request
-	if err := existingKyc.RejectKYC(request.RejectionReason); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+	levels, ok := kycLevelsToReview(c, existingKyc.UserID)
+	if !ok {
 		return
 	}
+
+	// Update the KYC request
+	for i := range levels {
+		if err := levels[i].Reject(adminId, request.RejectionReason); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+	}
+
+	existingKyc, _ = models.GetKYCByID(idUint)
+	go notifications.KYC(models.WebhookKYCRejected, existingKyc)
+	go webhooks.PublishKYC(models.WebhookKYCRejected, existingKyc)
 	c.JSON(http.StatusOK, gin.H{
 		"message": "KYC request rejected successfully",
+		"data":    kycLevels(levels),
 	})
 
 }
@@ -784,19 +821,51 @@ func DeleteKYC(c *gin.Context) {
 		return
 	}
 
-	// Find kyc data
-	existingKycData, err := models.GetKYCDataByUserId(existingKyc.UserID)
+	userId, err := tokens.ExtractUserID(c)
 	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	user, err := models.GetUserByID(userId)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	// only an admin or the owner may delete, and nobody once a tier is
+	// approved since its documents back that approval
+	if user.Role != "Admin" && existingKyc.UserID != userId {
 		c.JSON(http.StatusNotFound, gin.H{
-			"error": "KYC Data Not Found",
+			"error": "KYC Not Found",
+		})
+		return
+	}
+	levels, err := models.GetKYCLevels(existingKyc.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get KYC tiers",
+		})
+		return
+	}
+	if existingKyc.Status == models.Approved || models.TierFromLevels(levels) > models.KYCTier0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "KYC with an approved tier cannot be deleted",
 		})
 		return
 	}
 
-	// Delete the KYC Data
-	if err := existingKycData.DeleteKYCData(); err != nil {
+	// Delete the KYC Data left from before document storage
+	if existingKycData, err := models.GetKYCDataByUserId(existingKyc.UserID); err == nil {
+		if err := existingKycData.DeleteKYCData(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to delete KYC data",
+			})
+			return
+		}
+	}
+
+	if err := documents.DeleteUserDocuments(existingKyc.UserID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to delete KYC data",
+			"error": "Failed to delete KYC documents",
 		})
 		return
 	}
@@ -809,6 +878,14 @@ func DeleteKYC(c *gin.Context) {
 		return
 	}
 
+	// Tier reviews go with it so KYC can start over
+	if err := models.DeleteKYCLevels(existingKyc.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete KYC tiers",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "KYC request deleted successfully",
 	})
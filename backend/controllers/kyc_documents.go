@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"backend/models"
+	"backend/utils/documents"
+	"backend/utils/tokens"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// documentAccess identifies the viewer of a request for the access log
+func documentAccess(c *gin.Context) (documents.Access, error) {
+	viewerID, err := tokens.ExtractUserID(c)
+	if err != nil {
+		return documents.Access{}, err
+	}
+	return documents.Access{
+		ViewerID:  viewerID,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}, nil
+}
+
+// kycPhotos returns a signed URL per KYC form field the user has a document
+// for, documents not yet migrated come from the KYCData columns as before
+func kycPhotos(c *gin.Context, userID uint) (map[string]string, error) {
+	access, err := documentAccess(c)
+	if err != nil {
+		return nil, err
+	}
+	signed, err := documents.SignAll(userID, access)
+	if err != nil {
+		return nil, err
+	}
+
+	photos := map[string]string{}
+	if kycData, err := models.GetKYCDataByUserId(userID); err == nil {
+		for field := range models.KYCDocumentFields {
+			if value := kycData.Document(field); value != nil && *value != "" {
+				photos[field] = *value
+			}
+		}
+	}
+	for field, documentType := range models.KYCDocumentFields {
+		for _, url := range signed {
+			if url.Type == documentType {
+				photos[field] = url.URL
+			}
+		}
+	}
+	return photos, nil
+}
+
+// GetKYCDocuments lists the user's KYC documents with signed URLs to them
+func GetKYCDocuments(c *gin.Context) {
+	access, err := documentAccess(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	signed, err := documents.SignAll(access.ViewerID, access)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "fetched kyc documents",
+		"data":   signed,
+		"errors": false,
+	})
+}
+
+// GetUserKYCDocuments lists the KYC documents of a KYC for review, with
+// signed URLs issued to the admin
+func GetUserKYCDocuments(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid ID format - must be a positive integer",
+		})
+		return
+	}
+
+	kyc, err := models.GetKYCByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "KYC Not Found",
+		})
+		return
+	}
+	if !inTenant(c, kyc.UserID) {
+		return
+	}
+
+	access, err := documentAccess(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	signed, err := documents.SignAll(kyc.UserID, access)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "fetched kyc documents",
+		"data":   signed,
+		"errors": false,
+	})
+}
+
+// GetKYCDocumentAccessLogs lists who was issued or used links to a document
+func GetKYCDocumentAccessLogs(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid ID format - must be a positive integer",
+		})
+		return
+	}
+
+	document, err := models.GetKYCDocument(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+		return
+	}
+	if !inTenant(c, document.UserID) {
+		return
+	}
+
+	accesses, err := models.GetKYCDocumentAccesses(document.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "fetched document access logs",
+		"data":   accesses,
+		"errors": false,
+	})
+}
+
+// ServeKYCDocument serves a document through its signed URL. The signature
+// stands in for authentication so the link works in an img tag.
+func ServeKYCDocument(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid ID format - must be a positive integer",
+		})
+		return
+	}
+
+	access := documents.Access{IPAddress: c.ClientIP(), UserAgent: c.Request.UserAgent()}
+	err = documents.Verify(uint(id), c.Query("viewer"), c.Query("expires"), c.Query("signature"), access)
+	if errors.Is(err, documents.ErrInvalidSignature) || errors.Is(err, documents.ErrURLExpired) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	document, err := models.GetKYCDocument(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+		return
+	}
+	content, err := documents.Read(document)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, document.ContentType, content)
+}
@@ -0,0 +1,310 @@
+package controllers
+
+import (
+	"backend/models"
+	"backend/serializers"
+	"backend/utils"
+	"backend/utils/apperrors"
+	"backend/utils/cases"
+	"backend/utils/limits"
+	"backend/utils/tenancy"
+	"backend/utils/tokens"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// enforceLimit answers the request and returns false when the amount would
+// take the user over a limit on the rail and direction. Requests are refused
+// while the limits cannot be worked out. The amount of an allowed request is
+// reserved, the handler releases it once the request is recorded.
+func enforceLimit(c *gin.Context, user models.User, rail, direction, amount, currency string) (*limits.Reservation, bool) {
+	value, err := strconv.ParseFloat(strings.TrimSpace(amount), 64)
+	if err != nil || value <= 0 {
+		utils.BadRequest(c, errors.New("invalid amount: "+amount), "amount must be a positive number")
+		return nil, false
+	}
+
+	reservation, err := limits.Check(user, rail, direction, value, currency)
+	if err == nil {
+		return reservation, true
+	}
+	var limitErr *limits.Error
+	if errors.As(err, &limitErr) {
+		if limitErr.Code != limits.CodeKYCRequired {
+			detail := fmt.Sprintf("%s %s of %.2f USD refused, %s limit %.2f USD with %.2f USD used",
+				rail, direction, limitErr.Requested, limitErr.Period, limitErr.Limit, limitErr.Used)
+			ctx := c.Request.Context()
+			go func() {
+				if err := cases.FromLimitBreach(user.ID, limitErr.Code, detail); err != nil {
+					slog.ErrorContext(ctx, "limit breach not recorded", "user_id", user.ID, "code", limitErr.Code, "error", err)
+				}
+			}()
+		}
+		code := apperrors.CodeLimitExceeded
+		if limitErr.Code == limits.CodeKYCRequired {
+			code = apperrors.CodeKYCRequired
+		}
+		apperrors.Respond(c, apperrors.Wrap(code, limitErr, limitErr.Error()).WithReason(limitErr.Code).WithData(limitErr))
+		return nil, false
+	}
+	if errors.Is(err, limits.ErrStaleRate) {
+		apperrors.Respond(c, apperrors.Wrap(apperrors.CodeQuoteExpired, err, "The exchange rate could not be refreshed, please request a new quote"))
+		return nil, false
+	}
+	apperrors.Respond(c, apperrors.Wrap(apperrors.CodeServiceUnavailable, err, "could not verify transaction limits"))
+	return nil, false
+}
+
+// limitStatuses reports the user's limits on every rail and direction, or
+// only those matching the rail and direction when set
+func limitStatuses(user models.User, rail, direction string) ([]*limits.Status, error) {
+	var statuses []*limits.Status
+	for _, scope := range limits.Scopes() {
+		if (rail != "" && scope[0] != rail) || (direction != "" && scope[1] != direction) {
+			continue
+		}
+		status, err := limits.GetStatus(user, scope[0], scope[1])
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func limitScope(c *gin.Context) (string, string, bool) {
+	rail, direction := c.Query("rail"), c.Query("direction")
+	if rail != "" && !slices.Contains(models.LimitRails, rail) {
+		utils.BadRequest(c, errors.New("unknown rail: "+rail), "rail must be one of bank, mobile_money, borderless or onchain")
+		return "", "", false
+	}
+	if direction != "" && !slices.Contains(models.LimitDirections, direction) {
+		utils.BadRequest(c, errors.New("unknown direction: "+direction), "direction must be on_ramp or off_ramp")
+		return "", "", false
+	}
+	return rail, direction, true
+}
+
+// GetLimits returns the user's limits, usage and what remains, in USD
+func GetLimits(c *gin.Context) {
+	userID, err := tokens.ExtractUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	user, err := models.GetUserByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	rail, direction, ok := limitScope(c)
+	if !ok {
+		return
+	}
+
+	statuses, err := limitStatuses(user, rail, direction)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "fetched limits", "data": statuses, "errors": false})
+}
+
+func limitAmounts(input serializers.LimitAmounts) models.LimitAmounts {
+	return models.LimitAmounts{
+		PerTransaction: input.PerTransaction,
+		Daily:          input.Daily,
+		Monthly:        input.Monthly,
+		Lifetime:       input.Lifetime,
+	}
+}
+
+func applyLimitRule(rule *models.LimitRule, input serializers.LimitRule) {
+	rule.Tier = input.Tier
+	rule.Country = strings.ToUpper(input.Country)
+	rule.Rail = input.Rail
+	rule.Direction = input.Direction
+	rule.LimitAmounts = limitAmounts(input.LimitAmounts)
+}
+
+func GetLimitRules(c *gin.Context) {
+	rules, err := models.GetLimitRules()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "fetched limit rules", "data": rules, "errors": false})
+}
+
+func CreateLimitRule(c *gin.Context) {
+	var input serializers.LimitRule
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequest(c, err, "invalid limit rule")
+		return
+	}
+	var rule models.LimitRule
+	applyLimitRule(&rule, input)
+	if err := rule.CreateLimitRule(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"status": "limit rule created", "data": rule, "errors": false})
+}
+
+func limitRule(c *gin.Context) (*models.LimitRule, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, err, "invalid limit rule id")
+		return nil, false
+	}
+	rule, err := models.GetLimitRule(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return nil, false
+	}
+	return rule, true
+}
+
+func UpdateLimitRule(c *gin.Context) {
+	rule, ok := limitRule(c)
+	if !ok {
+		return
+	}
+	var input serializers.LimitRule
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequest(c, err, "invalid limit rule")
+		return
+	}
+	applyLimitRule(rule, input)
+	if err := rule.UpdateLimitRule(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "limit rule updated", "data": rule, "errors": false})
+}
+
+func DeleteLimitRule(c *gin.Context) {
+	rule, ok := limitRule(c)
+	if !ok {
+		return
+	}
+	if err := rule.DeleteLimitRule(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "limit rule deleted", "errors": false})
+}
+
+func limitUser(c *gin.Context) (models.User, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, err, "invalid user id")
+		return models.User{}, false
+	}
+	if !inTenant(c, uint(id)) {
+		return models.User{}, false
+	}
+	user, err := models.GetUserByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return models.User{}, false
+	}
+	return user, true
+}
+
+// GetUserLimits shows an admin a user's effective limits and overrides
+func GetUserLimits(c *gin.Context) {
+	user, ok := limitUser(c)
+	if !ok {
+		return
+	}
+	rail, direction, ok := limitScope(c)
+	if !ok {
+		return
+	}
+
+	statuses, err := limitStatuses(user, rail, direction)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	overrides, err := models.GetUserLimitOverrides(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": "fetched user limits",
+		"data":   gin.H{"limits": statuses, "overrides": overrides},
+		"errors": false,
+	})
+}
+
+// SetUserLimitOverride replaces the user's override on the rail and direction
+func SetUserLimitOverride(c *gin.Context) {
+	adminID, err := tokens.ExtractUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := limitUser(c)
+	if !ok {
+		return
+	}
+	var input serializers.LimitOverride
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequest(c, err, "invalid limit override")
+		return
+	}
+
+	override := models.LimitOverride{
+		UserID:       user.ID,
+		Rail:         input.Rail,
+		Direction:    input.Direction,
+		Reason:       input.Reason,
+		CreatedByID:  adminID,
+		ExpiresAt:    input.ExpiresAt,
+		LimitAmounts: limitAmounts(input.LimitAmounts),
+	}
+	// only platform admins may raise a user above the platform limits
+	if tenancy.TenantID(c) != 0 {
+		if err := limits.CapOverride(user, override); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if err := override.SaveLimitOverride(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "limit override saved", "data": override, "errors": false})
+}
+
+func DeleteUserLimitOverride(c *gin.Context) {
+	user, ok := limitUser(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("overrideId"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, err, "invalid override id")
+		return
+	}
+	override, err := models.GetLimitOverride(uint(id))
+	if err != nil || override.UserID != user.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return
+	}
+	if err := override.DeleteLimitOverride(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "limit override deleted", "errors": false})
+}
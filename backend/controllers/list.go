@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"backend/models"
+	"backend/utils"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// listQuery reads the params shared by list endpoints: cursor, limit, sort
+// ("-created_at" for newest first), from and to (YYYY-MM-DD, to inclusive),
+// min_amount, max_amount, status (comma separated or repeated) and q
+func listQuery(c *gin.Context) (models.ListQuery, bool) {
+	lq := models.ListQuery{
+		Cursor: c.Query("cursor"),
+		Sort:   c.Query("sort"),
+		Search: strings.TrimSpace(c.Query("q")),
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed < 1 || parsed > models.MaxListLimit {
+			utils.BadRequest(c, errors.New("invalid limit"), "limit must be between 1 and "+strconv.Itoa(models.MaxListLimit))
+			return lq, false
+		}
+		lq.Limit = parsed
+	}
+
+	if from := c.Query("from"); from != "" {
+		parsed, err := time.Parse(reportDateLayout, from)
+		if err != nil {
+			utils.BadRequest(c, err, "from must be a YYYY-MM-DD date")
+			return lq, false
+		}
+		lq.From = parsed
+	}
+	if to := c.Query("to"); to != "" {
+		parsed, err := time.Parse(reportDateLayout, to)
+		if err != nil {
+			utils.BadRequest(c, err, "to must be a YYYY-MM-DD date")
+			return lq, false
+		}
+		lq.To = parsed.AddDate(0, 0, 1)
+	}
+
+	for param, dst := range map[string]**float64{"min_amount": &lq.MinAmount, "max_amount": &lq.MaxAmount} {
+		if value := c.Query(param); value != "" {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				utils.BadRequest(c, err, param+" must be a number")
+				return lq, false
+			}
+			*dst = &parsed
+		}
+	}
+
+	for _, status := range c.QueryArray("status") {
+		for _, s := range strings.Split(status, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				lq.Statuses = append(lq.Statuses, s)
+			}
+		}
+	}
+	return lq, true
+}
+
+// respondList writes the list envelope every list endpoint shares
+func respondList(c *gin.Context, status string, data interface{}, info models.PageInfo) {
+	c.JSON(http.StatusOK, gin.H{
+		"errors": false,
+		"status": status,
+		"data":   data,
+		"meta":   info,
+	})
+}
+
+// listError answers a failed list call, bad cursors and sorts are the
+// caller's fault
+func listError(c *gin.Context, err error) {
+	if errors.Is(err, models.ErrInvalidCursor) || errors.Is(err, models.ErrInvalidSort) {
+		utils.BadRequest(c, err, "invalid list query")
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
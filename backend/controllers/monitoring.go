@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"backend/models"
+	"backend/serializers"
+	"backend/utils"
+	"backend/utils/monitoring"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBacktestRange keeps a backtest to a quarter of requests
+const maxBacktestRange = 92 * 24 * time.Hour
+
+func applyMonitoringRule(rule *models.MonitoringRule, input serializers.MonitoringRule) {
+	rule.Name = input.Name
+	rule.Type = input.Type
+	rule.Enabled = input.Enabled == nil || *input.Enabled
+	rule.Priority = input.Priority
+	rule.Blocking = input.Blocking
+	rule.Description = input.Description
+	rule.Params = models.MonitoringParams(input.Params)
+}
+
+func monitoringRule(c *gin.Context) (*models.MonitoringRule, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, err, "invalid monitoring rule id")
+		return nil, false
+	}
+	rule, err := models.GetMonitoringRule(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return nil, false
+	}
+	return rule, true
+}
+
+func GetMonitoringRules(c *gin.Context) {
+	rules, err := models.GetMonitoringRules()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "fetched monitoring rules", "data": rules, "errors": false})
+}
+
+func CreateMonitoringRule(c *gin.Context) {
+	var input serializers.MonitoringRule
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequest(c, err, "invalid monitoring rule")
+		return
+	}
+	var rule models.MonitoringRule
+	applyMonitoringRule(&rule, input)
+	if err := rule.Validate(); err != nil {
+		utils.BadRequest(c, err, err.Error())
+		return
+	}
+	if err := rule.CreateMonitoringRule(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"status": "monitoring rule created", "data": rule, "errors": false})
+}
+
+func UpdateMonitoringRule(c *gin.Context) {
+	rule, ok := monitoringRule(c)
+	if !ok {
+		return
+	}
+	var input serializers.MonitoringRule
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequest(c, err, "invalid monitoring rule")
+		return
+	}
+	applyMonitoringRule(rule, input)
+	if err := rule.Validate(); err != nil {
+		utils.BadRequest(c, err, err.Error())
+		return
+	}
+	if err := rule.UpdateMonitoringRule(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "monitoring rule updated", "data": rule, "errors": false})
+}
+
+func DeleteMonitoringRule(c *gin.Context) {
+	rule, ok := monitoringRule(c)
+	if !ok {
+		return
+	}
+	if err := rule.DeleteMonitoringRule(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "monitoring rule deleted", "errors": false})
+}
+
+// BacktestMonitoringRule runs the stored rule, or the rule in the body when
+// there is no id, over past requests without raising alerts
+func BacktestMonitoringRule(c *gin.Context) {
+	var input serializers.Backtest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequest(c, err, "invalid backtest")
+		return
+	}
+
+	var rule models.MonitoringRule
+	if c.Param("id") != "" {
+		stored, ok := monitoringRule(c)
+		if !ok {
+			return
+		}
+		rule = *stored
+	} else {
+		if input.Rule == nil {
+			utils.BadRequest(c, errors.New("missing rule"), "rule is required")
+			return
+		}
+		applyMonitoringRule(&rule, *input.Rule)
+		if err := rule.Validate(); err != nil {
+			utils.BadRequest(c, err, err.Error())
+			return
+		}
+	}
+
+	from, _ := time.Parse(reportDateLayout, input.From)
+	to, _ := time.Parse(reportDateLayout, input.To)
+	to = to.AddDate(0, 0, 1)
+	if !to.After(from) {
+		utils.BadRequest(c, errors.New("invalid date range"), "to must not be before from")
+		return
+	}
+	if to.Sub(from) > maxBacktestRange {
+		utils.BadRequest(c, errors.New("date range too long"), "a backtest covers at most 92 days")
+		return
+	}
+
+	result, err := monitoring.Backtest(rule, from, to, 0)
+	if err != nil {
+		if errors.Is(err, monitoring.ErrBacktestTooLarge) {
+			utils.BadRequest(c, err, err.Error())
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "backtested monitoring rule", "data": result, "errors": false})
+}
+
+// GetMonitoringAlerts lists alerts, filtered by rule_id, user_id and
+// rule_type
+func GetMonitoringAlerts(c *gin.Context) {
+	lq, ok := listQuery(c)
+	if !ok {
+		return
+	}
+	ruleID, ok := queryID(c, "rule_id")
+	if !ok {
+		return
+	}
+	userID, ok := queryID(c, "user_id")
+	if !ok {
+		return
+	}
+	ruleType := c.Query("rule_type")
+	if ruleType != "" && !slices.Contains(models.MonitoringRuleTypes, ruleType) {
+		utils.BadRequest(c, errors.New("unknown rule type: "+ruleType), "unknown rule_type")
+		return
+	}
+
+	alerts, info, err := models.FilterMonitoringAlerts(ruleID, userID, ruleType, lq)
+	if err != nil {
+		listError(c, err)
+		return
+	}
+	respondList(c, "fetched monitoring alerts", alerts, info)
+}
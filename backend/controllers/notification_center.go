@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"backend/models"
+	"backend/serializers"
+	"backend/utils"
+	"backend/utils/tokens"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultNotificationPageSize = 20
+	maxNotificationPageSize     = 100
+)
+
+func GetNotifications(c *gin.Context) {
+	userID, ok := notificationUser(c)
+	if !ok {
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(defaultNotificationPageSize)))
+	if err != nil || pageSize < 1 || pageSize > maxNotificationPageSize {
+		pageSize = defaultNotificationPageSize
+	}
+	unreadOnly := c.Query("unread") == "true"
+
+	notifications, total, err := models.GetUserNotifications(userID, unreadOnly, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "fetched notifications",
+		"data":   notifications,
+		"meta":   gin.H{"page": page, "page_size": pageSize, "total": total},
+		"errors": false,
+	})
+}
+
+func GetUnreadNotificationCount(c *gin.Context) {
+	userID, ok := notificationUser(c)
+	if !ok {
+		return
+	}
+
+	count, err := models.CountUnreadNotifications(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"unread": count}, "errors": false})
+}
+
+func MarkNotificationRead(c *gin.Context) {
+	userID, ok := notificationUser(c)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, err, "invalid notification id")
+		return
+	}
+	notification, err := models.GetUserNotification(userID, uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "notification not found"})
+		return
+	}
+	if err := notification.MarkAsRead(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": notification, "errors": false})
+}
+
+func MarkAllNotificationsRead(c *gin.Context) {
+	userID, ok := notificationUser(c)
+	if !ok {
+		return
+	}
+
+	updated, err := models.MarkAllNotificationsRead(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"updated": updated}, "errors": false})
+}
+
+func GetNotificationPreferences(c *gin.Context) {
+	userID, ok := notificationUser(c)
+	if !ok {
+		return
+	}
+	notificationPreferencesResponse(c, userID)
+}
+
+func UpdateNotificationPreferences(c *gin.Context) {
+	userID, ok := notificationUser(c)
+	if !ok {
+		return
+	}
+
+	var input serializers.NotificationPreferences
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequest(c, err, "invalid request payload")
+		return
+	}
+	if input.Events == nil {
+		input.Events = input.Email
+	}
+	if input.Events == nil && input.Channels == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "events or channels is required"})
+		return
+	}
+	if input.Events != nil {
+		if err := models.SetNotificationPreferences(userID, input.Events); err != nil {
+			utils.BadRequest(c, err, "updating preferences failed")
+			return
+		}
+	}
+	if input.Channels != nil {
+		if err := models.SetNotificationChannels(userID, input.Channels); err != nil {
+			utils.BadRequest(c, err, "updating channels failed")
+			return
+		}
+	}
+	notificationPreferencesResponse(c, userID)
+}
+
+func notificationPreferencesResponse(c *gin.Context, userID uint) {
+	preferences, err := models.GetNotificationPreferences(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"events":   preferences,
+			"email":    preferences, // old name of events
+			"channels": models.GetNotificationChannels(userID),
+		},
+		"errors": false,
+	})
+}
+
+func notificationUser(c *gin.Context) (uint, bool) {
+	userID, err := tokens.ExtractUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return 0, false
+	}
+	return userID, true
+}
@@ -0,0 +1,691 @@
+package controllers
+
+import (
+	"backend/apis"
+	"backend/models"
+	"backend/serializers"
+	"backend/utils"
+	"backend/utils/documents"
+	"backend/utils/limits"
+	"backend/utils/monitoring"
+	"backend/utils/openapi"
+	"backend/utils/screening"
+	"net/http"
+	"sort"
+)
+
+// APIDocs annotates every handler for the OpenAPI spec. Request and Response
+// take a value of the body's and the response data's type, a Response is only
+// given where the handler always answers with that type since the contract
+// check holds responses to it.
+var APIDocs = openapi.Annotations{
+	// docs
+	openapi.For(GetAPISpec, openapi.Operation{Auth: openapi.AuthNone, Hidden: true}),
+	openapi.For(GetAPIDocs, openapi.Operation{Auth: openapi.AuthNone, Hidden: true}),
+
+	// reference data
+	openapi.For(FetchChain, openapi.Operation{
+		Summary: "List supported chains", Auth: openapi.AuthNone,
+		Response: []serializers.Data{},
+	}),
+	openapi.For(FetchNetwork, openapi.Operation{
+		Summary: "List mobile money networks", Auth: openapi.AuthNone,
+		Response: []serializers.NetworkData{},
+	}),
+	openapi.For(GetExchangeRate, openapi.Operation{
+		Summary: "Get the exchange rate of an asset", Auth: openapi.AuthNone,
+		Query:    []openapi.Param{openapi.Q("fiat_currency", "e.g. NGN"), openapi.Q("asset", "e.g. USDC")},
+		Response: "",
+	}),
+	openapi.For(GetMasterWallets, openapi.Operation{
+		Summary: "List master wallets", Auth: openapi.AuthNone,
+		Response: []models.MasterWallet{},
+	}),
+	openapi.For(GetMasterWallet, openapi.Operation{
+		Summary: "Get the master wallet of a chain", Auth: openapi.AuthNone,
+		Query:    []openapi.Param{openapi.Q("asset", "chain of the wallet")},
+		Response: models.MasterWallet{},
+	}),
+
+	// user
+	openapi.For(FetchAuthenticatedUserToken, openapi.Operation{
+		Summary: "Log in", Auth: openapi.AuthNone,
+		Request: serializers.LoginSerializer{}, Response: map[string]string{},
+	}),
+	openapi.For(ForgetPassword, openapi.Operation{
+		Summary: "Send a password reset link", Auth: openapi.AuthNone,
+		Request: serializers.ForgetPassword{},
+	}),
+	openapi.For(ResetPassword, openapi.Operation{
+		Summary: "Reset the password with a mailed token", Auth: openapi.AuthNone,
+		Request: serializers.ResetPassword{},
+	}),
+	openapi.For(UnlockAccount, openapi.Operation{
+		Summary: "Unlock an account locked after failed logins", Auth: openapi.AuthNone,
+		Request: serializers.UnlockAccount{},
+	}),
+	openapi.For(CreateAccountV2, openapi.Operation{
+		Summary: "Register", Auth: openapi.AuthNone,
+		Request: serializers.User{},
+	}),
+	openapi.For(VerifyEmail, openapi.Operation{
+		Summary: "Verify the email address with a mailed token", Auth: openapi.AuthNone,
+		Request: serializers.VerifyEmail{},
+	}),
+	openapi.For(ResendVerificationEmail, openapi.Operation{
+		Summary: "Resend the verification email", Auth: openapi.AuthNone,
+		Request: serializers.ResendVerification{},
+	}),
+	openapi.For(CreateBorderlessVirtualAccount, openapi.Operation{
+		Summary: "Open a virtual bank account",
+		Request: serializers.UserAccountRequest{},
+	}),
+	openapi.For(GetUserAccounts, openapi.Operation{
+		Summary:  "List the user's virtual accounts",
+		Response: []models.UserAccounts{},
+	}),
+	openapi.For(FilterUserAccounts, openapi.Operation{
+		Summary: "Filter virtual accounts", List: true,
+		QueryStruct: serializers.UserAccountsFilter{}, Response: []models.UserAccounts{},
+	}),
+	openapi.For(GetAuthenticatedUser, openapi.Operation{
+		Summary:  "Get the authenticated user and their balances",
+		Response: map[string]interface{}{},
+	}),
+	openapi.For(UpdateUserLocale, openapi.Operation{
+		Summary: "Set the user's locale",
+		Request: serializers.UpdateLocale{},
+	}),
+	openapi.For(MakeAdmin, openapi.Operation{
+		Summary: "Make a user admin",
+		Request: serializers.AdminForm{},
+	}),
+
+	// kyc
+	openapi.For(GetUserKYC, openapi.Operation{Summary: "Get the user's KYC"}),
+	openapi.For(GetKYCTiers, openapi.Operation{Summary: "List KYC tiers and where the user stands"}),
+	openapi.For(GetKYCDocuments, openapi.Operation{
+		Summary:  "List signed links to the user's KYC documents",
+		Response: []documents.SignedURL{},
+	}),
+	openapi.For(CreateKYC, openapi.Operation{
+		Summary: "Submit KYC", Status: http.StatusCreated,
+		Form: append([]openapi.Param{
+			{Name: "email", Required: true},
+			{Name: "tax_id", Required: true},
+			{Name: "date_of_birth", Required: true},
+			{Name: "phone", Required: true},
+			{Name: "street_address", Required: true},
+			{Name: "city", Required: true},
+			{Name: "state", Required: true},
+			{Name: "postal_code", Required: true},
+			{Name: "country", Required: true, Description: "ISO 3166-1 alpha-2"},
+		}, kycDocumentParams()...),
+		Response: []serializers.KYCTier{},
+	}),
+	openapi.For(UpdateKYC, openapi.Operation{
+		Summary: "Update KYC or submit a higher tier",
+		Form: append([]openapi.Param{
+			{Name: "email", Required: true},
+			{Name: "tier", Type: "integer", Description: "1 to 3"},
+		}, kycDocumentParams()...),
+	}),
+	openapi.For(DeleteKYC, openapi.Operation{Summary: "Delete a KYC"}),
+	openapi.For(GetKYCS, openapi.Operation{
+		Summary: "Filter KYCs", List: true,
+		QueryStruct: serializers.KYCFilterRequest{}, Response: []serializers.KYC{},
+	}),
+	openapi.For(ApproveKYC, openapi.Operation{
+		Summary:  "Approve a KYC",
+		Response: []serializers.KYCLevel{},
+	}),
+	openapi.For(RejectKYC, openapi.Operation{
+		Summary: "Reject a KYC",
+		Request: serializers.RejectKYC{}, Response: []serializers.KYCLevel{},
+	}),
+	openapi.For(GetUserKYCDocuments, openapi.Operation{
+		Summary:  "List signed links to a user's KYC documents",
+		Response: []documents.SignedURL{},
+	}),
+	openapi.For(GetKYCDocumentAccessLogs, openapi.Operation{
+		Summary:  "List who viewed a KYC document",
+		Response: []models.KYCDocumentAccess{},
+	}),
+	openapi.For(ServeKYCDocument, openapi.Operation{
+		Summary: "Download a KYC document through a signed link", Auth: openapi.AuthNone,
+		Query: []openapi.Param{
+			{Name: "viewer", Required: true, Type: "string"},
+			{Name: "expires", Required: true, Type: "string"},
+			{Name: "signature", Required: true, Type: "string"},
+		},
+		Produces: "application/octet-stream",
+	}),
+
+	// transactions
+	openapi.For(RetrieveOnRampParamsV1, openapi.Operation{
+		Summary:  "Get the deposit details of the user's chain",
+		Response: map[string]interface{}{},
+	}),
+	openapi.For(GetUserTransactions, openapi.Operation{
+		Summary: "List the user's transactions", List: true,
+		Response: []models.Transaction{},
+	}),
+	openapi.For(GetTransactionsByHash, openapi.Operation{
+		Summary:  "Get a transaction by hash",
+		Query:    []openapi.Param{openapi.Q("hash", ""), openapi.Q("chain", "")},
+		Response: models.Transaction{},
+	}),
+	openapi.For(OffRampTransaction, openapi.Operation{
+		Summary: "Send crypto to an address", Idempotent: true,
+		Request: serializers.OffRampForm{},
+	}),
+	openapi.For(SignUrl, openapi.Operation{
+		Summary: "Sign an upload URL",
+		Request: serializers.SignUrl{},
+	}),
+	openapi.For(AmountToReceive, openapi.Operation{
+		Summary:  "Quote a bank on or off ramp",
+		Query:    quoteParams,
+		Response: map[string]string{},
+	}),
+	openapi.For(GetDestinationBankAccount, openapi.Operation{
+		Summary:  "Get the bank account to deposit to",
+		Query:    []openapi.Param{openapi.Q("countryCode", "")},
+		Response: serializers.Bank{},
+	}),
+	openapi.For(GenerateReference, openapi.Operation{
+		Summary:  "Generate a deposit reference",
+		Response: map[string]string{},
+	}),
+	openapi.For(OnRampV2, openapi.Operation{
+		Summary: "Request a bank on ramp", Idempotent: true,
+		Request: serializers.OnRamp{}, Response: models.DepositRequest{},
+	}),
+	openapi.For(OffRampV2, openapi.Operation{
+		Summary: "Request a bank off ramp", Idempotent: true,
+		Request: serializers.OffRamp{}, Response: models.WithdrawalRequest{},
+	}),
+	openapi.For(MobileMoneyAmountToReceive, openapi.Operation{
+		Summary:  "Quote a mobile money on ramp",
+		Query:    quoteParams,
+		Response: map[string]string{},
+	}),
+	openapi.For(MobileMoneyOnRamp, openapi.Operation{
+		Summary: "Request a mobile money on ramp", Idempotent: true,
+		Request: serializers.Payment{}, Response: apis.MobileMoneyResponse{},
+	}),
+	openapi.For(MobileMoneyOffRamp, openapi.Operation{
+		Summary: "Request a mobile money off ramp", Idempotent: true,
+		Request: serializers.MobileOffRamp{},
+	}),
+
+	// requests
+	openapi.For(FetchOnRampRequests, openapi.Operation{
+		Summary: "Filter on ramp requests", List: true,
+		Query: []openapi.Param{
+			openapi.Q("ref", ""), openapi.Q("currency", ""), openapi.Q("account_number", ""),
+			openapi.Q("country_code", ""), openapi.Q("crypto_asset", ""),
+			{Name: "fiat_amount", Type: "number"},
+		},
+		Response: []models.DepositRequestItem{},
+	}),
+	openapi.For(FetchOffRampRequests, openapi.Operation{
+		Summary: "Filter off ramp requests", List: true,
+		Query: []openapi.Param{
+			openapi.Q("chain", ""), openapi.Q("hash", ""), openapi.Q("address", ""), openapi.Q("account_number", ""),
+		},
+		Response: []models.WithdrawalRequestItem{},
+	}),
+	openapi.For(GetOnRampRequest, openapi.Operation{
+		Summary:  "Get an on ramp request",
+		Response: models.DepositRequest{},
+	}),
+	openapi.For(GetOffRampRequest, openapi.Operation{
+		Summary:  "Get an off ramp request",
+		Response: models.WithdrawalRequest{},
+	}),
+	openapi.For(VerifyOnRamp, openapi.Operation{
+		Summary: "Approve or reject an on ramp request",
+		Request: serializers.OnRampAction{}, Response: models.DepositRequest{},
+	}),
+	openapi.For(VerifyOffRamp, openapi.Operation{
+		Summary: "Approve or reject an off ramp request",
+		Request: serializers.OffRampAction{},
+	}),
+	openapi.For(ListHurupayRequest, openapi.Operation{
+		Summary: "Filter mobile money requests", List: true,
+		Query: []openapi.Param{openapi.Q("request_type", "")},
+	}),
+	openapi.For(GetHurupayRequest, openapi.Operation{Summary: "Get a mobile money request"}),
+	openapi.For(GetHurupayStats, openapi.Operation{
+		Summary:  "Count mobile money requests by status",
+		Response: map[string]int64{},
+	}),
+	openapi.For(GetVolumeReport, openapi.Operation{
+		Summary:  "Report volume",
+		Query:    append([]openapi.Param{openapi.Q("group_by", "day, week or month")}, reportParams...),
+		Response: []models.VolumeRow{},
+	}),
+	openapi.For(GetFunnelReport, openapi.Operation{
+		Summary: "Report the request funnel", Query: reportParams,
+		Response: []models.FunnelRow{},
+	}),
+	openapi.For(GetSettlementReport, openapi.Operation{
+		Summary: "Report settlement times", Query: reportParams,
+		Response: []models.SettlementRow{},
+	}),
+	openapi.For(GetFeeReport, openapi.Operation{
+		Summary: "Report fees", Query: reportParams,
+		Response: []models.FeeRow{},
+	}),
+
+	// payments
+	openapi.For(FilterBank, openapi.Operation{
+		Summary:  "List banks of a country",
+		Query:    []openapi.Param{openapi.Q("country", "")},
+		Response: []models.Bank{},
+	}),
+	openapi.For(BorderLessOnramp, openapi.Operation{
+		Summary: "Deposit through Borderless", Idempotent: true,
+		Request: serializers.BorderlessOnramp{}, Response: map[string]interface{}{},
+	}),
+	openapi.For(BorderLessOffRamp, openapi.Operation{
+		Summary: "Withdraw through Borderless", Idempotent: true,
+		Request: serializers.MakeWithdrawalBorderless{}, Response: map[string]interface{}{},
+	}),
+	openapi.For(BorderlessMobileMoneyOnRamp, openapi.Operation{
+		Summary: "Deposit mobile money through Borderless", Idempotent: true,
+		Request: serializers.BorderlessOnramp{}, Response: map[string]interface{}{},
+	}),
+	openapi.For(BorderlessMobileMoneyOffRamp, openapi.Operation{
+		Summary: "Withdraw to mobile money through Borderless", Idempotent: true,
+		Request: serializers.MakeWithdrawalBorderless{}, Response: map[string]interface{}{},
+	}),
+
+	// webhook
+	openapi.For(OnRampNotification, openapi.Operation{
+		Summary: "On ramp callback from the provider", Auth: openapi.AuthNone,
+		Request: serializers.Event{},
+	}),
+	openapi.For(OffRampNotification, openapi.Operation{
+		Summary: "Off ramp callback from the provider", Auth: openapi.AuthNone,
+		Request: serializers.Event{},
+	}),
+	openapi.For(BorderlessNotification, openapi.Operation{
+		Summary: "Borderless event", Auth: openapi.AuthSignature,
+		Request: utils.WebhookEvent{},
+	}),
+	openapi.For(CreateWebhookEndpoint, openapi.Operation{
+		Summary: "Register a webhook endpoint", Status: http.StatusCreated,
+		Description: "The signing secret is returned once, in secret.",
+		Request:     serializers.CreateWebhookEndpoint{}, Response: models.WebhookEndpoint{},
+	}),
+	openapi.For(GetWebhookEndpoints, openapi.Operation{
+		Summary:  "List webhook endpoints and the events they can subscribe to",
+		Response: []models.WebhookEndpoint{},
+	}),
+	openapi.For(GetWebhookEndpoint, openapi.Operation{
+		Summary:  "Get a webhook endpoint",
+		Response: models.WebhookEndpoint{},
+	}),
+	openapi.For(UpdateWebhookEndpoint, openapi.Operation{
+		Summary: "Update a webhook endpoint",
+		Request: serializers.UpdateWebhookEndpoint{}, Response: models.WebhookEndpoint{},
+	}),
+	openapi.For(DeleteWebhookEndpoint, openapi.Operation{Summary: "Delete a webhook endpoint"}),
+	openapi.For(RotateWebhookSecret, openapi.Operation{
+		Summary:  "Rotate a webhook endpoint's signing secret",
+		Response: models.WebhookEndpoint{},
+	}),
+	openapi.For(GetWebhookDeliveries, openapi.Operation{
+		Summary: "List deliveries to a webhook endpoint",
+		Query: []openapi.Param{
+			openapi.Q("status", ""),
+			{Name: "limit", Type: "integer", Description: "50 by default"},
+		},
+		Response: []models.WebhookDelivery{},
+	}),
+	openapi.For(GetWebhookDelivery, openapi.Operation{
+		Summary:  "Get a delivery and its attempts",
+		Response: models.WebhookDelivery{},
+	}),
+	openapi.For(RedeliverWebhook, openapi.Operation{
+		Summary: "Queue a delivery again", Status: http.StatusCreated,
+		Response: models.WebhookDelivery{},
+	}),
+
+	// partners
+	openapi.For(CreatePartner, openapi.Operation{
+		Summary: "Create a partner", Status: http.StatusCreated,
+		Request: serializers.CreatePartner{}, Response: models.Partner{},
+	}),
+	openapi.For(GetPartners, openapi.Operation{
+		Summary:  "List partners",
+		Response: []models.Partner{},
+	}),
+	openapi.For(GetPartner, openapi.Operation{
+		Summary:  "Get a partner",
+		Response: models.Partner{},
+	}),
+	openapi.For(UpdatePartnerStatus, openapi.Operation{
+		Summary: "Activate or suspend a partner",
+		Request: serializers.UpdatePartnerStatus{}, Response: models.Partner{},
+	}),
+	openapi.For(CreatePartnerAPIKey, openapi.Operation{
+		Summary: "Issue a partner API key", Status: http.StatusCreated,
+		Description: "The key's secret is returned once, in secret.",
+		Request:     serializers.PartnerAPIKeyRequest{}, Response: models.PartnerAPIKey{},
+	}),
+	openapi.For(RotatePartnerAPIKey, openapi.Operation{
+		Summary: "Replace a partner API key", Status: http.StatusCreated,
+		Response: models.PartnerAPIKey{},
+	}),
+	openapi.For(UpdatePartnerAPIKeyAccess, openapi.Operation{
+		Summary: "Change a partner API key's scopes and allowed IPs",
+		Request: serializers.PartnerAPIKeyAccess{}, Response: models.PartnerAPIKey{},
+	}),
+	openapi.For(RevokePartnerAPIKey, openapi.Operation{Summary: "Revoke a partner API key"}),
+	openapi.For(CreatePartnerUser, openapi.Operation{
+		Summary: "Create a sub-user", Status: http.StatusCreated,
+		Request: serializers.PartnerUser{}, Response: models.User{},
+	}),
+	openapi.For(ListPartnerUsers, openapi.Operation{
+		Summary:  "List sub-users",
+		Response: []models.User{},
+	}),
+	openapi.For(GetPartnerUser, openapi.Operation{
+		Summary:  "Get a sub-user",
+		Response: models.User{},
+	}),
+
+	// notifications
+	openapi.For(GetNotifications, openapi.Operation{
+		Summary: "List the user's notifications",
+		Query: []openapi.Param{
+			{Name: "page", Type: "integer"},
+			{Name: "page_size", Type: "integer"},
+			{Name: "unread", Type: "boolean"},
+		},
+		Response: []models.Notification{},
+	}),
+	openapi.For(GetUnreadNotificationCount, openapi.Operation{Summary: "Count unread notifications"}),
+	openapi.For(MarkAllNotificationsRead, openapi.Operation{Summary: "Mark every notification read"}),
+	openapi.For(MarkNotificationRead, openapi.Operation{
+		Summary:  "Mark a notification read",
+		Response: models.Notification{},
+	}),
+	openapi.For(GetNotificationPreferences, openapi.Operation{Summary: "Get notification channel preferences"}),
+	openapi.For(UpdateNotificationPreferences, openapi.Operation{
+		Summary: "Set notification channel preferences",
+		Request: serializers.NotificationPreferences{},
+	}),
+	openapi.For(StreamEvents, openapi.Operation{
+		Summary: "Stream model changes as server-sent events", Produces: "text/event-stream",
+	}),
+	openapi.For(StreamEventsWebSocket, openapi.Operation{
+		Summary: "Stream model changes over a WebSocket", Status: http.StatusSwitchingProtocols,
+	}),
+
+	// statements
+	openapi.For(CreateStatementExport, openapi.Operation{
+		Summary: "Queue a statement export", Status: http.StatusAccepted,
+		Request: serializers.CreateStatement{}, Response: models.StatementExport{},
+	}),
+	openapi.For(GetStatementExports, openapi.Operation{
+		Summary:  "List the user's statement exports",
+		Response: []models.StatementExport{},
+	}),
+	openapi.For(GetStatementExport, openapi.Operation{
+		Summary:  "Get a statement export",
+		Response: models.StatementExport{},
+	}),
+	openapi.For(DownloadStatementExport, openapi.Operation{
+		Summary: "Download a statement export", Produces: "application/octet-stream",
+	}),
+	openapi.For(DownloadStatementByToken, openapi.Operation{
+		Summary: "Download a statement export through the mailed link", Auth: openapi.AuthNone,
+		Produces: "application/octet-stream",
+	}),
+	openapi.For(CreateAdminStatementExport, openapi.Operation{
+		Summary: "Queue a statement export for the tenant or a user", Status: http.StatusAccepted,
+		Request: serializers.CreateAdminStatement{}, Response: models.StatementExport{},
+	}),
+	openapi.For(GetAdminStatementExports, openapi.Operation{
+		Summary:  "List the tenant's statement exports",
+		Response: []models.StatementExport{},
+	}),
+	openapi.For(GetAdminStatementExport, openapi.Operation{
+		Summary:  "Get a statement export of the tenant",
+		Response: models.StatementExport{},
+	}),
+	openapi.For(DownloadAdminStatementExport, openapi.Operation{
+		Summary: "Download a statement export of the tenant", Produces: "application/octet-stream",
+	}),
+
+	// beneficiaries
+	openapi.For(GetBeneficiaries, openapi.Operation{
+		Summary: "List saved beneficiaries and the allowlist setting",
+		Query:   []openapi.Param{openapi.Q("kind", "bank, mobile_money or crypto")},
+	}),
+	openapi.For(CreateBeneficiary, openapi.Operation{
+		Summary: "Save a beneficiary, confirmed by email", Status: http.StatusCreated,
+		Request: serializers.Beneficiary{}, Response: models.Beneficiary{},
+	}),
+	openapi.For(ConfirmBeneficiary, openapi.Operation{
+		Summary: "Confirm a beneficiary with the mailed token",
+		Request: serializers.ConfirmBeneficiary{}, Response: models.Beneficiary{},
+	}),
+	openapi.For(UpdateAllowlist, openapi.Operation{
+		Summary: "Restrict payouts to saved beneficiaries",
+		Request: serializers.Allowlist{},
+	}),
+	openapi.For(ResendBeneficiaryConfirmation, openapi.Operation{Summary: "Resend a beneficiary's confirmation email"}),
+	openapi.For(DeleteBeneficiary, openapi.Operation{Summary: "Delete a beneficiary"}),
+
+	// limits
+	openapi.For(GetLimits, openapi.Operation{
+		Summary: "Get the user's limits and usage", Query: limitParams,
+		Response: []*limits.Status{},
+	}),
+	openapi.For(GetUserLimits, openapi.Operation{
+		Summary: "Get a user's limits, usage and overrides", Query: limitParams,
+	}),
+	openapi.For(SetUserLimitOverride, openapi.Operation{
+		Summary: "Override a limit for a user",
+		Request: serializers.LimitOverride{}, Response: models.LimitOverride{},
+	}),
+	openapi.For(DeleteUserLimitOverride, openapi.Operation{Summary: "Remove a user's limit override"}),
+	openapi.For(GetLimitRules, openapi.Operation{
+		Summary:  "List limit rules",
+		Response: []models.LimitRule{},
+	}),
+	openapi.For(CreateLimitRule, openapi.Operation{
+		Summary: "Create a limit rule", Status: http.StatusCreated,
+		Request: serializers.LimitRule{}, Response: models.LimitRule{},
+	}),
+	openapi.For(UpdateLimitRule, openapi.Operation{
+		Summary: "Update a limit rule",
+		Request: serializers.LimitRule{}, Response: models.LimitRule{},
+	}),
+	openapi.For(DeleteLimitRule, openapi.Operation{Summary: "Delete a limit rule"}),
+
+	// admin users
+	openapi.For(GetUserStatus, openapi.Operation{Summary: "Get a user's status and its history"}),
+	openapi.For(UpdateUserStatus, openapi.Operation{
+		Summary: "Freeze, suspend or reactivate a user",
+		Request: serializers.UpdateUserStatus{}, Response: models.User{},
+	}),
+	openapi.For(CloseUserAccount, openapi.Operation{
+		Summary: "Close a user's account, sweeping the wallet",
+		Request: serializers.CloseUser{}, Response: models.AccountClosure{},
+	}),
+
+	// reconciliation
+	openapi.For(StartReconciliation, openapi.Operation{
+		Summary: "Start a reconciliation run", Status: http.StatusAccepted,
+		Request: serializers.StartReconciliation{}, Response: models.ReconciliationRun{},
+	}),
+	openapi.For(GetReconciliationRuns, openapi.Operation{
+		Summary:  "List reconciliation runs",
+		Query:    []openapi.Param{openapi.Q("source", "")},
+		Response: []models.ReconciliationRun{},
+	}),
+	openapi.For(GetReconciliationRun, openapi.Operation{
+		Summary:  "Get a reconciliation run",
+		Response: models.ReconciliationRun{},
+	}),
+	openapi.For(GetReconciliationItems, openapi.Operation{
+		Summary: "Filter reconciliation items", List: true,
+		Query: []openapi.Param{
+			{Name: "run_id", Type: "integer"}, openapi.Q("source", ""), openapi.Q("classification", ""),
+		},
+		Response: []models.ReconciliationItem{},
+	}),
+	openapi.For(ResolveReconciliationItem, openapi.Operation{
+		Summary: "Resolve a reconciliation item",
+		Request: serializers.ResolveReconciliationItem{}, Response: models.ReconciliationItem{},
+	}),
+
+	// screening
+	openapi.For(GetScreenings, openapi.Operation{
+		Summary: "Filter screenings", List: true,
+		Query:    []openapi.Param{{Name: "user_id", Type: "integer"}, openapi.Q("context", "")},
+		Response: []models.Screening{},
+	}),
+	openapi.For(CheckScreening, openapi.Operation{
+		Summary: "Screen a name against the watchlists",
+		Request: serializers.CheckScreening{}, Response: []models.ScreeningMatch{},
+	}),
+	openapi.For(GetWatchlists, openapi.Operation{
+		Summary:  "List loaded watchlists",
+		Response: []models.Watchlist{},
+	}),
+	openapi.For(ReloadWatchlists, openapi.Operation{
+		Summary:  "Reload the watchlists",
+		Response: []screening.LoadResult{},
+	}),
+	openapi.For(GetScreening, openapi.Operation{
+		Summary:  "Get a screening",
+		Response: models.Screening{},
+	}),
+	openapi.For(ResolveScreening, openapi.Operation{
+		Summary: "Clear or confirm a screening hit",
+		Request: serializers.ResolveScreening{}, Response: models.Screening{},
+	}),
+
+	// monitoring
+	openapi.For(GetMonitoringRules, openapi.Operation{
+		Summary:  "List monitoring rules",
+		Response: []models.MonitoringRule{},
+	}),
+	openapi.For(CreateMonitoringRule, openapi.Operation{
+		Summary: "Create a monitoring rule", Status: http.StatusCreated,
+		Request: serializers.MonitoringRule{}, Response: models.MonitoringRule{},
+	}),
+	openapi.For(UpdateMonitoringRule, openapi.Operation{
+		Summary: "Update a monitoring rule",
+		Request: serializers.MonitoringRule{}, Response: models.MonitoringRule{},
+	}),
+	openapi.For(DeleteMonitoringRule, openapi.Operation{Summary: "Delete a monitoring rule"}),
+	openapi.For(BacktestMonitoringRule, openapi.Operation{
+		Summary: "Backtest a stored or draft monitoring rule",
+		Request: serializers.Backtest{}, Response: monitoring.BacktestResult{},
+	}),
+	openapi.For(GetMonitoringAlerts, openapi.Operation{
+		Summary: "Filter monitoring alerts", List: true,
+		Query: []openapi.Param{
+			{Name: "rule_id", Type: "integer"}, {Name: "user_id", Type: "integer"}, openapi.Q("rule_type", ""),
+		},
+		Response: []models.MonitoringAlert{},
+	}),
+
+	// compliance cases
+	openapi.For(GetComplianceCases, openapi.Operation{
+		Summary: "Filter compliance cases", List: true,
+		Query: []openapi.Param{
+			{Name: "user_id", Type: "integer"}, {Name: "transaction_id", Type: "integer"}, {Name: "assignee_id", Type: "integer"},
+			openapi.Q("source", ""), openapi.Q("priority", ""), {Name: "overdue", Type: "boolean"},
+		},
+		Response: []models.ComplianceCase{},
+	}),
+	openapi.For(CreateComplianceCase, openapi.Operation{
+		Summary: "Open a compliance case", Status: http.StatusCreated,
+		Request: serializers.CreateCase{}, Response: models.ComplianceCase{},
+	}),
+	openapi.For(GetComplianceCaseByID, openapi.Operation{
+		Summary:  "Get a compliance case",
+		Response: models.ComplianceCase{},
+	}),
+	openapi.For(AssignComplianceCase, openapi.Operation{
+		Summary: "Assign a compliance case",
+		Request: serializers.AssignCase{}, Response: models.ComplianceCase{},
+	}),
+	openapi.For(AddComplianceCaseNote, openapi.Operation{
+		Summary: "Add a note to a compliance case", Status: http.StatusCreated,
+		Request: serializers.AddCaseNote{}, Response: models.CaseNote{},
+	}),
+	openapi.For(UploadCaseAttachment, openapi.Operation{
+		Summary: "Attach a file to a compliance case", Status: http.StatusCreated,
+		Form:     []openapi.Param{{Name: "file", Required: true, Type: "file"}},
+		Response: models.CaseAttachment{},
+	}),
+	openapi.For(DownloadCaseAttachment, openapi.Operation{
+		Summary: "Download a compliance case attachment", Produces: "application/octet-stream",
+	}),
+	openapi.For(DecideComplianceCase, openapi.Operation{
+		Summary: "Decide a compliance case",
+		Request: serializers.DecideCase{}, Response: models.ComplianceCase{},
+	}),
+
+	// tenants
+	openapi.For(CreateTenant, openapi.Operation{
+		Summary: "Create a tenant", Status: http.StatusCreated,
+		Request: serializers.Tenant{}, Response: models.Tenant{},
+	}),
+	openapi.For(GetTenants, openapi.Operation{
+		Summary:  "List tenants",
+		Response: []models.Tenant{},
+	}),
+	openapi.For(GetTenant, openapi.Operation{
+		Summary:  "Get a tenant",
+		Response: models.Tenant{},
+	}),
+	openapi.For(UpdateTenant, openapi.Operation{
+		Summary: "Update a tenant",
+		Request: serializers.Tenant{}, Response: models.Tenant{},
+	}),
+	openapi.For(SetTenantFeeRule, openapi.Operation{
+		Summary: "Set a tenant's developer fee on a rail",
+		Request: serializers.TenantFeeRule{}, Response: models.Tenant{},
+	}),
+}
+
+// quoteParams are the parameters of the amount to receive quotes
+var quoteParams = []openapi.Param{
+	openapi.Q("amount", ""),
+	openapi.Q("currency", "fiat currency"),
+	openapi.Q("cryptoAsset", ""),
+	openapi.Q("type", "on-ramp or off-ramp"),
+}
+
+var reportParams = []openapi.Param{
+	openapi.Q("rail", ""),
+	openapi.Q("direction", ""),
+	openapi.Q("from", "YYYY-MM-DD, inclusive"),
+	openapi.Q("to", "YYYY-MM-DD, inclusive"),
+}
+
+var limitParams = []openapi.Param{
+	openapi.Q("rail", "bank, mobile_money, borderless or onchain"),
+	openapi.Q("direction", ""),
+}
+
+// kycDocumentParams are the document uploads of the KYC form
+func kycDocumentParams() []openapi.Param {
+	var params []openapi.Param
+	for name, label := range kycDocumentLabels {
+		params = append(params, openapi.Param{Name: name, Description: label, Type: "file"})
+	}
+	sort.Slice(params, func(i, j int) bool { return params[i].Name < params[j].Name })
+	return params
+}
@@ -0,0 +1,285 @@
+package controllers
+
+import (
+	"backend/models"
+	"backend/serializers"
+	"backend/utils"
+	"backend/utils/tenancy"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func CreatePartner(c *gin.Context) {
+	var input serializers.CreatePartner
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequest(c, err, "invalid request payload")
+		return
+	}
+
+	if input.TenantID != nil {
+		if _, err := models.GetTenantByID(*input.TenantID); err != nil {
+			utils.BadRequest(c, err, "tenant not found")
+			return
+		}
+	}
+
+	partner := models.Partner{
+		Name:         input.Name,
+		ContactEmail: input.ContactEmail,
+		Status:       models.PartnerActive,
+		TenantID:     input.TenantID,
+	}
+	if err := partner.CreatePartner(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": partner})
+}
+
+func GetPartners(c *gin.Context) {
+	partners, err := models.GetPartners()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": partners})
+}
+
+func GetPartner(c *gin.Context) {
+	partner, ok := partnerFromParam(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": partner})
+}
+
+func UpdatePartnerStatus(c *gin.Context) {
+	partner, ok := partnerFromParam(c)
+	if !ok {
+		return
+	}
+
+	var input serializers.UpdatePartnerStatus
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequest(c, err, "invalid request payload")
+		return
+	}
+
+	partner.Status = models.PartnerStatus(input.Status)
+	if err := partner.UpdatePartner(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": partner})
+}
+
+func CreatePartnerAPIKey(c *gin.Context) {
+	partner, ok := partnerFromParam(c)
+	if !ok {
+		return
+	}
+
+	var input serializers.PartnerAPIKeyRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequest(c, err, "invalid request payload")
+		return
+	}
+
+	key, secret, err := models.NewPartnerAPIKey(partner.ID, input.Name, input.Scopes, input.AllowedIPs)
+	if err != nil {
+		utils.BadRequest(c, err, "creating API key failed")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data":    key,
+		"secret":  secret,
+		"message": "store the secret safely, it will not be shown again",
+	})
+}
+
+func RotatePartnerAPIKey(c *gin.Context) {
+	key, ok := partnerAPIKeyFromParam(c)
+	if !ok {
+		return
+	}
+
+	newKey, secret, err := key.Rotate()
+	if err != nil {
+		utils.BadRequest(c, err, "rotating API key failed")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data":    newKey,
+		"secret":  secret,
+		"message": "the previous key has been revoked, store the new secret safely",
+	})
+}
+
+func UpdatePartnerAPIKeyAccess(c *gin.Context) {
+	key, ok := partnerAPIKeyFromParam(c)
+	if !ok {
+		return
+	}
+
+	var input serializers.PartnerAPIKeyAccess
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequest(c, err, "invalid request payload")
+		return
+	}
+
+	if err := key.UpdateAccess(input.Scopes, input.AllowedIPs); err != nil {
+		utils.BadRequest(c, err, "updating API key failed")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": key})
+}
+
+func RevokePartnerAPIKey(c *gin.Context) {
+	key, ok := partnerAPIKeyFromParam(c)
+	if !ok {
+		return
+	}
+
+	if err := key.Revoke(); err != nil {
+		utils.BadRequest(c, err, "revoking API key failed")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
+
+// CreatePartnerUser onboards a sub-user for the calling partner. The partner
+// is responsible for verifying its users so the wallet is provisioned right away.
+func CreatePartnerUser(c *gin.Context) {
+	var input serializers.PartnerUser
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequest(c, err, "invalid request payload")
+		return
+	}
+
+	if _, exists := models.FindUserByEmail(input.Email); exists {
+		utils.BadRequest(c, errors.New("DUPLICATE USER"), "User already exists")
+		return
+	}
+
+	chain := strings.ToUpper(input.Chain)
+	if !isSupportedChain(chain) || !tenancy.ChainEnabled(c, chain) {
+		utils.BadRequest(c, fmt.Errorf("unsupported chain: %s", input.Chain), "unsupported chain type")
+		return
+	}
+
+	password, err := models.RandomPassword()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// the partner vouches for the address, its users skip verification
+	partnerID := c.GetUint("partner_id")
+	now := time.Now()
+	user := models.User{
+		FirstName:       input.FirstName,
+		LastName:        input.LastName,
+		Email:           input.Email,
+		Password:        password,
+		Country:         input.Country,
+		Currency:        input.Currency,
+		CountryCode:     input.CountryCode,
+		CryptoCurrency:  chain,
+		PartnerID:       &partnerID,
+		TenantID:        tenancy.TenantIDPtr(c),
+		Locale:          models.ResolveLocale(input.Locale, input.CountryCode),
+		EmailVerified:   true,
+		EmailVerifiedAt: &now,
+	}
+
+	if err := user.SaveUser(); err != nil {
+		utils.BadRequest(c, err, "creating user failed")
+		return
+	}
+
+	if err := provisionWallet(&user); err != nil {
+		slog.ErrorContext(c.Request.Context(), "partner user wallet setup failed", "user_id", user.ID, "error", err)
+		if delErr := models.PurgePartnerUser(user.ID); delErr != nil {
+			slog.ErrorContext(c.Request.Context(), "partner user not removed after wallet failure", "user_id", user.ID, "error", delErr)
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error(), "message": "wallet setup failed"})
+		return
+	}
+
+	if err := user.CompleteEmailVerification(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	user.PrepareGive()
+	c.JSON(http.StatusCreated, gin.H{"data": user})
+}
+
+func ListPartnerUsers(c *gin.Context) {
+	users, err := models.GetPartnerUsers(c.GetUint("partner_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": users})
+}
+
+func GetPartnerUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, err, "invalid user id")
+		return
+	}
+
+	user, err := models.GetPartnerUser(c.GetUint("partner_id"), uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": user})
+}
+
+func partnerFromParam(c *gin.Context) (*models.Partner, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, err, "invalid partner id")
+		return nil, false
+	}
+
+	partner, err := models.GetPartnerByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "partner not found"})
+		return nil, false
+	}
+	return partner, true
+}
+
+func partnerAPIKeyFromParam(c *gin.Context) (*models.PartnerAPIKey, bool) {
+	partnerID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, err, "invalid partner id")
+		return nil, false
+	}
+	keyID, err := strconv.ParseUint(c.Param("keyId"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, err, "invalid key id")
+		return nil, false
+	}
+
+	key, err := models.GetPartnerAPIKey(uint(partnerID), uint(keyID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return nil, false
+	}
+	return key, true
+}
@@ -7,38 +7,50 @@ import (
 	"backend/serializers"
 	"backend/state"
 	"backend/utils"
+	"backend/utils/apperrors"
+	"backend/utils/idempotency"
 	"backend/utils/tokens"
+	"context"
 	"fmt"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
+// borderlessPayoutAsset is sent to the master wallet to fund bank payouts
+const borderlessPayoutAsset = "USDC_MATIC"
+
 func BorderLessOnramp(c *gin.Context) {
 	userId, err := tokens.ExtractUserID(c)
 	if err != nil {
-		c.JSON(401, gin.H{"error": err.Error()})
+		apperrors.Respond(c, apperrors.Wrap(apperrors.CodeUnauthorized, err, ""))
 		return
 	}
 	user, err := models.GetUserByID(userId)
 	if err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, err)
 		return
 	}
 	var input serializers.BorderlessOnramp
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, apperrors.Invalid(err, "invalid request body"))
+		return
+	}
+	reservation, ok := enforceLimit(c, user, models.RailBorderless, models.LimitOnRamp, input.Amount, input.Fiat)
+	if !ok {
 		return
 	}
-	borderless := borderless.NewBorderless()
+	defer reservation.Release()
+	borderless := borderless.NewBorderless().WithContext(c.Request.Context())
 	response, err := borderless.MakeDeposit(
-		input.Amount, input.Asset, input.Country, input.Fiat)
+		input.Amount, input.Asset, input.Country, input.Fiat, idempotency.ProviderKey(c))
 	if err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, err)
 		return
 	}
 	borderlessRequest := models.BorderlessRequest{}
 	borderlessRequest.FiatAmount = input.Amount
+	borderlessRequest.FiatCurrency = input.Fiat
 	borderlessRequest.Asset = input.Asset     // change this for prod use
 	borderlessRequest.Country = input.Country //change this for prod use
 	borderlessRequest.UserId = userId
@@ -48,12 +60,12 @@ func BorderLessOnramp(c *gin.Context) {
 	borderlessRequest.TxId = response.ID
 	borderlessRequest.FeeAmount = response.FeeAmount
 	if err := models.CreateBorderlessRequest(&borderlessRequest); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, err)
 		return
 	}
 	transactionInstruction, err := borderless.GetTransaction(response.ID)
 	if err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, err)
 		return
 	}
 	c.JSON(200, gin.H{"data": transactionInstruction, "status": "success", "errors": false})
@@ -70,43 +82,68 @@ func FilterBank(c *gin.Context) {
 	c.JSON(200, gin.H{"data": banks, "status": "success", "errors": false})
 }
 
+// applyBankBeneficiary pays the withdrawal out to the saved beneficiary
+func applyBankBeneficiary(input *serializers.MakeWithdrawalBorderless, b *models.Beneficiary) {
+	input.BankId = uint64(*b.BankID)
+	input.AccountNumber, input.AccountHolderName = b.AccountNumber, b.AccountName
+	if b.AccountType != "" {
+		input.AccountType = b.AccountType
+	}
+}
+
 func BorderLessOffRamp(c *gin.Context) {
 	userId, err := tokens.ExtractUserID(c)
 	if err != nil {
-		c.JSON(401, gin.H{"error": err.Error()})
+		apperrors.Respond(c, apperrors.Wrap(apperrors.CodeUnauthorized, err, ""))
 		return
 	}
 	user, err := models.GetUserByID(userId)
 	if err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, err)
 		return
 	}
 	var input serializers.MakeWithdrawalBorderless
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, apperrors.Invalid(err, "invalid request body"))
+		return
+	}
+	saved, ok := payoutBeneficiary(c, user, models.BeneficiaryBank, input.BeneficiaryID, models.BankBeneficiaryKey(uint(input.BankId), input.AccountNumber))
+	if !ok {
+		return
+	}
+	if saved != nil {
+		applyBankBeneficiary(&input, saved)
+	}
+	reservation, ok := enforceLimit(c, user, models.RailBorderless, models.LimitOffRamp, input.Amount, borderlessPayoutAsset)
+	if !ok {
+		return
+	}
+	defer reservation.Release()
+	if !screenBeneficiary(c, user, input.AccountHolderName, models.RailBorderless) {
 		return
 	}
 	bank, err := models.GetBankData(int(input.BankId))
 	if err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, apperrors.Wrap(apperrors.CodeBadRequest, err, "unknown bank_id"))
 		return
 	}
-	polygon := apis.NewTatumPolygon()
+	polygon := apis.NewTatumPolygon().WithContext(context.WithoutCancel(c.Request.Context()))
 	paymentInstruction := borderless.NewPayment(
 		bank.Country, input.Currency, fmt.Sprintf("%s %s", user.FirstName, user.LastName),
 		"Wire", input.AccountHolderName, input.AccountNumber,
 		input.AccountType, bank.Name, *bank.Street, *bank.City, bank.Country, *bank.ZipCode, *bank.SwiftCode,
 		input.AccountNumber, *bank.SwiftCode, *bank.Street, *bank.State)
 
-	borderlessHandler := borderless.NewBorderless()
+	borderlessHandler := borderless.NewBorderless().WithContext(context.WithoutCancel(c.Request.Context()))
 	paymentInstructionResponse, err := borderlessHandler.MakePaymentInstruction(paymentInstruction)
 	if err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, err)
 		return
 	}
 	masterWallet, err := models.FetchMasterWallet("MATIC")
 	if err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, apperrors.Wrap(apperrors.CodeServiceUnavailable, err, "payouts on this chain are unavailable"))
+		return
 	}
 	var trans models.Transaction
 	trans.UserID = userId
@@ -122,23 +159,25 @@ func BorderLessOffRamp(c *gin.Context) {
 	borderlessRequest.User = user
 	borderlessRequest.Status = "Pending"
 	borderlessRequest.FiatAmount = input.Amount
-	borderlessRequest.Asset = "USDC_MATIC"
+	borderlessRequest.Asset = borderlessPayoutAsset
 	borderlessRequest.PaymentInstructionId = &paymentInstructionResponse.ID
-	currency, err := apis.ParseCurrencyType("USDC_MATIC")
+	currency, err := apis.ParseCurrencyType(borderlessPayoutAsset)
 	if err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, err)
 		return
 	}
+	idempotency.MarkSubmitted(c)
 	hashResponse, err := polygon.PerformTransaction(
 		masterWallet.PublicAddress, input.Amount, user.PrivateKey, currency)
 	if err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, err)
 		return
 	}
 	trans.Hash = hashResponse.TxId
 	trans.TransactionId = hashResponse.TxId
+	borderlessRequest.TxHash = hashResponse.TxId
 	if err := trans.SaveTransaction(); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, err)
 		return
 	}
 	accountID := state.AppConfig.BorderlessAccountId
@@ -147,18 +186,18 @@ func BorderLessOffRamp(c *gin.Context) {
 		accountID, input.PaymentPurpose, paymentInstructionResponse.ID)
 	res, err := borderlessHandler.MakeWithdrawal(withdraw)
 	if err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, err)
 		return
 	}
 	borderlessRequest.TxId = res.ID
 	if err := models.CreateBorderlessRequest(&borderlessRequest); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, err)
 		return
 	}
 
 	transactionInstruction, err := borderlessHandler.GetTransaction(res.ID)
 	if err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, err)
 		return
 	}
 	c.JSON(200, gin.H{"data": transactionInstruction, "status": "success", "errors": false})
@@ -168,19 +207,19 @@ func BorderLessOffRamp(c *gin.Context) {
 func BorderlessMobileMoneyOnRamp(c *gin.Context) {
 	userId, err := tokens.ExtractUserID(c)
 	if err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, apperrors.Wrap(apperrors.CodeUnauthorized, err, ""))
 		return
 	}
 
 	user, err := models.GetUserByID(userId)
 	if err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, err)
 		return
 	}
 
 	var input serializers.BorderlessOnramp
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, apperrors.Invalid(err, "invalid request body"))
 		return
 	}
 
@@ -193,10 +232,16 @@ func BorderlessMobileMoneyOnRamp(c *gin.Context) {
 		return
 	}
 
-	borderless := borderless.NewBorderless()
+	reservation, ok := enforceLimit(c, user, models.RailBorderless, models.LimitOnRamp, input.Amount, input.Fiat)
+	if !ok {
+		return
+	}
+	defer reservation.Release()
+
+	borderless := borderless.NewBorderless().WithContext(c.Request.Context())
 	availableCountries, err := borderless.GetAvailableCountries("deposits")
 	if err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, err)
 		return
 	}
 
@@ -212,7 +257,7 @@ func BorderlessMobileMoneyOnRamp(c *gin.Context) {
 	// find mobile money deposit option
 	depositOption, err := borderless.GetDepositOrWithdrawalOption("deposits", input.Country, input.Fiat, input.Asset)
 	if err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, err)
 		return
 	}
 
@@ -222,15 +267,16 @@ func BorderlessMobileMoneyOnRamp(c *gin.Context) {
 	}
 
 	makeDepositResponse, err := borderless.MobileMoneyDeposit(
-		input.AccountId, input.Fiat, input.Country, input.Asset, input.Amount, depositOption.Method)
+		input.AccountId, input.Fiat, input.Country, input.Asset, input.Amount, depositOption.Method, idempotency.ProviderKey(c))
 
 	if err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, err)
 		return
 	}
 
 	borderlessRequest := models.BorderlessRequest{}
 	borderlessRequest.FiatAmount = input.Amount
+	borderlessRequest.FiatCurrency = input.Fiat
 	borderlessRequest.Asset = input.Asset
 	borderlessRequest.Country = input.Country
 	borderlessRequest.UserId = userId
@@ -241,13 +287,13 @@ func BorderlessMobileMoneyOnRamp(c *gin.Context) {
 	borderlessRequest.FeeAmount = makeDepositResponse.FeeAmount
 
 	if err := models.CreateBorderlessRequest(&borderlessRequest); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, err)
 		return
 	}
 
 	transactionInstruction, err := borderless.GetTransaction(makeDepositResponse.ID)
 	if err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, err)
 		return
 	}
 
@@ -257,19 +303,19 @@ func BorderlessMobileMoneyOnRamp(c *gin.Context) {
 func BorderlessMobileMoneyOffRamp(c *gin.Context) {
 	userId, err := tokens.ExtractUserID(c)
 	if err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, apperrors.Wrap(apperrors.CodeUnauthorized, err, ""))
 		return
 	}
 
 	user, err := models.GetUserByID(userId)
 	if err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, err)
 		return
 	}
 
 	var input serializers.MakeWithdrawalBorderless
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, apperrors.Invalid(err, "invalid request body"))
 		return
 	}
 
@@ -285,17 +331,33 @@ func BorderlessMobileMoneyOffRamp(c *gin.Context) {
 		return
 	}
 
+	saved, ok := payoutBeneficiary(c, user, models.BeneficiaryBank, input.BeneficiaryID, models.BankBeneficiaryKey(uint(input.BankId), input.AccountNumber))
+	if !ok {
+		return
+	}
+	if saved != nil {
+		applyBankBeneficiary(&input, saved)
+	}
+	reservation, ok := enforceLimit(c, user, models.RailBorderless, models.LimitOffRamp, input.Amount, input.Asset)
+	if !ok {
+		return
+	}
+	defer reservation.Release()
+	if !screenBeneficiary(c, user, input.AccountHolderName, models.RailBorderless) {
+		return
+	}
+
 	bank, err := models.GetBankData(int(input.BankId))
 	if err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, apperrors.Wrap(apperrors.CodeBadRequest, err, "unknown bank_id"))
 		return
 	}
 
-	borderlessHandler := borderless.NewBorderless()
+	borderlessHandler := borderless.NewBorderless().WithContext(context.WithoutCancel(c.Request.Context()))
 
 	availableCountries, err := borderlessHandler.GetAvailableCountries("withdrawals")
 	if err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, err)
 		return
 	}
 
@@ -311,7 +373,7 @@ func BorderlessMobileMoneyOffRamp(c *gin.Context) {
 	// find mobile money withdrawal option
 	withdrawalOption, err := borderlessHandler.GetDepositOrWithdrawalOption("withdrawals", bank.Country, input.Currency, input.Asset)
 	if err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, err)
 		return
 	}
 
@@ -329,13 +391,13 @@ func BorderlessMobileMoneyOffRamp(c *gin.Context) {
 
 	paymentInstructionResponse, err := borderlessHandler.MakePaymentInstruction(paymentInstruction)
 	if err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, err)
 		return
 	}
 
 	masterWallet, err := models.FetchMasterWallet(input.MasterWallet)
 	if err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, apperrors.Wrap(apperrors.CodeServiceUnavailable, err, "payouts on this chain are unavailable"))
 		return
 	}
 
@@ -359,24 +421,26 @@ func BorderlessMobileMoneyOffRamp(c *gin.Context) {
 
 	currency, err := apis.ParseCurrencyType(input.Asset)
 	if err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, err)
 		return
 	}
 
-	tatumInstance := apis.NewTatumPolygon()
+	tatumInstance := apis.NewTatumPolygon().WithContext(context.WithoutCancel(c.Request.Context()))
+	idempotency.MarkSubmitted(c)
 	hashResponse, err := tatumInstance.PerformTransaction(
 		masterWallet.PublicAddress, input.Amount,
 		user.PrivateKey, currency)
 	if err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, err)
 		return
 	}
 
 	trans.Hash = hashResponse.TxId
 	trans.TransactionId = hashResponse.TxId
+	borderlessRequest.TxHash = hashResponse.TxId
 
 	if err := trans.SaveTransaction(); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, err)
 		return
 	}
 
@@ -390,19 +454,19 @@ func BorderlessMobileMoneyOffRamp(c *gin.Context) {
 		input.Amount, accountID, input.PaymentPurpose, paymentInstructionResponse.ID)
 	res, err := borderlessHandler.MakeWithdrawal(withdraw)
 	if err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, err)
 		return
 	}
 
 	borderlessRequest.TxId = res.ID
 	if err := models.CreateBorderlessRequest(&borderlessRequest); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, err)
 		return
 	}
 
 	transactionInstruction, err := borderlessHandler.GetTransaction(res.ID)
 	if err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, err)
 		return
 	}
 
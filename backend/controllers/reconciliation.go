@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"backend/models"
+	"backend/serializers"
+	"backend/utils"
+	"backend/utils/reconciliation"
+	"backend/utils/tokens"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxReconciliationRange keeps a manual run to a month of records
+const maxReconciliationRange = 31 * 24 * time.Hour
+
+const reconciliationRunLimit = 50
+
+var reconciliationClassifications = []string{
+	models.ReconMatched, models.ReconMissingLocal, models.ReconMissingRemote, models.ReconAmountMismatch,
+}
+
+func StartReconciliation(c *gin.Context) {
+	adminID, err := tokens.ExtractUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var input serializers.StartReconciliation
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequest(c, err, "invalid reconciliation request")
+		return
+	}
+	from, _ := time.Parse(reportDateLayout, input.From)
+	to, _ := time.Parse(reportDateLayout, input.To)
+	to = to.AddDate(0, 0, 1)
+	if !to.After(from) {
+		utils.BadRequest(c, errors.New("invalid date range"), "to must not be before from")
+		return
+	}
+	if to.Sub(from) > maxReconciliationRange {
+		utils.BadRequest(c, errors.New("date range too long"), "a reconciliation run covers at most 31 days")
+		return
+	}
+
+	run, err := reconciliation.Start(input.Source, from, to, adminID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"status": "reconciliation started", "data": run, "errors": false})
+}
+
+func GetReconciliationRuns(c *gin.Context) {
+	source := c.Query("source")
+	if source != "" && !slices.Contains(models.ReconciliationSources, source) {
+		utils.BadRequest(c, errors.New("unknown source: "+source), "source must be one of borderless, hurupay or chain")
+		return
+	}
+	runs, err := models.GetReconciliationRuns(source, reconciliationRunLimit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "fetched reconciliation runs", "data": runs, "errors": false})
+}
+
+func GetReconciliationRun(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, err, "invalid reconciliation run id")
+		return
+	}
+	run, err := models.GetReconciliationRun(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "fetched reconciliation run", "data": run, "errors": false})
+}
+
+// GetReconciliationItems lists the outcomes of runs, filtered by run_id,
+// source, classification and status (open or resolved). Breaks are every
+// classification but matched.
+func GetReconciliationItems(c *gin.Context) {
+	lq, ok := listQuery(c)
+	if !ok {
+		return
+	}
+
+	var runID uint
+	if value := c.Query("run_id"); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			utils.BadRequest(c, err, "invalid run_id")
+			return
+		}
+		runID = uint(parsed)
+	}
+	classification := c.Query("classification")
+	if classification != "" && !slices.Contains(reconciliationClassifications, classification) {
+		utils.BadRequest(c, errors.New("unknown classification: "+classification), "classification must be one of matched, missing_local, missing_remote or amount_mismatch")
+		return
+	}
+
+	items, info, err := models.FilterReconciliationItems(runID, c.Query("source"), classification, lq)
+	if err != nil {
+		listError(c, err)
+		return
+	}
+	respondList(c, "fetched reconciliation items", items, info)
+}
+
+func ResolveReconciliationItem(c *gin.Context) {
+	adminID, err := tokens.ExtractUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, err, "invalid reconciliation item id")
+		return
+	}
+
+	var input serializers.ResolveReconciliationItem
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequest(c, err, "invalid resolution")
+		return
+	}
+
+	item, err := models.GetReconciliationItem(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return
+	}
+	if err := item.Resolve(adminID, input.Resolution, input.Note); err != nil {
+		if errors.Is(err, models.ErrAlreadyResolved) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "reconciliation item resolved", "data": item, "errors": false})
+}
@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"backend/models"
+	"backend/utils"
+	"backend/utils/tenancy"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const reportDateLayout = "2006-01-02"
+
+// defaultReportWindow is used when no from date is given
+const defaultReportWindow = 30 * 24 * time.Hour
+
+func GetVolumeReport(c *gin.Context) {
+	filter, ok := reportFilter(c)
+	if !ok {
+		return
+	}
+	groupBy := c.DefaultQuery("group_by", models.ReportByDay)
+	if !slices.Contains(models.ReportDimensions, groupBy) {
+		utils.BadRequest(c, errors.New("unknown group_by: "+groupBy), "group_by must be one of day, week, month, rail, direction, chain, asset, country or status")
+		return
+	}
+
+	rows, err := models.ReportVolume(filter, groupBy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": "fetched volume report",
+		"data":   rows,
+		"meta":   reportMeta(filter, gin.H{"group_by": groupBy}),
+		"errors": false,
+	})
+}
+
+func GetFunnelReport(c *gin.Context) {
+	filter, ok := reportFilter(c)
+	if !ok {
+		return
+	}
+	rows, err := models.ReportFunnel(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "fetched funnel report", "data": rows, "meta": reportMeta(filter, nil), "errors": false})
+}
+
+func GetSettlementReport(c *gin.Context) {
+	filter, ok := reportFilter(c)
+	if !ok {
+		return
+	}
+	rows, err := models.ReportSettlement(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "fetched settlement report", "data": rows, "meta": reportMeta(filter, nil), "errors": false})
+}
+
+func GetFeeReport(c *gin.Context) {
+	filter, ok := reportFilter(c)
+	if !ok {
+		return
+	}
+	rows, err := models.ReportFees(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "fetched fee report", "data": rows, "meta": reportMeta(filter, nil), "errors": false})
+}
+
+// reportFilter reads the from and to dates (YYYY-MM-DD, to inclusive), rail
+// and direction query params, scoped to the admin's tenant. Reports cover the
+// last 30 days unless from is given.
+func reportFilter(c *gin.Context) (models.ReportFilter, bool) {
+	filter := models.ReportFilter{
+		TenantID:  tenancy.TenantID(c),
+		Rail:      c.Query("rail"),
+		Direction: c.Query("direction"),
+	}
+
+	if from := c.Query("from"); from != "" {
+		parsed, err := time.Parse(reportDateLayout, from)
+		if err != nil {
+			utils.BadRequest(c, err, "from must be a YYYY-MM-DD date")
+			return filter, false
+		}
+		filter.From = parsed
+	} else {
+		filter.From = time.Now().UTC().Add(-defaultReportWindow).Truncate(24 * time.Hour)
+	}
+	if to := c.Query("to"); to != "" {
+		parsed, err := time.Parse(reportDateLayout, to)
+		if err != nil {
+			utils.BadRequest(c, err, "to must be a YYYY-MM-DD date")
+			return filter, false
+		}
+		filter.To = parsed.AddDate(0, 0, 1)
+	}
+	if !filter.To.IsZero() && !filter.To.After(filter.From) {
+		utils.BadRequest(c, errors.New("invalid date range"), "to must not be before from")
+		return filter, false
+	}
+	if filter.Rail != "" && filter.Rail != models.RailOnchain && !slices.Contains(models.AllRails, filter.Rail) {
+		utils.BadRequest(c, errors.New("unknown rail: "+filter.Rail), "invalid rail")
+		return filter, false
+	}
+	return filter, true
+}
+
+func reportMeta(filter models.ReportFilter, extra gin.H) gin.H {
+	meta := gin.H{"from": filter.From.Format(reportDateLayout), "rail": filter.Rail, "direction": filter.Direction}
+	if !filter.To.IsZero() {
+		meta["to"] = filter.To.AddDate(0, 0, -1).Format(reportDateLayout)
+	}
+	for key, value := range extra {
+		meta[key] = value
+	}
+	return meta
+}
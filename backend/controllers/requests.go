@@ -2,18 +2,23 @@ package controllers
 
 import (
 	"backend/models"
+	"backend/utils/tenancy"
 	"github.com/gin-gonic/gin"
 	"strconv"
 )
 
 func ListHurupayRequest(c *gin.Context) {
-	requests, err := models.GetHurupayRequest()
+	lq, ok := listQuery(c)
+	if !ok {
+		return
+	}
+	requestType := models.RequestType(c.Query("request_type"))
+	requests, info, err := models.GetHurupayRequest(tenancy.TenantID(c), requestType, lq)
 	if err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		listError(c, err)
 		return
 	}
-	serializedData := models.ConvertToSerializer(requests)
-	c.JSON(200, gin.H{"status": "fetched hurupay requests", "data": serializedData, "errors": false})
+	respondList(c, "fetched hurupay requests", models.ConvertToSerializer(requests), info)
 }
 
 func GetHurupayRequest(c *gin.Context) {
@@ -28,12 +33,15 @@ func GetHurupayRequest(c *gin.Context) {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
+	if !inTenant(c, uint(request.UserId)) {
+		return
+	}
 	serializedData := models.ConvertToSerializer(request)
 	c.JSON(200, gin.H{"status": "fetched hurupay request", "data": serializedData, "errors": false})
 }
 
 func GetHurupayStats(c *gin.Context) {
-	stats, err := models.GetHurupayStats()
+	stats, err := models.GetHurupayStats(tenancy.TenantID(c))
 	if err != nil {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"backend/models"
+	"backend/serializers"
+	"backend/utils"
+	"backend/utils/apperrors"
+	"backend/utils/cases"
+	"backend/utils/screening"
+	"backend/utils/tokens"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var screeningContexts = []string{models.ScreeningKYCSubmission, models.ScreeningKYCApproval, models.ScreeningPayout}
+
+// screeningBlocked answers the request and returns true when the screening
+// is not clear
+func screeningBlocked(c *gin.Context, result *models.Screening) bool {
+	if result.Status == models.ScreeningClear || result.Status == models.ScreeningCleared {
+		return false
+	}
+	code, message := "screening_review", "screening hit is awaiting compliance review"
+	if result.Status == models.ScreeningConfirmed {
+		code, message = "screening_confirmed", "screening hit was confirmed by compliance"
+	}
+	apperrors.Respond(c, apperrors.New(apperrors.CodeComplianceHold, message).
+		WithReason(code).
+		WithData(gin.H{"screening_id": result.ID, "status": result.Status}))
+	return true
+}
+
+// screenBeneficiary answers the request and returns false when the payout
+// beneficiary is on a watchlist or cannot be screened
+func screenBeneficiary(c *gin.Context, user models.User, name, rail string) bool {
+	if strings.TrimSpace(name) == "" {
+		utils.BadRequest(c, errors.New("missing beneficiary name"), "beneficiary name is required")
+		return false
+	}
+	result, err := screening.Screen(screening.BeneficiarySubject(user, name, rail), models.ScreeningPayout)
+	if err != nil {
+		apperrors.Respond(c, apperrors.Wrap(apperrors.CodeServiceUnavailable, err, "could not screen beneficiary"))
+		return false
+	}
+	return !screeningBlocked(c, result)
+}
+
+// screenKYC screens a submitted KYC in the background, a hit is reviewed
+// before the KYC can be approved
+func screenKYC(ctx context.Context, kyc *models.KYC) {
+	user, err := models.GetUserByID(kyc.UserID)
+	if err != nil {
+		slog.ErrorContext(ctx, "kyc screening failed", "kyc_id", kyc.ID, "error", err)
+		return
+	}
+	if _, err := screening.Screen(screening.UserSubject(user, kyc), models.ScreeningKYCSubmission); err != nil {
+		slog.ErrorContext(ctx, "kyc screening failed", "kyc_id", kyc.ID, "error", err)
+	}
+}
+
+// kycScreeningClear screens the user again before approval, answering the
+// request and returning false on a hit
+func kycScreeningClear(c *gin.Context, user models.User, kyc *models.KYC) bool {
+	result, err := screening.Screen(screening.UserSubject(user, kyc), models.ScreeningKYCApproval)
+	if err != nil {
+		apperrors.Respond(c, apperrors.Wrap(apperrors.CodeServiceUnavailable, err, "could not screen user"))
+		return false
+	}
+	return !screeningBlocked(c, result)
+}
+
+// GetScreenings lists screenings, filtered by user_id, context and status
+// (clear, review, cleared or confirmed)
+func GetScreenings(c *gin.Context) {
+	lq, ok := listQuery(c)
+	if !ok {
+		return
+	}
+	var userID uint
+	if value := c.Query("user_id"); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			utils.BadRequest(c, err, "invalid user_id")
+			return
+		}
+		userID = uint(parsed)
+	}
+	context := c.Query("context")
+	if context != "" && !slices.Contains(screeningContexts, context) {
+		utils.BadRequest(c, errors.New("unknown context: "+context), "context must be one of kyc_submission, kyc_approval or payout")
+		return
+	}
+
+	screenings, info, err := models.FilterScreenings(userID, context, lq)
+	if err != nil {
+		listError(c, err)
+		return
+	}
+	respondList(c, "fetched screenings", screenings, info)
+}
+
+func GetScreening(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, err, "invalid screening id")
+		return
+	}
+	result, err := models.GetScreening(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "fetched screening", "data": result, "errors": false})
+}
+
+func ResolveScreening(c *gin.Context) {
+	adminID, err := tokens.ExtractUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, err, "invalid screening id")
+		return
+	}
+
+	var input serializers.ResolveScreening
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequest(c, err, "invalid decision")
+		return
+	}
+
+	result, err := models.GetScreening(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return
+	}
+	if err := result.Resolve(adminID, input.Decision, input.Note); err != nil {
+		if errors.Is(err, models.ErrScreeningResolved) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := cases.ScreeningResolved(result, adminID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "screening resolved", "data": result, "errors": false})
+}
+
+// CheckScreening shows what a name would hit, for tuning thresholds
+func CheckScreening(c *gin.Context) {
+	var input serializers.CheckScreening
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequest(c, err, "invalid screening check")
+		return
+	}
+	matches, err := screening.Match(input.Name, input.DateOfBirth)
+	if err != nil {
+		apperrors.Respond(c, apperrors.Wrap(apperrors.CodeServiceUnavailable, err, "could not screen name"))
+		return
+	}
+	if matches == nil {
+		matches = []models.ScreeningMatch{}
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "screened name", "data": matches, "errors": false})
+}
+
+func GetWatchlists(c *gin.Context) {
+	lists, err := models.GetWatchlists()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "fetched watchlists", "data": lists, "errors": false})
+}
+
+// ReloadWatchlists loads the list files now rather than on the schedule
+func ReloadWatchlists(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "reloaded watchlists", "data": screening.LoadLists(), "errors": false})
+}
@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"backend/models"
+	"backend/serializers"
+	"backend/utils"
+	"backend/utils/statements"
+	"backend/utils/tenancy"
+	"backend/utils/tokens"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxStatementRange keeps a single export to about a year of activity
+const maxStatementRange = 366 * 24 * time.Hour
+
+const statementListLimit = 50
+
+func CreateStatementExport(c *gin.Context) {
+	userID, err := tokens.ExtractUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var input serializers.CreateStatement
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequest(c, err, "invalid statement request")
+		return
+	}
+	createStatementExport(c, userID, &userID, input)
+}
+
+func GetStatementExports(c *gin.Context) {
+	userID, err := tokens.ExtractUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	exports, err := models.GetStatementExports(userID, 0, statementListLimit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "fetched statement exports", "data": exports, "errors": false})
+}
+
+func GetStatementExport(c *gin.Context) {
+	export, ok := ownStatementExport(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "fetched statement export", "data": export, "errors": false})
+}
+
+func DownloadStatementExport(c *gin.Context) {
+	export, ok := ownStatementExport(c)
+	if !ok {
+		return
+	}
+	serveStatement(c, export)
+}
+
+// DownloadStatementByToken serves the file behind the link mailed to the
+// requester, the token is the only credential
+func DownloadStatementByToken(c *gin.Context) {
+	export, err := models.GetStatementExportByToken(c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "statement not found or expired"})
+		return
+	}
+	serveStatement(c, export)
+}
+
+func CreateAdminStatementExport(c *gin.Context) {
+	adminID, err := tokens.ExtractUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var input serializers.CreateAdminStatement
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequest(c, err, "invalid statement request")
+		return
+	}
+	if input.AllUsers == (input.UserID != nil) {
+		utils.BadRequest(c, errors.New("invalid statement scope"), "either user_id or all_users is required")
+		return
+	}
+	if input.UserID != nil {
+		if _, err := models.GetUserByID(*input.UserID); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		if !inTenant(c, *input.UserID) {
+			return
+		}
+	}
+	createStatementExport(c, adminID, input.UserID, input.CreateStatement)
+}
+
+// GetAdminStatementExports lists the exports of the admin's tenant, every
+// export for platform admins
+func GetAdminStatementExports(c *gin.Context) {
+	exports, err := models.GetStatementExports(0, tenancy.TenantID(c), statementListLimit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "fetched statement exports", "data": exports, "errors": false})
+}
+
+func GetAdminStatementExport(c *gin.Context) {
+	export, ok := tenantStatementExport(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "fetched statement export", "data": export, "errors": false})
+}
+
+func DownloadAdminStatementExport(c *gin.Context) {
+	export, ok := tenantStatementExport(c)
+	if !ok {
+		return
+	}
+	serveStatement(c, export)
+}
+
+// createStatementExport stores the export job for userID, nil for every user
+// of the tenant, and starts building it
+func createStatementExport(c *gin.Context, requestedBy uint, userID *uint, input serializers.CreateStatement) {
+	from, _ := time.Parse(reportDateLayout, input.From)
+	to, _ := time.Parse(reportDateLayout, input.To)
+	to = to.AddDate(0, 0, 1)
+	if !to.After(from) {
+		utils.BadRequest(c, errors.New("invalid date range"), "to must not be before from")
+		return
+	}
+	if to.Sub(from) > maxStatementRange {
+		utils.BadRequest(c, errors.New("date range too long"), "statements cover at most one year")
+		return
+	}
+
+	export := models.StatementExport{
+		RequestedByID: requestedBy,
+		UserID:        userID,
+		TenantID:      tenancy.TenantIDPtr(c),
+		Format:        input.Format,
+		From:          from,
+		To:            to,
+	}
+	if err := export.CreateStatementExport(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	statements.Enqueue(&export)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"status": "statement export queued, a download link will be emailed when it is ready",
+		"data":   export,
+		"errors": false,
+	})
+}
+
+func statementExportID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, err, "invalid statement export id")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// ownStatementExport loads an export the authenticated user requested
+func ownStatementExport(c *gin.Context) (*models.StatementExport, bool) {
+	userID, err := tokens.ExtractUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	id, ok := statementExportID(c)
+	if !ok {
+		return nil, false
+	}
+	export, err := models.GetStatementExport(id)
+	if err != nil || export.RequestedByID != userID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return nil, false
+	}
+	return export, true
+}
+
+// tenantStatementExport loads an export of the admin's tenant
+func tenantStatementExport(c *gin.Context) (*models.StatementExport, bool) {
+	id, ok := statementExportID(c)
+	if !ok {
+		return nil, false
+	}
+	export, err := models.GetStatementExport(id)
+	if err != nil || (tenancy.TenantID(c) != 0 && !tenancy.SameTenant(c, export.TenantID)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return nil, false
+	}
+	return export, true
+}
+
+func serveStatement(c *gin.Context, export *models.StatementExport) {
+	if export.Status != models.StatementCompleted || export.ExpiresAt == nil || time.Now().After(*export.ExpiresAt) {
+		c.JSON(http.StatusConflict, gin.H{"error": "statement is not available for download", "status": export.Status})
+		return
+	}
+	c.Header("Content-Type", statements.ContentType(export.Format))
+	c.FileAttachment(export.FilePath, export.FileName)
+}
@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"backend/utils/realtime"
+	"backend/utils/tokens"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+// streamHeartbeat keeps idle connections open through proxies
+const streamHeartbeat = 25 * time.Second
+
+// StreamEvents pushes the user's transaction, request and notification
+// changes as Server-Sent Events. Browsers' EventSource cannot set headers, so
+// the token may also be passed as ?token=Bearer <jwt>.
+func StreamEvents(c *gin.Context) {
+	userID, err := tokens.ExtractUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	sub := realtime.Subscribe(userID)
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	fmt.Fprint(c.Writer, "event: ready\ndata: {}\n\n")
+	c.Writer.Flush()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event := <-sub.C:
+			payload, err := json.Marshal(event)
+			if err != nil {
+				return true
+			}
+			fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, payload)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case <-c.Request.Context().Done():
+			return false
+		}
+		return true
+	})
+}
+
+// StreamEventsWebSocket pushes the same events as StreamEvents over a
+// WebSocket, one JSON event per message. Messages from the client are ignored.
+func StreamEventsWebSocket(c *gin.Context) {
+	userID, err := tokens.ExtractUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	server := websocket.Server{Handler: func(ws *websocket.Conn) {
+		defer ws.Close()
+
+		sub := realtime.Subscribe(userID)
+		defer sub.Close()
+
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			var discard string
+			for websocket.Message.Receive(ws, &discard) == nil {
+			}
+		}()
+
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case event := <-sub.C:
+				if err := websocket.JSON.Send(ws, event); err != nil {
+					return
+				}
+			case <-heartbeat.C:
+				if err := websocket.JSON.Send(ws, gin.H{"type": "ping"}); err != nil {
+					return
+				}
+			case <-closed:
+				return
+			}
+		}
+	}}
+	server.ServeHTTP(c.Writer, c.Request)
+}
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"backend/models"
+	"backend/serializers"
+	"backend/utils"
+	"backend/utils/tenancy"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func CreateTenant(c *gin.Context) {
+	var input serializers.Tenant
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequest(c, err, "invalid request payload")
+		return
+	}
+
+	tenant := models.Tenant{Status: models.TenantActive}
+	applyTenantInput(&tenant, input)
+	if err := tenant.CreateTenant(); err != nil {
+		utils.BadRequest(c, err, "creating tenant failed")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": tenant})
+}
+
+func GetTenants(c *gin.Context) {
+	tenants, err := models.GetTenants()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": tenants})
+}
+
+func GetTenant(c *gin.Context) {
+	tenant, ok := tenantFromParam(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": tenant})
+}
+
+func UpdateTenant(c *gin.Context) {
+	tenant, ok := tenantFromParam(c)
+	if !ok {
+		return
+	}
+
+	var input serializers.Tenant
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequest(c, err, "invalid request payload")
+		return
+	}
+
+	applyTenantInput(tenant, input)
+	if err := tenant.UpdateTenant(); err != nil {
+		utils.BadRequest(c, err, "updating tenant failed")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": tenant})
+}
+
+func SetTenantFeeRule(c *gin.Context) {
+	tenant, ok := tenantFromParam(c)
+	if !ok {
+		return
+	}
+
+	var input serializers.TenantFeeRule
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequest(c, err, "invalid request payload")
+		return
+	}
+
+	if err := tenant.SetFeeRule(input.Rail, input.DeveloperFee); err != nil {
+		utils.BadRequest(c, err, "updating fee rule failed")
+		return
+	}
+
+	tenant, err := models.GetTenantByID(tenant.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": tenant})
+}
+
+func applyTenantInput(tenant *models.Tenant, input serializers.Tenant) {
+	tenant.Name = input.Name
+	tenant.Slug = strings.ToLower(input.Slug)
+	if input.Status != "" {
+		tenant.Status = models.TenantStatus(input.Status)
+	}
+	tenant.Domains = joinLower(input.Domains)
+	tenant.AllowedOrigins = strings.Join(input.AllowedOrigins, ",")
+	tenant.EnabledChains = strings.ToUpper(strings.Join(input.EnabledChains, ","))
+	tenant.EnabledRails = joinLower(input.EnabledRails)
+	tenant.BlockedCountries = strings.ToUpper(strings.Join(input.BlockedCountries, ","))
+	tenant.EmailSenderName = input.EmailSenderName
+	tenant.EmailReplyTo = input.EmailReplyTo
+	tenant.EmailTemplateSet = input.EmailTemplateSet
+}
+
+func joinLower(values []string) string {
+	return strings.ToLower(strings.Join(values, ","))
+}
+
+func tenantFromParam(c *gin.Context) (*models.Tenant, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, err, "invalid tenant id")
+		return nil, false
+	}
+
+	tenant, err := models.GetTenantByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tenant not found"})
+		return nil, false
+	}
+	return tenant, true
+}
+
+// inTenant stops tenant admins from reaching records of other tenants' users
+func inTenant(c *gin.Context, userID uint) bool {
+	if !models.UserInTenant(userID, tenancy.TenantID(c)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "record not found"})
+		return false
+	}
+	return true
+}
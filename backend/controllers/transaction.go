@@ -6,13 +6,18 @@ import (
 	"backend/serializers"
 	"backend/state"
 	"backend/utils"
+	"backend/utils/apperrors"
+	"backend/utils/idempotency"
 	"backend/utils/mails"
+	"backend/utils/notifications"
 	"backend/utils/signing"
+	"backend/utils/tenancy"
 	"backend/utils/tokens"
+	"backend/utils/webhooks"
+	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -77,19 +82,16 @@ func GetUserTransactions(c *gin.Context) {
 		})
 		return
 	}
-	transactions, err := models.GetTransactionsByUserID(userId)
+	lq, ok := listQuery(c)
+	if !ok {
+		return
+	}
+	transactions, info, err := models.ListUserTransactions(userId, lq)
 	if err != nil {
-		c.JSON(400, gin.H{
-			"error": err.Error(),
-		})
+		listError(c, err)
 		return
 	}
-	c.JSON(200, gin.H{
-		"errors": false,
-		"data":   transactions,
-		"status": "retrieved transactions successfully",
-	})
-
+	respondList(c, "retrieved transactions successfully", transactions, info)
 }
 
 func GetTransactionsByHash(c *gin.Context) {
@@ -110,37 +112,64 @@ func GetTransactionsByHash(c *gin.Context) {
 
 }
 
+// onchainAsset is the asset OffRampTransaction sends on the chain
+func onchainAsset(chain string) string {
+	if strings.ToUpper(chain) == serializers.Chains.Celo {
+		return "CUSD"
+	}
+	return "USDC"
+}
+
 func OffRampTransaction(c *gin.Context) {
 	var input serializers.OffRampForm
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, apperrors.Invalid(err, "invalid request body"))
 		return
 	}
 
 	userId, err := tokens.ExtractUserID(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":  err.Error(),
-			"errors": true,
-		})
+		apperrors.Respond(c, apperrors.Wrap(apperrors.CodeUnauthorized, err, ""))
 		return
 	}
 	user, err := models.GetUserByID(userId)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": err.Error(),
-		})
+		apperrors.Respond(c, err)
+		return
+	}
+	saved, ok := payoutBeneficiary(c, user, models.BeneficiaryCrypto, input.BeneficiaryID, models.CryptoBeneficiaryKey(input.Chain, input.AccountAddress))
+	if !ok {
 		return
 	}
+	if saved != nil {
+		input.Chain, input.AccountAddress = saved.Chain, saved.Address
+	}
+	if !serializers.ValidAddress(strings.ToUpper(input.Chain), input.AccountAddress) {
+		utils.BadRequest(c, errors.New("invalid account address"), "account_address is not a valid "+strings.ToUpper(input.Chain)+" address")
+		return
+	}
+	reservation, ok := enforceLimit(c, user, models.RailOnchain, models.LimitOffRamp, input.Amount, onchainAsset(input.Chain))
+	if !ok {
+		return
+	}
+	// the transfer is recorded when the chain notifies it, so a submitted
+	// transfer's reservation is left to expire, a failed one releases it
+	submitted := false
+	defer func() {
+		if !submitted {
+			reservation.Release()
+		}
+	}()
 	amount, accountAddress, Chain := input.Amount, input.AccountAddress, input.Chain
 	switch strings.ToUpper(Chain) {
 	case serializers.Chains.Celo:
-
-		txHash, code, err := apis.PerformTransactionCelo(amount, accountAddress, user.PrivateKey, false)
+		idempotency.MarkSubmitted(c)
+		txHash, _, err := apis.PerformTransactionCelo(context.WithoutCancel(c.Request.Context()), amount, accountAddress, user.PrivateKey, false)
 		if err != nil {
-			c.JSON(code, gin.H{"error": err.Error(), "message": "transaction failed"})
+			apperrors.Respond(c, err)
 			return
 		}
+		submitted = true
 		data := map[string]interface{}{
 			"transaction_hash": txHash,
 		}
@@ -163,11 +192,13 @@ func OffRampTransaction(c *gin.Context) {
 			IssuerAccount: user.AccountAddress,
 			FromAccount:   user.AccountAddress,
 		}
-		txData, code, err := apis.PerformTransactionXLM(transferData)
+		idempotency.MarkSubmitted(c)
+		txData, _, err := apis.PerformTransactionXLM(context.WithoutCancel(c.Request.Context()), transferData)
 		if err != nil {
-			c.JSON(code, gin.H{"error": err.Error(), "message": "transaction failed"})
+			apperrors.Respond(c, err)
 			return
 		}
+		submitted = true
 		hash := txData["txId"]
 
 		data := map[string]interface{}{
@@ -184,7 +215,7 @@ func OffRampTransaction(c *gin.Context) {
 		return
 
 	}
-
+	utils.BadRequest(c, errors.New("unsupported chain"), "chain "+input.Chain+" is not supported")
 }
 
 func SignUrl(c *gin.Context) {
@@ -271,7 +302,8 @@ func AmountToReceive(c *gin.Context) {
 		})
 		return
 	case <-time.After(10 * time.Second):
-		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "Request timed out"})
+		// no rate came back to price the quote with
+		apperrors.Respond(c, apperrors.New(apperrors.CodeQuoteExpired, ""))
 		return
 	}
 
@@ -346,24 +378,25 @@ func GenerateReference(c *gin.Context) {
 func OnRampV2(c *gin.Context) {
 	var input serializers.OnRamp
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, apperrors.Invalid(err, "invalid request body"))
 		return
 	}
 
 	userId, err := tokens.ExtractUserID(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		apperrors.Respond(c, apperrors.Wrap(apperrors.CodeUnauthorized, err, ""))
 		return
 	}
 	user, err := models.GetUserByID(userId)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": err.Error(),
-		})
+		apperrors.Respond(c, err)
+		return
+	}
+	reservation, ok := enforceLimit(c, user, models.RailBank, models.LimitOnRamp, input.AssetAmount, input.Asset)
+	if !ok {
 		return
 	}
+	defer reservation.Release()
 	var deposit models.DepositRequest
 	deposit.UserID = user.ID
 	deposit.User = user
@@ -377,7 +410,7 @@ func OnRampV2(c *gin.Context) {
 	deposit.Status = "pending"
 	deposit.ProposedAsset = input.Asset
 	if err = deposit.SaveDepositRequest(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apperrors.Respond(c, err)
 		return
 	}
 	go func() {
@@ -389,7 +422,6 @@ func OnRampV2(c *gin.Context) {
 		for _, admin := range admins {
 			adminEmails = append(adminEmails, admin.Email)
 		}
-		log.Println("emails:", adminEmails)
 		onRamp := serializers.AdminOnRampSerializer{
 			Name:          "Admin",
 			BankName:      input.BankName,
@@ -411,44 +443,43 @@ func OnRampV2(c *gin.Context) {
 }
 
 func FetchOnRampRequests(c *gin.Context) {
+	lq, ok := listQuery(c)
+	if !ok {
+		return
+	}
 	ref := c.Query("ref")
 	currency := c.Query("currency")
-	fiatAmount := c.Query("fiat_amount")
 	AccountNumber := c.Query("account_number")
-	status := c.Query("status")
 	countryCode := c.Query("country_code")
 	cryptoAsset := c.Query("crypto_asset")
-	requests, err := models.FilterDepositRequests(ref, currency, fiatAmount, AccountNumber, status, countryCode, cryptoAsset)
+	// fiat_amount predates amount ranges and still matches one exact amount
+	if fiatAmount, err := strconv.ParseFloat(c.Query("fiat_amount"), 64); err == nil {
+		lq.MinAmount, lq.MaxAmount = &fiatAmount, &fiatAmount
+	}
+	requests, info, err := models.FilterDepositRequests(tenancy.TenantID(c), ref, currency, AccountNumber, countryCode, cryptoAsset, lq)
 	if err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		listError(c, err)
 		return
 	}
-	// log.Println(requests)
-	c.JSON(200, gin.H{
-		"errors": false,
-		"status": "requests fetched successfully",
-		"data":   requests,
-	})
+	respondList(c, "requests fetched successfully", requests, info)
 }
 
 func FetchOffRampRequests(c *gin.Context) {
+	lq, ok := listQuery(c)
+	if !ok {
+		return
+	}
 	chain := c.Query("chain")
 	hash := c.Query("hash")
 	address := c.Query("address")
 	AccountNumber := c.Query("account_number")
-	status := c.Query("status")
 
-	requests, err := models.FilterWithdrawalRequests(status, chain, hash, address, AccountNumber)
+	requests, info, err := models.FilterWithdrawalRequests(tenancy.TenantID(c), chain, hash, address, AccountNumber, lq)
 	if err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		listError(c, err)
 		return
 	}
-	// log.Println(requests)
-	c.JSON(200, gin.H{
-		"errors": false,
-		"status": "requests fetched successfully",
-		"data":   requests,
-	})
+	respondList(c, "requests fetched successfully", requests, info)
 }
 
 func GetOnRampRequest(c *gin.Context) {
@@ -459,6 +490,9 @@ func GetOnRampRequest(c *gin.Context) {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
+	if !inTenant(c, deposit.UserID) {
+		return
+	}
 	c.JSON(200, gin.H{
 		"errors": false,
 		"status": "request fetched successfully",
@@ -474,6 +508,9 @@ func GetOffRampRequest(c *gin.Context) {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
+	if !inTenant(c, withdrawal.UserID) {
+		return
+	}
 	c.JSON(200, gin.H{
 		"errors": false,
 		"status": "request fetched successfully",
@@ -489,6 +526,9 @@ func VerifyOnRamp(c *gin.Context) {
 		c.JSON(400, gin.H{"error": err.Error(), "message": "error fetching request"})
 		return
 	}
+	if !inTenant(c, deposit.UserID) {
+		return
+	}
 	var input serializers.OnRampAction
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(400, gin.H{"error": err.Error(), "message": "in"})
@@ -518,7 +558,7 @@ func VerifyOnRamp(c *gin.Context) {
 		var transaction models.Transaction
 		switch user.CryptoCurrency {
 		case "CELO":
-			txHash, code, err := apis.PerformTransactionCelo(deposit.AssetEquivalent, user.AccountAddress, masterWallet.PrivateKey, false)
+			txHash, code, err := apis.PerformTransactionCelo(context.WithoutCancel(c.Request.Context()), deposit.AssetEquivalent, user.AccountAddress, masterWallet.PrivateKey, false)
 			if err != nil {
 				c.JSON(code, gin.H{"error": err.Error(), "message": "transaction failed"})
 				return
@@ -527,7 +567,7 @@ func VerifyOnRamp(c *gin.Context) {
 			transaction.Chain = "CELO"
 			go func() {
 				time.AfterFunc(1*time.Minute, func() {
-					hash, _, _ := apis.PerformTransactionCelo(nativeAmount, user.AccountAddress, masterWallet.PrivateKey, true)
+					hash, _, _ := apis.PerformTransactionCelo(context.WithoutCancel(c.Request.Context()), nativeAmount, user.AccountAddress, masterWallet.PrivateKey, true)
 
 					var nativeTrans models.Transaction
 					nativeTrans.Address = user.AccountAddress
@@ -558,7 +598,7 @@ func VerifyOnRamp(c *gin.Context) {
 				IssuerAccount: masterWallet.PublicAddress,
 				FromAccount:   masterWallet.PublicAddress,
 			}
-			txData, code, err := apis.PerformTransactionXLM(transferData)
+			txData, code, err := apis.PerformTransactionXLM(context.WithoutCancel(c.Request.Context()), transferData)
 			if err != nil {
 				c.JSON(code, gin.H{"error": err.Error(), "message": "transaction failed"})
 				return
@@ -576,7 +616,7 @@ func VerifyOnRamp(c *gin.Context) {
 						Initialize:  true,
 						FromAccount: masterWallet.PublicAddress,
 					}
-					txData, _, _ := apis.PerformTransactionXLM(transferData)
+					txData, _, _ := apis.PerformTransactionXLM(context.WithoutCancel(c.Request.Context()), transferData)
 
 					var xlmTrans models.Transaction
 					id := txData["txId"]
@@ -617,6 +657,14 @@ func VerifyOnRamp(c *gin.Context) {
 		deposit.Status = "Rejected"
 	}
 	_ = deposit.UpdateDepositRequest()
+	switch deposit.Status {
+	case "Approved":
+		go notifications.Deposit(models.WebhookDepositApproved, deposit)
+		go webhooks.PublishDeposit(models.WebhookDepositApproved, deposit)
+	case "Rejected":
+		go notifications.Deposit(models.WebhookDepositRejected, deposit)
+		go webhooks.PublishDeposit(models.WebhookDepositRejected, deposit)
+	}
 	c.JSON(200, gin.H{
 		"errors": false,
 		"status": "request verified successfully",
@@ -627,19 +675,35 @@ func VerifyOnRamp(c *gin.Context) {
 func OffRampV2(c *gin.Context) {
 	var input serializers.OffRamp
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, apperrors.Invalid(err, "invalid request body"))
 		return
 	}
 
 	userId, err := tokens.ExtractUserID(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apperrors.Respond(c, apperrors.Wrap(apperrors.CodeUnauthorized, err, ""))
 		return
 	}
 
 	user, err := models.GetUserByID(userId)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		apperrors.Respond(c, err)
+		return
+	}
+
+	saved, ok := payoutBeneficiary(c, user, models.BeneficiaryBank, input.BeneficiaryID, bankBeneficiaryKey(input.BankName, input.AccountNumber))
+	if !ok {
+		return
+	}
+	if saved != nil {
+		input.BankName, input.AccountNumber, input.AccountName = saved.Bank.Name, saved.AccountNumber, saved.AccountName
+	}
+	reservation, ok := enforceLimit(c, user, models.RailBank, models.LimitOffRamp, input.CryptoAmount, input.Asset)
+	if !ok {
+		return
+	}
+	defer reservation.Release()
+	if !screenBeneficiary(c, user, input.AccountName, models.RailBank) {
 		return
 	}
 
@@ -660,13 +724,14 @@ func OffRampV2(c *gin.Context) {
 
 	masterWallet, err := models.FetchMasterWallet(input.Chain)
 	if err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, apperrors.Wrap(apperrors.CodeServiceUnavailable, err, "payouts on this chain are unavailable"))
 		return
 	}
 
 	trans := models.Transaction{}
-	if err := processTransaction(&trans, &withdrawal, input, user, masterWallet); err != nil {
-		c.JSON(err.StatusCode, gin.H{"error": err.Error(), "message": "transaction failed"})
+	idempotency.MarkSubmitted(c)
+	if err := processTransaction(context.WithoutCancel(c.Request.Context()), &trans, &withdrawal, input, user, masterWallet); err != nil {
+		apperrors.Respond(c, err)
 		return
 	}
 	trans.UserID = user.ID
@@ -675,16 +740,18 @@ func OffRampV2(c *gin.Context) {
 	trans.TransactionType = "Fungible Token"
 	trans.TransactionSubType = "Withdrawal"
 	if err := trans.SaveTransaction(); err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		apperrors.Respond(c, err)
 		return
 	}
 
 	if err = withdrawal.SaveWithdrawalRequest(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apperrors.Respond(c, err)
 		return
 	}
 
 	go notifyAdmins(input, trans.Hash)
+	go notifications.Withdrawal(models.WebhookWithdrawalSubmitted, &withdrawal)
+	go webhooks.PublishWithdrawal(models.WebhookWithdrawalSubmitted, &withdrawal)
 
 	c.JSON(200, gin.H{
 		"errors": false,
@@ -693,12 +760,12 @@ func OffRampV2(c *gin.Context) {
 	})
 }
 
-func processTransaction(trans *models.Transaction, withdrawal *models.WithdrawalRequest, input serializers.OffRamp, user models.User, masterWallet models.MasterWallet) *apiError {
+func processTransaction(ctx context.Context, trans *models.Transaction, withdrawal *models.WithdrawalRequest, input serializers.OffRamp, user models.User, masterWallet models.MasterWallet) error {
 	switch input.Chain {
 	case "CELO":
-		hash, code, err := apis.PerformTransactionCelo(withdrawal.CryptoAmount, masterWallet.PublicAddress, user.PrivateKey, false)
+		hash, _, err := apis.PerformTransactionCelo(ctx, withdrawal.CryptoAmount, masterWallet.PublicAddress, user.PrivateKey, false)
 		if err != nil {
-			return &apiError{code, err}
+			return err
 		}
 		trans.Hash = hash
 		trans.Chain = "CELO"
@@ -715,9 +782,9 @@ func processTransaction(trans *models.Transaction, withdrawal *models.Withdrawal
 			IssuerAccount: user.AccountAddress,
 			FromAccount:   user.AccountAddress,
 		}
-		txData, code, err := apis.PerformTransactionXLM(transferData)
+		txData, _, err := apis.PerformTransactionXLM(ctx, transferData)
 		if err != nil {
-			return &apiError{code, err}
+			return err
 		}
 		hash := txData["txId"]
 		trans.Hash = hash
@@ -751,15 +818,6 @@ func notifyAdmins(input serializers.OffRamp, hash string) {
 	_ = mails.AdminOffRampMail(adminEmails, offRamp)
 }
 
-type apiError struct {
-	StatusCode int
-	Err        error
-}
-
-func (e *apiError) Error() string {
-	return e.Err.Error()
-}
-
 func VerifyOffRamp(c *gin.Context) {
 	id := c.Param("id")
 	intId, _ := strconv.Atoi(id)
@@ -768,6 +826,9 @@ func VerifyOffRamp(c *gin.Context) {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
+	if !inTenant(c, withdrawal.UserID) {
+		return
+	}
 	var input serializers.OffRampAction
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(400, gin.H{"error": err.Error()})
@@ -779,32 +840,9 @@ func VerifyOffRamp(c *gin.Context) {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
-	user, err := models.GetUserByID(withdrawal.UserID)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": err.Error(),
-		})
-		return
-	}
-	go func() {
-		floatAmount, err := strconv.ParseFloat(withdrawal.EquivalentFiat, 64)
-		if err != nil {
-			panic(err)
-		}
-		amount := utils.FormatAmountWithCommas(floatAmount)
-		data := serializers.UserOffRampMail{
-			Name:          fmt.Sprintf("%s %s", user.LastName, user.FirstName),
-			Amount:        amount,
-			Currency:      withdrawal.FiatCurrency,
-			Ref:           withdrawal.BankRef,
-			BankName:      withdrawal.BankName,
-			AccountNumber: withdrawal.AccountNumber,
-			AccountName:   withdrawal.AccountName,
-		}
-		emails := []string{user.Email}
-		_ = mails.UserOffRampMail(emails, data)
-	}()
 	_ = withdrawal.UpdateWithdrawalRequest()
+	go notifications.Withdrawal(models.WebhookWithdrawalCompleted, withdrawal)
+	go webhooks.PublishWithdrawal(models.WebhookWithdrawalCompleted, withdrawal)
 	c.JSON(200, gin.H{
 		"errors": false,
 		"status": "request verified successfully",
@@ -832,10 +870,7 @@ func GetExchangeRate(c *gin.Context) {
 			"status": error.Error(),
 		})
 	case <-time.After(10 * time.Second):
-		c.JSON(408, gin.H{
-			"errors": true,
-			"status": "Request timed out",
-		})
+		apperrors.Respond(c, apperrors.New(apperrors.CodeQuoteExpired, ""))
 	}
 }
 
@@ -880,7 +915,7 @@ func MobileMoneyAmountToReceive(c *gin.Context) {
 		})
 		return
 	case <-time.After(15 * time.Second):
-		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "Request timed out"})
+		apperrors.Respond(c, apperrors.New(apperrors.CodeQuoteExpired, ""))
 		return
 	}
 
@@ -889,29 +924,37 @@ func MobileMoneyAmountToReceive(c *gin.Context) {
 func MobileMoneyOnRamp(c *gin.Context) {
 	var input serializers.Payment
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, apperrors.Invalid(err, "invalid request body"))
 		return
 	}
-	input.DeveloperFee = "0.25" // update is needed
+	input.DeveloperFee = tenancy.DeveloperFee(c, models.RailMobileMoney)
 
 	if input.Transfer.DigitalAsset == "CUSD" {
 		input.Transfer.DigitalAsset = "cUSD"
 	}
 
-	resp, err := apis.OnRampMobileMoney(input)
+	userId, err := tokens.ExtractUserID(c)
 	if err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperrors.Respond(c, apperrors.Wrap(apperrors.CodeUnauthorized, err, ""))
 		return
 	}
-	userId, err := tokens.ExtractUserID(c)
+
+	user, err := models.GetUserByID(userId)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apperrors.Respond(c, err)
+		return
+	}
+	amount := strconv.Itoa(input.Collection.Amount)
+	reservation, ok := enforceLimit(c, user, models.RailMobileMoney, models.LimitOnRamp, amount, input.Collection.CountryCode)
+	if !ok {
 		return
 	}
+	defer reservation.Release()
 
-	user, err := models.GetUserByID(userId)
+	idempotency.MarkSubmitted(c)
+	resp, err := apis.OnRampMobileMoney(context.WithoutCancel(c.Request.Context()), input)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		apperrors.Respond(c, err)
 		return
 	}
 	var request models.HurupayRequest
@@ -926,8 +969,9 @@ func MobileMoneyOnRamp(c *gin.Context) {
 	request.CryptoChain = input.Transfer.DigitalNetwork
 	request.RequestId = resp.Data.CollectionRequestID
 	request.CountryCurrency = input.Collection.CountryCode
+	request.DeveloperFee = input.DeveloperFee
 	if err := request.SaveHurupayRequest(); err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		apperrors.Respond(c, err)
 		return
 	}
 
@@ -942,39 +986,61 @@ func MobileMoneyOnRamp(c *gin.Context) {
 func MobileMoneyOffRamp(c *gin.Context) {
 	userId, err := tokens.ExtractUserID(c)
 	if err != nil {
-		respondWithError(c, http.StatusBadRequest, err.Error())
+		apperrors.Respond(c, apperrors.Wrap(apperrors.CodeUnauthorized, err, ""))
 		return
 	}
 
 	user, err := models.GetUserByID(userId)
 	if err != nil {
-		respondWithError(c, http.StatusNotFound, err.Error())
+		apperrors.Respond(c, err)
 		return
 	}
 
 	var input serializers.MobileOffRamp
 	if err := c.ShouldBindJSON(&input); err != nil {
-		respondWithError(c, http.StatusBadRequest, err.Error())
+		apperrors.Respond(c, apperrors.Invalid(err, "invalid request body"))
 		return
 	}
 
+	saved, ok := payoutBeneficiary(c, user, models.BeneficiaryMobileMoney, input.BeneficiaryID, models.MobileBeneficiaryKey(input.CountryCode, input.PhoneNumber))
+	if !ok {
+		return
+	}
+	if saved != nil {
+		input.CustomerName, input.PhoneNumber, input.CountryCode = saved.AccountName, saved.PhoneNumber, saved.CountryCode
+		if saved.MobileNetwork != "" {
+			input.MobileProvider = saved.MobileNetwork
+		}
+	}
+
 	if err := validateOffRampRequest(input, user); err != nil {
-		respondWithError(c, http.StatusBadRequest, err.Error())
+		apperrors.Respond(c, apperrors.Invalid(err, err.Error()))
+		return
+	}
+
+	reservation, ok := enforceLimit(c, user, models.RailMobileMoney, models.LimitOffRamp, input.AmountSending, input.Token)
+	if !ok {
+		return
+	}
+	defer reservation.Release()
+	if !screenBeneficiary(c, user, input.CustomerName, models.RailMobileMoney) {
 		return
 	}
 
 	data := createTransactionRequest(input)
-	resp, err := apis.OffRampMobileMoney(data)
+	idempotency.MarkSubmitted(c)
+	resp, err := apis.OffRampMobileMoney(context.WithoutCancel(c.Request.Context()), data)
 	if err != nil {
-		respondWithError(c, http.StatusBadRequest, err.Error())
+		apperrors.Respond(c, err)
 		return
 	}
-	if err := storeHurupayRequest(input, user, resp.Data.PayoutRequestID); err != nil {
-		respondWithError(c, http.StatusBadRequest, err.Error())
+	developerFee := tenancy.DeveloperFee(c, models.RailMobileMoney)
+	if err := storeHurupayRequest(input, user, resp.Data.PayoutRequestID, developerFee); err != nil {
+		apperrors.Respond(c, err)
 		return
 	}
 
-	go handleOffRampTransaction(input, user, resp)
+	go handleOffRampTransaction(context.WithoutCancel(c.Request.Context()), input, user, resp, developerFee)
 
 	c.JSON(http.StatusOK, gin.H{
 		"errors": false,
@@ -982,11 +1048,6 @@ func MobileMoneyOffRamp(c *gin.Context) {
 	})
 }
 
-// Helper to send error responses
-func respondWithError(c *gin.Context, code int, message string) {
-	c.JSON(code, gin.H{"error": message})
-}
-
 // Validate the input and user's account address
 func validateOffRampRequest(input serializers.MobileOffRamp, user models.User) error {
 	if input.Token == "CUSD" {
@@ -1009,40 +1070,39 @@ func createTransactionRequest(input serializers.MobileOffRamp) serializers.Trans
 }
 
 // Handle the transaction in a goroutine
-func handleOffRampTransaction(input serializers.MobileOffRamp, user models.User, resp apis.PayoutResponse) {
+func handleOffRampTransaction(ctx context.Context, input serializers.MobileOffRamp, user models.User, resp apis.PayoutResponse, developerFee string) {
 	var hash string
 	var err error
 
 	switch input.Network {
 	case "CELO":
-		hash, err = executeCeloTransaction(input, user, resp.Data.EscrowAddress)
+		hash, err = executeCeloTransaction(ctx, input, user, resp.Data.EscrowAddress)
 	case "XLM":
-		hash, err = executeXlmTransaction(input, user, resp.Data.EscrowAddress)
+		hash, err = executeXlmTransaction(ctx, input, user, resp.Data.EscrowAddress)
 	}
 
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "mobile money offramp transfer failed", "network", input.Network, "payout_request_id", resp.Data.PayoutRequestID, "error", err)
 	}
-	log.Println("hash: ", hash)
 
-	transaction := prepareTransactionDetails(input, resp, hash)
+	transaction := prepareTransactionDetails(input, resp, hash, developerFee)
 
-	output, err := apis.OffRampMobileFinalize(transaction)
+	output, err := apis.OffRampMobileFinalize(ctx, transaction)
 	if err != nil || output.Data.ResultCode != 0 {
-		log.Println("hurrupay failed to finalize", err)
+		slog.ErrorContext(ctx, "hurupay payout not finalized", "payout_request_id", resp.Data.PayoutRequestID, "result_code", output.Data.ResultCode, "error", err)
 		return
 	}
 
 }
 
 // Execute the CELO transaction
-func executeCeloTransaction(input serializers.MobileOffRamp, user models.User, escrowAddress string) (string, error) {
-	hash, _, err := apis.PerformTransactionCelo(input.AmountSending, escrowAddress, user.PrivateKey, false)
+func executeCeloTransaction(ctx context.Context, input serializers.MobileOffRamp, user models.User, escrowAddress string) (string, error) {
+	hash, _, err := apis.PerformTransactionCelo(ctx, input.AmountSending, escrowAddress, user.PrivateKey, false)
 	return hash, err
 }
 
 // Execute the XLM transaction
-func executeXlmTransaction(input serializers.MobileOffRamp, user models.User, escrowAddress string) (string, error) {
+func executeXlmTransaction(ctx context.Context, input serializers.MobileOffRamp, user models.User, escrowAddress string) (string, error) {
 	transferData := serializers.TransferXLM{
 		Amount:        input.AmountSending,
 		To:            escrowAddress,
@@ -1052,7 +1112,7 @@ func executeXlmTransaction(input serializers.MobileOffRamp, user models.User, es
 		IssuerAccount: user.AccountAddress,
 		FromAccount:   user.AccountAddress,
 	}
-	txData, _, err := apis.PerformTransactionXLM(transferData)
+	txData, _, err := apis.PerformTransactionXLM(ctx, transferData)
 	if err != nil {
 		return "", err
 	}
@@ -1061,7 +1121,7 @@ func executeXlmTransaction(input serializers.MobileOffRamp, user models.User, es
 }
 
 // Prepare the transaction details
-func prepareTransactionDetails(input serializers.MobileOffRamp, resp apis.PayoutResponse, hash string) serializers.TransactionDetails {
+func prepareTransactionDetails(input serializers.MobileOffRamp, resp apis.PayoutResponse, hash, developerFee string) serializers.TransactionDetails {
 	return serializers.TransactionDetails{
 		Collection: struct {
 			TransactionHash string `json:"transactionHash"`
@@ -1085,13 +1145,13 @@ func prepareTransactionDetails(input serializers.MobileOffRamp, resp apis.Payout
 			CountryCode:  input.CountryCode,
 			Network:      input.MobileProvider,
 		},
-		DeveloperFee: "0.25",
+		DeveloperFee: developerFee,
 	}
 
 }
 
 // Store the Hurupay request to the database
-func storeHurupayRequest(input serializers.MobileOffRamp, user models.User, requestId string) error {
+func storeHurupayRequest(input serializers.MobileOffRamp, user models.User, requestId, developerFee string) error {
 	request := models.HurupayRequest{
 		Amount:          input.AmountSending,
 		UserId:          int32(user.ID),
@@ -1104,6 +1164,7 @@ func storeHurupayRequest(input serializers.MobileOffRamp, user models.User, requ
 		CryptoChain:     input.Network,
 		RequestId:       requestId,
 		CountryCurrency: input.CountryCode,
+		DeveloperFee:    developerFee,
 	}
 	return request.SaveHurupayRequest()
 }
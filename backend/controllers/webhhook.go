@@ -5,7 +5,10 @@ import (
 	"backend/models"
 	"backend/serializers"
 	"backend/utils"
-	"log"
+	"backend/utils/notifications"
+	"backend/utils/webhooks"
+	"context"
+	"log/slog"
 	"strings"
 	"time"
 
@@ -15,23 +18,22 @@ import (
 func OnRampNotification(c *gin.Context) {
 	var input serializers.Event
 	if err := c.ShouldBindJSON(&input); err != nil {
-		log.Println("error", err.Error())
+		slog.WarnContext(c.Request.Context(), "invalid onramp notification", "error", err)
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
-	log.Println("onramp request body: ", input)
 
 	// Fetch the request based on the EventObject ID
 	request, err := models.GetHurupayRequestRequestId(input.EventObject.ID)
 	if err != nil {
-		log.Println("error", err.Error())
+		slog.WarnContext(c.Request.Context(), "onramp notification for unknown request", "event_id", input.EventObject.ID, "error", err)
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
 
 	trans, err := createTransaction(request, input)
 	if err != nil {
-		log.Println("error", err.Error())
+		slog.ErrorContext(c.Request.Context(), "onramp notification transaction not saved", "event_id", input.EventObject.ID, "error", err)
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
@@ -39,14 +41,14 @@ func OnRampNotification(c *gin.Context) {
 	// Fetch the master wallet for the user's cryptocurrency
 	masterWallet, err := models.FetchMasterWallet(request.User.CryptoCurrency)
 	if err != nil {
-		log.Println("error", err.Error())
+		slog.ErrorContext(c.Request.Context(), "master wallet not found", "chain", request.User.CryptoCurrency, "error", err)
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
 
 	// Process the event based on the event type
-	if err = processEvent(input.EventType, *request, *trans, masterWallet); err != nil {
-		log.Println("error", err.Error())
+	if err = processEvent(c.Request.Context(), input.EventType, *request, *trans, masterWallet); err != nil {
+		slog.ErrorContext(c.Request.Context(), "onramp notification not processed", "event_id", input.EventObject.ID, "event_type", input.EventType, "error", err)
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
@@ -94,13 +96,15 @@ func createTransaction(request *models.HurupayRequest, input serializers.Event)
 }
 
 // Process event based on event type
-func processEvent(eventType string, request models.HurupayRequest, trans models.Transaction, masterWallet models.MasterWallet) error {
+func processEvent(ctx context.Context, eventType string, request models.HurupayRequest, trans models.Transaction, masterWallet models.MasterWallet) error {
 	switch eventType {
 	case "collections.successful":
 		request.Status = "Completed"
 		trans.Status = "Completed"
 		_ = trans.UpdateTransaction()
 		_ = request.UpdateHurupayRequest()
+		go notifications.Hurupay(eventType, &request)
+		go webhooks.PublishHurupay(eventType, &request)
 
 		nativeAmount, err := utils.PerformDepositofNativeCalculation(trans.Amount, "USD", request.User.CryptoCurrency)
 		if err != nil {
@@ -108,32 +112,40 @@ func processEvent(eventType string, request models.HurupayRequest, trans models.
 		}
 
 		// Process native transaction based on the cryptocurrency
-		return processNativeTransaction(request.User.CryptoCurrency, nativeAmount, request, masterWallet)
+		return processNativeTransaction(ctx, request.User.CryptoCurrency, nativeAmount, request, masterWallet)
 
 	default:
 		request.Status = strings.ToUpper(utils.LastPart(eventType, "."))
 		trans.Status = strings.ToUpper(utils.LastPart(eventType, "."))
 		// Save the updated request and transaction
 		_ = trans.UpdateTransaction()
-		return request.UpdateHurupayRequest()
+		if err := request.UpdateHurupayRequest(); err != nil {
+			return err
+		}
+		go notifications.Hurupay(eventType, &request)
+		go webhooks.PublishHurupay(eventType, &request)
+		return nil
 	}
 }
 
-// Handle native transaction processing for CELO and XLM
-func processNativeTransaction(crypto string, nativeAmount string, request models.HurupayRequest, masterWallet models.MasterWallet) error {
+// Handle native transaction processing for CELO and XLM. The transfers run
+// after the notification is answered, so they keep the request ID of ctx but
+// not its cancellation.
+func processNativeTransaction(ctx context.Context, crypto string, nativeAmount string, request models.HurupayRequest, masterWallet models.MasterWallet) error {
+	ctx = context.WithoutCancel(ctx)
 	switch crypto {
 	case "CELO":
-		go processCeloTransaction(nativeAmount, &request.User, &masterWallet)
+		go processCeloTransaction(ctx, nativeAmount, &request.User, &masterWallet)
 	case "XLM":
-		go processXlmTransaction(nativeAmount, &request.User, &masterWallet)
+		go processXlmTransaction(ctx, nativeAmount, &request.User, &masterWallet)
 	}
 	return nil
 }
 
 // Process CELO native transaction
-func processCeloTransaction(nativeAmount string, user *models.User, masterWallet *models.MasterWallet) {
+func processCeloTransaction(ctx context.Context, nativeAmount string, user *models.User, masterWallet *models.MasterWallet) {
 	time.AfterFunc(1*time.Minute, func() {
-		hash, _, _ := apis.PerformTransactionCelo(nativeAmount, user.AccountAddress, masterWallet.PrivateKey, true)
+		hash, _, _ := apis.PerformTransactionCelo(ctx, nativeAmount, user.AccountAddress, masterWallet.PrivateKey, true)
 
 		nativeTrans := createNativeTransaction(user, masterWallet, hash, nativeAmount, "CELO")
 		_ = nativeTrans.SaveTransaction()
@@ -141,7 +153,7 @@ func processCeloTransaction(nativeAmount string, user *models.User, masterWallet
 }
 
 // Process XLM native transaction
-func processXlmTransaction(nativeAmount string, user *models.User, masterWallet *models.MasterWallet) {
+func processXlmTransaction(ctx context.Context, nativeAmount string, user *models.User, masterWallet *models.MasterWallet) {
 	time.AfterFunc(1*time.Minute, func() {
 		transferData := serializers.TransferXLM{
 			Amount:      nativeAmount,
@@ -150,7 +162,7 @@ func processXlmTransaction(nativeAmount string, user *models.User, masterWallet
 			Initialize:  true,
 			FromAccount: masterWallet.PublicAddress,
 		}
-		txData, _, _ := apis.PerformTransactionXLM(transferData)
+		txData, _, _ := apis.PerformTransactionXLM(ctx, transferData)
 
 		id := txData["txId"]
 		nativeTrans := createNativeTransaction(user, masterWallet, id, nativeAmount, "XLM")
@@ -189,7 +201,12 @@ func processOffRampEvent(eventType string, request *models.HurupayRequest, trans
 
 	}
 	_ = trans.UpdateTransaction()
-	return request.UpdateHurupayRequest()
+	if err := request.UpdateHurupayRequest(); err != nil {
+		return err
+	}
+	go notifications.Hurupay(eventType, request)
+	go webhooks.PublishHurupay(eventType, request)
+	return nil
 }
 
 func OffRampNotification(c *gin.Context) {
@@ -198,7 +215,6 @@ func OffRampNotification(c *gin.Context) {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
-	log.Println("offramp request body: ", input)
 
 	// Fetch the request based on the EventObject ID
 	request, err := models.GetHurupayRequestRequestId(input.EventObject.ID)
@@ -224,7 +240,6 @@ func BorderlessNotification(c *gin.Context) {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
-	log.Println("borderless request body: ", input)
 	flag, err := utils.BorderlessWebhookHandler(input)
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
@@ -0,0 +1,261 @@
+package controllers
+
+import (
+	"backend/apis"
+	"backend/middlewares"
+	"backend/models"
+	"backend/serializers"
+	"backend/state"
+	"backend/utils"
+	"backend/utils/webhooks"
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func CreateWebhookEndpoint(c *gin.Context) {
+	var input serializers.CreateWebhookEndpoint
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequest(c, err, "invalid request payload")
+		return
+	}
+	if err := validateWebhookURL(c.Request.Context(), input.URL); err != nil {
+		utils.BadRequest(c, err, "invalid webhook url")
+		return
+	}
+
+	endpoint := models.WebhookEndpoint{URL: input.URL, Description: input.Description}
+	if err := endpoint.SetEventTypes(input.EventTypes); err != nil {
+		utils.BadRequest(c, err, "invalid event types")
+		return
+	}
+
+	secret, ok := setWebhookSecret(c, &endpoint)
+	if !ok {
+		return
+	}
+	if err := endpoint.CreateWebhookEndpoint(webhookOwner(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data":    endpoint,
+		"secret":  secret,
+		"message": "store the signing secret safely, it will not be shown again",
+	})
+}
+
+func GetWebhookEndpoints(c *gin.Context) {
+	endpoints, err := models.GetWebhookEndpoints(webhookOwner(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": endpoints, "events": models.AllWebhookEvents})
+}
+
+func GetWebhookEndpoint(c *gin.Context) {
+	endpoint, ok := webhookEndpointFromParam(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": endpoint})
+}
+
+func UpdateWebhookEndpoint(c *gin.Context) {
+	endpoint, ok := webhookEndpointFromParam(c)
+	if !ok {
+		return
+	}
+
+	var input serializers.UpdateWebhookEndpoint
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequest(c, err, "invalid request payload")
+		return
+	}
+
+	if input.URL != "" {
+		if err := validateWebhookURL(c.Request.Context(), input.URL); err != nil {
+			utils.BadRequest(c, err, "invalid webhook url")
+			return
+		}
+		endpoint.URL = input.URL
+	}
+	if input.Description != nil {
+		endpoint.Description = *input.Description
+	}
+	if input.EventTypes != nil {
+		if err := endpoint.SetEventTypes(input.EventTypes); err != nil {
+			utils.BadRequest(c, err, "invalid event types")
+			return
+		}
+	}
+	if input.Active != nil {
+		if *input.Active {
+			endpoint.Enable()
+		} else {
+			endpoint.Active = false
+		}
+	}
+
+	if err := endpoint.UpdateWebhookEndpoint(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": endpoint})
+}
+
+func DeleteWebhookEndpoint(c *gin.Context) {
+	endpoint, ok := webhookEndpointFromParam(c)
+	if !ok {
+		return
+	}
+	if err := endpoint.DeleteWebhookEndpoint(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "webhook endpoint deleted"})
+}
+
+func RotateWebhookSecret(c *gin.Context) {
+	endpoint, ok := webhookEndpointFromParam(c)
+	if !ok {
+		return
+	}
+
+	secret, ok := setWebhookSecret(c, endpoint)
+	if !ok {
+		return
+	}
+	if err := endpoint.UpdateWebhookEndpoint(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    endpoint,
+		"secret":  secret,
+		"message": "the previous secret no longer signs deliveries, store the new one safely",
+	})
+}
+
+func GetWebhookDeliveries(c *gin.Context) {
+	endpoint, ok := webhookEndpointFromParam(c)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 200 {
+		limit = 50
+	}
+	deliveries, err := models.GetWebhookDeliveries(endpoint.ID, c.Query("status"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": deliveries})
+}
+
+func GetWebhookDelivery(c *gin.Context) {
+	delivery, ok := webhookDeliveryFromParam(c)
+	if !ok {
+		return
+	}
+
+	attempts, err := models.GetWebhookAttempts(delivery.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": delivery, "attempts": attempts})
+}
+
+// RedeliverWebhook queues the delivery's event again, the worker picks it up
+// on its next tick
+func RedeliverWebhook(c *gin.Context) {
+	delivery, ok := webhookDeliveryFromParam(c)
+	if !ok {
+		return
+	}
+
+	redelivery, err := delivery.Redeliver()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"data": redelivery, "message": "delivery queued"})
+}
+
+func webhookOwner(c *gin.Context) models.WebhookOwner {
+	owner, _ := c.Get(middlewares.WebhookOwnerKey)
+	return owner.(models.WebhookOwner)
+}
+
+// validateWebhookURL requires https outside of development so signed payloads
+// never travel in the clear, and a host that resolves to public addresses only
+func validateWebhookURL(ctx context.Context, rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return errors.New("url must be absolute")
+	}
+	if parsed.Scheme != "https" && (parsed.Scheme != "http" || state.AppConfig.AppEnv == "production") {
+		return errors.New("url must use https")
+	}
+	return webhooks.ValidateURL(ctx, rawURL)
+}
+
+func setWebhookSecret(c *gin.Context, endpoint *models.WebhookEndpoint) (string, bool) {
+	secret, err := models.NewWebhookSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return "", false
+	}
+	encrypted, err := apis.Encrypt(secret)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return "", false
+	}
+	endpoint.Secret = encrypted
+	return secret, true
+}
+
+func webhookEndpointFromParam(c *gin.Context) (*models.WebhookEndpoint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, err, "invalid webhook endpoint id")
+		return nil, false
+	}
+
+	endpoint, err := models.GetWebhookEndpoint(webhookOwner(c), uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "webhook endpoint not found"})
+		return nil, false
+	}
+	return endpoint, true
+}
+
+func webhookDeliveryFromParam(c *gin.Context) (*models.WebhookDelivery, bool) {
+	endpoint, ok := webhookEndpointFromParam(c)
+	if !ok {
+		return nil, false
+	}
+
+	id, err := strconv.ParseUint(c.Param("deliveryId"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, err, "invalid delivery id")
+		return nil, false
+	}
+
+	delivery, err := models.GetWebhookDelivery(endpoint.ID, uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "delivery not found"})
+		return nil, false
+	}
+	return delivery, true
+}
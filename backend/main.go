@@ -5,6 +5,21 @@ import (
 	"backend/middlewares"
 	"backend/models"
 	"backend/state"
+	"backend/utils/cases"
+	"backend/utils/idempotency"
+	"backend/utils/logging"
+	"backend/utils/mails"
+	"backend/utils/monitoring"
+	"backend/utils/notifications"
+	"backend/utils/openapi"
+	"backend/utils/ratelimit"
+	"backend/utils/realtime"
+	"backend/utils/reconciliation"
+	"backend/utils/screening"
+	"backend/utils/statements"
+	"backend/utils/webhooks"
+	"log/slog"
+	"os"
 	"time"
 
 	//"github.com/gin-contrib/cors"
@@ -18,14 +33,14 @@ func CORS() gin.HandlerFunc {
 	// TO allow CORS
 	return func(c *gin.Context) {
 		origin := c.Request.Header.Get("Origin")
-		allowedOrigins := []string{"http://localhost:3000", "localhost:8080", "34.227.150.136", "apis.greyboxpay.com", "wallet.greyboxpay.com", "https://wallet.greyboxpay.com", "https://apis.greyboxpay.com"} // Add other allowed origins if needed
-
-		if slices.Contains(allowedOrigins, origin) {
+		// platform origins come from the config, tenant origins from the database
+		if slices.Contains(state.AppConfig.AllowedOrigins, origin) || slices.Contains(models.TenantOrigins(), origin) {
 			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
 			c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 		}
 
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, Idempotency-Key, X-Request-ID")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, Idempotent-Replayed")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
@@ -48,17 +63,91 @@ func main() {
 	// load env
 	state.LoadEnv()
 
+	// JSON logs tagged with request IDs, secrets and personal data redacted
+	logging.Setup(state.AppConfig.LogLevel, state.AppConfig.LogFormat)
+
 	//gin.SetMode(gin.ReleaseMode)
 
 	db := models.InitializeDB()
 	models.Migrate(db)
-	r := gin.Default()
+
+	// retry outbound webhook deliveries in the background
+	go webhooks.StartWorker(15 * time.Second)
+
+	// send queued mails and retry failed ones
+	if err := mails.StartOutbox(30 * time.Second); err != nil {
+		slog.Error("mail transport not set up", "error", err)
+		os.Exit(1)
+	}
+
+	// build queued statement exports and remove expired files
+	go statements.StartWorker(time.Minute)
+
+	// compare local records with Borderless, Hurupay and the chains
+	go reconciliation.StartScheduler(6 * time.Hour)
+
+	// load sanctions and PEP lists, and their updates, from SANCTIONS_DIR
+	go screening.StartScheduler(time.Hour)
+
+	// evaluate new requests against the transaction monitoring rules
+	go monitoring.StartWorker(time.Minute)
+
+	// flag compliance cases left undecided past their SLA
+	go cases.StartSLAWatcher(15 * time.Minute)
+
+	// forget idempotency keys and partner nonces once they can no longer be
+	// replayed
+	go idempotency.StartCleaner(time.Hour)
+
+	// forget rate limit counters once their window ended
+	go ratelimit.StartCleaner(time.Hour)
+
+	// email, SMS and WhatsApp channels for user notifications
+	notifications.Setup()
+
+	// stream model changes to connected clients, fanned out to every instance
+	// through LISTEN/NOTIFY when running on PostgreSQL
+	var eventBackend realtime.Backend
+	if dbConfig := models.LoadDBConfigFromEnv(); !dbConfig.UseSQLite {
+		eventBackend = &realtime.PostgresBackend{DSN: dbConfig.DSN()}
+	}
+	realtime.Start(eventBackend)
+
+	r := newRouter()
+
+	undocumented := openapi.Load(r.Routes(), controllers.APIDocs)
+	for _, route := range undocumented {
+		slog.Warn("route missing from the OpenAPI spec", "route", route)
+	}
+
+	r.Run(":8080")
+}
+
+// newRouter registers the middlewares and every route of the API
+func newRouter() *gin.Engine {
+	r := gin.New()
+	// the client IP keys partner allowlists and rate limits, so only
+	// configured proxies may set it through X-Forwarded-For
+	if err := r.SetTrustedProxies(state.AppConfig.TrustedProxies); err != nil {
+		slog.Error("trusted proxies not set", "error", err)
+		os.Exit(1)
+	}
+	r.Use(gin.Recovery())
+
+	// tag every request with an id and log it, answer errors in one shape and
+	// hold requests and responses to the OpenAPI spec
+	r.Use(middlewares.RequestID(), middlewares.RequestLogger(), middlewares.RenderErrors(), middlewares.Contract())
 
 	//config := cors.DefaultConfig()
 	//config.AllowOrigins = []string{"http://localhost:3000"}
 	r.Use(CORS())
 
-	r.Use(middlewares.AllowedHosts([]string{"localhost:3000", "http://localhost:3000", "localhost:8080", "34.227.150.136", "apis.greyboxpay.com", "wallet.greyboxpay.com"}))
+	r.Use(middlewares.AllowedHosts(state.AppConfig.AllowedHosts))
+	r.Use(middlewares.ResolveTenant())
+
+	// the OpenAPI spec of every route below and Swagger UI to browse it
+	r.GET("/api/docs", controllers.GetAPIDocs)
+	r.GET("/api/docs/openapi.json", controllers.GetAPISpec)
 
 	chains := r.Group("/api/v1/chains")
 	{
@@ -79,15 +168,18 @@ func main() {
 
 	public := r.Group("/api/v1/user")
 	{
-		public.POST("/login", controllers.FetchAuthenticatedUserToken)
-		public.POST("/forget-password", controllers.ForgetPassword)
-		public.POST("/reset-password", controllers.ResetPassword)
+		public.POST("/login", middlewares.RateLimit("login", 10, time.Minute), controllers.FetchAuthenticatedUserToken)
+		public.POST("/forget-password", middlewares.RateLimit("forget-password", 5, 15*time.Minute), controllers.ForgetPassword)
+		public.POST("/reset-password", middlewares.RateLimit("reset-password", 10, 15*time.Minute), controllers.ResetPassword)
+		public.POST("/unlock-account", middlewares.RateLimit("unlock-account", 10, 15*time.Minute), controllers.UnlockAccount)
 	}
 
 	publicV2 := r.Group("/api/v2/user")
 	{
 		publicV2.POST("/register", controllers.CreateAccountV2)
-		publicV2.Use(middlewares.JwtAuthMiddleware()).POST("/account", controllers.CreateBorderlessVirtualAccount)
+		publicV2.POST("/verify-email", middlewares.RateLimit("verify-email", 10, 15*time.Minute), controllers.VerifyEmail)
+		publicV2.POST("/resend-verification", middlewares.RateLimit("resend-verification", 5, 15*time.Minute), controllers.ResendVerificationEmail)
+		publicV2.Use(middlewares.JwtAuthMiddleware()).POST("/account", middlewares.EmailVerified(), middlewares.ActiveAccount(), middlewares.RequireKYCTier(models.VirtualAccountKYCTier), controllers.CreateBorderlessVirtualAccount)
 		publicV2.Use(middlewares.JwtAuthMiddleware()).GET("/account", controllers.GetUserAccounts)
 		publicV2.Use(middlewares.JwtAuthMiddleware()).Use(middlewares.IsAdmin()).GET("/accounts", controllers.FilterUserAccounts)
 	}
@@ -96,28 +188,37 @@ func main() {
 	{
 		kyc.Use(middlewares.JwtAuthMiddleware())
 		kyc.GET("/mine", controllers.GetUserKYC)
+		kyc.GET("/tiers", controllers.GetKYCTiers)
+		kyc.GET("/documents", controllers.GetKYCDocuments)
 		kyc.POST("", controllers.CreateKYC)
 		kyc.PATCH("", controllers.UpdateKYC)
 		kyc.DELETE("/:id", controllers.DeleteKYC)
 		kyc.Use(middlewares.IsAdmin()).GET("", controllers.GetKYCS)
 		kyc.Use(middlewares.IsAdmin()).PATCH("/:id/approve", controllers.ApproveKYC)
 		kyc.Use(middlewares.IsAdmin()).PATCH("/:id/reject", controllers.RejectKYC)
+		kyc.Use(middlewares.IsAdmin()).GET("/:id/documents", controllers.GetUserKYCDocuments)
+		kyc.Use(middlewares.IsAdmin()).GET("/documents/:id/access-logs", controllers.GetKYCDocumentAccessLogs)
 	}
 
+	// Signed links stand in for authentication
+	r.GET("/api/v2/kyc-documents/:id", controllers.ServeKYCDocument)
+
 	user := r.Group("/api/v1/auth")
 	{
 		user.Use(middlewares.JwtAuthMiddleware())
 		user.GET("/user", controllers.GetAuthenticatedUser)
+		user.PATCH("/locale", controllers.UpdateUserLocale)
 		user.POST("/make-admin", controllers.MakeAdmin)
 	}
 
 	trans := r.Group("/api/v1/transaction")
 	{
 		trans.Use(middlewares.JwtAuthMiddleware())
+		trans.Use(middlewares.EmailVerified())
 		trans.GET("/on-ramp", controllers.RetrieveOnRampParamsV1)
 		trans.GET("", controllers.GetUserTransactions)
 		trans.GET("/hash", controllers.GetTransactionsByHash)
-		trans.POST("/off-ramp", controllers.OffRampTransaction)
+		trans.POST("/off-ramp", middlewares.ActiveAccount(), middlewares.NoOpenCase(), middlewares.Idempotent(), controllers.OffRampTransaction)
 		trans.POST("/sign-url", controllers.SignUrl)
 	}
 
@@ -141,14 +242,15 @@ func main() {
 	transV2 := r.Group("/api/v2/transaction")
 	{
 		transV2.Use(middlewares.JwtAuthMiddleware())
+		transV2.Use(middlewares.EmailVerified())
 		transV2.GET("/equivalent-amount", controllers.AmountToReceive)
 		transV2.GET("/destination-bank", controllers.GetDestinationBankAccount)
 		transV2.GET("/reference", controllers.GenerateReference)
-		transV2.POST("/on-ramp", controllers.OnRampV2)
-		transV2.POST("/off-ramp", controllers.OffRampV2)
+		transV2.POST("/on-ramp", middlewares.RequireRail(models.RailBank), middlewares.Idempotent(), controllers.OnRampV2)
+		transV2.POST("/off-ramp", middlewares.RequireRail(models.RailBank), middlewares.Idempotent(), controllers.OffRampV2)
 		transV2.GET("/on-ramp/mobile/equivalent-amount", controllers.MobileMoneyAmountToReceive)
-		transV2.POST("/on-ramp/mobile", controllers.MobileMoneyOnRamp)
-		transV2.POST("/off-ramp/mobile", controllers.MobileMoneyOffRamp)
+		transV2.POST("/on-ramp/mobile", middlewares.RequireRail(models.RailMobileMoney), middlewares.Idempotent(), controllers.MobileMoneyOnRamp)
+		transV2.POST("/off-ramp/mobile", middlewares.RequireRail(models.RailMobileMoney), middlewares.Idempotent(), controllers.MobileMoneyOffRamp)
 
 	}
 
@@ -165,16 +267,230 @@ func main() {
 		requests.GET("/hurupay-requests", controllers.ListHurupayRequest)
 		requests.GET("/hurupay-requests/:id", controllers.GetHurupayRequest)
 		requests.GET("/hurupay-requests/stats", controllers.GetHurupayStats)
+		requests.GET("/reports/volume", controllers.GetVolumeReport)
+		requests.GET("/reports/funnel", controllers.GetFunnelReport)
+		requests.GET("/reports/settlement", controllers.GetSettlementReport)
+		requests.GET("/reports/fees", controllers.GetFeeReport)
 	}
 
 	payments := r.Group("/api/v1/payments")
 	{
 		payments.Use(middlewares.JwtAuthMiddleware())
+		payments.Use(middlewares.EmailVerified())
 		payments.GET("/banks", controllers.FilterBank)
-		payments.POST("/borderless-onramp", controllers.BorderLessOnramp)
-		payments.POST("/borderless-offramp", controllers.BorderLessOffRamp)
-		payments.POST("/borderless-onramp/mobilemoney", controllers.BorderlessMobileMoneyOnRamp)
-		payments.POST("/borderless-offramp/mobilemoney", controllers.BorderlessMobileMoneyOffRamp)
+		payments.POST("/borderless-onramp", middlewares.RequireRail(models.RailBorderless), middlewares.Idempotent(), controllers.BorderLessOnramp)
+		payments.POST("/borderless-offramp", middlewares.RequireRail(models.RailBorderless), middlewares.Idempotent(), controllers.BorderLessOffRamp)
+		payments.POST("/borderless-onramp/mobilemoney", middlewares.RequireRail(models.RailBorderless), middlewares.Idempotent(), controllers.BorderlessMobileMoneyOnRamp)
+		payments.POST("/borderless-offramp/mobilemoney", middlewares.RequireRail(models.RailBorderless), middlewares.Idempotent(), controllers.BorderlessMobileMoneyOffRamp)
+	}
+
+	partners := r.Group("/api/v1/partners")
+	{
+		partners.Use(middlewares.JwtAuthMiddleware())
+		partners.Use(middlewares.IsAdmin())
+		partners.Use(middlewares.PlatformAdmin())
+		partners.POST("", controllers.CreatePartner)
+		partners.GET("", controllers.GetPartners)
+		partners.GET("/:id", controllers.GetPartner)
+		partners.PATCH("/:id/status", controllers.UpdatePartnerStatus)
+		partners.POST("/:id/keys", controllers.CreatePartnerAPIKey)
+		partners.POST("/:id/keys/:keyId/rotate", controllers.RotatePartnerAPIKey)
+		partners.PATCH("/:id/keys/:keyId", controllers.UpdatePartnerAPIKeyAccess)
+		partners.DELETE("/:id/keys/:keyId", controllers.RevokePartnerAPIKey)
+	}
+
+	partnerAPI := r.Group("/api/partner/v1")
+	{
+		partnerAPI.Use(middlewares.PartnerAuth())
+		partnerAPI.POST("/users", middlewares.RequireScope(models.ScopeUsersWrite), controllers.CreatePartnerUser)
+		partnerAPI.GET("/users", middlewares.RequireScope(models.ScopeUsersRead), controllers.ListPartnerUsers)
+		partnerAPI.GET("/users/:id", middlewares.RequireScope(models.ScopeUsersRead), controllers.GetPartnerUser)
+
+		// routes acting on behalf of a sub-user named in X-On-Behalf-Of
+		onBehalf := partnerAPI.Group("", middlewares.ActOnBehalf())
+		onBehalf.GET("/accounts", middlewares.RequireScope(models.ScopeAccountsRead), controllers.GetUserAccounts)
+		onBehalf.GET("/transactions", middlewares.RequireScope(models.ScopeTransactionsRead), controllers.GetUserTransactions)
+		onBehalf.POST("/on-ramp", middlewares.RequireScope(models.ScopeOnRampWrite), middlewares.RequireRail(models.RailBank), middlewares.Idempotent(), controllers.OnRampV2)
+		onBehalf.POST("/off-ramp", middlewares.RequireScope(models.ScopeOffRampWrite), middlewares.RequireRail(models.RailBank), middlewares.Idempotent(), controllers.OffRampV2)
+		onBehalf.POST("/off-ramp/mobile", middlewares.RequireScope(models.ScopeOffRampWrite), middlewares.RequireRail(models.RailMobileMoney), middlewares.Idempotent(), controllers.MobileMoneyOffRamp)
+
+		partnerWebhooks := partnerAPI.Group("/webhooks", middlewares.RequireScope(models.ScopeWebhooksManage), middlewares.PartnerWebhooks())
+		webhookEndpointRoutes(partnerWebhooks)
+	}
+
+	notificationCenter := r.Group("/api/v1/notifications")
+	{
+		notificationCenter.Use(middlewares.JwtAuthMiddleware())
+		notificationCenter.GET("", controllers.GetNotifications)
+		notificationCenter.GET("/unread-count", controllers.GetUnreadNotificationCount)
+		notificationCenter.PATCH("/read-all", controllers.MarkAllNotificationsRead)
+		notificationCenter.PATCH("/:id/read", controllers.MarkNotificationRead)
+		notificationCenter.GET("/preferences", controllers.GetNotificationPreferences)
+		notificationCenter.PUT("/preferences", controllers.UpdateNotificationPreferences)
+	}
+
+	userStatements := r.Group("/api/v1/statements")
+	{
+		userStatements.Use(middlewares.JwtAuthMiddleware())
+		userStatements.POST("", controllers.CreateStatementExport)
+		userStatements.GET("", controllers.GetStatementExports)
+		userStatements.GET("/:id", controllers.GetStatementExport)
+		userStatements.GET("/:id/download", controllers.DownloadStatementExport)
+	}
+
+	// the link mailed when an export is ready, the token authenticates it
+	r.GET("/api/v1/statement-downloads/:token", controllers.DownloadStatementByToken)
+
+	// saved payout destinations, confirmed by email and usable after a
+	// cooling period
+	beneficiaries := r.Group("/api/v1/beneficiaries")
+	{
+		beneficiaries.Use(middlewares.JwtAuthMiddleware())
+		beneficiaries.GET("", controllers.GetBeneficiaries)
+		beneficiaries.POST("", middlewares.ActiveAccount(), controllers.CreateBeneficiary)
+		beneficiaries.POST("/confirm", controllers.ConfirmBeneficiary)
+		beneficiaries.PATCH("/allowlist", controllers.UpdateAllowlist)
+		beneficiaries.POST("/:id/resend-confirmation", controllers.ResendBeneficiaryConfirmation)
+		beneficiaries.DELETE("/:id", controllers.DeleteBeneficiary)
+	}
+
+	userLimits := r.Group("/api/v1/limits")
+	{
+		userLimits.Use(middlewares.JwtAuthMiddleware())
+		userLimits.GET("", controllers.GetLimits)
+	}
+
+	// freezing, suspending and closing users, within the admin's tenant.
+	// Closing sweeps the wallet and is left to platform admins.
+	adminUsers := r.Group("/api/v1/admin/users")
+	{
+		adminUsers.Use(middlewares.JwtAuthMiddleware())
+		adminUsers.Use(middlewares.IsAdmin())
+		adminUsers.GET("/:id/status", controllers.GetUserStatus)
+		adminUsers.PATCH("/:id/status", controllers.UpdateUserStatus)
+		adminUsers.POST("/:id/close", middlewares.PlatformAdmin(), controllers.CloseUserAccount)
+	}
+
+	// rules are platform wide, overrides follow the admin's tenant
+	adminLimits := r.Group("/api/v1/admin/limits")
+	{
+		adminLimits.Use(middlewares.JwtAuthMiddleware())
+		adminLimits.Use(middlewares.IsAdmin())
+		adminLimits.GET("/users/:id", controllers.GetUserLimits)
+		adminLimits.PUT("/users/:id/overrides", controllers.SetUserLimitOverride)
+		adminLimits.DELETE("/users/:id/overrides/:overrideId", controllers.DeleteUserLimitOverride)
+
+		rules := adminLimits.Group("/rules", middlewares.PlatformAdmin())
+		rules.GET("", controllers.GetLimitRules)
+		rules.POST("", controllers.CreateLimitRule)
+		rules.PATCH("/:id", controllers.UpdateLimitRule)
+		rules.DELETE("/:id", controllers.DeleteLimitRule)
+	}
+
+	adminStatements := r.Group("/api/v1/admin/statements")
+	{
+		adminStatements.Use(middlewares.JwtAuthMiddleware())
+		adminStatements.Use(middlewares.IsAdmin())
+		adminStatements.POST("", controllers.CreateAdminStatementExport)
+		adminStatements.GET("", controllers.GetAdminStatementExports)
+		adminStatements.GET("/:id", controllers.GetAdminStatementExport)
+		adminStatements.GET("/:id/download", controllers.DownloadAdminStatementExport)
+	}
+
+	// provider and chain data spans tenants, reconciliation is platform only
+	adminReconciliation := r.Group("/api/v1/admin/reconciliation")
+	{
+		adminReconciliation.Use(middlewares.JwtAuthMiddleware())
+		adminReconciliation.Use(middlewares.IsAdmin())
+		adminReconciliation.Use(middlewares.PlatformAdmin())
+		adminReconciliation.POST("/runs", controllers.StartReconciliation)
+		adminReconciliation.GET("/runs", controllers.GetReconciliationRuns)
+		adminReconciliation.GET("/runs/:id", controllers.GetReconciliationRun)
+		adminReconciliation.GET("/items", controllers.GetReconciliationItems)
+		adminReconciliation.PATCH("/items/:id/resolve", controllers.ResolveReconciliationItem)
+	}
+
+	// watchlist hits on users and payout beneficiaries are reviewed by
+	// platform compliance
+	adminScreening := r.Group("/api/v1/admin/screening")
+	{
+		adminScreening.Use(middlewares.JwtAuthMiddleware())
+		adminScreening.Use(middlewares.IsAdmin())
+		adminScreening.Use(middlewares.PlatformAdmin())
+		adminScreening.GET("", controllers.GetScreenings)
+		adminScreening.POST("/check", controllers.CheckScreening)
+		adminScreening.GET("/lists", controllers.GetWatchlists)
+		adminScreening.POST("/lists/reload", controllers.ReloadWatchlists)
+		adminScreening.GET("/:id", controllers.GetScreening)
+		adminScreening.PATCH("/:id/resolve", controllers.ResolveScreening)
+	}
+
+	// behavioural monitoring rules, their alerts and backtests
+	adminMonitoring := r.Group("/api/v1/admin/monitoring")
+	{
+		adminMonitoring.Use(middlewares.JwtAuthMiddleware())
+		adminMonitoring.Use(middlewares.IsAdmin())
+		adminMonitoring.Use(middlewares.PlatformAdmin())
+		adminMonitoring.GET("/rules", controllers.GetMonitoringRules)
+		adminMonitoring.POST("/rules", controllers.CreateMonitoringRule)
+		adminMonitoring.PUT("/rules/:id", controllers.UpdateMonitoringRule)
+		adminMonitoring.DELETE("/rules/:id", controllers.DeleteMonitoringRule)
+		adminMonitoring.POST("/rules/:id/backtest", controllers.BacktestMonitoringRule)
+		adminMonitoring.POST("/backtest", controllers.BacktestMonitoringRule)
+		adminMonitoring.GET("/alerts", controllers.GetMonitoringAlerts)
+	}
+
+	// compliance investigations, opened by hand or from screening hits, limit
+	// breaches, reconciliation breaks and monitoring alerts
+	adminCases := r.Group("/api/v1/admin/cases")
+	{
+		adminCases.Use(middlewares.JwtAuthMiddleware())
+		adminCases.Use(middlewares.IsAdmin())
+		adminCases.Use(middlewares.PlatformAdmin())
+		adminCases.GET("", controllers.GetComplianceCases)
+		adminCases.POST("", controllers.CreateComplianceCase)
+		adminCases.GET("/:id", controllers.GetComplianceCaseByID)
+		adminCases.PATCH("/:id/assign", controllers.AssignComplianceCase)
+		adminCases.POST("/:id/notes", controllers.AddComplianceCaseNote)
+		adminCases.POST("/:id/attachments", controllers.UploadCaseAttachment)
+		adminCases.GET("/:id/attachments/:attachmentId", controllers.DownloadCaseAttachment)
+		adminCases.POST("/:id/decision", controllers.DecideComplianceCase)
+	}
+
+	events := r.Group("/api/v1/events")
+	{
+		events.Use(middlewares.JwtAuthMiddleware())
+		events.GET("/stream", controllers.StreamEvents)
+		events.GET("/ws", controllers.StreamEventsWebSocket)
+	}
+
+	userWebhooks := r.Group("/api/v1/webhooks")
+	{
+		userWebhooks.Use(middlewares.JwtAuthMiddleware())
+		userWebhooks.Use(middlewares.EmailVerified())
+		userWebhooks.Use(middlewares.UserWebhooks())
+		webhookEndpointRoutes(userWebhooks)
+	}
+
+	// tenant admins manage their tenant's endpoints, platform admins the platform's
+	adminWebhooks := r.Group("/api/v1/admin/webhooks")
+	{
+		adminWebhooks.Use(middlewares.JwtAuthMiddleware())
+		adminWebhooks.Use(middlewares.IsAdmin())
+		adminWebhooks.Use(middlewares.TenantWebhooks())
+		webhookEndpointRoutes(adminWebhooks)
+	}
+
+	tenants := r.Group("/api/v1/tenants")
+	{
+		tenants.Use(middlewares.JwtAuthMiddleware())
+		tenants.Use(middlewares.IsAdmin())
+		tenants.Use(middlewares.PlatformAdmin())
+		tenants.POST("", controllers.CreateTenant)
+		tenants.GET("", controllers.GetTenants)
+		tenants.GET("/:id", controllers.GetTenant)
+		tenants.PATCH("/:id", controllers.UpdateTenant)
+		tenants.PUT("/:id/fee-rules", controllers.SetTenantFeeRule)
 	}
 
 	webhook := r.Group("/api/v1/webhook")
@@ -183,5 +499,17 @@ func main() {
 		webhook.POST("/borderless", controllers.BorderlessNotification)
 	}
 
-	r.Run(":8080")
+	return r
+}
+
+func webhookEndpointRoutes(group *gin.RouterGroup) {
+	group.POST("", controllers.CreateWebhookEndpoint)
+	group.GET("", controllers.GetWebhookEndpoints)
+	group.GET("/:id", controllers.GetWebhookEndpoint)
+	group.PATCH("/:id", controllers.UpdateWebhookEndpoint)
+	group.DELETE("/:id", controllers.DeleteWebhookEndpoint)
+	group.POST("/:id/rotate-secret", controllers.RotateWebhookSecret)
+	group.GET("/:id/deliveries", controllers.GetWebhookDeliveries)
+	group.GET("/:id/deliveries/:deliveryId", controllers.GetWebhookDelivery)
+	group.POST("/:id/deliveries/:deliveryId/redeliver", controllers.RedeliverWebhook)
 }
@@ -0,0 +1,44 @@
+package middlewares
+
+import (
+	"backend/models"
+	"backend/utils/apperrors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ActiveAccount blocks routes that move money unless the user is active
+func ActiveAccount() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !accountActive(c) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// accountActive aborts the request when the user's status does not allow
+// moving money
+func accountActive(c *gin.Context) bool {
+	user, ok := currentUser(c)
+	if !ok {
+		return false
+	}
+	if status := user.CurrentStatus(); status != models.UserActive {
+		apperrors.Respond(c, statusError(status))
+		return false
+	}
+	return true
+}
+
+// statusError is the response for a user whose status refuses the request
+func statusError(status string) *apperrors.Error {
+	message := "Your account is frozen, please contact support"
+	switch status {
+	case models.UserSuspended:
+		message = "Your account is suspended, please contact support"
+	case models.UserClosed:
+		message = "This account is closed"
+	}
+	return apperrors.New(apperrors.CodeAccountRestricted, message).WithReason("account_" + status)
+}
@@ -1,6 +1,8 @@
 package middlewares
 
 import (
+	"backend/models"
+	"net"
 	"net/http"
 
 	"slices"
@@ -15,6 +17,15 @@ func AllowedHosts(allowedHosts []string) gin.HandlerFunc {
 			return
 		}
 
+		// tenant domains are configured in the database
+		host := requestHost
+		if h, _, err := net.SplitHostPort(requestHost); err == nil {
+			host = h
+		}
+		if slices.Contains(models.TenantHosts(), host) {
+			return
+		}
+
 		// If the host is not allowed, return a 403 Forbidden response
 		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Forbidden: oops!!! Host not allowed"})
 		c.Abort()
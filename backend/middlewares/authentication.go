@@ -2,8 +2,9 @@ package middlewares
 
 import (
 	"backend/models"
+	"backend/utils/apperrors"
+	"backend/utils/tenancy"
 	"backend/utils/tokens"
-	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
@@ -15,34 +16,86 @@ func JwtAuthMiddleware() gin.HandlerFunc {
 		flag := tokens.IsTokenValid(tokenString)
 
 		if !flag {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-			c.Abort()
+			apperrors.Respond(c, apperrors.New(apperrors.CodeUnauthorized, ""))
 			return
 		}
+
+		// reject tokens issued before the user's sessions were revoked
+		claims, err := tokens.ExtractClaims(c)
+		if err != nil {
+			apperrors.Respond(c, apperrors.Wrap(apperrors.CodeUnauthorized, err, ""))
+			return
+		}
+		user, err := models.GetUserByID(claims.ID)
+		if err != nil || !user.SessionValid(claims.IssuedAt) {
+			apperrors.Respond(c, apperrors.Wrap(apperrors.CodeUnauthorized, err, "Session expired, please log in again").
+				WithReason("session_expired"))
+			return
+		}
+		if !user.CanSignIn() {
+			apperrors.Respond(c, statusError(user.CurrentStatus()))
+			return
+		}
+
+		// a user of one tenant can't use their session on another tenant's domain
+		if _, resolved := c.Get(tenancy.ContextKey); resolved && !tenancy.SameTenant(c, user.TenantID) {
+			apperrors.Respond(c, apperrors.New(apperrors.CodeUnauthorized, ""))
+			return
+		}
+		if user.TenantID != nil {
+			if _, err := models.GetCachedTenant(*user.TenantID); err != nil {
+				apperrors.Respond(c, apperrors.Wrap(apperrors.CodeForbidden, err, "This service is currently unavailable").
+					WithReason("tenant_unavailable"))
+				return
+			}
+		}
+		tenancy.Set(c, user.TenantID)
 		c.Next()
 	}
 }
 
 func IsAdmin() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id, err := tokens.ExtractUserID(c)
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-			c.Abort()
+		user, ok := currentUser(c)
+		if !ok {
 			return
 		}
-		user, err := models.GetUserByID(id)
-		if err != nil {
-			c.JSON(401, gin.H{
-				"error": err.Error(),
-			})
+		if user.Role != "Admin" {
+			apperrors.Respond(c, apperrors.New(apperrors.CodeForbidden, ""))
 			return
 		}
-		if user.Role != "Admin" {
-			c.AbortWithStatusJSON(403, gin.H{"error": "You don't have permission to perform this action"})
-			c.Abort()
+		c.Next()
+	}
+}
+
+// EmailVerified blocks users who have not confirmed their email address
+func EmailVerified() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := currentUser(c)
+		if !ok {
+			return
+		}
+		if !user.EmailVerified {
+			apperrors.Respond(c, apperrors.New(apperrors.CodeForbidden, "Please verify your email address to continue").
+				WithReason("email_not_verified"))
 			return
 		}
 		c.Next()
 	}
 }
+
+// currentUser loads the user of the token, the request is aborted when
+// there is none
+func currentUser(c *gin.Context) (models.User, bool) {
+	id, err := tokens.ExtractUserID(c)
+	if err != nil {
+		apperrors.Respond(c, apperrors.Wrap(apperrors.CodeUnauthorized, err, ""))
+		return models.User{}, false
+	}
+	user, err := models.GetUserByID(id)
+	if err != nil {
+		apperrors.Respond(c, apperrors.Wrap(apperrors.CodeUnauthorized, err, ""))
+		return models.User{}, false
+	}
+	return user, true
+}
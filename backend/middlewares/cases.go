@@ -0,0 +1,42 @@
+package middlewares
+
+import (
+	"backend/models"
+	"backend/utils/apperrors"
+	"backend/utils/tokens"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NoOpenCase blocks routes that start payments while the user has a
+// blocking compliance case
+func NoOpenCase() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !caseFree(c) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// caseFree aborts the request when a compliance case holds the user, the
+// case itself is not disclosed
+func caseFree(c *gin.Context) bool {
+	id, err := tokens.ExtractUserID(c)
+	if err != nil {
+		apperrors.Respond(c, apperrors.Wrap(apperrors.CodeUnauthorized, err, ""))
+		return false
+	}
+	blocking, err := models.GetBlockingCase(id)
+	if err != nil {
+		apperrors.Respond(c, err)
+		return false
+	}
+	if blocking != nil {
+		apperrors.Respond(c, apperrors.New(apperrors.CodeComplianceHold, "Your account is under compliance review, payments are paused").
+			WithReason("compliance_case_open").
+			WithData(gin.H{"case_id": blocking.ID}))
+		return false
+	}
+	return true
+}
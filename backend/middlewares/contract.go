@@ -0,0 +1,97 @@
+package middlewares
+
+import (
+	"backend/state"
+	"backend/utils/apperrors"
+	"backend/utils/openapi"
+	"bytes"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// contractWriter keeps a copy of the response body for the drift check
+type contractWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *contractWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *contractWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Contract checks requests and responses against the OpenAPI spec. A JSON
+// body that does not match its schema is refused before the handler runs.
+// With OPENAPI_CONTRACT set to log, success responses are checked too and
+// drift is logged, the response itself always goes out as the handler wrote
+// it. The contract test holds handlers to the spec before they ship.
+func Contract() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		doc := openapi.Current()
+		if doc == nil {
+			c.Next()
+			return
+		}
+		route := doc.Lookup(c.Request.Method, c.FullPath())
+		if route == nil {
+			c.Next()
+			return
+		}
+		if route.Request != nil && !validRequest(c, doc, route) {
+			return
+		}
+
+		if route.Response == nil || state.AppConfig.OpenAPIContract != state.ContractLog {
+			c.Next()
+			return
+		}
+
+		w := &contractWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		// error bodies are rewritten by RenderErrors further out, only
+		// success responses are final here
+		status := w.Status()
+		if status < 200 || status >= 300 {
+			return
+		}
+		problems := doc.Validate(c.Request.Method, c.FullPath(), status, w.Header().Get("Content-Type"), w.body.Bytes())
+		if len(problems) > 0 {
+			slog.WarnContext(c.Request.Context(), "response drifts from the OpenAPI spec",
+				"method", c.Request.Method, "route", c.FullPath(), "problems", problems)
+		}
+	}
+}
+
+// validRequest answers the request and returns false when its JSON body does
+// not match the spec, an empty body is left to the handler
+func validRequest(c *gin.Context, doc *openapi.Document, route *openapi.Route) bool {
+	if c.Request.Body == nil || !strings.HasPrefix(c.ContentType(), "application/json") && c.ContentType() != "" {
+		return true
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		apperrors.Respond(c, apperrors.Invalid(err, "could not read request body"))
+		return false
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if len(bytes.TrimSpace(body)) == 0 {
+		return true
+	}
+	if problems := doc.ValidateJSON(route.Request, body, "body"); len(problems) > 0 {
+		apperrors.Respond(c, apperrors.New(apperrors.CodeValidationFailed, "").
+			WithData(gin.H{"problems": problems}))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,185 @@
+package middlewares
+
+import (
+	"backend/utils/apperrors"
+	"backend/utils/logging"
+	"backend/utils/requestid"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestID gives every request an ID, returned in the X-Request-ID header
+// and in error responses. The request's context carries it so logs of the
+// controllers and provider clients are tagged with it.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := requestid.Set(c)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// errorWriter holds back error responses so they can be rewritten, other
+// responses go straight through
+type errorWriter struct {
+	gin.ResponseWriter
+	held    bool
+	body    bytes.Buffer
+	flushed bool
+}
+
+func (w *errorWriter) holding() bool {
+	if !w.flushed && w.ResponseWriter.Status() >= 400 {
+		w.held = true
+	}
+	return w.held
+}
+
+func (w *errorWriter) Write(b []byte) (int, error) {
+	if w.holding() {
+		return w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *errorWriter) WriteString(s string) (int, error) {
+	if w.holding() {
+		return w.body.WriteString(s)
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *errorWriter) WriteHeaderNow() {
+	if !w.holding() {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *errorWriter) Written() bool {
+	return w.held || w.ResponseWriter.Written()
+}
+
+func (w *errorWriter) Size() int {
+	if w.held {
+		return w.body.Len()
+	}
+	return w.ResponseWriter.Size()
+}
+
+func (w *errorWriter) Flush() {
+	if !w.held {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// RenderErrors makes every error response follow the apperrors model:
+// errors left on the context are rendered, error bodies written by handlers
+// get a code and the request ID, and messages of internal errors are
+// replaced by a safe one. A panic is answered as an internal error.
+func RenderErrors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		w := &errorWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		defer func() {
+			if r := recover(); r != nil {
+				slog.ErrorContext(c.Request.Context(), "panic", "panic", fmt.Sprint(r), "stack", string(debug.Stack()))
+				w.body.Reset()
+				w.held = true
+				w.WriteHeader(http.StatusInternalServerError)
+				_ = c.Error(apperrors.New(apperrors.CodeInternal, ""))
+				c.Abort()
+			}
+			w.flushed = true
+			c.Writer = w.ResponseWriter
+			if w.held {
+				writeErrorBody(c, w)
+			} else if !w.ResponseWriter.Written() && len(c.Errors) > 0 {
+				apperrors.Respond(c, c.Errors.Last().Err)
+			}
+		}()
+		c.Next()
+	}
+}
+
+// writeErrorBody writes the held error response in the apperrors model
+func writeErrorBody(c *gin.Context, w *errorWriter) {
+	status := w.ResponseWriter.Status()
+	body := normalizeErrorBody(c, status, w.body.Bytes())
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		encoded, _ = json.Marshal(apperrors.Body(c, apperrors.New(apperrors.CodeInternal, "")))
+	}
+	header := w.ResponseWriter.Header()
+	header.Del("Content-Length")
+	header.Set("Content-Type", "application/json; charset=utf-8")
+	_, _ = w.ResponseWriter.Write(encoded)
+}
+
+func normalizeErrorBody(c *gin.Context, status int, raw []byte) map[string]interface{} {
+	var body map[string]interface{}
+	if err := json.Unmarshal(raw, &body); err != nil || body == nil {
+		body = map[string]interface{}{}
+		if text := string(bytes.TrimSpace(raw)); text != "" {
+			body["error"] = text
+		}
+	}
+
+	// an error rendered through apperrors already is left alone
+	if code, _ := body["code"].(string); apperrors.Code(code).Known() {
+		if _, ok := body["request_id"]; !ok {
+			body["request_id"] = requestid.Get(c)
+		}
+		body["errors"] = true
+		return body
+	}
+
+	code := apperrors.CodeForStatus(status)
+	if lastErr := c.Errors.Last(); lastErr != nil {
+		if e := apperrors.From(lastErr.Err); e.Status() == status {
+			code = e.Code
+		}
+	}
+	if reason, ok := body["code"].(string); ok && reason != "" {
+		body["reason"] = reason
+	}
+
+	message, _ := body["error"].(string)
+	if message == "" {
+		if errValue, ok := body["error"]; ok && errValue != nil {
+			body["detail"] = errValue
+		}
+		for _, key := range []string{"message", "status"} {
+			if s, ok := body[key].(string); ok && s != "" {
+				message = s
+				break
+			}
+		}
+	}
+	if code == apperrors.CodeInternal {
+		// internal messages can hold database or upstream details
+		if message != "" {
+			slog.ErrorContext(c.Request.Context(), "internal error", "method", c.Request.Method, "path", c.Request.URL.Path, "status", status, "error", message)
+		}
+		message = ""
+		delete(body, "message")
+		delete(body, "detail")
+		if _, ok := body["status"].(string); ok {
+			delete(body, "status")
+		}
+	}
+	if message == "" {
+		message = apperrors.New(code, "").Message
+	}
+
+	body["code"] = code
+	body["error"] = message
+	body["errors"] = true
+	body["request_id"] = requestid.Get(c)
+	return body
+}
@@ -0,0 +1,159 @@
+package middlewares
+
+import (
+	"backend/models"
+	"backend/state"
+	"backend/utils/apperrors"
+	"backend/utils/idempotency"
+	"backend/utils/tokens"
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotencyReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+)
+
+// responseRecorder keeps a copy of the response written through it
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotent makes a request carrying an Idempotency-Key safe to retry. The
+// first response of a user's key is stored for a day and replayed to retries
+// with the same body, a retry with another body is refused. Requests without
+// a key run as usual unless IdempotencyKeyRequired is set. It goes after the
+// gates of a route, so their refusals are never stored.
+func Idempotent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			if state.AppConfig.IdempotencyKeyRequired {
+				apperrors.Respond(c, apperrors.New(apperrors.CodeBadRequest, "the Idempotency-Key header is required").
+					WithReason("idempotency_key_required"))
+				return
+			}
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			apperrors.Respond(c, apperrors.New(apperrors.CodeBadRequest, "the Idempotency-Key header is longer than 255 characters").
+				WithReason("idempotency_key_invalid"))
+			return
+		}
+		userID, err := tokens.ExtractUserID(c)
+		if err != nil {
+			apperrors.Respond(c, apperrors.Wrap(apperrors.CodeUnauthorized, err, ""))
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			apperrors.Respond(c, apperrors.Invalid(err, "could not read request body"))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		idempotency.Set(c, userID, key)
+
+		record := &models.IdempotencyKey{
+			UserID:      userID,
+			Key:         key,
+			Method:      c.Request.Method,
+			Path:        c.Request.URL.Path,
+			RequestHash: idempotency.RequestHash(c.Request.Method, c.Request.URL.Path, body),
+			ExpiresAt:   time.Now().Add(idempotency.TTL),
+		}
+		existing, err := models.ClaimIdempotencyKey(record)
+		if err != nil {
+			apperrors.Respond(c, apperrors.Wrap(apperrors.CodeServiceUnavailable, err, "could not check the idempotency key"))
+			return
+		}
+		if existing != nil {
+			replay(c, existing, record.RequestHash)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		// a 5xx answer before any transfer was submitted frees the key for a
+		// retry. After a submission money may have moved whatever the answer,
+		// so the key is kept failed, as on a panic or a response that cannot
+		// be stored.
+		finished := false
+		defer func() {
+			if !finished {
+				failIdempotencyKey(c, record)
+			}
+		}()
+
+		c.Next()
+		finished = true
+
+		switch {
+		case !recorder.Written():
+			failIdempotencyKey(c, record)
+		case recorder.Status() >= http.StatusInternalServerError && idempotency.Submitted(c):
+			failIdempotencyKey(c, record)
+		case recorder.Status() >= http.StatusInternalServerError:
+			if err := record.Release(); err != nil {
+				slog.ErrorContext(c.Request.Context(), "idempotency key not released", "key_id", record.ID, "error", err)
+			}
+		default:
+			if err := record.Complete(recorder.Status(), recorder.Header().Get("Content-Type"), recorder.body.Bytes()); err != nil {
+				slog.ErrorContext(c.Request.Context(), "idempotent response not stored", "key_id", record.ID, "error", err)
+				failIdempotencyKey(c, record)
+			}
+		}
+	}
+}
+
+// failIdempotencyKey marks the key failed, when even that cannot be stored
+// the key stays processing until it expires
+func failIdempotencyKey(c *gin.Context, record *models.IdempotencyKey) {
+	if err := record.Fail(); err != nil {
+		slog.ErrorContext(c.Request.Context(), "idempotency key not marked failed", "key_id", record.ID, "error", err)
+	}
+}
+
+// replay answers a retry with the stored response of its key
+func replay(c *gin.Context, existing *models.IdempotencyKey, requestHash string) {
+	if existing.RequestHash != requestHash {
+		apperrors.Respond(c, apperrors.New(apperrors.CodeIdempotencyKeyReuse, "the Idempotency-Key was already used with a different request"))
+		return
+	}
+	if existing.Status == models.IdempotencyFailed {
+		apperrors.Respond(c, apperrors.New(apperrors.CodeConflict,
+			"the outcome of the request with this Idempotency-Key is unknown, check its result before retrying with a new key").
+			WithReason("idempotency_outcome_unknown"))
+		return
+	}
+	if existing.Status != models.IdempotencyCompleted {
+		c.Header("Retry-After", "1")
+		apperrors.Respond(c, apperrors.New(apperrors.CodeRequestInProgress, "a request with this Idempotency-Key is still being processed"))
+		return
+	}
+	c.Header(IdempotencyReplayedHeader, "true")
+	contentType := existing.ResponseContentType
+	if contentType == "" {
+		contentType = "application/json; charset=utf-8"
+	}
+	c.Data(existing.ResponseStatus, contentType, existing.ResponseBody)
+	c.Abort()
+}
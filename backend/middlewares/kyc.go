@@ -0,0 +1,40 @@
+package middlewares
+
+import (
+	"backend/models"
+	"backend/utils/apperrors"
+	"backend/utils/tokens"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireKYCTier blocks users whose approved KYC is below the tier
+func RequireKYCTier(tier int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !kycTierReached(c, tier) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// kycTierReached aborts the request when the user is below the tier
+func kycTierReached(c *gin.Context, tier int) bool {
+	if tier <= models.KYCTier0 {
+		return true
+	}
+	id, err := tokens.ExtractUserID(c)
+	if err != nil {
+		apperrors.Respond(c, apperrors.Wrap(apperrors.CodeUnauthorized, err, ""))
+		return false
+	}
+	if current := models.KYCTier(id); current < tier {
+		apperrors.Respond(c, apperrors.New(apperrors.CodeKYCRequired,
+			fmt.Sprintf("KYC tier %d is required, please complete verification to continue", tier)).
+			WithReason("kyc_tier_required").
+			WithData(gin.H{"required_tier": tier, "current_tier": current}))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,143 @@
+package middlewares
+
+import (
+	"backend/models"
+	"backend/utils/apperrors"
+	"backend/utils/signing"
+	"backend/utils/tenancy"
+	"backend/utils/tokens"
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PartnerRequestTolerance is how far a partner's X-Timestamp may drift from
+// the server clock
+const PartnerRequestTolerance = 5 * time.Minute
+
+// PartnerAuth authenticates a request signed with a partner API key. Partners
+// send X-Api-Key, X-Timestamp (unix seconds) and X-Signature, the base64
+// HMAC-SHA256 of signing.PartnerStringToSign.
+func PartnerAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keyID := c.GetHeader("X-Api-Key")
+		timestamp := c.GetHeader("X-Timestamp")
+		signature := c.GetHeader("X-Signature")
+		if keyID == "" || timestamp == "" || signature == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing X-Api-Key, X-Timestamp or X-Signature header"})
+			return
+		}
+
+		unix, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid X-Timestamp header"})
+			return
+		}
+		if drift := time.Since(time.Unix(unix, 0)); drift > PartnerRequestTolerance || drift < -PartnerRequestTolerance {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Request timestamp outside the allowed window"})
+			return
+		}
+
+		key, err := models.GetActivePartnerAPIKey(keyID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		if !key.IPAllowed(c.ClientIP()) {
+			slog.WarnContext(c.Request.Context(), "partner key used from a non allowlisted ip", "key_id", key.KeyID, "client_ip", c.ClientIP())
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "IP address not allowed for this API key"})
+			return
+		}
+
+		secret, err := key.Secret()
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "partner key secret unreadable", "key_id", key.KeyID, "error", err)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Unable to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
+
+		if !signing.VerifyPartnerSignature(secret, c.Request.Method, c.Request.URL.RequestURI(), timestamp, body, signature) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
+			return
+		}
+
+		// a signature stays replayable until its timestamp leaves the window
+		fresh, err := models.ClaimPartnerNonce(key.KeyID, signature, time.Unix(unix, 0).Add(PartnerRequestTolerance))
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "partner request nonce not stored", "key_id", key.KeyID, "error", err)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Unable to verify the request, try again"})
+			return
+		}
+		if !fresh {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Request has already been processed"})
+			return
+		}
+
+		key.TouchLastUsed()
+		c.Set("partner_key", key)
+		c.Set("partner_id", key.PartnerID)
+		tenancy.Set(c, key.Partner.TenantID)
+		c.Next()
+	}
+}
+
+// RequireScope must run after PartnerAuth
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, ok := c.Get("partner_key")
+		key, isKey := value.(*models.PartnerAPIKey)
+		if !ok || !isKey {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		if !key.HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API key is missing the " + scope + " scope"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// ActOnBehalf resolves the sub-user named in X-On-Behalf-Of, which must belong
+// to the authenticated partner, so user scoped handlers can be reused as is
+func ActOnBehalf() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		partnerID := c.GetUint("partner_id")
+		if partnerID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		userID, err := strconv.ParseUint(c.GetHeader("X-On-Behalf-Of"), 10, 64)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Missing or invalid X-On-Behalf-Of header"})
+			return
+		}
+
+		user, err := models.GetPartnerUser(partnerID, uint(userID))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if !user.CanSignIn() {
+			apperrors.Respond(c, statusError(user.CurrentStatus()))
+			return
+		}
+
+		c.Set(tokens.ActingUserKey, user.ID)
+		c.Next()
+	}
+}
@@ -0,0 +1,26 @@
+package middlewares
+
+import (
+	"backend/utils/apperrors"
+	"backend/utils/ratelimit"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit limits how often a single client IP may hit the route, the IP
+// is only taken from X-Forwarded-For when sent by a trusted proxy
+func RateLimit(scope string, limit int, window time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := fmt.Sprintf("%s:ip:%s", scope, c.ClientIP())
+		allowed, retryAfter := ratelimit.Allow(key, limit, window)
+		if !allowed {
+			c.Header("Retry-After", fmt.Sprintf("%d", int(math.Ceil(retryAfter.Seconds()))))
+			apperrors.Respond(c, apperrors.New(apperrors.CodeRateLimited, ""))
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,48 @@
+package middlewares
+
+import (
+	"backend/models"
+	"backend/utils/tenancy"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ResolveTenant scopes the request to the tenant owning the requested host.
+// The Origin header is not used, any client can set it.
+func ResolveTenant() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if tenant, found := models.GetTenantByDomain(c.Request.Host); found {
+			tenancy.Set(c, &tenant.ID)
+		}
+		c.Next()
+	}
+}
+
+// RequireRail blocks payment routes on rails the tenant has not enabled, for
+// users who are not active or whose KYC tier does not reach the rail, and
+// while a compliance case holds the user
+func RequireRail(rail string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if tenant := tenancy.Tenant(c); tenant != nil && !tenant.RailEnabled(rail) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "This payment method is not available"})
+			return
+		}
+		if !accountActive(c) || !kycTierReached(c, models.RailKYCTiers[rail]) || !caseFree(c) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// PlatformAdmin must run after IsAdmin and restricts the route to admins of
+// the platform itself rather than of a tenant
+func PlatformAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if tenancy.TenantID(c) != 0 {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You don't have permission to perform this action"})
+			return
+		}
+		c.Next()
+	}
+}
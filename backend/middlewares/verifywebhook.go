@@ -3,7 +3,7 @@ package middlewares
 import (
 	"bytes"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 
@@ -16,7 +16,7 @@ func WebhookSignatureMiddleware() gin.HandlerFunc {
 		bodyBytes, err := io.ReadAll(c.Request.Body)
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Unable to read request body"})
-			log.Println("Unable to read request body")
+			slog.WarnContext(c.Request.Context(), "webhook: unable to read request body", "error", err)
 			return
 		}
 
@@ -27,7 +27,7 @@ func WebhookSignatureMiddleware() gin.HandlerFunc {
 		signature := c.GetHeader("x-webhook-signature")
 		if signature == "" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing signature"})
-			log.Println("Missing signature")
+			slog.WarnContext(c.Request.Context(), "webhook: missing signature")
 			return
 		}
 		dir, _ := os.Getwd()
@@ -40,7 +40,7 @@ func WebhookSignatureMiddleware() gin.HandlerFunc {
 			pemFilePath = dir + "/offramp-public.pem"
 		default:
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid webhook path"})
-			log.Println("Invalid webhook path")
+			slog.WarnContext(c.Request.Context(), "webhook: invalid path", "path", c.Request.URL.Path)
 			return
 		}
 
@@ -48,12 +48,10 @@ func WebhookSignatureMiddleware() gin.HandlerFunc {
 		_, err = os.ReadFile(pemFilePath)
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Unable to read public key file"})
-			log.Println("Unable to read public key file", err)
+			slog.ErrorContext(c.Request.Context(), "webhook: unable to read public key file", "error", err)
 			return
 		}
 
-		log.Println("signature", signature)
-
 		// Verify the webhook signature
 		//flag, err := signing.VerifyWebhookSignature(bodyString, signature, publicKey)
 		//if err != nil || !flag {
@@ -0,0 +1,47 @@
+package middlewares
+
+import (
+	"backend/models"
+	"backend/utils/tenancy"
+	"backend/utils/tokens"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WebhookOwnerKey holds the models.WebhookOwner whose endpoints a webhook
+// management route works on
+const WebhookOwnerKey = "webhook_owner"
+
+// UserWebhooks must run after JwtAuthMiddleware and scopes webhook management
+// to the signed in user's own endpoints
+func UserWebhooks() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := tokens.ExtractUserID(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		c.Set(WebhookOwnerKey, models.WebhookOwner{UserID: &id})
+		c.Next()
+	}
+}
+
+// TenantWebhooks must run after IsAdmin and scopes webhook management to the
+// endpoints of the admin's tenant, platform admins manage platform endpoints
+func TenantWebhooks() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(WebhookOwnerKey, models.WebhookOwner{TenantID: tenancy.TenantIDPtr(c)})
+		c.Next()
+	}
+}
+
+// PartnerWebhooks must run after PartnerAuth and scopes webhook management to
+// the partner's endpoints
+func PartnerWebhooks() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		partnerID := c.GetUint("partner_id")
+		c.Set(WebhookOwnerKey, models.WebhookOwner{PartnerID: &partnerID})
+		c.Next()
+	}
+}
@@ -0,0 +1,8 @@
+-- accounts the up migration marked verified carry their creation time as
+-- the verification time, accounts verified since keep their verification
+UPDATE users
+SET
+    email_verified = false,
+    email_verified_at = NULL
+WHERE
+    email_verified_at = created_at;
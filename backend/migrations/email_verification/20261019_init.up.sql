@@ -0,0 +1,9 @@
+-- accounts created before email verification already have a wallet,
+-- treat them as verified so they are not locked out
+UPDATE users
+SET
+    email_verified = true,
+    email_verified_at = created_at
+WHERE
+    account_address IS NOT NULL
+    AND account_address <> '';
@@ -0,0 +1,31 @@
+// Moves the KYC documents held in KYCData columns into document storage.
+// Run after the main migration has created the kyc_documents table.
+package main
+
+import (
+	"backend/models"
+	"backend/state"
+	"backend/utils/documents"
+	"log"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	// Load environment variables from .env file if not in production
+	state.LoadEnv()
+	if state.AppConfig.AppEnv != "production" {
+		err := godotenv.Load()
+		if err != nil {
+			log.Fatal("Error loading .env file")
+		}
+	}
+
+	models.InitializeDB()
+
+	moved, err := documents.MigrateKYCData()
+	if err != nil {
+		log.Fatalf("KYC document migration failed after %d documents: %v", moved, err)
+	}
+	log.Printf("Moved %d KYC documents to %s storage", moved, state.AppConfig.DocumentStorage)
+}
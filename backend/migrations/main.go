@@ -28,24 +28,19 @@ func main() {
 		log.Fatalf("Database initialization failed: %v", err)
 	}
 
+	// idx_open_case cannot be created over duplicate open cases
+	if err := models.CloseDuplicateCases(db); err != nil {
+		log.Fatalf("Closing duplicate cases failed: %v", err)
+	}
+
 	// Perform database migrations
-	err = models.Migrate(db,
-		&models.User{},
-		&models.Token{},
-		&models.Transaction{},
-		&models.XlmPublic{},
-		&models.MasterWallet{},
-		&models.WalletAddress{},
-		&models.DepositRequest{},
-		&models.WithdrawalRequest{},
-		&models.HurupayRequest{},
-		&models.BorderlessRequest{},
-		&models.KYC{},
-		&models.KYCData{},
-		&models.UserAccounts{},
-		&models.Bank{},
-	)
+	err = models.Migrate(db, models.Tables()...)
 	if err != nil {
 		log.Fatalf("Database migration failed: %v", err)
 	}
+
+	// KYC submitted before tiers becomes tiers 1 and 2
+	if err := models.BackfillKYCLevels(db); err != nil {
+		log.Fatalf("KYC tier backfill failed: %v", err)
+	}
 }
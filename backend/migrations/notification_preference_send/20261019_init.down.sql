@@ -0,0 +1,9 @@
+ALTER TABLE notification_preferences
+ADD COLUMN email BOOLEAN;
+
+UPDATE notification_preferences
+SET
+    email = send;
+
+ALTER TABLE notification_preferences
+DROP COLUMN send;
@@ -0,0 +1,11 @@
+-- the per-event setting now covers SMS and WhatsApp too, it moves from
+-- email to send with the choices users already made
+ALTER TABLE notification_preferences
+ADD COLUMN IF NOT EXISTS send BOOLEAN;
+
+UPDATE notification_preferences
+SET
+    send = email;
+
+ALTER TABLE notification_preferences
+DROP COLUMN email;
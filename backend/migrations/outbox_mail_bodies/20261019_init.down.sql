@@ -0,0 +1,2 @@
+-- the cleared bodies cannot be restored, sent mails stay without them
+SELECT 1;
@@ -0,0 +1,8 @@
+-- mails that will not be sent again no longer keep their bodies, which
+-- can carry password reset and verification tokens
+UPDATE outbox_mails
+SET
+    html_body = '',
+    text_body = ''
+WHERE
+    status IN ('Sent', 'Failed');
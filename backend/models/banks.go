@@ -1,6 +1,8 @@
 package models
 
 import (
+	"strings"
+
 	"gorm.io/gorm"
 )
 
@@ -39,3 +41,11 @@ func GetBankData(id int) (*Bank, error) {
 
 	return &bank, nil
 }
+
+func GetBankByName(name string) (*Bank, error) {
+	var bank Bank
+	if err := db.Where("LOWER(name) = LOWER(?)", strings.TrimSpace(name)).First(&bank).Error; err != nil {
+		return nil, err
+	}
+	return &bank, nil
+}
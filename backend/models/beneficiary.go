@@ -0,0 +1,207 @@
+package models
+
+import (
+	"backend/serializers"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// Kinds of payout beneficiaries
+const (
+	BeneficiaryCrypto      = "crypto"
+	BeneficiaryBank        = "bank"
+	BeneficiaryMobileMoney = "mobile_money"
+)
+
+var BeneficiaryKinds = []string{BeneficiaryCrypto, BeneficiaryBank, BeneficiaryMobileMoney}
+
+// Beneficiary statuses, a beneficiary is confirmed through a mailed link
+const (
+	BeneficiaryPending   = "pending_confirmation"
+	BeneficiaryConfirmed = "confirmed"
+)
+
+var ErrBeneficiaryExists = errors.New("this beneficiary is already saved")
+
+// Beneficiary is a saved payout destination of a user. It can be paid once
+// confirmed and past its cooling period, UsableAt.
+type Beneficiary struct {
+	gorm.Model
+	UserID uint   `gorm:"uniqueIndex:idx_beneficiary_user_key" json:"user_id"`
+	Kind   string `gorm:"index" json:"kind"`
+	Label  string `json:"label"`
+	// DestinationKey identifies the destination whatever its formatting, a
+	// payout matches a beneficiary on it
+	DestinationKey string `gorm:"uniqueIndex:idx_beneficiary_user_key" json:"-"`
+
+	// crypto
+	Chain   string `json:"chain,omitempty"`
+	Address string `json:"address,omitempty"`
+
+	// bank
+	BankID        *uint  `json:"bank_id,omitempty"`
+	Bank          *Bank  `json:"bank,omitempty"`
+	AccountNumber string `json:"account_number,omitempty"`
+	AccountName   string `json:"account_name,omitempty"`
+	AccountType   string `json:"account_type,omitempty"`
+
+	// mobile money, AccountName is the holder's name
+	CountryCode   string `json:"country_code,omitempty"`
+	MobileNetwork string `json:"mobile_network,omitempty"`
+	PhoneNumber   string `json:"phone_number,omitempty"`
+
+	Status      string     `gorm:"index" json:"status"`
+	ConfirmedAt *time.Time `json:"confirmed_at"`
+	UsableAt    *time.Time `json:"usable_at"`
+}
+
+// CryptoBeneficiaryKey identifies an address, EVM addresses are not case
+// sensitive
+func CryptoBeneficiaryKey(chain, address string) string {
+	chain = strings.ToUpper(chain)
+	address = strings.TrimSpace(address)
+	if chain != serializers.ChainStellar {
+		address = strings.ToLower(address)
+	}
+	return fmt.Sprintf("%s:%s:%s", BeneficiaryCrypto, chain, address)
+}
+
+// BankBeneficiaryKey identifies an account at a bank of the Bank table
+func BankBeneficiaryKey(bankID uint, accountNumber string) string {
+	return fmt.Sprintf("%s:%d:%s", BeneficiaryBank, bankID, strings.ToUpper(alphanumeric(accountNumber)))
+}
+
+// MobileBeneficiaryKey identifies a mobile money number
+func MobileBeneficiaryKey(countryCode, phoneNumber string) string {
+	return fmt.Sprintf("%s:%s:%s", BeneficiaryMobileMoney, strings.ToUpper(strings.TrimSpace(countryCode)), alphanumeric(phoneNumber))
+}
+
+func alphanumeric(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+func (b *Beneficiary) key() string {
+	switch b.Kind {
+	case BeneficiaryCrypto:
+		return CryptoBeneficiaryKey(b.Chain, b.Address)
+	case BeneficiaryBank:
+		if b.BankID != nil {
+			return BankBeneficiaryKey(*b.BankID, b.AccountNumber)
+		}
+	case BeneficiaryMobileMoney:
+		return MobileBeneficiaryKey(b.CountryCode, b.PhoneNumber)
+	}
+	return ""
+}
+
+func (b *Beneficiary) BeforeSave(tx *gorm.DB) error {
+	b.DestinationKey = b.key()
+	return nil
+}
+
+// Usable reports whether the beneficiary can be paid at t
+func (b *Beneficiary) Usable(t time.Time) bool {
+	return b.Status == BeneficiaryConfirmed && b.UsableAt != nil && !t.Before(*b.UsableAt)
+}
+
+// CreateBeneficiary saves the beneficiary pending confirmation
+func (b *Beneficiary) CreateBeneficiary() error {
+	b.Status = BeneficiaryPending
+	b.ConfirmedAt, b.UsableAt = nil, nil
+	var count int64
+	if err := db.Model(&Beneficiary{}).Where("user_id = ? AND destination_key = ?", b.UserID, b.key()).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrBeneficiaryExists
+	}
+	return db.Create(b).Error
+}
+
+// Confirm confirms the beneficiary, it can be paid after the cooling period
+func (b *Beneficiary) Confirm(cooling time.Duration) error {
+	if b.Status == BeneficiaryConfirmed {
+		return nil
+	}
+	now := time.Now()
+	usableAt := now.Add(cooling)
+	b.Status, b.ConfirmedAt, b.UsableAt = BeneficiaryConfirmed, &now, &usableAt
+	return db.Model(&Beneficiary{}).Where("id = ?", b.ID).Updates(map[string]interface{}{
+		"status":       b.Status,
+		"confirmed_at": now,
+		"usable_at":    usableAt,
+	}).Error
+}
+
+// DeleteBeneficiary removes the beneficiary for good, saving it again starts
+// over with confirmation and cooling
+func (b *Beneficiary) DeleteBeneficiary() error {
+	return db.Unscoped().Delete(b).Error
+}
+
+func GetUserBeneficiaries(userID uint, kind string) ([]Beneficiary, error) {
+	var beneficiaries []Beneficiary
+	query := db.Preload("Bank").Where("user_id = ?", userID)
+	if kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	err := query.Order("created_at DESC").Find(&beneficiaries).Error
+	return beneficiaries, err
+}
+
+func GetUserBeneficiary(userID, id uint) (*Beneficiary, error) {
+	var beneficiary Beneficiary
+	if err := db.Preload("Bank").Where("user_id = ?", userID).First(&beneficiary, id).Error; err != nil {
+		return nil, err
+	}
+	return &beneficiary, nil
+}
+
+// FindUserBeneficiary returns the user's beneficiary with the key, nil when
+// there is none
+func FindUserBeneficiary(userID uint, key string) (*Beneficiary, error) {
+	var beneficiary Beneficiary
+	err := db.Preload("Bank").Where("user_id = ? AND destination_key = ?", userID, key).First(&beneficiary).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &beneficiary, nil
+}
+
+// AllowlistEnforced reports whether the user's payouts are restricted to
+// their beneficiaries at t. Turning the allowlist off only takes effect once
+// AllowlistReleaseAt has passed.
+func (u *User) AllowlistEnforced(t time.Time) bool {
+	return u.AllowlistOnly || (u.AllowlistReleaseAt != nil && t.Before(*u.AllowlistReleaseAt))
+}
+
+// SetAllowlistOnly turns the allowlist on at once, or off after the cooling
+// period so a stolen session cannot lift it to pay out right away
+func (u *User) SetAllowlistOnly(enabled bool, cooling time.Duration) error {
+	var releaseAt *time.Time
+	switch {
+	case !enabled && u.AllowlistOnly:
+		t := time.Now().Add(cooling)
+		releaseAt = &t
+	case !enabled:
+		releaseAt = u.AllowlistReleaseAt
+	}
+	u.AllowlistOnly, u.AllowlistReleaseAt = enabled, releaseAt
+	return db.Model(&User{}).Where("id = ?", u.ID).Updates(map[string]interface{}{
+		"allowlist_only":       enabled,
+		"allowlist_release_at": releaseAt,
+	}).Error
+}
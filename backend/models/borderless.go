@@ -5,6 +5,7 @@ import "gorm.io/gorm"
 type BorderlessRequest struct {
 	*gorm.Model
 	FiatAmount           string  `json:"fiatAmount"`
+	FiatCurrency         string  `json:"fiatCurrency"` // currency of FiatAmount on deposits, withdrawals are in Asset
 	Asset                string  `json:"asset"`
 	Country              string  `json:"country"`
 	UserId               uint    `json:"userId"`
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"log/slog"
+
+	"gorm.io/gorm"
+)
+
+// Kinds of records streamed to users as they change
+const (
+	ChangeTransaction  = "transaction"
+	ChangeDeposit      = "deposit"
+	ChangeWithdrawal   = "withdrawal"
+	ChangeHurupay      = "hurupay"
+	ChangeBorderless   = "borderless"
+	ChangeNotification = "notification"
+)
+
+// Change describes a saved record owned by a user. Data holds the fields a
+// client needs to refresh its view, never the nested user.
+type Change struct {
+	Kind   string                 `json:"kind"`
+	UserID uint                   `json:"user_id"`
+	Data   map[string]interface{} `json:"data"`
+}
+
+var changeListeners []func(*gorm.DB, Change) error
+
+// OnChange registers fn to be called on every tracked save with the saving
+// transaction. Listeners must not block, anything they write through tx
+// commits or rolls back with the record. Delivery is best effort, an error
+// is logged and never fails the save.
+func OnChange(fn func(tx *gorm.DB, change Change) error) {
+	changeListeners = append(changeListeners, fn)
+}
+
+// emitChange always returns nil so the hooks calling it never roll back the
+// record, a listener that fails only costs clients a live update
+func emitChange(tx *gorm.DB, kind string, userID uint, data map[string]interface{}) error {
+	// bulk updates through db.Model(&T{}) run hooks on an empty record
+	if userID == 0 {
+		return nil
+	}
+	for _, fn := range changeListeners {
+		if err := fn(tx, Change{Kind: kind, UserID: userID, Data: data}); err != nil {
+			slog.Error("change not delivered", "kind", kind, "user_id", userID, "error", err)
+		}
+	}
+	return nil
+}
+
+// Notify sends payload on a PostgreSQL NOTIFY channel once tx commits, it is
+// dropped when tx rolls back. A new session keeps the saving statement's
+// result untouched, and a savepoint keeps a failed notify from aborting tx.
+func Notify(tx *gorm.DB, channel, payload string) error {
+	session := tx.Session(&gorm.Session{NewDB: true})
+	if err := session.SavePoint("notify_change").Error; err != nil {
+		return err
+	}
+	if err := session.Exec("SELECT pg_notify(?, ?)", channel, payload).Error; err != nil {
+		session.RollbackTo("notify_change")
+		return err
+	}
+	return nil
+}
+
+func (t *Transaction) AfterSave(tx *gorm.DB) error {
+	return emitChange(tx, ChangeTransaction, t.UserID, map[string]interface{}{
+		"id":                   t.ID,
+		"status":               t.Status,
+		"amount":               t.Amount,
+		"asset":                t.Asset,
+		"chain":                t.Chain,
+		"hash":                 t.Hash,
+		"transaction_type":     t.TransactionType,
+		"transaction_sub_type": t.TransactionSubType,
+		"request_id":           t.RequestId,
+		"updated_at":           t.UpdatedAt,
+	})
+}
+
+func (d *DepositRequest) AfterSave(tx *gorm.DB) error {
+	return emitChange(tx, ChangeDeposit, d.UserID, map[string]interface{}{
+		"id":          d.ID,
+		"status":      d.Status,
+		"ref":         d.Ref,
+		"fiat_amount": d.FiatAmount,
+		"currency":    d.Currency,
+		"updated_at":  d.UpdatedAt,
+	})
+}
+
+func (w *WithdrawalRequest) AfterSave(tx *gorm.DB) error {
+	return emitChange(tx, ChangeWithdrawal, w.UserID, map[string]interface{}{
+		"id":            w.ID,
+		"status":        w.Status,
+		"crypto_amount": w.CryptoAmount,
+		"asset":         w.Asset,
+		"hash":          w.Hash,
+		"updated_at":    w.UpdatedAt,
+	})
+}
+
+func (h *HurupayRequest) AfterSave(tx *gorm.DB) error {
+	return emitChange(tx, ChangeHurupay, uint(h.UserId), map[string]interface{}{
+		"id":           h.ID,
+		"request_id":   h.RequestId,
+		"request_type": h.RequestType,
+		"status":       h.Status,
+		"amount":       h.Amount,
+		"currency":     h.CountryCurrency,
+		"updated_at":   h.UpdatedAt,
+	})
+}
+
+func (b *BorderlessRequest) AfterSave(tx *gorm.DB) error {
+	data := map[string]interface{}{
+		"tx_id":       b.TxId,
+		"status":      b.Status,
+		"fiat_amount": b.FiatAmount,
+		"asset":       b.Asset,
+	}
+	if b.Model != nil {
+		data["id"] = b.ID
+		data["updated_at"] = b.UpdatedAt
+	}
+	return emitChange(tx, ChangeBorderless, b.UserId, data)
+}
+
+func (n *Notification) AfterSave(tx *gorm.DB) error {
+	return emitChange(tx, ChangeNotification, n.UserID, map[string]interface{}{
+		"id":    n.ID,
+		"type":  n.Type,
+		"title": n.Title,
+		"body":  n.Body,
+		"link":  n.Link,
+		"read":  n.Read,
+	})
+}
@@ -0,0 +1,315 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Where a case came from
+const (
+	CaseManual         = "manual"
+	CaseScreening      = "screening"
+	CaseLimitBreach    = "limit_breach"
+	CaseReconciliation = "reconciliation"
+	CaseMonitoring     = "monitoring"
+)
+
+var CaseSources = []string{CaseManual, CaseScreening, CaseLimitBreach, CaseReconciliation, CaseMonitoring}
+
+// Case statuses, only closed cases carry a decision
+const (
+	CaseOpen          = "open"
+	CaseInvestigating = "investigating"
+	CaseClosed        = "closed"
+)
+
+// Case decisions
+const (
+	DecisionClear  = "clear"  // nothing found, the user may carry on
+	DecisionFreeze = "freeze" // the user is frozen
+	DecisionReport = "report" // reported to the regulator
+)
+
+// Case priorities, each with the time compliance has to decide
+const (
+	CasePriorityLow      = "low"
+	CasePriorityMedium   = "medium"
+	CasePriorityHigh     = "high"
+	CasePriorityCritical = "critical"
+)
+
+var CaseSLAs = map[string]time.Duration{
+	CasePriorityLow:      7 * 24 * time.Hour,
+	CasePriorityMedium:   72 * time.Hour,
+	CasePriorityHigh:     24 * time.Hour,
+	CasePriorityCritical: 4 * time.Hour,
+}
+
+var ErrCaseClosed = errors.New("case is already closed")
+
+// ComplianceCase tracks an investigation of a user or a transaction. Open
+// blocking cases stop the user from starting payments. A user has at most
+// one undecided case per source and reference, manual cases aside.
+type ComplianceCase struct {
+	gorm.Model
+	UserID        *uint  `gorm:"index;uniqueIndex:idx_open_case,where:source <> 'manual' AND status <> 'closed' AND deleted_at IS NULL" json:"user_id"`
+	TransactionID *uint  `gorm:"index" json:"transaction_id"`
+	Source        string `gorm:"index;uniqueIndex:idx_open_case" json:"source"`
+	// SourceRef is the screening, limit code, reconciliation item or
+	// monitoring rule the case was opened from
+	SourceRef   string `gorm:"index;uniqueIndex:idx_open_case" json:"source_ref,omitempty"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Priority    string `json:"priority"`
+	Blocking    bool   `json:"blocking"`
+	Status      string `gorm:"index" json:"status"`
+	AssigneeID  *uint  `gorm:"index" json:"assignee_id"`
+	OpenedByID  *uint  `json:"opened_by_id"` // nil when opened by the system
+	// DueAt is when the SLA of the priority runs out, SLABreachedAt is set
+	// once it ran out with the case still undecided
+	DueAt           time.Time        `gorm:"index" json:"due_at"`
+	SLABreachedAt   *time.Time       `json:"sla_breached_at"`
+	Decision        string           `json:"decision,omitempty"`
+	DecisionNote    string           `json:"decision_note,omitempty"`
+	ReportReference string           `json:"report_reference,omitempty"`
+	DecidedByID     *uint            `json:"decided_by_id"`
+	ClosedAt        *time.Time       `json:"closed_at"`
+	Notes           []CaseNote       `gorm:"foreignKey:CaseID" json:"notes,omitempty"`
+	Attachments     []CaseAttachment `gorm:"foreignKey:CaseID" json:"attachments,omitempty"`
+}
+
+// CaseNote is an entry in the case's investigation log, the system writes
+// notes too when it adds to an open case
+type CaseNote struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CaseID    uint      `gorm:"index" json:"case_id"`
+	AuthorID  *uint     `json:"author_id"` // nil for system notes
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CaseAttachment is evidence kept encrypted in document storage
+type CaseAttachment struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	CaseID       uint      `gorm:"index" json:"case_id"`
+	UploadedByID uint      `json:"uploaded_by_id"`
+	FileName     string    `json:"file_name"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	SHA256       string    `json:"sha256"`
+	Backend      string    `json:"-"`
+	StorageKey   string    `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Open stores a new case, its SLA starting now
+func (cc *ComplianceCase) Open() error {
+	cc.prepareOpen()
+	return db.Create(cc).Error
+}
+
+// OpenOnce opens the case unless the user already has an undecided one from
+// the same source and reference, reporting false and loading that one into
+// cc then
+func (cc *ComplianceCase) OpenOnce() (bool, error) {
+	cc.prepareOpen()
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(cc)
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.RowsAffected > 0, result.Error
+	}
+	existing, err := GetOpenCaseFor(cc.Source, cc.SourceRef, cc.UserID)
+	if err != nil {
+		return false, err
+	}
+	if existing == nil {
+		return false, errors.New("open case not found after conflict")
+	}
+	*cc = *existing
+	return false, nil
+}
+
+func (cc *ComplianceCase) prepareOpen() {
+	if cc.Priority == "" {
+		cc.Priority = CasePriorityMedium
+	}
+	cc.Status = CaseOpen
+	cc.DueAt = time.Now().Add(CaseSLAs[cc.Priority])
+}
+
+// CloseDuplicateCases closes all but the oldest of the undecided cases a user
+// has from the same source and reference, which idx_open_case refuses. It
+// runs with the migrations, before the index is created.
+func CloseDuplicateCases(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&ComplianceCase{}) {
+		return nil
+	}
+	var open []ComplianceCase
+	err := db.Where("user_id IS NOT NULL AND source <> ? AND status <> ?", CaseManual, CaseClosed).
+		Order("id").Find(&open).Error
+	if err != nil {
+		return err
+	}
+	kept := map[string]uint{}
+	now := time.Now()
+	for _, cc := range open {
+		key := fmt.Sprintf("%d/%s/%s", *cc.UserID, cc.Source, cc.SourceRef)
+		first, ok := kept[key]
+		if !ok {
+			kept[key] = cc.ID
+			continue
+		}
+		err := db.Model(&ComplianceCase{}).Where("id = ?", cc.ID).Updates(map[string]interface{}{
+			"status":        CaseClosed,
+			"closed_at":     now,
+			"decision_note": fmt.Sprintf("duplicate of case %d", first),
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func GetComplianceCase(id uint) (*ComplianceCase, error) {
+	var cc ComplianceCase
+	err := db.Preload("Notes", func(tx *gorm.DB) *gorm.DB { return tx.Order("id") }).
+		Preload("Attachments").First(&cc, id).Error
+	return &cc, err
+}
+
+// GetOpenCaseFor returns the undecided case opened from the source and
+// reference, nil when there is none
+func GetOpenCaseFor(source, sourceRef string, userID *uint) (*ComplianceCase, error) {
+	var cc ComplianceCase
+	query := db.Where("source = ? AND source_ref = ? AND status <> ?", source, sourceRef, CaseClosed)
+	if userID != nil {
+		query = query.Where("user_id = ?", *userID)
+	}
+	err := query.Order("id desc").First(&cc).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &cc, err
+}
+
+// GetBlockingCase returns the open case stopping the user from starting
+// payments, nil when there is none
+func GetBlockingCase(userID uint) (*ComplianceCase, error) {
+	var cc ComplianceCase
+	err := db.Where("user_id = ? AND blocking AND status <> ?", userID, CaseClosed).
+		Order("id desc").First(&cc).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &cc, err
+}
+
+func (cc *ComplianceCase) AddNote(authorID *uint, body string) (*CaseNote, error) {
+	note := &CaseNote{CaseID: cc.ID, AuthorID: authorID, Body: body}
+	return note, db.Create(note).Error
+}
+
+func (cc *ComplianceCase) AddAttachment(attachment *CaseAttachment) error {
+	attachment.CaseID = cc.ID
+	return db.Create(attachment).Error
+}
+
+func GetCaseAttachment(caseID, id uint) (*CaseAttachment, error) {
+	var attachment CaseAttachment
+	err := db.Where("case_id = ?", caseID).First(&attachment, id).Error
+	return &attachment, err
+}
+
+// Assign hands the case to an admin, an open case moves to investigating
+func (cc *ComplianceCase) Assign(assigneeID uint) error {
+	if cc.Status == CaseClosed {
+		return ErrCaseClosed
+	}
+	cc.AssigneeID = &assigneeID
+	if cc.Status == CaseOpen {
+		cc.Status = CaseInvestigating
+	}
+	return db.Omit("Notes", "Attachments").Save(cc).Error
+}
+
+// Decide closes the case with one of the Decision* values
+func (cc *ComplianceCase) Decide(adminID uint, decision, note, reportReference string) error {
+	if cc.Status == CaseClosed {
+		return ErrCaseClosed
+	}
+	now := time.Now()
+	cc.Status = CaseClosed
+	cc.Decision = decision
+	cc.DecisionNote = note
+	cc.ReportReference = reportReference
+	cc.DecidedByID = &adminID
+	cc.ClosedAt = &now
+	return db.Omit("Notes", "Attachments").Save(cc).Error
+}
+
+// MarkSLABreaches stamps the undecided cases whose SLA ran out and returns
+// them
+func MarkSLABreaches() ([]ComplianceCase, error) {
+	var cases []ComplianceCase
+	now := time.Now()
+	err := db.Where("status <> ? AND due_at < ? AND sla_breached_at IS NULL", CaseClosed, now).Find(&cases).Error
+	if err != nil || len(cases) == 0 {
+		return cases, err
+	}
+	ids := make([]uint, len(cases))
+	for i := range cases {
+		ids[i] = cases[i].ID
+		cases[i].SLABreachedAt = &now
+	}
+	return cases, db.Model(&ComplianceCase{}).Where("id IN ?", ids).Update("sla_breached_at", now).Error
+}
+
+// CaseFilter narrows the case list, zero values do not filter
+type CaseFilter struct {
+	UserID        uint
+	TransactionID uint
+	AssigneeID    uint
+	Source        string
+	Priority      string
+	Overdue       bool
+}
+
+var caseListSpec = ListSpec{
+	Sorts: map[string]SortField{
+		"created_at": timeSort("created_at", func(row interface{}) time.Time { return row.(ComplianceCase).CreatedAt }),
+		"updated_at": timeSort("updated_at", func(row interface{}) time.Time { return row.(ComplianceCase).UpdatedAt }),
+		"due_at":     timeSort("due_at", func(row interface{}) time.Time { return row.(ComplianceCase).DueAt }),
+	},
+	DefaultSort:   "-created_at",
+	SearchColumns: []string{"title", "description"},
+	id:            func(row interface{}) uint { return row.(ComplianceCase).ID },
+}
+
+// FilterComplianceCases lists cases without their notes and attachments,
+// status filters on open, investigating and closed
+func FilterComplianceCases(filter CaseFilter, lq ListQuery) ([]ComplianceCase, PageInfo, error) {
+	query := db.Model(&ComplianceCase{})
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.TransactionID != 0 {
+		query = query.Where("transaction_id = ?", filter.TransactionID)
+	}
+	if filter.AssigneeID != 0 {
+		query = query.Where("assignee_id = ?", filter.AssigneeID)
+	}
+	if filter.Source != "" {
+		query = query.Where("source = ?", filter.Source)
+	}
+	if filter.Priority != "" {
+		query = query.Where("priority = ?", filter.Priority)
+	}
+	if filter.Overdue {
+		query = query.Where("status <> ? AND due_at < ?", CaseClosed, time.Now())
+	}
+	return paginate[ComplianceCase](query, caseListSpec, lq)
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Idempotency key states, a key is processing until its request answered.
+// A failed key is one whose handler ran but left no response to replay, so
+// whether the request went through is unknown.
+const (
+	IdempotencyProcessing = "processing"
+	IdempotencyCompleted  = "completed"
+	IdempotencyFailed     = "failed"
+)
+
+// IdempotencyKey is a client supplied key of a request, with the response to
+// replay when the request is retried
+type IdempotencyKey struct {
+	ID          uint   `gorm:"primarykey" json:"id"`
+	UserID      uint   `gorm:"uniqueIndex:idx_idempotency_user_key" json:"user_id"`
+	Key         string `gorm:"column:idempotency_key;size:255;uniqueIndex:idx_idempotency_user_key" json:"key"`
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	RequestHash string `json:"-"`
+	Status      string `json:"status"`
+	// the response, once completed
+	ResponseStatus      int       `json:"response_status"`
+	ResponseContentType string    `json:"-"`
+	ResponseBody        []byte    `json:"-"`
+	CreatedAt           time.Time `json:"created_at"`
+	ExpiresAt           time.Time `gorm:"index" json:"expires_at"`
+}
+
+// ClaimIdempotencyKey stores the key as processing. When the user already
+// used the key, and it has not expired, the stored key is returned instead.
+func ClaimIdempotencyKey(key *IdempotencyKey) (*IdempotencyKey, error) {
+	var existing *IdempotencyKey
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND idempotency_key = ? AND expires_at <= ?", key.UserID, key.Key, time.Now()).
+			Delete(&IdempotencyKey{}).Error; err != nil {
+			return err
+		}
+		key.Status = IdempotencyProcessing
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(key)
+		if result.Error != nil || result.RowsAffected > 0 {
+			return result.Error
+		}
+		existing = &IdempotencyKey{}
+		return tx.Where("user_id = ? AND idempotency_key = ?", key.UserID, key.Key).First(existing).Error
+	})
+	return existing, err
+}
+
+// Complete stores the response to replay
+func (k *IdempotencyKey) Complete(status int, contentType string, body []byte) error {
+	k.Status, k.ResponseStatus, k.ResponseContentType, k.ResponseBody = IdempotencyCompleted, status, contentType, body
+	return db.Model(&IdempotencyKey{}).Where("id = ?", k.ID).Updates(map[string]interface{}{
+		"status":                k.Status,
+		"response_status":       status,
+		"response_content_type": contentType,
+		"response_body":         body,
+	}).Error
+}
+
+// Fail keeps the key of a request whose outcome is unknown, retries are
+// refused until it expires
+func (k *IdempotencyKey) Fail() error {
+	k.Status = IdempotencyFailed
+	return db.Model(&IdempotencyKey{}).Where("id = ?", k.ID).Update("status", k.Status).Error
+}
+
+// Release frees the key of a request that did not go through, so it can be
+// retried
+func (k *IdempotencyKey) Release() error {
+	return db.Delete(&IdempotencyKey{}, k.ID).Error
+}
+
+// DeleteExpiredIdempotencyKeys removes keys past their expiry
+func DeleteExpiredIdempotencyKeys() (int64, error) {
+	result := db.Where("expires_at <= ?", time.Now()).Delete(&IdempotencyKey{})
+	return result.RowsAffected, result.Error
+}
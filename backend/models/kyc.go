@@ -2,7 +2,7 @@ package models
 
 import (
 	"backend/serializers"
-	"fmt"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -35,6 +35,7 @@ type KYC struct {
 	State                string    `json:"state"`
 	PostalCode           string    `json:"postal_code"`
 	Country              string    `json:"country"`
+	SourceOfFunds        string    `json:"source_of_funds"`
 	RejectionReason      string    `json:"rejection_reason"`
 	CreatedAt            time.Time `json:"created_at"`
 	ApprovedAt           time.Time `json:"approved_at"`
@@ -42,17 +43,14 @@ type KYC struct {
 	UpdatedAt            time.Time `json:"updated_at"`
 }
 
-type KYCWithData struct {
-	Kyc        KYC
-	FrontPhoto string `json:"front_photo"`
-	BackPhoto  string `json:"back_photo"`
-}
-
 type KYCData struct {
 	gorm.Model
-	UserID     uint   `gorm:"uniqueIndex" json:"user_id"`
-	FrontPhoto string `json:"front_photo"`
-	BackPhoto  string `json:"back_photo"`
+	UserID                uint   `gorm:"uniqueIndex" json:"user_id"`
+	FrontPhoto            string `json:"front_photo"`
+	BackPhoto             string `json:"back_photo"`
+	Selfie                string `json:"selfie"`
+	ProofOfAddress        string `json:"proof_of_address"`
+	SourceOfFundsDocument string `json:"source_of_funds_document"`
 }
 
 type KYCRequest struct {
@@ -142,38 +140,40 @@ func (kycData *KYCData) CreateKYCData() error {
 	return nil
 }
 
-func (kyc *KYC) UpdateKYC(data KYCRequest) error {
-	// Only update if status is rejected
-	if kyc.Status != Rejected {
-		return fmt.Errorf("KYC can only be updated when status is rejected")
+// SubmitKYC saves the KYC, creating it when new, records its uploaded
+// documents and puts the tiers up for review, all or nothing. The documents
+// the uploads replace are returned so their content can be removed.
+func SubmitKYC(kyc *KYC, uploads []*KYCDocument, tiers []int) ([]KYCDocument, error) {
+	var replaced []KYCDocument
+	err := db.Transaction(func(tx *gorm.DB) error {
+		save := tx.Save
+		if kyc.ID == 0 {
+			save = tx.Unscoped().Create
+		}
+		if err := save(kyc).Error; err != nil {
+			return err
+		}
+		for _, upload := range uploads {
+			documents, err := upload.create(tx)
+			if err != nil {
+				return err
+			}
+			replaced = append(replaced, documents...)
+		}
+		return submitKYCLevels(tx, kyc.UserID, tiers)
+	})
+	if err != nil {
+		return nil, err
 	}
+	return replaced, nil
+}
 
-	kyc.IDType = data.IDType
-	kyc.IdNumber = data.IdNumber
-	kyc.IssueDate = data.IssueDate
-	kyc.ExpiryDate = data.ExpiryDate
-	kyc.TaxId = data.TaxId
-	kyc.Phone = data.Phone
-	kyc.StreetAddress = data.StreetAddress
-	kyc.City = data.City
-	kyc.State = data.State
-	kyc.PostalCode = data.PostalCode
-	kyc.Country = data.Country
-	kyc.DateOfBirth = data.DateOfBirth
-	kyc.Status = Pending // set status to pending during update
-
+// UpdateKYC saves changed fields, tiers decide when they may change
+func (kyc *KYC) UpdateKYC() error {
 	return db.Save(kyc).Error
 }
 
-func (kycData *KYCData) UpdateKYCData(status KYCStatus, data KYCDataRequest) error {
-	// Only update if status of kyc is pending
-	if status != Rejected {
-		return fmt.Errorf("KYC can only be updated when status is rejected")
-	}
-
-	kycData.FrontPhoto = data.FrontPhoto
-	kycData.BackPhoto = data.BackPhoto
-
+func (kycData *KYCData) UpdateKYCData() error {
 	return db.Save(kycData).Error
 }
 
@@ -194,30 +194,6 @@ func (kycData *KYCData) DeleteKYCData() error {
 	return nil
 }
 
-func (kyc *KYC) ApproveKYC(borderlessIdentityId string) error {
-	// Only update if status is pending
-	if kyc.Status != Pending {
-		return fmt.Errorf("KYC can only be approved when status is pending")
-	}
-
-	kyc.Status = Approved
-	kyc.BorderlessIdentityId = borderlessIdentityId
-	kyc.ApprovedAt = time.Now()
-	return db.Save(kyc).Error
-}
-
-func (k *KYC) RejectKYC(rejectionReason string) error {
-	// Only update if status is pending
-	if k.Status != Pending {
-		return fmt.Errorf("KYC can only be rejected when status is pending")
-	}
-
-	k.Status = Rejected
-	k.RejectionReason = rejectionReason
-	k.RejectedAt = time.Now()
-	return db.Save(k).Error
-}
-
 func GetKYCByUserID(id uint) (*KYC, error) {
 	var kyc KYC
 	err := db.Where("user_id = ?", id).First(&kyc).Error
@@ -236,29 +212,19 @@ func GetKYCByID(id uint) (*KYC, error) {
 	return &kyc, err
 }
 
-func GetKycByUserIDWithPhotos(id uint) (*KYCWithData, error) {
-	var kyc KYC
-	err := db.
-		Where("user_id = ?", id).
-		First(&kyc).Error
-
-	kycData, err := GetKYCDataByUserId(kyc.UserID)
-	if err != nil {
-		return nil, err
-	}
-
-	fullKyc := KYCWithData{
-		Kyc:        kyc,
-		FrontPhoto: kycData.FrontPhoto,
-		BackPhoto:  kycData.BackPhoto,
-	}
-
-	return &fullKyc, err
+var kycListSpec = ListSpec{
+	Sorts: map[string]SortField{
+		"created_at": timeSort("created_at", func(row interface{}) time.Time { return row.(KYC).CreatedAt }),
+		"updated_at": timeSort("updated_at", func(row interface{}) time.Time { return row.(KYC).UpdatedAt }),
+		"status":     stringSort("status", func(row interface{}) string { return string(row.(KYC).Status) }),
+	},
+	DefaultSort:   "-created_at",
+	SearchColumns: []string{"id_number", "phone", "city", "country", "rejection_reason"},
+	id:            func(row interface{}) uint { return row.(KYC).ID },
 }
 
-func FilterKYC(filter serializers.KYCFilterRequest) ([]KYC, error) {
-	var kycs []KYC
-	query := db
+func FilterKYC(tenantID uint, filter serializers.KYCFilterRequest, lq ListQuery) ([]KYC, PageInfo, error) {
+	query := db.Model(&KYC{}).Scopes(ScopeTenantUsers(tenantID))
 
 	// Build the query dynamically based on provided filters
 	if filter.ID != nil {
@@ -269,12 +235,16 @@ func FilterKYC(filter serializers.KYCFilterRequest) ([]KYC, error) {
 		query = query.Where("user_id = ?", *filter.UserID)
 	}
 
-	if filter.Status != nil {
-		query = query.Where("status = ?", *filter.Status)
+	if filter.Tier != nil {
+		levels := db.Model(&KYCLevel{}).Select("user_id").Where("tier = ?", *filter.Tier)
+		if filter.TierStatus != nil {
+			levels = levels.Where("status = ?", *filter.TierStatus)
+		}
+		query = query.Where("user_id IN (?)", levels)
 	}
 
 	if filter.RejectionReason != nil {
-		query = query.Where("rejection_reason ILIKE ?", "%"+*filter.RejectionReason+"%")
+		query = query.Where("LOWER(rejection_reason) LIKE ?", "%"+strings.ToLower(*filter.RejectionReason)+"%")
 	}
 
 	if filter.CreatedAt != nil {
@@ -289,9 +259,7 @@ func FilterKYC(filter serializers.KYCFilterRequest) ([]KYC, error) {
 		query = query.Where("rejected_at = ?", *filter.RejectedAt)
 	}
 
-	// Execute the query
-	err := query.Find(&kycs).Error
-	return kycs, err
+	return paginate[KYC](query, kycListSpec, lq)
 }
 
 // Helper function to convert serializers.KYCRequest to models.KYCRequest
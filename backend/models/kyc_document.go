@@ -0,0 +1,172 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// KYC document types
+const (
+	DocumentIDFront        = "id_front"
+	DocumentIDBack         = "id_back"
+	DocumentSelfie         = "selfie"
+	DocumentProofOfAddress = "proof_of_address"
+	DocumentSourceOfFunds  = "source_of_funds"
+)
+
+// KYCDocumentFields maps the KYC form fields onto the document types stored
+var KYCDocumentFields = map[string]string{
+	"front_photo":              DocumentIDFront,
+	"back_photo":               DocumentIDBack,
+	"selfie":                   DocumentSelfie,
+	"proof_of_address":         DocumentProofOfAddress,
+	"source_of_funds_document": DocumentSourceOfFunds,
+}
+
+// KYCDocument is an encrypted KYC upload held by a storage backend, a new
+// upload of the same type replaces it
+type KYCDocument struct {
+	gorm.Model
+	UserID      uint   `gorm:"index" json:"user_id"`
+	Type        string `gorm:"index" json:"type"`
+	Backend     string `json:"-"` // local or s3
+	StorageKey  string `json:"-"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"` // of the plaintext, checked on every read
+}
+
+// Document access actions
+const (
+	DocumentURLIssued = "url_issued"
+	DocumentViewed    = "viewed"
+	DocumentDenied    = "denied"
+)
+
+// KYCDocumentAccess logs each signed URL issued for a document and each use
+type KYCDocumentAccess struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	DocumentID uint      `gorm:"index" json:"document_id"`
+	ViewerID   uint      `json:"viewer_id"`
+	Action     string    `json:"action"`
+	IPAddress  string    `json:"ip_address"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// CreateKYCDocument stores the document and deletes the user's previous one
+// of the same type, which is returned so its content can be removed from
+// storage
+func (d *KYCDocument) CreateKYCDocument() ([]KYCDocument, error) {
+	var replaced []KYCDocument
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		replaced, err = d.create(tx)
+		return err
+	})
+	return replaced, err
+}
+
+func (d *KYCDocument) create(tx *gorm.DB) ([]KYCDocument, error) {
+	// rows retired before documents were hard deleted go along too
+	var replaced []KYCDocument
+	err := tx.Unscoped().Where("user_id = ? AND type = ?", d.UserID, d.Type).Find(&replaced).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(replaced) > 0 {
+		if err := tx.Unscoped().Delete(&replaced).Error; err != nil {
+			return nil, err
+		}
+	}
+	return replaced, tx.Create(d).Error
+}
+
+func GetKYCDocument(id uint) (*KYCDocument, error) {
+	var document KYCDocument
+	err := db.First(&document, id).Error
+	return &document, err
+}
+
+// GetUserKYCDocuments returns the user's current documents
+func GetUserKYCDocuments(userID uint) ([]KYCDocument, error) {
+	var documents []KYCDocument
+	err := db.Where("user_id = ?", userID).Order("type").Find(&documents).Error
+	return documents, err
+}
+
+// GetUserKYCDocument returns the user's current document of the type
+func GetUserKYCDocument(userID uint, documentType string) (*KYCDocument, error) {
+	var document KYCDocument
+	err := db.Where("user_id = ? AND type = ?", userID, documentType).Order("id desc").First(&document).Error
+	return &document, err
+}
+
+// DeleteUserKYCDocuments deletes every document of the user and returns
+// them so their content can be removed from storage
+func DeleteUserKYCDocuments(userID uint) ([]KYCDocument, error) {
+	var deleted []KYCDocument
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("user_id = ?", userID).Find(&deleted).Error; err != nil {
+			return err
+		}
+		if len(deleted) == 0 {
+			return nil
+		}
+		return tx.Unscoped().Delete(&deleted).Error
+	})
+	return deleted, err
+}
+
+// PresentKYCDocuments returns the KYC form fields the user has a document
+// for, in storage or still in the KYCData columns
+func PresentKYCDocuments(userID uint) (map[string]bool, error) {
+	present := map[string]bool{}
+	documents, err := GetUserKYCDocuments(userID)
+	if err != nil {
+		return nil, err
+	}
+	for field, documentType := range KYCDocumentFields {
+		for _, document := range documents {
+			if document.Type == documentType {
+				present[field] = true
+			}
+		}
+	}
+
+	if kycData, err := GetKYCDataByUserId(userID); err == nil {
+		for field := range KYCDocumentFields {
+			if value := kycData.Document(field); value != nil && *value != "" {
+				present[field] = true
+			}
+		}
+	}
+	return present, nil
+}
+
+func LogKYCDocumentAccess(access *KYCDocumentAccess) error {
+	return db.Create(access).Error
+}
+
+func GetKYCDocumentAccesses(documentID uint) ([]KYCDocumentAccess, error) {
+	var accesses []KYCDocumentAccess
+	err := db.Where("document_id = ?", documentID).Order("id desc").Find(&accesses).Error
+	return accesses, err
+}
+
+// GetLegacyKYCData returns KYCData rows still holding documents
+func GetLegacyKYCData() ([]KYCData, error) {
+	var rows []KYCData
+	err := db.Where("front_photo <> '' OR back_photo <> '' OR selfie <> '' OR proof_of_address <> '' OR source_of_funds_document <> ''").
+		Order("id").Find(&rows).Error
+	return rows, err
+}
+
+// ClearKYCDataDocument empties a KYCData column once its document is stored
+func (kycData *KYCData) ClearKYCDataDocument(field string) error {
+	if value := kycData.Document(field); value != nil {
+		*value = ""
+	}
+	return db.Model(kycData).Update(field, "").Error
+}
@@ -0,0 +1,420 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// KYC tiers decide a user's limits and which rails they may use. Tier 0 is
+// a verified email, each tier above it is reviewed on its own.
+const (
+	KYCTier0   = 0
+	KYCTier1   = 1 // basic identity
+	KYCTier2   = 2 // ID document and selfie
+	KYCTier3   = 3 // proof of address and source of funds
+	MaxKYCTier = KYCTier3
+)
+
+// VirtualAccountKYCTier is needed to open a virtual account, the Borderless
+// identity behind it is created when tier 2 is approved
+const VirtualAccountKYCTier = KYCTier2
+
+// RailKYCTiers is the tier a user needs before initiating on each rail
+var RailKYCTiers = map[string]int{
+	RailBank:        KYCTier1,
+	RailMobileMoney: KYCTier1,
+	RailBorderless:  KYCTier2,
+	RailOnchain:     KYCTier1,
+}
+
+// KYCTierRequirement lists the form fields and documents a tier needs
+type KYCTierRequirement struct {
+	Tier      int      `json:"tier"`
+	Name      string   `json:"name"`
+	Fields    []string `json:"fields"`
+	Documents []string `json:"documents"`
+}
+
+var KYCTierRequirements = []KYCTierRequirement{
+	{
+		Tier:   KYCTier1,
+		Name:   "Basic identity",
+		Fields: []string{"date_of_birth", "phone", "tax_id", "street_address", "city", "state", "postal_code", "country"},
+	},
+	{
+		Tier:      KYCTier2,
+		Name:      "ID document and selfie",
+		Fields:    []string{"id_type", "id_number", "issue_date", "expiry_date"},
+		Documents: []string{"front_photo", "back_photo", "selfie"},
+	},
+	{
+		Tier:      KYCTier3,
+		Name:      "Proof of address and source of funds",
+		Fields:    []string{"source_of_funds"},
+		Documents: []string{"proof_of_address", "source_of_funds_document"},
+	},
+}
+
+// GetKYCTierRequirement returns the requirements of tiers 1 to 3
+func GetKYCTierRequirement(tier int) (KYCTierRequirement, bool) {
+	for _, requirement := range KYCTierRequirements {
+		if requirement.Tier == tier {
+			return requirement, true
+		}
+	}
+	return KYCTierRequirement{}, false
+}
+
+// Field points at the KYC field with the form name, nil when unknown
+func (kyc *KYC) Field(name string) *string {
+	switch name {
+	case "date_of_birth":
+		return &kyc.DateOfBirth
+	case "phone":
+		return &kyc.Phone
+	case "tax_id":
+		return &kyc.TaxId
+	case "street_address":
+		return &kyc.StreetAddress
+	case "city":
+		return &kyc.City
+	case "state":
+		return &kyc.State
+	case "postal_code":
+		return &kyc.PostalCode
+	case "country":
+		return &kyc.Country
+	case "id_type":
+		return &kyc.IDType
+	case "id_number":
+		return &kyc.IdNumber
+	case "issue_date":
+		return &kyc.IssueDate
+	case "expiry_date":
+		return &kyc.ExpiryDate
+	case "source_of_funds":
+		return &kyc.SourceOfFunds
+	}
+	return nil
+}
+
+// Document points at the KYCData column of the form field, nil when unknown.
+// The columns only hold documents uploaded before document storage.
+func (kycData *KYCData) Document(name string) *string {
+	switch name {
+	case "front_photo":
+		return &kycData.FrontPhoto
+	case "back_photo":
+		return &kycData.BackPhoto
+	case "selfie":
+		return &kycData.Selfie
+	case "proof_of_address":
+		return &kycData.ProofOfAddress
+	case "source_of_funds_document":
+		return &kycData.SourceOfFundsDocument
+	}
+	return nil
+}
+
+// MissingKYCRequirements lists the fields of the tier that are still empty
+// and its documents not among those present
+func MissingKYCRequirements(tier int, kyc *KYC, documents map[string]bool) []string {
+	requirement, _ := GetKYCTierRequirement(tier)
+	var missing []string
+	for _, name := range requirement.Fields {
+		if value := kyc.Field(name); value == nil || *value == "" {
+			missing = append(missing, name)
+		}
+	}
+	for _, name := range requirement.Documents {
+		if !documents[name] {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// KYCLevel is the review of one tier of a user's KYC
+type KYCLevel struct {
+	gorm.Model
+	UserID          uint       `gorm:"uniqueIndex:idx_kyc_levels_user_tier" json:"user_id"`
+	Tier            int        `gorm:"uniqueIndex:idx_kyc_levels_user_tier" json:"tier"`
+	Status          KYCStatus  `json:"status"`
+	RejectionReason string     `json:"rejection_reason,omitempty"`
+	ReviewedByID    *uint      `json:"reviewed_by_id"`
+	SubmittedAt     time.Time  `json:"submitted_at"`
+	ApprovedAt      *time.Time `json:"approved_at"`
+	RejectedAt      *time.Time `json:"rejected_at"`
+}
+
+var ErrKYCLevelNotPending = errors.New("KYC tier can only be reviewed when status is pending")
+
+func GetKYCLevels(userID uint) ([]KYCLevel, error) {
+	return getKYCLevels(db, userID)
+}
+
+func getKYCLevels(tx *gorm.DB, userID uint) ([]KYCLevel, error) {
+	var levels []KYCLevel
+	err := tx.Where("user_id = ?", userID).Order("tier").Find(&levels).Error
+	return levels, err
+}
+
+// GetKYCLevelsByUser returns the levels of each user, for listing KYCs
+func GetKYCLevelsByUser(userIDs []uint) (map[uint][]KYCLevel, error) {
+	byUser := make(map[uint][]KYCLevel, len(userIDs))
+	if len(userIDs) == 0 {
+		return byUser, nil
+	}
+	var levels []KYCLevel
+	if err := db.Where("user_id IN ?", userIDs).Order("tier").Find(&levels).Error; err != nil {
+		return nil, err
+	}
+	for _, level := range levels {
+		byUser[level.UserID] = append(byUser[level.UserID], level)
+	}
+	return byUser, nil
+}
+
+func GetKYCLevel(userID uint, tier int) (*KYCLevel, error) {
+	var level KYCLevel
+	err := db.Where("user_id = ? AND tier = ?", userID, tier).First(&level).Error
+	return &level, err
+}
+
+// KYCTier returns the user's current tier
+func KYCTier(userID uint) int {
+	levels, err := GetKYCLevels(userID)
+	if err != nil {
+		return KYCTier0
+	}
+	return TierFromLevels(levels)
+}
+
+// TierFromLevels is the highest tier approved along with every tier below
+// it, levels must be ordered by tier
+func TierFromLevels(levels []KYCLevel) int {
+	tier := KYCTier0
+	for _, level := range levels {
+		if level.Tier != tier+1 || level.Status != Approved {
+			break
+		}
+		tier = level.Tier
+	}
+	return tier
+}
+
+// CheckKYCSubmission returns why the tiers cannot go up for review together.
+// Each must be new or rejected, and follow a tier already in review or
+// approved, or one in the same submission.
+func CheckKYCSubmission(userID uint, tiers []int) error {
+	return checkKYCSubmission(db, userID, tiers)
+}
+
+func checkKYCSubmission(tx *gorm.DB, userID uint, tiers []int) error {
+	levels, err := getKYCLevels(tx, userID)
+	if err != nil {
+		return err
+	}
+	statuses := make(map[int]KYCStatus, len(levels))
+	for _, level := range levels {
+		statuses[level.Tier] = level.Status
+	}
+
+	for _, tier := range tiers {
+		if tier < KYCTier1 || tier > MaxKYCTier {
+			return fmt.Errorf("unknown KYC tier %d", tier)
+		}
+		if status, ok := statuses[tier]; ok && status != Rejected {
+			return fmt.Errorf("KYC tier %d is already %s", tier, status)
+		}
+		if tier == KYCTier1 || slices.Contains(tiers, tier-1) {
+			continue
+		}
+		if status, ok := statuses[tier-1]; !ok || status == Rejected {
+			return fmt.Errorf("KYC tier %d must be submitted before tier %d", tier-1, tier)
+		}
+	}
+	return nil
+}
+
+// SubmitKYCLevels puts the tiers up for review, a tier already pending or
+// approved is never redone
+func SubmitKYCLevels(userID uint, tiers []int) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		return submitKYCLevels(tx, userID, tiers)
+	})
+}
+
+func submitKYCLevels(tx *gorm.DB, userID uint, tiers []int) error {
+	if err := checkKYCSubmission(tx, userID, tiers); err != nil {
+		return err
+	}
+	tiers = slices.Clone(tiers)
+	slices.Sort(tiers)
+	for _, tier := range tiers {
+		level := &KYCLevel{}
+		err := tx.Where("user_id = ? AND tier = ?", userID, tier).First(level).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		level.UserID = userID
+		level.Tier = tier
+		level.Status = Pending
+		level.RejectionReason = ""
+		level.ReviewedByID = nil
+		level.RejectedAt = nil
+		level.SubmittedAt = time.Now()
+		if err := tx.Save(level).Error; err != nil {
+			return err
+		}
+	}
+	return syncKYCStatus(tx, userID)
+}
+
+// CanApprove returns why the tier cannot be approved, tiers are approved in
+// order
+func (l *KYCLevel) CanApprove() error {
+	return l.canApprove(db)
+}
+
+func (l *KYCLevel) canApprove(tx *gorm.DB) error {
+	if l.Status != Pending {
+		return ErrKYCLevelNotPending
+	}
+	if l.Tier > KYCTier1 {
+		var previous KYCLevel
+		err := tx.Where("user_id = ? AND tier = ?", l.UserID, l.Tier-1).First(&previous).Error
+		if err != nil || previous.Status != Approved {
+			return fmt.Errorf("KYC tier %d must be approved before tier %d", l.Tier-1, l.Tier)
+		}
+	}
+	return nil
+}
+
+// Approve passes a pending tier
+func (l *KYCLevel) Approve(adminID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		return l.approve(tx, adminID)
+	})
+}
+
+// ApproveWithIdentity passes tier 2 with the Borderless identity created for
+// it. The identity on the KYC, the user's verification and the tier are
+// saved together, so a failure leaves the user on their previous tier.
+func (l *KYCLevel) ApproveWithIdentity(adminID uint, kyc *KYC, borderlessIdentityID string) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&KYC{}).Where("id = ?", kyc.ID).Update("borderless_identity_id", borderlessIdentityID).Error; err != nil {
+			return err
+		}
+		// the ID document makes the user verified
+		if err := tx.Model(&User{}).Where("id = ?", l.UserID).Update("is_verified", true).Error; err != nil {
+			return err
+		}
+		return l.approve(tx, adminID)
+	})
+	if err == nil {
+		kyc.BorderlessIdentityId = borderlessIdentityID
+	}
+	return err
+}
+
+func (l *KYCLevel) approve(tx *gorm.DB, adminID uint) error {
+	if err := l.canApprove(tx); err != nil {
+		return err
+	}
+	now := time.Now()
+	l.Status = Approved
+	l.ReviewedByID = &adminID
+	l.ApprovedAt = &now
+	if err := tx.Save(l).Error; err != nil {
+		return err
+	}
+	return syncKYCStatus(tx, l.UserID)
+}
+
+func (l *KYCLevel) Reject(adminID uint, reason string) error {
+	if l.Status != Pending {
+		return ErrKYCLevelNotPending
+	}
+	now := time.Now()
+	l.Status = Rejected
+	l.RejectionReason = reason
+	l.ReviewedByID = &adminID
+	l.RejectedAt = &now
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(l).Error; err != nil {
+			return err
+		}
+		return syncKYCStatus(tx, l.UserID)
+	})
+}
+
+// syncKYCStatus keeps the overall KYC status for clients that predate
+// tiers: rejected when a tier is rejected, pending while one is in review
+// and approved otherwise
+func syncKYCStatus(tx *gorm.DB, userID uint) error {
+	var kyc KYC
+	if err := tx.Where("user_id = ?", userID).First(&kyc).Error; err != nil {
+		return nil
+	}
+	levels, err := getKYCLevels(tx, userID)
+	if err != nil {
+		return err
+	}
+
+	status := Approved
+	for _, level := range levels {
+		if level.Status == Rejected {
+			status = Rejected
+			kyc.RejectionReason = level.RejectionReason
+			kyc.RejectedAt = time.Now()
+			break
+		}
+		if level.Status == Pending {
+			status = Pending
+		}
+	}
+	if status == Approved && kyc.Status != Approved {
+		kyc.ApprovedAt = time.Now()
+	}
+	kyc.Status = status
+	return tx.Save(&kyc).Error
+}
+
+// DeleteKYCLevels removes the user's pending and rejected reviews so KYC
+// can start over, approved tiers are kept
+func DeleteKYCLevels(userID uint) error {
+	return db.Unscoped().Where("user_id = ? AND status <> ?", userID, Approved).Delete(&KYCLevel{}).Error
+}
+
+// BackfillKYCLevels gives KYC submitted before tiers existed a tier 1 and
+// tier 2 review in its current status, it runs with the migrations
+func BackfillKYCLevels(db *gorm.DB) error {
+	var kycs []KYC
+	err := db.Where("user_id NOT IN (?)", db.Model(&KYCLevel{}).Select("user_id")).Find(&kycs).Error
+	if err != nil {
+		return err
+	}
+	for _, kyc := range kycs {
+		for _, tier := range []int{KYCTier1, KYCTier2} {
+			level := KYCLevel{
+				UserID: kyc.UserID, Tier: tier, Status: kyc.Status,
+				RejectionReason: kyc.RejectionReason, SubmittedAt: kyc.CreatedAt,
+			}
+			switch kyc.Status {
+			case Approved:
+				level.ApprovedAt = &kyc.ApprovedAt
+			case Rejected:
+				level.RejectedAt = &kyc.RejectedAt
+			}
+			if err := db.Create(&level).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,303 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Directions limits apply to, outgoing on-chain transfers count as off-ramps
+const (
+	LimitOnRamp  = "on_ramp"
+	LimitOffRamp = "off_ramp"
+)
+
+var LimitDirections = []string{LimitOnRamp, LimitOffRamp}
+
+// LimitRails are the rails limits can be set on
+var LimitRails = []string{RailBank, RailMobileMoney, RailBorderless, RailOnchain}
+
+// Limit periods, per transaction limits cap a single request
+const (
+	LimitPerTransaction = "per_transaction"
+	LimitDaily          = "daily"
+	LimitMonthly        = "monthly"
+	LimitLifetime       = "lifetime"
+)
+
+var LimitPeriods = []string{LimitPerTransaction, LimitDaily, LimitMonthly, LimitLifetime}
+
+// LimitAmounts are USD amounts per period, nil means the period is not
+// limited at this level
+type LimitAmounts struct {
+	PerTransaction *float64 `json:"per_transaction"`
+	Daily          *float64 `json:"daily"`
+	Monthly        *float64 `json:"monthly"`
+	Lifetime       *float64 `json:"lifetime"`
+}
+
+// Get returns the amount for one of LimitPeriods
+func (a LimitAmounts) Get(period string) *float64 {
+	switch period {
+	case LimitPerTransaction:
+		return a.PerTransaction
+	case LimitDaily:
+		return a.Daily
+	case LimitMonthly:
+		return a.Monthly
+	case LimitLifetime:
+		return a.Lifetime
+	}
+	return nil
+}
+
+// LimitRule limits users by KYC tier, country, rail and direction. Empty
+// fields match everything and the most specific matching rule wins.
+type LimitRule struct {
+	gorm.Model
+	Tier      *int   `gorm:"index" json:"tier"`
+	Country   string `json:"country"` // alpha-2 country code of the user
+	Rail      string `json:"rail"`
+	Direction string `json:"direction"`
+	LimitAmounts
+}
+
+// LimitOverride replaces the rules for one user, on one rail and direction
+// or, left empty, on all of them
+type LimitOverride struct {
+	gorm.Model
+	UserID      uint       `gorm:"index" json:"user_id"`
+	Rail        string     `json:"rail"`
+	Direction   string     `json:"direction"`
+	Reason      string     `json:"reason"`
+	CreatedByID uint       `json:"created_by_id"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	LimitAmounts
+}
+
+// specificity counts the fields a rule or override narrows on
+func specificity(fields ...string) int {
+	n := 0
+	for _, field := range fields {
+		if field != "" {
+			n++
+		}
+	}
+	return n
+}
+
+func (r LimitRule) Specificity() int {
+	n := specificity(r.Country, r.Rail, r.Direction)
+	if r.Tier != nil {
+		n++
+	}
+	return n
+}
+
+func (o LimitOverride) Specificity() int {
+	return specificity(o.Rail, o.Direction)
+}
+
+func (r *LimitRule) CreateLimitRule() error {
+	return db.Create(r).Error
+}
+
+func (r *LimitRule) UpdateLimitRule() error {
+	return db.Save(r).Error
+}
+
+func (r *LimitRule) DeleteLimitRule() error {
+	return db.Delete(r).Error
+}
+
+func GetLimitRule(id uint) (*LimitRule, error) {
+	var rule LimitRule
+	err := db.First(&rule, id).Error
+	return &rule, err
+}
+
+func GetLimitRules() ([]LimitRule, error) {
+	var rules []LimitRule
+	err := db.Order("id").Find(&rules).Error
+	return rules, err
+}
+
+// GetMatchingLimitRules returns the rules that apply to a user of the tier
+// and country on the rail and direction
+func GetMatchingLimitRules(tier int, country, rail, direction string) ([]LimitRule, error) {
+	var rules []LimitRule
+	err := db.Where("(tier IS NULL OR tier = ?)", tier).
+		Where("(country = '' OR UPPER(country) = ?)", strings.ToUpper(country)).
+		Where("(rail = '' OR rail = ?)", rail).
+		Where("(direction = '' OR direction = ?)", direction).
+		Find(&rules).Error
+	return rules, err
+}
+
+func GetLimitOverride(id uint) (*LimitOverride, error) {
+	var override LimitOverride
+	err := db.First(&override, id).Error
+	return &override, err
+}
+
+func GetUserLimitOverrides(userID uint) ([]LimitOverride, error) {
+	var overrides []LimitOverride
+	err := db.Where("user_id = ?", userID).Order("id").Find(&overrides).Error
+	return overrides, err
+}
+
+// GetMatchingLimitOverrides returns the user's unexpired overrides for the
+// rail and direction
+func GetMatchingLimitOverrides(userID uint, rail, direction string) ([]LimitOverride, error) {
+	var overrides []LimitOverride
+	err := db.Where("user_id = ?", userID).
+		Where("(rail = '' OR rail = ?)", rail).
+		Where("(direction = '' OR direction = ?)", direction).
+		Where("(expires_at IS NULL OR expires_at > ?)", time.Now()).
+		Find(&overrides).Error
+	return overrides, err
+}
+
+// SaveLimitOverride replaces the user's override for the same rail and
+// direction
+func (o *LimitOverride) SaveLimitOverride() error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("user_id = ? AND rail = ? AND direction = ?", o.UserID, o.Rail, o.Direction).
+			Delete(&LimitOverride{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(o).Error
+	})
+}
+
+func (o *LimitOverride) DeleteLimitOverride() error {
+	return db.Delete(o).Error
+}
+
+// LimitReservationTTL is how long a passed limit check holds its amount when
+// the request is not released earlier, long enough for the request to be
+// recorded
+const LimitReservationTTL = 30 * time.Minute
+
+// LimitReservation holds the USD amount of a request that passed its limit
+// check until the request is recorded, so the user's concurrent requests
+// count it
+type LimitReservation struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	UserID    uint      `gorm:"index" json:"user_id"`
+	Rail      string    `json:"rail"`
+	Direction string    `json:"direction"`
+	AmountUSD float64   `json:"amount_usd"`
+	ExpiresAt time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ReserveLimit locks the user's row, runs check with the USD amount already
+// reserved on the rail and direction and stores the reservation when check
+// passes. Limit checks of one user run one at a time.
+func ReserveLimit(r *LimitReservation, check func(reserved float64) error) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&User{}, r.UserID).Error
+		if err != nil {
+			return err
+		}
+		// the user's expired reservations are cleared on their next check
+		now := time.Now()
+		if err := tx.Where("user_id = ? AND expires_at <= ?", r.UserID, now).Delete(&LimitReservation{}).Error; err != nil {
+			return err
+		}
+		var reserved float64
+		err = tx.Model(&LimitReservation{}).Select("COALESCE(SUM(amount_usd), 0)").
+			Where("user_id = ? AND rail = ? AND direction = ?", r.UserID, r.Rail, r.Direction).
+			Scan(&reserved).Error
+		if err != nil {
+			return err
+		}
+		if err := check(reserved); err != nil {
+			return err
+		}
+		r.ExpiresAt = now.Add(LimitReservationTTL)
+		return tx.Create(r).Error
+	})
+}
+
+// ReleaseLimitReservation drops a reservation once its request is recorded
+// or has failed
+func ReleaseLimitReservation(id uint) error {
+	return db.Delete(&LimitReservation{}, id).Error
+}
+
+// CurrencyTotal is an amount summed per currency or asset
+type CurrencyTotal struct {
+	Currency string
+	Total    float64
+}
+
+// limitSource maps one request table onto the amount and currency limits
+// are counted in, the crypto side wherever the request has one
+type limitSource struct {
+	table     string
+	rail      string
+	direction string
+	amount    string
+	currency  string
+	where     string
+}
+
+var limitSources = []limitSource{
+	{table: "deposit_requests", rail: RailBank, direction: LimitOnRamp, amount: "asset_equivalent", currency: "proposed_asset"},
+	{table: "withdrawal_requests", rail: RailBank, direction: LimitOffRamp, amount: "crypto_amount", currency: "asset"},
+	{
+		table: "hurupay_requests", rail: RailMobileMoney, direction: LimitOnRamp, amount: "amount",
+		currency: "country_currency", where: fmt.Sprintf("request_type = '%s'", OnRamp),
+	},
+	{
+		table: "hurupay_requests", rail: RailMobileMoney, direction: LimitOffRamp, amount: "amount",
+		currency: "token", where: fmt.Sprintf("request_type = '%s'", OffRamp),
+	},
+	{
+		table: "borderless_requests", rail: RailBorderless, direction: LimitOnRamp, amount: "fiat_amount",
+		currency: "fiat_currency", where: "payment_instruction_id IS NULL",
+	},
+	{
+		table: "borderless_requests", rail: RailBorderless, direction: LimitOffRamp, amount: "fiat_amount",
+		currency: "asset", where: "payment_instruction_id IS NOT NULL",
+	},
+	{
+		table: "transactions", rail: RailOnchain, direction: LimitOffRamp, amount: "amount",
+		currency: "asset", where: "LOWER(transaction_sub_type) = 'withdrawal' AND " + standaloneTransfers,
+	},
+}
+
+var ErrUnknownLimitScope = errors.New("unknown rail or direction")
+
+// LimitUsage sums the user's requests on the rail and direction created
+// since the given time, per currency. Failed requests do not count, pending
+// ones do so concurrent requests cannot slip past a limit.
+func LimitUsage(userID uint, rail, direction string, since time.Time) ([]CurrencyTotal, error) {
+	for _, s := range limitSources {
+		if s.rail != rail || s.direction != direction {
+			continue
+		}
+		query := db.Table(s.table).
+			Select(fmt.Sprintf("UPPER(COALESCE(%s, '')) AS currency, COALESCE(SUM(%s), 0) AS total", s.currency, toNumber(s.amount))).
+			Where("user_id = ? AND deleted_at IS NULL", userID).
+			Where("LOWER(COALESCE(status, '')) NOT IN ?", failedStatuses).
+			Group(fmt.Sprintf("UPPER(COALESCE(%s, ''))", s.currency))
+		if !since.IsZero() {
+			query = query.Where("created_at >= ?", since)
+		}
+		if s.where != "" {
+			query = query.Where(s.where)
+		}
+		var totals []CurrencyTotal
+		err := query.Scan(&totals).Error
+		return totals, err
+	}
+	return nil, ErrUnknownLimitScope
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type MailStatus string
+
+const (
+	MailPending MailStatus = "Pending"
+	MailSent    MailStatus = "Sent"
+	MailFailed  MailStatus = "Failed"
+)
+
+// Retry policy, attempt n waits MailBaseBackoff * 2^(n-1) capped at
+// MailMaxBackoff, mails are given up after MailMaxAttempts
+const (
+	MailMaxAttempts  = 8
+	MailBaseBackoff  = time.Minute
+	MailMaxBackoff   = 2 * time.Hour
+	mailClaimLease   = 2 * time.Minute
+	mailErrorMaxSize = 1000
+)
+
+// OutboxMail is a rendered mail waiting to be sent. It is kept after sending
+// as a record of what went out, without its bodies which can carry tokens.
+type OutboxMail struct {
+	gorm.Model
+	Recipients    string     `gorm:"type:text" json:"recipients"`
+	FromName      string     `json:"from_name"`
+	ReplyTo       string     `json:"reply_to"`
+	Subject       string     `json:"subject"`
+	HTMLBody      string     `gorm:"type:text" json:"-"`
+	TextBody      string     `gorm:"type:text" json:"-"`
+	Template      string     `gorm:"index" json:"template"`
+	Locale        string     `json:"locale"`
+	Status        MailStatus `gorm:"default:Pending;index" json:"status"`
+	Attempts      int        `gorm:"default:0" json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"index" json:"next_attempt_at"`
+	LastError     string     `json:"last_error"`
+	SentAt        *time.Time `json:"sent_at"`
+}
+
+func (m *OutboxMail) CreateOutboxMail() error {
+	return db.Create(m).Error
+}
+
+func (m *OutboxMail) RecipientList() []string {
+	return splitList(m.Recipients)
+}
+
+func GetOutboxMailByID(id uint) (*OutboxMail, error) {
+	var mail OutboxMail
+	err := db.First(&mail, id).Error
+	return &mail, err
+}
+
+// GetDueOutboxMails returns pending mails whose next attempt is due
+func GetDueOutboxMails(limit int) ([]OutboxMail, error) {
+	var mails []OutboxMail
+	err := db.Where("status = ? AND next_attempt_at <= ?", MailPending, time.Now()).
+		Order("next_attempt_at asc").Limit(limit).Find(&mails).Error
+	return mails, err
+}
+
+// Claim leases the mail to the caller by pushing its next attempt out, so
+// concurrent workers never send the same mail twice
+func (m *OutboxMail) Claim() bool {
+	lease := time.Now().Add(mailClaimLease)
+	result := db.Model(&OutboxMail{}).
+		Where("id = ? AND status = ? AND next_attempt_at = ?", m.ID, MailPending, m.NextAttemptAt).
+		Update("next_attempt_at", lease)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false
+	}
+	m.NextAttemptAt = lease
+	return true
+}
+
+func (m *OutboxMail) MarkSent() error {
+	now := time.Now()
+	m.Attempts++
+	m.Status = MailSent
+	m.SentAt = &now
+	m.LastError = ""
+	m.clearBodies()
+	return db.Save(m).Error
+}
+
+// RecordFailure schedules the next attempt or gives up on the mail
+func (m *OutboxMail) RecordFailure(sendErr error) error {
+	m.Attempts++
+	m.LastError = sendErr.Error()
+	if len(m.LastError) > mailErrorMaxSize {
+		m.LastError = m.LastError[:mailErrorMaxSize]
+	}
+	if m.Attempts >= MailMaxAttempts {
+		m.Status = MailFailed
+		m.clearBodies()
+	} else {
+		m.NextAttemptAt = time.Now().Add(exponentialBackoff(MailBaseBackoff, MailMaxBackoff, m.Attempts))
+	}
+	return db.Save(m).Error
+}
+
+// clearBodies drops the content of a mail that will not be sent again
+func (m *OutboxMail) clearBodies() {
+	m.HTMLBody = ""
+	m.TextBody = ""
+}
@@ -0,0 +1,416 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Monitoring rule types
+const (
+	RuleStructuring        = "structuring"           // repeated amounts just under a threshold
+	RuleRapidInOut         = "rapid_in_out"          // on-ramped funds off-ramped again within hours
+	RuleManyBeneficiaries  = "many_beneficiaries"    // payouts to many distinct beneficiaries
+	RuleNewDeviceHighValue = "new_device_high_value" // a large request soon after signing in on a new device
+	RuleHighRiskCorridor   = "high_risk_corridor"    // requests from or to a high-risk country
+)
+
+const (
+	defaultRuleWindowHours = 24
+	maxRuleWindowHours     = 30 * 24
+	maxRuleCountries       = 100
+	maxRuleNameLength      = 100
+)
+
+var MonitoringRuleTypes = []string{
+	RuleStructuring, RuleRapidInOut, RuleManyBeneficiaries, RuleNewDeviceHighValue, RuleHighRiskCorridor,
+}
+
+// MonitoringParams are the thresholds of a rule, amounts are in USD. Each
+// rule type reads only some of them:
+//
+//	structuring: Threshold (the user's per transaction limit when 0), Margin,
+//	             MinCount and WindowHours
+//	rapid_in_out: MinAmount, Ratio and WindowHours
+//	many_beneficiaries: MinCount and WindowHours
+//	new_device_high_value: MinAmount and WindowHours, how new the device is
+//	high_risk_corridor: Countries and MinAmount
+type MonitoringParams struct {
+	WindowHours int      `json:"window_hours,omitempty"`
+	MinAmount   float64  `json:"min_amount,omitempty"`
+	Threshold   float64  `json:"threshold,omitempty"`
+	Margin      float64  `json:"margin,omitempty"`
+	MinCount    int      `json:"min_count,omitempty"`
+	Ratio       float64  `json:"ratio,omitempty"`
+	Countries   []string `json:"countries,omitempty"`
+}
+
+// Window is how far back the rule looks
+func (p MonitoringParams) Window() time.Duration {
+	if p.WindowHours <= 0 {
+		return defaultRuleWindowHours * time.Hour
+	}
+	return time.Duration(p.WindowHours) * time.Hour
+}
+
+// MonitoringRule is a behavioural check run on every new request, hits
+// raise alerts that open compliance cases of the rule's priority
+type MonitoringRule struct {
+	gorm.Model
+	Name        string           `json:"name"`
+	Type        string           `gorm:"index" json:"type"`
+	Enabled     bool             `json:"enabled"`
+	Priority    string           `json:"priority"`
+	Blocking    bool             `json:"blocking"`
+	Params      MonitoringParams `gorm:"-" json:"params"`
+	ParamsJSON  string           `gorm:"column:params" json:"-"`
+	Description string           `json:"description"`
+}
+
+func (r *MonitoringRule) BeforeSave(tx *gorm.DB) error {
+	encoded, err := json.Marshal(r.Params)
+	if err != nil {
+		return err
+	}
+	r.ParamsJSON = string(encoded)
+	return nil
+}
+
+func (r *MonitoringRule) AfterFind(tx *gorm.DB) error {
+	if r.ParamsJSON == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(r.ParamsJSON), &r.Params)
+}
+
+// Validate checks the rule has the thresholds its type needs
+func (r *MonitoringRule) Validate() error {
+	p := r.Params
+	if strings.TrimSpace(r.Name) == "" || len(r.Name) > maxRuleNameLength {
+		return errors.New("name is required, at most 100 characters")
+	}
+	if _, ok := CaseSLAs[r.Priority]; !ok {
+		return errors.New("priority must be one of low, medium, high or critical")
+	}
+	if p.WindowHours < 0 || p.WindowHours > maxRuleWindowHours {
+		return errors.New("window_hours must be between 0 and 720")
+	}
+	if p.MinAmount < 0 || p.Threshold < 0 {
+		return errors.New("amounts cannot be negative")
+	}
+	switch r.Type {
+	case RuleStructuring:
+		if p.Margin <= 0 || p.Margin >= 1 {
+			return errors.New("structuring needs a margin between 0 and 1")
+		}
+		if p.MinCount < 2 {
+			return errors.New("structuring needs a min_count of at least 2")
+		}
+	case RuleRapidInOut:
+		if p.Ratio <= 0 || p.Ratio > 1 {
+			return errors.New("rapid_in_out needs a ratio between 0 and 1")
+		}
+	case RuleManyBeneficiaries:
+		if p.MinCount < 2 {
+			return errors.New("many_beneficiaries needs a min_count of at least 2")
+		}
+	case RuleNewDeviceHighValue:
+		if p.MinAmount <= 0 {
+			return errors.New("new_device_high_value needs a min_amount")
+		}
+	case RuleHighRiskCorridor:
+		if len(p.Countries) == 0 || len(p.Countries) > maxRuleCountries {
+			return errors.New("high_risk_corridor needs between 1 and 100 countries")
+		}
+		for i, country := range p.Countries {
+			if len(country) != 2 {
+				return fmt.Errorf("%q is not an alpha-2 country code", country)
+			}
+			r.Params.Countries[i] = strings.ToUpper(country)
+		}
+	default:
+		return errors.New("unknown rule type: " + r.Type)
+	}
+	return nil
+}
+
+func (r *MonitoringRule) CreateMonitoringRule() error {
+	return db.Create(r).Error
+}
+
+func (r *MonitoringRule) UpdateMonitoringRule() error {
+	return db.Save(r).Error
+}
+
+func (r *MonitoringRule) DeleteMonitoringRule() error {
+	return db.Delete(r).Error
+}
+
+func GetMonitoringRule(id uint) (*MonitoringRule, error) {
+	var rule MonitoringRule
+	err := db.First(&rule, id).Error
+	return &rule, err
+}
+
+func GetMonitoringRules() ([]MonitoringRule, error) {
+	var rules []MonitoringRule
+	err := db.Order("id").Find(&rules).Error
+	return rules, err
+}
+
+func GetEnabledMonitoringRules() ([]MonitoringRule, error) {
+	var rules []MonitoringRule
+	err := db.Where("enabled = ?", true).Order("id").Find(&rules).Error
+	return rules, err
+}
+
+// SeedMonitoringRules stores the rules when no rule exists yet, so the
+// defaults can be tuned or disabled but are not brought back
+func SeedMonitoringRules(rules []MonitoringRule) error {
+	var count int64
+	if err := db.Unscoped().Model(&MonitoringRule{}).Count(&count).Error; err != nil || count > 0 {
+		return err
+	}
+	return db.Create(&rules).Error
+}
+
+// MonitoringAlert is a rule hit on one request, raised once per rule and
+// request
+type MonitoringAlert struct {
+	gorm.Model
+	RuleID    uint    `gorm:"uniqueIndex:idx_monitoring_alert" json:"rule_id"`
+	RuleType  string  `gorm:"index" json:"rule_type"`
+	UserID    uint    `gorm:"index" json:"user_id"`
+	Source    string  `gorm:"uniqueIndex:idx_monitoring_alert" json:"source"`
+	SourceID  uint    `gorm:"uniqueIndex:idx_monitoring_alert" json:"source_id"`
+	AmountUSD float64 `json:"amount_usd"`
+	Detail    string  `json:"detail"`
+	CaseID    *uint   `gorm:"index" json:"case_id"`
+}
+
+// CreateMonitoringAlert stores the alert, reporting false when the rule
+// already alerted on the request and loading the stored alert into a then
+func (a *MonitoringAlert) CreateMonitoringAlert() (bool, error) {
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(a)
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.RowsAffected > 0, result.Error
+	}
+	err := db.Where("rule_id = ? AND source = ? AND source_id = ?", a.RuleID, a.Source, a.SourceID).First(a).Error
+	return false, err
+}
+
+func (a *MonitoringAlert) LinkCase(caseID uint) error {
+	a.CaseID = &caseID
+	return db.Model(a).Update("case_id", caseID).Error
+}
+
+var monitoringAlertListSpec = ListSpec{
+	Sorts: map[string]SortField{
+		"created_at": timeSort("created_at", func(row interface{}) time.Time { return row.(MonitoringAlert).CreatedAt }),
+	},
+	DefaultSort:   "-created_at",
+	SearchColumns: []string{"detail"},
+	id:            func(row interface{}) uint { return row.(MonitoringAlert).ID },
+}
+
+// FilterMonitoringAlerts lists alerts, zero values do not filter
+func FilterMonitoringAlerts(ruleID, userID uint, ruleType string, lq ListQuery) ([]MonitoringAlert, PageInfo, error) {
+	query := db.Model(&MonitoringAlert{})
+	if ruleID != 0 {
+		query = query.Where("rule_id = ?", ruleID)
+	}
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	if ruleType != "" {
+		query = query.Where("rule_type = ?", ruleType)
+	}
+	return paginate[MonitoringAlert](query, monitoringAlertListSpec, lq)
+}
+
+// MonitoringCursor is the last request of a table the monitor evaluated
+type MonitoringCursor struct {
+	Source    string `gorm:"primarykey"`
+	LastID    uint
+	UpdatedAt time.Time
+}
+
+// GetMonitoringCursor returns the cursor of the table, nil before the first
+// run
+func GetMonitoringCursor(source string) (*MonitoringCursor, error) {
+	var cursor MonitoringCursor
+	err := db.Where("source = ?", source).First(&cursor).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &cursor, err
+}
+
+func (c *MonitoringCursor) SaveMonitoringCursor() error {
+	return db.Save(c).Error
+}
+
+// MonitoredEvent is a request of any rail in the shape rules read it, the
+// amount in the currency or asset limits count it in
+type MonitoredEvent struct {
+	Source      string    `json:"source"`
+	ID          uint      `json:"id"`
+	UserID      uint      `json:"user_id"`
+	Rail        string    `json:"rail"`
+	Direction   string    `json:"direction"`
+	Amount      float64   `json:"amount"`
+	Currency    string    `json:"currency"`
+	Country     string    `json:"country"`
+	UserCountry string    `json:"user_country"`
+	Beneficiary string    `json:"beneficiary,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// monitoredSource maps one request table onto MonitoredEvent, every field is
+// a SQL expression over that table
+type monitoredSource struct {
+	table       string
+	rail        string
+	direction   string
+	amount      string
+	currency    string
+	country     string
+	beneficiary string
+}
+
+var monitoredSources = []monitoredSource{
+	{
+		table: "deposit_requests", rail: RailBank, direction: "'" + LimitOnRamp + "'",
+		amount: "asset_equivalent", currency: "proposed_asset", country: "country_code", beneficiary: "''",
+	},
+	{
+		table: "withdrawal_requests", rail: RailBank, direction: "'" + LimitOffRamp + "'",
+		amount: "crypto_amount", currency: "asset", country: "''", beneficiary: "account_number",
+	},
+	{
+		table: "hurupay_requests", rail: RailMobileMoney,
+		direction:   fmt.Sprintf("CASE WHEN request_type = '%s' THEN '%s' ELSE '%s' END", OnRamp, LimitOnRamp, LimitOffRamp),
+		amount:      "amount",
+		currency:    fmt.Sprintf("CASE WHEN request_type = '%s' THEN country_currency ELSE token END", OnRamp),
+		country:     "country_code",
+		beneficiary: fmt.Sprintf("CASE WHEN request_type = '%s' THEN mobile_number ELSE '' END", OffRamp),
+	},
+	{
+		table: "borderless_requests", rail: RailBorderless,
+		direction:   fmt.Sprintf("CASE WHEN payment_instruction_id IS NULL THEN '%s' ELSE '%s' END", LimitOnRamp, LimitOffRamp),
+		amount:      "fiat_amount",
+		currency:    "CASE WHEN payment_instruction_id IS NULL THEN fiat_currency ELSE asset END",
+		country:     "country",
+		beneficiary: "CASE WHEN payment_instruction_id IS NULL THEN '' ELSE account_id END",
+	},
+	{
+		table: "transactions", rail: RailOnchain,
+		direction: fmt.Sprintf("CASE WHEN LOWER(transaction_sub_type) = 'withdrawal' THEN '%s' ELSE '%s' END", LimitOffRamp, LimitOnRamp),
+		amount:    "amount", currency: "asset", country: "''",
+		beneficiary: "CASE WHEN LOWER(transaction_sub_type) = 'withdrawal' THEN counter_address ELSE '' END",
+	},
+}
+
+// MonitoredSources are the tables the monitor reads
+func MonitoredSources() []string {
+	tables := make([]string, len(monitoredSources))
+	for i, s := range monitoredSources {
+		tables[i] = s.table
+	}
+	return tables
+}
+
+func (s monitoredSource) query() string {
+	return fmt.Sprintf(`SELECT '%s' AS source, id, user_id, '%s' AS rail, %s AS direction,
+	COALESCE(%s, 0) AS amount, UPPER(COALESCE(%s, '')) AS currency, UPPER(COALESCE(%s, '')) AS country,
+	COALESCE(%s, '') AS user_country, COALESCE(%s, '') AS beneficiary, created_at
+	FROM %s WHERE deleted_at IS NULL AND LOWER(COALESCE(status, '')) NOT IN ('%s')`,
+		s.table, s.rail, s.direction, toNumber(s.amount), s.currency, s.country,
+		userCountry(s.table), s.beneficiary, s.table, strings.Join(failedStatuses, "', '"))
+}
+
+func monitoredRows() string {
+	parts := make([]string, len(monitoredSources))
+	for i, s := range monitoredSources {
+		parts[i] = s.query()
+	}
+	return "(" + strings.Join(parts, "\nUNION ALL\n") + ") monitored_events"
+}
+
+// MonitoredEventsAfter returns up to limit requests of the table created
+// after the one with afterID, failed requests are left out
+func MonitoredEventsAfter(source string, afterID uint, limit int) ([]MonitoredEvent, error) {
+	for _, s := range monitoredSources {
+		if s.table != source {
+			continue
+		}
+		var events []MonitoredEvent
+		err := db.Raw(fmt.Sprintf("SELECT * FROM (%s) e WHERE id > ? ORDER BY id LIMIT ?", s.query()), afterID, limit).
+			Scan(&events).Error
+		return events, err
+	}
+	return nil, errors.New("unknown monitored source: " + source)
+}
+
+// LatestMonitoredID is the id of the table's newest row, failed or not
+func LatestMonitoredID(source string) (uint, error) {
+	var id uint
+	err := db.Table(source).Select("COALESCE(MAX(id), 0)").Scan(&id).Error
+	return id, err
+}
+
+// UserMonitoredEvents returns the user's requests of every rail created in
+// [from, to], oldest first
+func UserMonitoredEvents(userID uint, from, to time.Time) ([]MonitoredEvent, error) {
+	var events []MonitoredEvent
+	err := db.Raw("SELECT * FROM "+monitoredRows()+" WHERE user_id = ? AND created_at >= ? AND created_at <= ? ORDER BY created_at, id",
+		userID, from, to).Scan(&events).Error
+	return events, err
+}
+
+// MonitoredEventsBetween returns the requests created in [from, to), of the
+// tenant's users when tenantID is set, up to limit
+func MonitoredEventsBetween(from, to time.Time, tenantID uint, limit int) ([]MonitoredEvent, error) {
+	query := "SELECT * FROM " + monitoredRows() + " WHERE created_at >= ? AND created_at < ?"
+	args := []interface{}{from, to}
+	if tenantID != 0 {
+		query += " AND user_id IN (SELECT id FROM users WHERE tenant_id = ?)"
+		args = append(args, tenantID)
+	}
+	var events []MonitoredEvent
+	err := db.Raw(query+" ORDER BY created_at, id LIMIT ?", append(args, limit)...).Scan(&events).Error
+	return events, err
+}
+
+// LastLoginBefore is the user's last successful sign in before t, nil when
+// there is none
+func LastLoginBefore(userID uint, t time.Time) (*SecurityEvent, error) {
+	var event SecurityEvent
+	err := db.Where("user_id = ? AND event = ? AND created_at <= ?", userID, EventLoginSucceeded, t).
+		Order("created_at desc").First(&event).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &event, err
+}
+
+// FirstLoginFrom is when the user first signed in with the user agent, and
+// whether they had signed in with another one before that
+func FirstLoginFrom(userID uint, userAgent string) (time.Time, bool, error) {
+	var first SecurityEvent
+	err := db.Where("user_id = ? AND event = ? AND user_agent = ?", userID, EventLoginSucceeded, userAgent).
+		Order("created_at").First(&first).Error
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	var earlier int64
+	err = db.Model(&SecurityEvent{}).
+		Where("user_id = ? AND event = ? AND user_agent <> ? AND created_at < ?", userID, EventLoginSucceeded, userAgent, first.CreatedAt).
+		Count(&earlier).Error
+	return first.CreatedAt, earlier > 0, err
+}
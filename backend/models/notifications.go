@@ -1,8 +1,11 @@
 package models
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"slices"
+	"strings"
 	"time"
 
 	"backend/serializers"
@@ -26,26 +29,71 @@ type Hmac struct {
 	Secret string `gorm:"type:varchar(64)" json:"hmac_secret"`
 }
 
+// Notification is an in-app message shown in the user's notification center,
+// Type is the event type that raised it
 type Notification struct {
-	*gorm.Model
-	UserID uint      `json:"user_id"`
-	User   User      `gorm:"foreignKey:UserID" json:"user"`
-	Type   string    `json:"type"`
-	Body   string    `json:"body"`
-	Read   bool      `json:"read"`
-	Link   string    `json:"link"`
-	ReadAt time.Time `json:"read_at"`
+	gorm.Model
+	UserID uint       `gorm:"index" json:"user_id"`
+	User   User       `gorm:"foreignKey:UserID" json:"-"`
+	Type   string     `gorm:"index" json:"type"`
+	Title  string     `json:"title"`
+	Body   string     `json:"body"`
+	Read   bool       `gorm:"default:false;index" json:"read"`
+	Link   string     `json:"link"`
+	ReadAt *time.Time `json:"read_at"`
 }
 
-func (n *Notification) MarkAsRead() {
-	n.Read = true
-	n.ReadAt = time.Now()
-	db.Save(n)
+// NotificationPreference opts a user in or out of messages outside the app
+// (email, SMS or WhatsApp) for one event type, event types without a row
+// send them
+type NotificationPreference struct {
+	gorm.Model
+	UserID    uint   `gorm:"uniqueIndex:idx_user_notification_event" json:"user_id"`
+	EventType string `gorm:"uniqueIndex:idx_user_notification_event" json:"event_type"`
+	Send      bool   `json:"send"`
+}
+
+// Channels messages outside the app can go out on
+const (
+	ChannelEmail    = "email"
+	ChannelSMS      = "sms"
+	ChannelWhatsApp = "whatsapp"
+)
+
+var NotificationChannels = []string{ChannelEmail, ChannelSMS, ChannelWhatsApp}
 
+// defaultNotificationChannels is used for users who never picked channels
+var defaultNotificationChannels = []string{ChannelEmail}
+
+// NotificationChannelPreference is the user's channel order, the first
+// channel that reaches the user wins and the rest are fallbacks
+type NotificationChannelPreference struct {
+	gorm.Model
+	UserID   uint   `gorm:"uniqueIndex" json:"user_id"`
+	Channels string `json:"channels"` // comma separated, in order
+}
+
+// NotificationEvents are the event types that reach the notification center
+var NotificationEvents = []string{
+	WebhookDepositApproved, WebhookDepositRejected,
+	WebhookWithdrawalSubmitted, WebhookWithdrawalCompleted,
+	WebhookKYCApproved, WebhookKYCRejected,
+	WebhookCollectionCompleted, WebhookCollectionFailed,
+	WebhookPayoutCompleted, WebhookPayoutFailed,
+}
+
+func (n *Notification) MarkAsRead() error {
+	if n.Read {
+		return nil
+	}
+	now := time.Now()
+	n.Read = true
+	n.ReadAt = &now
+	return db.Save(n).Error
 }
 
 func (n *Notification) CreateNotification() error {
-	return db.Create(&n).Error
+	return db.Create(n).Error
 }
 
 // GenerateHmacSecret generates a new HMAC secret
@@ -99,8 +147,134 @@ func VerifyWebhookAuthenticity(secret string, payload serializers.Webhook) bool
 	return checkValues
 }
 
-func GetUserNotifications(user *User, isRead bool) []Notification {
+// GetUserNotifications returns a page of the user's notifications, newest
+// first, along with the total matching count
+func GetUserNotifications(userID uint, unreadOnly bool, page, pageSize int) ([]Notification, int64, error) {
 	var notifications []Notification
-	db.Where("user_id = ? AND read = ?", user.ID, isRead).Find(&notifications)
-	return notifications
+	var total int64
+
+	query := db.Model(&Notification{}).Where("user_id = ?", userID)
+	if unreadOnly {
+		query = query.Where("read = ?", false)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("id desc").Offset((page - 1) * pageSize).Limit(pageSize).Find(&notifications).Error
+	return notifications, total, err
+}
+
+func GetUserNotification(userID, id uint) (*Notification, error) {
+	var notification Notification
+	err := db.Where("user_id = ?", userID).First(&notification, id).Error
+	return &notification, err
+}
+
+func CountUnreadNotifications(userID uint) (int64, error) {
+	var count int64
+	err := db.Model(&Notification{}).Where("user_id = ? AND read = ?", userID, false).Count(&count).Error
+	return count, err
+}
+
+// MarkAllNotificationsRead marks every unread notification of the user as read
+// and returns how many changed
+func MarkAllNotificationsRead(userID uint) (int64, error) {
+	var marked int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Notification{}).
+			Where("user_id = ? AND read = ?", userID, false).
+			Updates(map[string]interface{}{"read": true, "read_at": time.Now()})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		marked = result.RowsAffected
+		return emitChange(tx, ChangeNotification, userID, map[string]interface{}{"all_read": true})
+	})
+	return marked, err
+}
+
+// GetNotificationPreferences returns whether every notification event type
+// goes out of the app for the user, filling in the default for unset ones
+func GetNotificationPreferences(userID uint) (map[string]bool, error) {
+	var rows []NotificationPreference
+	if err := db.Where("user_id = ?", userID).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	preferences := make(map[string]bool, len(NotificationEvents))
+	for _, event := range NotificationEvents {
+		preferences[event] = true
+	}
+	for _, row := range rows {
+		preferences[row.EventType] = row.Send
+	}
+	return preferences, nil
+}
+
+// SetNotificationPreferences stores the setting of the given event types
+func SetNotificationPreferences(userID uint, preferences map[string]bool) error {
+	for event := range preferences {
+		if !slices.Contains(NotificationEvents, event) {
+			return errors.New("unknown notification event: " + event)
+		}
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for event, send := range preferences {
+			var row NotificationPreference
+			err := tx.Where("user_id = ? AND event_type = ?", userID, event).First(&row).Error
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			row.UserID = userID
+			row.EventType = event
+			row.Send = send
+			if err := tx.Save(&row).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// NotificationEnabled reports whether the user wants the event outside the app
+func NotificationEnabled(userID uint, eventType string) bool {
+	var row NotificationPreference
+	if err := db.Where("user_id = ? AND event_type = ?", userID, eventType).First(&row).Error; err != nil {
+		return true
+	}
+	return row.Send
+}
+
+// GetNotificationChannels returns the user's channels in fallback order
+func GetNotificationChannels(userID uint) []string {
+	var row NotificationChannelPreference
+	if err := db.Where("user_id = ?", userID).First(&row).Error; err != nil || row.Channels == "" {
+		return defaultNotificationChannels
+	}
+	return strings.Split(row.Channels, ",")
+}
+
+// SetNotificationChannels stores the user's channel order
+func SetNotificationChannels(userID uint, channels []string) error {
+	if len(channels) == 0 {
+		return errors.New("at least one notification channel is required")
+	}
+	for i, channel := range channels {
+		if !slices.Contains(NotificationChannels, channel) {
+			return errors.New("unknown notification channel: " + channel)
+		}
+		if slices.Contains(channels[:i], channel) {
+			return errors.New("duplicate notification channel: " + channel)
+		}
+	}
+
+	var row NotificationChannelPreference
+	err := db.Where("user_id = ?", userID).First(&row).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	row.UserID = userID
+	row.Channels = strings.Join(channels, ",")
+	return db.Save(&row).Error
 }
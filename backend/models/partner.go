@@ -0,0 +1,395 @@
+package models
+
+import (
+	"backend/state"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PartnerStatus string
+
+const (
+	PartnerActive    PartnerStatus = "Active"
+	PartnerSuspended PartnerStatus = "Suspended"
+)
+
+// Scopes a partner API key can be granted
+const (
+	ScopeOnRampWrite      = "onramp:write"
+	ScopeOffRampWrite     = "offramp:write"
+	ScopeAccountsRead     = "accounts:read"
+	ScopeUsersWrite       = "users:write"
+	ScopeUsersRead        = "users:read"
+	ScopeTransactionsRead = "transactions:read"
+	ScopeWebhooksManage   = "webhooks:manage"
+)
+
+var AllPartnerScopes = []string{
+	ScopeOnRampWrite, ScopeOffRampWrite, ScopeAccountsRead,
+	ScopeUsersWrite, ScopeUsersRead, ScopeTransactionsRead, ScopeWebhooksManage,
+}
+
+type Partner struct {
+	gorm.Model
+	Name         string          `gorm:"not null" json:"name"`
+	ContactEmail string          `json:"contact_email"`
+	Status       PartnerStatus   `gorm:"default:Active" json:"status"`
+	TenantID     *uint           `gorm:"index" json:"tenant_id,omitempty"`
+	APIKeys      []PartnerAPIKey `gorm:"foreignKey:PartnerID" json:"api_keys,omitempty"`
+}
+
+// PartnerAPIKey never stores the signing secret in the clear. The secret is
+// random, shown once, and kept sealed under PARTNER_KEY_SECRET so signatures
+// can be verified. Its SHA-256 hash detects a tampered row or a rotated
+// master secret.
+type PartnerAPIKey struct {
+	gorm.Model
+	PartnerID    uint       `gorm:"index" json:"partner_id"`
+	Partner      Partner    `gorm:"foreignKey:PartnerID" json:"-"`
+	Name         string     `json:"name"`
+	KeyID        string     `gorm:"uniqueIndex;not null" json:"key_id"`
+	SealedSecret string     `json:"-"`
+	SecretHash   string     `json:"-"`
+	Scopes       string     `json:"scopes"`
+	AllowedIPs   string     `json:"allowed_ips"`
+	LastUsedAt   *time.Time `json:"last_used_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	RotatedToID  *uint      `json:"rotated_to_id"`
+}
+
+// PartnerRequestNonce remembers a signed partner request until its timestamp
+// leaves the tolerance window, the unique index rejects a replay on every
+// instance
+type PartnerRequestNonce struct {
+	ID        uint      `gorm:"primarykey"`
+	KeyID     string    `gorm:"uniqueIndex:idx_partner_nonce;not null"`
+	Signature string    `gorm:"uniqueIndex:idx_partner_nonce;not null"`
+	ExpiresAt time.Time `gorm:"index"`
+}
+
+func (p *Partner) CreatePartner() error {
+	return db.Create(p).Error
+}
+
+func (p *Partner) UpdatePartner() error {
+	return db.Save(p).Error
+}
+
+func (p *Partner) IsActive() bool {
+	return p.Status == PartnerActive
+}
+
+func GetPartnerByID(id uint) (*Partner, error) {
+	var partner Partner
+	err := db.Preload("APIKeys").First(&partner, id).Error
+	return &partner, err
+}
+
+func GetPartners() ([]Partner, error) {
+	var partners []Partner
+	err := db.Find(&partners).Error
+	return partners, err
+}
+
+// ValidateScopes makes sure every requested scope is known
+func ValidateScopes(scopes []string) error {
+	for _, scope := range scopes {
+		if !slices.Contains(AllPartnerScopes, scope) {
+			return fmt.Errorf("unknown scope: %s", scope)
+		}
+	}
+	return nil
+}
+
+// ValidateAllowedIPs accepts plain IPs and CIDR ranges
+func ValidateAllowedIPs(ips []string) error {
+	for _, ip := range ips {
+		if strings.Contains(ip, "/") {
+			if _, _, err := net.ParseCIDR(ip); err != nil {
+				return fmt.Errorf("invalid CIDR range: %s", ip)
+			}
+			continue
+		}
+		if net.ParseIP(ip) == nil {
+			return fmt.Errorf("invalid IP address: %s", ip)
+		}
+	}
+	return nil
+}
+
+// newAPISecret is a random signing secret for a partner key
+func newAPISecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "sk_" + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func partnerKeyCipher() (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte(state.AppConfig.PartnerKeySecret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealAPISecret encrypts the secret with AES-GCM, the nonce leads the
+// ciphertext
+func sealAPISecret(secret string) (string, error) {
+	aead, err := partnerKeyCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(secret), nil)), nil
+}
+
+func openAPISecret(sealed string) (string, error) {
+	aead, err := partnerKeyCipher()
+	if err != nil {
+		return "", err
+	}
+	raw, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(raw) < aead.NonceSize() {
+		return "", errors.New("sealed secret is malformed")
+	}
+	secret, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], nil)
+	return string(secret), err
+}
+
+func hashAPISecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewPartnerAPIKey creates a key for the partner and returns the signing
+// secret, which is only ever shown once
+func NewPartnerAPIKey(partnerID uint, name string, scopes, allowedIPs []string) (*PartnerAPIKey, string, error) {
+	if err := ValidateScopes(scopes); err != nil {
+		return nil, "", err
+	}
+	if err := ValidateAllowedIPs(allowedIPs); err != nil {
+		return nil, "", err
+	}
+
+	id, err := randString(24)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := newAPISecret()
+	if err != nil {
+		return nil, "", err
+	}
+	sealed, err := sealAPISecret(secret)
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := &PartnerAPIKey{
+		PartnerID:    partnerID,
+		Name:         name,
+		KeyID:        "pk_" + id,
+		SealedSecret: sealed,
+		SecretHash:   hashAPISecret(secret),
+		Scopes:       strings.Join(scopes, ","),
+		AllowedIPs:   strings.Join(allowedIPs, ","),
+	}
+
+	if err := db.Create(key).Error; err != nil {
+		return nil, "", err
+	}
+	return key, secret, nil
+}
+
+func GetPartnerAPIKey(partnerID, id uint) (*PartnerAPIKey, error) {
+	var key PartnerAPIKey
+	err := db.Where("partner_id = ?", partnerID).First(&key, id).Error
+	return &key, err
+}
+
+// GetActivePartnerAPIKey resolves a key ID sent by a partner, together with
+// its partner
+func GetActivePartnerAPIKey(keyID string) (*PartnerAPIKey, error) {
+	var key PartnerAPIKey
+	if err := db.Preload("Partner").Where("key_id = ?", keyID).First(&key).Error; err != nil {
+		return nil, errors.New("unknown API key")
+	}
+	if key.RevokedAt != nil {
+		return nil, errors.New("API key has been revoked")
+	}
+	if !key.Partner.IsActive() {
+		return nil, errors.New("partner is not active")
+	}
+	return &key, nil
+}
+
+// Secret unseals the signing secret and checks it against the stored hash
+func (k *PartnerAPIKey) Secret() (string, error) {
+	secret, err := openAPISecret(k.SealedSecret)
+	if err != nil {
+		return "", errors.New("API key secret cannot be unsealed")
+	}
+	if subtle.ConstantTimeCompare([]byte(hashAPISecret(secret)), []byte(k.SecretHash)) != 1 {
+		return "", errors.New("API key secret mismatch")
+	}
+	return secret, nil
+}
+
+func (k *PartnerAPIKey) ScopeList() []string {
+	if k.Scopes == "" {
+		return nil
+	}
+	return strings.Split(k.Scopes, ",")
+}
+
+func (k *PartnerAPIKey) HasScope(scope string) bool {
+	return slices.Contains(k.ScopeList(), scope)
+}
+
+// IPAllowed reports whether ip may use the key, an empty allowlist allows all
+func (k *PartnerAPIKey) IPAllowed(ip string) bool {
+	if k.AllowedIPs == "" {
+		return true
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, allowed := range strings.Split(k.AllowedIPs, ",") {
+		if strings.Contains(allowed, "/") {
+			if _, network, err := net.ParseCIDR(allowed); err == nil && network.Contains(parsed) {
+				return true
+			}
+			continue
+		}
+		if parsed.Equal(net.ParseIP(allowed)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (k *PartnerAPIKey) UpdateAccess(scopes, allowedIPs []string) error {
+	if err := ValidateScopes(scopes); err != nil {
+		return err
+	}
+	if err := ValidateAllowedIPs(allowedIPs); err != nil {
+		return err
+	}
+	k.Scopes = strings.Join(scopes, ",")
+	k.AllowedIPs = strings.Join(allowedIPs, ",")
+	return db.Save(k).Error
+}
+
+func (k *PartnerAPIKey) Revoke() error {
+	if k.RevokedAt != nil {
+		return errors.New("API key already revoked")
+	}
+	now := time.Now()
+	k.RevokedAt = &now
+	return db.Save(k).Error
+}
+
+// Rotate issues a replacement key with the same scopes and allowlist and
+// revokes the current one
+func (k *PartnerAPIKey) Rotate() (*PartnerAPIKey, string, error) {
+	if k.RevokedAt != nil {
+		return nil, "", errors.New("cannot rotate a revoked API key")
+	}
+	newKey, secret, err := NewPartnerAPIKey(k.PartnerID, k.Name, k.ScopeList(), splitList(k.AllowedIPs))
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	k.RevokedAt = &now
+	k.RotatedToID = &newKey.ID
+	if err := db.Save(k).Error; err != nil {
+		return nil, "", err
+	}
+	return newKey, secret, nil
+}
+
+// ClaimPartnerNonce records a signed request and reports false when the same
+// signature was already seen for the key
+func ClaimPartnerNonce(keyID, signature string, expiresAt time.Time) (bool, error) {
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&PartnerRequestNonce{
+		KeyID:     keyID,
+		Signature: signature,
+		ExpiresAt: expiresAt,
+	})
+	return result.RowsAffected == 1, result.Error
+}
+
+// DeleteExpiredPartnerNonces forgets requests whose timestamp can no longer
+// pass the tolerance check
+func DeleteExpiredPartnerNonces() (int64, error) {
+	result := db.Where("expires_at <= ?", time.Now()).Delete(&PartnerRequestNonce{})
+	return result.RowsAffected, result.Error
+}
+
+func (k *PartnerAPIKey) TouchLastUsed() {
+	now := time.Now()
+	k.LastUsedAt = &now
+	db.Model(&PartnerAPIKey{}).Where("id = ?", k.ID).Update("last_used_at", now)
+}
+
+// GetPartnerUser returns the user only if it belongs to the partner
+func GetPartnerUser(partnerID, userID uint) (User, error) {
+	var u User
+	if err := db.Where("id = ? AND partner_id = ?", userID, partnerID).First(&u).Error; err != nil {
+		return u, errors.New("user not found for partner")
+	}
+	u.PrepareGive()
+	return u, nil
+}
+
+func GetPartnerUsers(partnerID uint) ([]User, error) {
+	var users []User
+	err := db.Where("partner_id = ?", partnerID).Find(&users).Error
+	for i := range users {
+		users[i].PrepareGive()
+	}
+	return users, err
+}
+
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// RandomPassword generates a throwaway password that satisfies
+// ValidatePassword, used for sub-users created by partners
+func RandomPassword() (string, error) {
+	s, err := randString(32)
+	if err != nil {
+		return "", err
+	}
+	return "P" + s + "9", nil
+}
+
+// PurgePartnerUser hard deletes a sub-user whose onboarding failed so the
+// partner can retry with the same email
+func PurgePartnerUser(userID uint) error {
+	return db.Unscoped().Where("partner_id IS NOT NULL").Delete(&User{}, userID).Error
+}
@@ -0,0 +1,286 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultListLimit = 20
+	MaxListLimit     = 100
+)
+
+// Errors caused by the list query, callers answer them with a bad request
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidSort   = errors.New("unsupported sort")
+)
+
+// ListQuery is the pagination, sorting and filtering shared by list
+// endpoints. Zero values mean no restriction.
+type ListQuery struct {
+	Cursor    string
+	Limit     int
+	Sort      string // a sort field name, prefixed with "-" for descending
+	From      time.Time
+	To        time.Time
+	MinAmount *float64
+	MaxAmount *float64
+	Statuses  []string
+	Search    string
+}
+
+// Sort field kinds, they decide how cursor values are decoded
+const (
+	sortTime   = "time"
+	sortString = "string"
+	sortNumber = "number"
+)
+
+// SortField is a column a list may be ordered by
+type SortField struct {
+	Expr string // column or SQL expression ordered on
+	Kind string
+	// value reads the field of a row for the cursor
+	value func(row interface{}) interface{}
+}
+
+func timeSort(column string, value func(row interface{}) time.Time) SortField {
+	return SortField{Expr: column, Kind: sortTime, value: func(row interface{}) interface{} { return value(row) }}
+}
+
+func stringSort(column string, value func(row interface{}) string) SortField {
+	return SortField{Expr: column, Kind: sortString, value: func(row interface{}) interface{} { return value(row) }}
+}
+
+// amountSort orders a text amount column numerically
+func amountSort(column string, value func(row interface{}) string) SortField {
+	return SortField{
+		Expr: column,
+		Kind: sortNumber,
+		value: func(row interface{}) interface{} {
+			amount, _ := strconv.ParseFloat(strings.TrimSpace(value(row)), 64)
+			return amount
+		},
+	}
+}
+
+// sql is the expression ordered on, amounts are cast when the query runs as
+// the cast depends on the database
+func (f SortField) sql() string {
+	if f.Kind == sortNumber {
+		return fmt.Sprintf("COALESCE(%s, 0)", toNumber(f.Expr))
+	}
+	return f.Expr
+}
+
+// ListSpec describes how one model is listed
+type ListSpec struct {
+	Sorts         map[string]SortField
+	DefaultSort   string
+	Amount        string // text amount column, empty when amount ranges do not apply
+	SearchColumns []string
+	// id reads the primary key of a row, the cursor tie-breaker
+	id func(row interface{}) uint
+}
+
+// PageInfo is the list envelope metadata
+type PageInfo struct {
+	Total      int64  `json:"total"`
+	Limit      int    `json:"limit"`
+	Sort       string `json:"sort"`
+	NextCursor string `json:"next_cursor"`
+	PrevCursor string `json:"prev_cursor"`
+}
+
+// cursor is the decoded form of the opaque cursor strings
+type cursor struct {
+	Sort   string          `json:"s"`
+	Value  json.RawMessage `json:"v"`
+	ID     uint            `json:"id"`
+	Before bool            `json:"b,omitempty"`
+}
+
+func encodeCursor(c cursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// decodeValue turns the cursor value back into the type the column compares
+// against, times must stay times for SQLite's text timestamps
+func (f SortField) decodeValue(raw json.RawMessage) (interface{}, error) {
+	switch f.Kind {
+	case sortTime:
+		var t time.Time
+		err := json.Unmarshal(raw, &t)
+		return t, err
+	case sortNumber:
+		var n float64
+		err := json.Unmarshal(raw, &n)
+		return n, err
+	default:
+		var s string
+		err := json.Unmarshal(raw, &s)
+		return s, err
+	}
+}
+
+// filter applies the date, amount, status and search filters of the list
+// query, leaving pagination out so the result can also be counted
+func (spec ListSpec) filter(query *gorm.DB, lq ListQuery) *gorm.DB {
+	if !lq.From.IsZero() {
+		query = query.Where("created_at >= ?", lq.From)
+	}
+	if !lq.To.IsZero() {
+		query = query.Where("created_at < ?", lq.To)
+	}
+	if spec.Amount != "" && lq.MinAmount != nil {
+		query = query.Where(toNumber(spec.Amount)+" >= ?", *lq.MinAmount)
+	}
+	if spec.Amount != "" && lq.MaxAmount != nil {
+		query = query.Where(toNumber(spec.Amount)+" <= ?", *lq.MaxAmount)
+	}
+	if len(lq.Statuses) > 0 {
+		statuses := make([]string, len(lq.Statuses))
+		for i, status := range lq.Statuses {
+			statuses[i] = strings.ToLower(status)
+		}
+		query = query.Where("LOWER(status) IN ?", statuses)
+	}
+	if lq.Search != "" && len(spec.SearchColumns) > 0 {
+		conditions := make([]string, len(spec.SearchColumns))
+		args := make([]interface{}, len(spec.SearchColumns))
+		for i, column := range spec.SearchColumns {
+			conditions[i] = fmt.Sprintf("LOWER(%s) LIKE ?", column)
+			args[i] = "%" + strings.ToLower(lq.Search) + "%"
+		}
+		query = query.Where("("+strings.Join(conditions, " OR ")+")", args...)
+	}
+	return query
+}
+
+// paginate counts the filtered query and loads the page the list query's
+// cursor points at
+func paginate[T any](query *gorm.DB, spec ListSpec, lq ListQuery) ([]T, PageInfo, error) {
+	sort := lq.Sort
+	if sort == "" {
+		sort = spec.DefaultSort
+	}
+	field, ok := spec.Sorts[strings.TrimPrefix(sort, "-")]
+	if !ok {
+		return nil, PageInfo{}, fmt.Errorf("%w: %s", ErrInvalidSort, sort)
+	}
+	descending := strings.HasPrefix(sort, "-")
+
+	limit := lq.Limit
+	if limit <= 0 || limit > MaxListLimit {
+		limit = DefaultListLimit
+	}
+	info := PageInfo{Limit: limit, Sort: sort}
+
+	query = spec.filter(query, lq)
+	if err := query.Session(&gorm.Session{}).Count(&info.Total).Error; err != nil {
+		return nil, info, err
+	}
+
+	var after cursor
+	if lq.Cursor != "" {
+		var err error
+		if after, err = decodeCursor(lq.Cursor); err != nil || after.Sort != sort {
+			return nil, info, ErrInvalidCursor
+		}
+		value, err := field.decodeValue(after.Value)
+		if err != nil {
+			return nil, info, ErrInvalidCursor
+		}
+		// walking backwards flips the comparison and the order
+		op := ">"
+		if descending != after.Before {
+			op = "<"
+		}
+		expr := field.sql()
+		query = query.Where(fmt.Sprintf("(%s %s ? OR (%s = ? AND id %s ?))", expr, op, expr, op), value, value, after.ID)
+	}
+
+	direction := "ASC"
+	if descending != after.Before {
+		direction = "DESC"
+	}
+	var rows []T
+	err := query.Order(fmt.Sprintf("%s %s, id %s", field.sql(), direction, direction)).Limit(limit + 1).Find(&rows).Error
+	if err != nil {
+		return nil, info, err
+	}
+
+	more := len(rows) > limit
+	if more {
+		rows = rows[:limit]
+	}
+	if after.Before {
+		slices.Reverse(rows)
+	}
+	if len(rows) == 0 {
+		return rows, info, nil
+	}
+
+	cursorAt := func(row T, before bool) string {
+		value, _ := json.Marshal(field.value(row))
+		return encodeCursor(cursor{Sort: sort, Value: value, ID: spec.id(row), Before: before})
+	}
+	// a page reached going forwards has rows behind it, and the other way round
+	if (more && !after.Before) || (lq.Cursor != "" && after.Before) {
+		info.NextCursor = cursorAt(rows[len(rows)-1], false)
+	}
+	if (more && after.Before) || (lq.Cursor != "" && !after.Before) {
+		info.PrevCursor = cursorAt(rows[0], true)
+	}
+	return rows, info, nil
+}
+
+// UserSummary is the part of a user list endpoints show next to a record
+type UserSummary struct {
+	ID        uint   `json:"id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+}
+
+func (UserSummary) TableName() string {
+	return "users"
+}
+
+// userSummaries loads the summaries of the given users keyed by ID
+func userSummaries(ids []uint) (map[uint]*UserSummary, error) {
+	summaries := make(map[uint]*UserSummary, len(ids))
+	if len(ids) == 0 {
+		return summaries, nil
+	}
+	var users []*UserSummary
+	if err := db.Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		summaries[user.ID] = user
+	}
+	return summaries, nil
+}
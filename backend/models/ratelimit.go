@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RateLimitCounter counts the hits of a limiter key in a fixed window, kept
+// in the database so every instance shares the count
+type RateLimitCounter struct {
+	ID        uint      `gorm:"primarykey"`
+	Key       string    `gorm:"column:limit_key;size:255;uniqueIndex;not null"`
+	Count     int       `gorm:"not null;default:0"`
+	ExpiresAt time.Time `gorm:"index"`
+}
+
+// HitRateLimit counts a hit against key and returns the count in the current
+// window and when the window ends. A window that ended starts over. The row
+// is locked while counting so concurrent hits are all counted.
+func HitRateLimit(key string, window time.Duration) (int, time.Time, error) {
+	var counter RateLimitCounter
+	err := db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&RateLimitCounter{
+			Key:       key,
+			ExpiresAt: now.Add(window),
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("limit_key = ?", key).First(&counter).Error; err != nil {
+			return err
+		}
+
+		if !counter.ExpiresAt.After(now) {
+			counter.Count = 0
+			counter.ExpiresAt = now.Add(window)
+		}
+		counter.Count++
+		return tx.Model(&RateLimitCounter{}).Where("id = ?", counter.ID).Updates(map[string]interface{}{
+			"count":      counter.Count,
+			"expires_at": counter.ExpiresAt,
+		}).Error
+	})
+	return counter.Count, counter.ExpiresAt, err
+}
+
+// ResetRateLimit clears the counter of key
+func ResetRateLimit(key string) error {
+	return db.Where("limit_key = ?", key).Delete(&RateLimitCounter{}).Error
+}
+
+// DeleteExpiredRateLimitCounters forgets counters whose window ended
+func DeleteExpiredRateLimitCounters() (int64, error) {
+	result := db.Where("expires_at <= ?", time.Now()).Delete(&RateLimitCounter{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,324 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Reconciliation sources, each compares one kind of local row with the
+// provider or chain that settled it
+const (
+	ReconcileBorderless = "borderless"
+	ReconcileHurupay    = "hurupay"
+	ReconcileChain      = "chain"
+)
+
+var ReconciliationSources = []string{ReconcileBorderless, ReconcileHurupay, ReconcileChain}
+
+// How a local row compares with the remote record
+const (
+	ReconMatched        = "matched"
+	ReconMissingLocal   = "missing_local"
+	ReconMissingRemote  = "missing_remote"
+	ReconAmountMismatch = "amount_mismatch"
+)
+
+type ReconciliationRunStatus string
+
+const (
+	ReconciliationRunning   ReconciliationRunStatus = "Running"
+	ReconciliationCompleted ReconciliationRunStatus = "Completed"
+	ReconciliationFailed    ReconciliationRunStatus = "Failed"
+)
+
+// Item statuses, only breaks start open
+const (
+	ReconItemOpen     = "open"
+	ReconItemResolved = "resolved"
+)
+
+// Resolutions an admin may close a break with
+const (
+	ResolutionCorrected     = "corrected"      // the local row was fixed
+	ResolutionAccepted      = "accepted"       // the difference is expected, e.g. provider fees
+	ResolutionFalsePositive = "false_positive" // the records do match
+)
+
+var ErrAlreadyResolved = errors.New("reconciliation item already resolved")
+
+// ReconciliationRun is one pass of a source over created_at in [From, To)
+type ReconciliationRun struct {
+	gorm.Model
+	Source         string                  `gorm:"index" json:"source"`
+	From           time.Time               `json:"from"`
+	To             time.Time               `json:"to"`
+	Status         ReconciliationRunStatus `gorm:"default:Running" json:"status"`
+	RequestedByID  *uint                   `json:"requested_by_id"` // nil for scheduled runs
+	Matched        int                     `json:"matched"`
+	MissingLocal   int                     `json:"missing_local"`
+	MissingRemote  int                     `json:"missing_remote"`
+	AmountMismatch int                     `json:"amount_mismatch"`
+	Unchecked      int                     `json:"unchecked"` // rows the remote could not be asked about
+	LastError      string                  `json:"last_error,omitempty"`
+	CompletedAt    *time.Time              `json:"completed_at"`
+}
+
+// ReconciliationItem is the outcome for one record of a run, breaks stay
+// open until an admin resolves them
+type ReconciliationItem struct {
+	gorm.Model
+	RunID          uint       `gorm:"index" json:"run_id"`
+	Source         string     `gorm:"index" json:"source"`
+	Classification string     `gorm:"index" json:"classification"`
+	Status         string     `gorm:"index" json:"status"`
+	Reference      string     `gorm:"index" json:"reference"`
+	LocalTable     string     `json:"local_table,omitempty"`
+	LocalID        *uint      `json:"local_id"`
+	UserID         *uint      `gorm:"index" json:"user_id"`
+	LocalAmount    string     `json:"local_amount"`
+	RemoteAmount   string     `json:"remote_amount"`
+	LocalStatus    string     `json:"local_status"`
+	RemoteStatus   string     `json:"remote_status"`
+	Detail         string     `json:"detail,omitempty"`
+	Resolution     string     `json:"resolution,omitempty"`
+	ResolutionNote string     `json:"resolution_note,omitempty"`
+	ResolvedByID   *uint      `json:"resolved_by_id"`
+	ResolvedAt     *time.Time `json:"resolved_at"`
+}
+
+func (r *ReconciliationRun) CreateReconciliationRun() error {
+	r.Status = ReconciliationRunning
+	return db.Create(r).Error
+}
+
+// AddItems stores the outcomes of the run in one transaction and counts
+// them, a run that fails before it gets here leaves no items behind
+func (r *ReconciliationRun) AddItems(items []*ReconciliationItem) error {
+	counted := *r
+	for _, item := range items {
+		item.RunID = r.ID
+		item.Source = r.Source
+		item.Status = ReconItemOpen
+		switch item.Classification {
+		case ReconMatched:
+			item.Status = ReconItemResolved
+			counted.Matched++
+		case ReconMissingLocal:
+			counted.MissingLocal++
+		case ReconMissingRemote:
+			counted.MissingRemote++
+		case ReconAmountMismatch:
+			counted.AmountMismatch++
+		}
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, item := range items {
+			if err := tx.Create(item).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	r.Matched, r.MissingLocal = counted.Matched, counted.MissingLocal
+	r.MissingRemote, r.AmountMismatch = counted.MissingRemote, counted.AmountMismatch
+	return nil
+}
+
+func (r *ReconciliationRun) Finish(runErr error) error {
+	now := time.Now()
+	r.CompletedAt = &now
+	r.Status = ReconciliationCompleted
+	if runErr != nil {
+		r.Status = ReconciliationFailed
+		r.LastError = runErr.Error()
+	}
+	return db.Save(r).Error
+}
+
+func GetReconciliationRun(id uint) (*ReconciliationRun, error) {
+	var run ReconciliationRun
+	err := db.First(&run, id).Error
+	return &run, err
+}
+
+func GetReconciliationRuns(source string, limit int) ([]ReconciliationRun, error) {
+	var runs []ReconciliationRun
+	query := db.Order("id desc").Limit(limit)
+	if source != "" {
+		query = query.Where("source = ?", source)
+	}
+	err := query.Find(&runs).Error
+	return runs, err
+}
+
+// LastReconciledUntil is the end of the latest successful run of the source,
+// zero when it never ran
+func LastReconciledUntil(source string) (time.Time, error) {
+	var run ReconciliationRun
+	err := db.Where("source = ? AND status = ? AND requested_by_id IS NULL", source, ReconciliationCompleted).
+		Order("\"to\" desc").First(&run).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return time.Time{}, nil
+	}
+	return run.To, err
+}
+
+func GetReconciliationItem(id uint) (*ReconciliationItem, error) {
+	var item ReconciliationItem
+	err := db.First(&item, id).Error
+	return &item, err
+}
+
+// Resolve closes a break with one of the Resolution* values
+func (i *ReconciliationItem) Resolve(adminID uint, resolution, note string) error {
+	if i.Status == ReconItemResolved {
+		return ErrAlreadyResolved
+	}
+	now := time.Now()
+	i.Status = ReconItemResolved
+	i.Resolution = resolution
+	i.ResolutionNote = note
+	i.ResolvedByID = &adminID
+	i.ResolvedAt = &now
+	return db.Save(i).Error
+}
+
+var reconciliationItemListSpec = ListSpec{
+	Sorts: map[string]SortField{
+		"created_at": timeSort("created_at", func(row interface{}) time.Time { return row.(ReconciliationItem).CreatedAt }),
+		"updated_at": timeSort("updated_at", func(row interface{}) time.Time { return row.(ReconciliationItem).UpdatedAt }),
+		"amount":     amountSort("local_amount", func(row interface{}) string { return row.(ReconciliationItem).LocalAmount }),
+	},
+	DefaultSort:   "-created_at",
+	Amount:        "local_amount",
+	SearchColumns: []string{"reference", "detail"},
+	id:            func(row interface{}) uint { return row.(ReconciliationItem).ID },
+}
+
+// FilterReconciliationItems lists the items of a run, or of every run with
+// runID 0, status filters on open and resolved
+func FilterReconciliationItems(runID uint, source, classification string, lq ListQuery) ([]ReconciliationItem, PageInfo, error) {
+	query := db.Model(&ReconciliationItem{})
+	if runID != 0 {
+		query = query.Where("run_id = ?", runID)
+	}
+	if source != "" {
+		query = query.Where("source = ?", source)
+	}
+	if classification != "" {
+		query = query.Where("classification = ?", classification)
+	}
+	return paginate[ReconciliationItem](query, reconciliationItemListSpec, lq)
+}
+
+// ReconciliationRecord is a local row in the shape sources compare
+type ReconciliationRecord struct {
+	Table     string
+	ID        uint
+	UserID    uint
+	Reference string
+	Chain     string
+	Address   string
+	Amount    string
+	Status    string
+	Payout    bool
+}
+
+// GetBorderlessRecords returns the borderless requests created in the
+// window that Borderless knows by ID
+func GetBorderlessRecords(from, to time.Time) ([]ReconciliationRecord, error) {
+	var requests []BorderlessRequest
+	err := db.Where("created_at >= ? AND created_at < ? AND tx_id <> ''", from, to).Order("id").Find(&requests).Error
+	records := make([]ReconciliationRecord, len(requests))
+	for i, r := range requests {
+		records[i] = ReconciliationRecord{
+			Table: "borderless_requests", ID: r.ID, UserID: r.UserId, Reference: r.TxId,
+			Amount: r.FiatAmount, Status: r.Status, Payout: r.PaymentInstructionId != nil,
+		}
+	}
+	return records, err
+}
+
+// GetHurupayRecords returns the mobile money requests created in the window
+// that Hurupay knows by request ID
+func GetHurupayRecords(from, to time.Time) ([]ReconciliationRecord, error) {
+	var requests []HurupayRequest
+	err := db.Where("created_at >= ? AND created_at < ? AND request_id <> ''", from, to).Order("id").Find(&requests).Error
+	records := make([]ReconciliationRecord, len(requests))
+	for i, r := range requests {
+		records[i] = ReconciliationRecord{
+			Table: "hurupay_requests", ID: r.ID, UserID: uint(r.UserId), Reference: r.RequestId,
+			Amount: r.Amount, Status: r.Status, Payout: r.RequestType == OffRamp,
+		}
+	}
+	return records, err
+}
+
+// GetChainRecords returns the wallet transactions created in the window that
+// carry a hash
+func GetChainRecords(from, to time.Time) ([]ReconciliationRecord, error) {
+	var transactions []Transaction
+	err := db.Where("created_at >= ? AND created_at < ? AND hash <> ''", from, to).Order("id").Find(&transactions).Error
+	records := make([]ReconciliationRecord, len(transactions))
+	for i, t := range transactions {
+		records[i] = ReconciliationRecord{
+			Table: "transactions", ID: t.ID, UserID: t.UserID, Reference: t.Hash, Chain: t.Chain,
+			Address: t.Address, Amount: t.Amount, Status: t.Status,
+			Payout: strings.EqualFold(t.TransactionSubType, "Withdrawal"),
+		}
+	}
+	return records, err
+}
+
+// ChainWallet is a user's on-chain address, scanned for transfers we never
+// recorded
+type ChainWallet struct {
+	UserID  uint
+	Chain   string
+	Address string
+}
+
+func GetChainWallets(chain string) ([]ChainWallet, error) {
+	var wallets []ChainWallet
+	err := db.Model(&User{}).Select("id AS user_id, crypto_currency AS chain, account_address AS address").
+		Where("UPPER(crypto_currency) = ? AND account_address <> ''", chain).Scan(&wallets).Error
+	return wallets, err
+}
+
+// KnownTransactionHashes returns which of the hashes have a local transaction
+func KnownTransactionHashes(hashes []string) (map[string]bool, error) {
+	return knownReferences(&Transaction{}, "hash", hashes)
+}
+
+// KnownBorderlessTxIDs returns which of the Borderless transaction IDs have
+// a local request
+func KnownBorderlessTxIDs(ids []string) (map[string]bool, error) {
+	return knownReferences(&BorderlessRequest{}, "tx_id", ids)
+}
+
+// KnownHurupayRequestIDs returns which of the Hurupay request IDs have a
+// local request
+func KnownHurupayRequestIDs(ids []string) (map[string]bool, error) {
+	return knownReferences(&HurupayRequest{}, "request_id", ids)
+}
+
+func knownReferences(model interface{}, column string, references []string) (map[string]bool, error) {
+	known := make(map[string]bool, len(references))
+	if len(references) == 0 {
+		return known, nil
+	}
+	var found []string
+	if err := db.Model(model).Where(column+" IN ?", references).Pluck(column, &found).Error; err != nil {
+		return nil, err
+	}
+	for _, reference := range found {
+		known[reference] = true
+	}
+	return known, nil
+}
@@ -0,0 +1,321 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Report dimensions volumes can be grouped by
+const (
+	ReportByDay       = "day"
+	ReportByWeek      = "week"
+	ReportByMonth     = "month"
+	ReportByRail      = "rail"
+	ReportByDirection = "direction"
+	ReportByChain     = "chain"
+	ReportByAsset     = "asset"
+	ReportByCountry   = "country"
+	ReportByStatus    = "status"
+)
+
+var ReportDimensions = []string{
+	ReportByDay, ReportByWeek, ReportByMonth, ReportByRail, ReportByDirection,
+	ReportByChain, ReportByAsset, ReportByCountry, ReportByStatus,
+}
+
+// RailOnchain covers plain crypto transfers, it is a reporting rail only
+const RailOnchain = "onchain"
+
+// Outcomes request statuses are folded into, providers and handlers spell
+// statuses differently so reports never compare them verbatim
+const (
+	OutcomeCompleted = "completed"
+	OutcomeFailed    = "failed"
+	OutcomePending   = "pending"
+)
+
+var (
+	completedStatuses = []string{"completed", "approved", "success", "successful", "confirmed"}
+	failedStatuses    = []string{"rejected", "cancelled", "canceled", "declined", "failed", "expired"}
+)
+
+// ReportFilter narrows every report, zero values mean no restriction
+type ReportFilter struct {
+	TenantID  uint
+	From      time.Time
+	To        time.Time
+	Rail      string
+	Direction string // on_ramp and off_ramp, or incoming and outgoing on chain
+}
+
+type VolumeRow struct {
+	Key      string  `json:"key"`
+	Currency string  `json:"currency"`
+	Count    int64   `json:"count"`
+	Volume   float64 `json:"volume"`
+}
+
+type FunnelRow struct {
+	Rail           string  `json:"rail"`
+	Direction      string  `json:"direction"`
+	Created        int64   `json:"created"`
+	Pending        int64   `json:"pending"`
+	Completed      int64   `json:"completed"`
+	Failed         int64   `json:"failed"`
+	ConversionRate float64 `json:"conversion_rate"`
+}
+
+type SettlementRow struct {
+	Rail              string  `json:"rail"`
+	Direction         string  `json:"direction"`
+	Settled           int64   `json:"settled"`
+	AverageSeconds    float64 `json:"average_seconds"`
+	AverageSettlement string  `json:"average_settlement"`
+}
+
+type FeeRow struct {
+	Rail     string  `json:"rail"`
+	Currency string  `json:"currency"`
+	Count    int64   `json:"count"`
+	Revenue  float64 `json:"revenue"`
+}
+
+// reportSource maps one request table onto the common report columns, every
+// field is a SQL expression over that table
+type reportSource struct {
+	table       string
+	rail        string
+	direction   string
+	amount      string
+	currency    string
+	chain       string
+	asset       string
+	country     string
+	fee         string
+	feeCurrency string
+	where       string
+}
+
+// userCountry falls back to the owner's country for tables without one
+func userCountry(table string) string {
+	return fmt.Sprintf("(SELECT users.country_code FROM users WHERE users.id = %s.user_id)", table)
+}
+
+var reportSources = []reportSource{
+	{
+		table: "deposit_requests", rail: "'" + RailBank + "'", direction: "'on_ramp'",
+		amount: "fiat_amount", currency: "currency", chain: "''", asset: "proposed_asset",
+		country: "country_code", fee: "NULL", feeCurrency: "''",
+	},
+	{
+		table: "withdrawal_requests", rail: "'" + RailBank + "'", direction: "'off_ramp'",
+		amount: "equivalent_fiat", currency: "fiat_currency", chain: "chain", asset: "asset",
+		country: userCountry("withdrawal_requests"), fee: "NULL", feeCurrency: "''",
+	},
+	{
+		table: "hurupay_requests", rail: "'" + RailMobileMoney + "'",
+		direction: fmt.Sprintf("CASE WHEN request_type = '%s' THEN 'on_ramp' ELSE 'off_ramp' END", OnRamp),
+		amount:    "amount", currency: "country_currency", chain: "crypto_chain", asset: "token",
+		country: "COALESCE(NULLIF(country_code, ''), country_currency)",
+		fee:     "developer_fee", feeCurrency: "token",
+	},
+	{
+		table: "borderless_requests", rail: "'" + RailBorderless + "'",
+		direction: "CASE WHEN payment_instruction_id IS NULL THEN 'on_ramp' ELSE 'off_ramp' END",
+		amount:    "fiat_amount", currency: "''", chain: "''", asset: "asset",
+		country: "country", fee: "fee_amount", feeCurrency: "asset",
+	},
+	{
+		table: "transactions", rail: "'" + RailOnchain + "'",
+		direction: "CASE WHEN LOWER(transaction_sub_type) = 'deposit' THEN 'incoming' ELSE 'outgoing' END",
+		amount:    "amount", currency: "asset", chain: "chain", asset: "asset",
+		country: userCountry("transactions"), fee: "NULL", feeCurrency: "''",
+		where: standaloneTransfers,
+	},
+}
+
+// toNumber casts a text amount, blanks and amounts that are not a plain
+// decimal count as NULL so sums skip them instead of failing the query
+func toNumber(expr string) string {
+	if expr == "NULL" {
+		return expr
+	}
+	if isPostgres() {
+		return fmt.Sprintf(`CASE WHEN TRIM(%s) ~ '^-{0,1}([0-9]+(\.[0-9]*){0,1}|\.[0-9]+)$' THEN CAST(TRIM(%s) AS DECIMAL) END`, expr, expr)
+	}
+	return fmt.Sprintf("CASE WHEN TRIM(%s) <> '' AND TRIM(%s) NOT GLOB '*[^0-9.-]*' THEN CAST(TRIM(%s) AS DECIMAL) END", expr, expr, expr)
+}
+
+// standaloneTransfers leaves out the transactions that are the crypto leg of
+// a ramp request, the request already counts them. Mobile money and
+// Borderless legs carry the request reference, bank withdrawals and
+// Borderless payouts share the transfer hash with their request.
+const standaloneTransfers = `COALESCE(request_id, '') = '' AND COALESCE(hash, '') NOT IN (
+	SELECT hash FROM withdrawal_requests WHERE COALESCE(hash, '') <> ''
+	UNION SELECT tx_hash FROM borderless_requests WHERE COALESCE(tx_hash, '') <> '')`
+
+func outcomeExpr() string {
+	quote := func(values []string) string {
+		return "'" + strings.Join(values, "', '") + "'"
+	}
+	return fmt.Sprintf("CASE WHEN LOWER(status) IN (%s) THEN '%s' WHEN LOWER(status) IN (%s) THEN '%s' ELSE '%s' END",
+		quote(completedStatuses), OutcomeCompleted, quote(failedStatuses), OutcomeFailed, OutcomePending)
+}
+
+// reportRows is a UNION ALL of every request table in the common shape,
+// filtered by the report filter
+func reportRows(filter ReportFilter) (string, []interface{}) {
+	parts := make([]string, 0, len(reportSources))
+	for _, s := range reportSources {
+		part := fmt.Sprintf(`SELECT %s AS rail, %s AS direction, %s AS amount,
+	COALESCE(%s, '') AS currency, COALESCE(%s, '') AS chain, COALESCE(%s, '') AS asset, COALESCE(%s, '') AS country,
+	LOWER(COALESCE(status, '')) AS status, %s AS outcome, %s AS fee, COALESCE(%s, '') AS fee_currency,
+	user_id, created_at, updated_at
+	FROM %s WHERE deleted_at IS NULL`,
+			s.rail, s.direction, toNumber(s.amount), s.currency, s.chain, s.asset, s.country,
+			outcomeExpr(), toNumber(s.fee), s.feeCurrency, s.table)
+		if s.where != "" {
+			part += " AND " + s.where
+		}
+		parts = append(parts, part)
+	}
+
+	var where []string
+	var args []interface{}
+	if filter.TenantID != 0 {
+		where = append(where, "user_id IN (SELECT id FROM users WHERE tenant_id = ?)")
+		args = append(args, filter.TenantID)
+	}
+	if !filter.From.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, filter.From)
+	}
+	if !filter.To.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, filter.To)
+	}
+	if filter.Rail != "" {
+		where = append(where, "rail = ?")
+		args = append(args, filter.Rail)
+	}
+	if filter.Direction != "" {
+		where = append(where, "direction = ?")
+		args = append(args, filter.Direction)
+	}
+
+	query := "SELECT * FROM (" + strings.Join(parts, "\nUNION ALL\n") + ") report_rows"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	return query, args
+}
+
+func isPostgres() bool {
+	return db.Dialector.Name() == "postgres"
+}
+
+// periodExpr buckets created_at as an ISO date string, weeks start on Monday
+func periodExpr(period string) string {
+	if isPostgres() {
+		switch period {
+		case ReportByWeek:
+			return "to_char(date_trunc('week', created_at), 'YYYY-MM-DD')"
+		case ReportByMonth:
+			return "to_char(created_at, 'YYYY-MM')"
+		default:
+			return "to_char(created_at, 'YYYY-MM-DD')"
+		}
+	}
+	switch period {
+	case ReportByWeek:
+		return "date(created_at, 'weekday 0', '-6 days')"
+	case ReportByMonth:
+		return "strftime('%Y-%m', created_at)"
+	default:
+		return "strftime('%Y-%m-%d', created_at)"
+	}
+}
+
+// secondsExpr is the number of seconds between two timestamps
+func secondsExpr(from, to string) string {
+	if isPostgres() {
+		return fmt.Sprintf("EXTRACT(EPOCH FROM (%s - %s))", to, from)
+	}
+	return fmt.Sprintf("(julianday(%s) - julianday(%s)) * 86400", to, from)
+}
+
+// ReportVolume counts requests and sums their amounts per currency, grouped
+// by one of ReportDimensions
+func ReportVolume(filter ReportFilter, groupBy string) ([]VolumeRow, error) {
+	var key string
+	switch groupBy {
+	case ReportByDay, ReportByWeek, ReportByMonth:
+		key = periodExpr(groupBy)
+	case ReportByRail, ReportByDirection, ReportByChain, ReportByAsset, ReportByCountry, ReportByStatus:
+		key = groupBy
+	default:
+		return nil, errors.New("unknown report dimension: " + groupBy)
+	}
+
+	rows, args := reportRows(filter)
+	query := fmt.Sprintf(`SELECT %s AS key, currency, COUNT(*) AS count, COALESCE(SUM(amount), 0) AS volume
+FROM (%s) r GROUP BY %s, currency ORDER BY 1, 2`, key, rows, key)
+
+	var result []VolumeRow
+	err := db.Raw(query, args...).Scan(&result).Error
+	return result, err
+}
+
+// ReportFunnel counts how many created requests ended up pending, completed
+// or failed, per rail and direction
+func ReportFunnel(filter ReportFilter) ([]FunnelRow, error) {
+	rows, args := reportRows(filter)
+	query := fmt.Sprintf(`SELECT rail, direction, COUNT(*) AS created,
+	SUM(CASE WHEN outcome = '%s' THEN 1 ELSE 0 END) AS pending,
+	SUM(CASE WHEN outcome = '%s' THEN 1 ELSE 0 END) AS completed,
+	SUM(CASE WHEN outcome = '%s' THEN 1 ELSE 0 END) AS failed
+FROM (%s) r GROUP BY rail, direction ORDER BY 1, 2`, OutcomePending, OutcomeCompleted, OutcomeFailed, rows)
+
+	var result []FunnelRow
+	if err := db.Raw(query, args...).Scan(&result).Error; err != nil {
+		return nil, err
+	}
+	for i := range result {
+		if result[i].Created > 0 {
+			result[i].ConversionRate = float64(result[i].Completed) / float64(result[i].Created)
+		}
+	}
+	return result, nil
+}
+
+// ReportSettlement averages the time from creation to the last update of
+// completed requests, per rail and direction
+func ReportSettlement(filter ReportFilter) ([]SettlementRow, error) {
+	rows, args := reportRows(filter)
+	query := fmt.Sprintf(`SELECT rail, direction, COUNT(*) AS settled, AVG(%s) AS average_seconds
+FROM (%s) r WHERE outcome = '%s' GROUP BY rail, direction ORDER BY 1, 2`,
+		secondsExpr("created_at", "updated_at"), rows, OutcomeCompleted)
+
+	var result []SettlementRow
+	if err := db.Raw(query, args...).Scan(&result).Error; err != nil {
+		return nil, err
+	}
+	for i := range result {
+		result[i].AverageSettlement = (time.Duration(result[i].AverageSeconds) * time.Second).String()
+	}
+	return result, nil
+}
+
+// ReportFees sums the fees charged on completed requests per rail and fee
+// currency
+func ReportFees(filter ReportFilter) ([]FeeRow, error) {
+	rows, args := reportRows(filter)
+	query := fmt.Sprintf(`SELECT rail, fee_currency AS currency, COUNT(*) AS count, COALESCE(SUM(fee), 0) AS revenue
+FROM (%s) r WHERE outcome = '%s' AND fee IS NOT NULL GROUP BY rail, fee_currency ORDER BY 1, 2`, rows, OutcomeCompleted)
+
+	var result []FeeRow
+	err := db.Raw(query, args...).Scan(&result).Error
+	return result, err
+}
@@ -0,0 +1,222 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Watchlists screened against
+const (
+	WatchlistOFAC = "ofac" // OFAC Specially Designated Nationals
+	WatchlistUN   = "un"   // UN Security Council consolidated list
+	WatchlistEU   = "eu"   // EU consolidated financial sanctions
+	WatchlistPEP  = "pep"  // politically exposed persons
+)
+
+var Watchlists = []string{WatchlistOFAC, WatchlistUN, WatchlistEU, WatchlistPEP}
+
+// Watchlist records the file a list was last loaded from
+type Watchlist struct {
+	gorm.Model
+	Name     string    `gorm:"uniqueIndex" json:"name"`
+	File     string    `json:"file"`
+	SHA256   string    `json:"sha256"`
+	Entries  int       `json:"entries"`
+	LoadedAt time.Time `json:"loaded_at"`
+}
+
+// WatchlistEntry is a person or entity on a list, names and dates are kept
+// as the list gives them and normalised when matched
+type WatchlistEntry struct {
+	ID           uint   `gorm:"primarykey" json:"id"`
+	List         string `gorm:"index" json:"list"`
+	Reference    string `json:"reference"` // the entry's id on the list
+	Kind         string `json:"kind"`      // individual, entity, vessel...
+	Name         string `json:"name"`
+	Aliases      string `json:"aliases"`        // separated by "|"
+	DatesOfBirth string `json:"dates_of_birth"` // YYYY or YYYY-MM-DD, separated by "|"
+	Programs     string `json:"programs"`
+}
+
+// Screening subjects
+const (
+	ScreeningSubjectUser        = "user"
+	ScreeningSubjectBeneficiary = "beneficiary"
+)
+
+// Where a screening ran
+const (
+	ScreeningKYCSubmission = "kyc_submission"
+	ScreeningKYCApproval   = "kyc_approval"
+	ScreeningPayout        = "payout"
+)
+
+// Screening statuses, review blocks the flow until an admin clears or
+// confirms the hits
+const (
+	ScreeningClear     = "clear"
+	ScreeningReview    = "review"
+	ScreeningCleared   = "cleared"   // the hits were false positives
+	ScreeningConfirmed = "confirmed" // a hit is the subject, the flow stays blocked
+)
+
+var ErrScreeningResolved = errors.New("screening already resolved")
+
+// Screening is one check of a name against the watchlists
+type Screening struct {
+	gorm.Model
+	UserID  uint   `gorm:"index" json:"user_id"`
+	Subject string `json:"subject"`
+	// SubjectKey identifies who was screened across screenings, cleared hits
+	// are not raised again for the same key
+	SubjectKey   string           `gorm:"index" json:"subject_key"`
+	Context      string           `gorm:"index" json:"context"`
+	Rail         string           `json:"rail,omitempty"`
+	Name         string           `json:"name"`
+	DateOfBirth  string           `json:"date_of_birth,omitempty"`
+	Status       string           `gorm:"index" json:"status"`
+	Matches      []ScreeningMatch `json:"matches,omitempty"`
+	ReviewNote   string           `json:"review_note,omitempty"`
+	ReviewedByID *uint            `json:"reviewed_by_id"`
+	ReviewedAt   *time.Time       `json:"reviewed_at"`
+}
+
+// ScreeningMatch is a watchlist entry a screening hit
+type ScreeningMatch struct {
+	ID               uint      `gorm:"primarykey" json:"id"`
+	ScreeningID      uint      `gorm:"index" json:"screening_id"`
+	List             string    `json:"list"`
+	Reference        string    `json:"reference"`
+	Kind             string    `json:"kind"`
+	MatchedName      string    `json:"matched_name"`
+	Score            float64   `json:"score"`
+	DateOfBirthMatch bool      `json:"date_of_birth_match"`
+	Programs         string    `json:"programs"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// Key identifies the entry across list reloads
+func (m ScreeningMatch) Key() string {
+	return m.List + ":" + m.Reference
+}
+
+// ReplaceWatchlist swaps the entries of the list for those loaded from its
+// file
+func ReplaceWatchlist(list *Watchlist, entries []WatchlistEntry) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("list = ?", list.Name).Delete(&WatchlistEntry{}).Error; err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			if err := tx.CreateInBatches(entries, 500).Error; err != nil {
+				return err
+			}
+		}
+		var existing Watchlist
+		err := tx.Where("name = ?", list.Name).First(&existing).Error
+		if err == nil {
+			list.ID = existing.ID
+			list.CreatedAt = existing.CreatedAt
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		return tx.Save(list).Error
+	})
+}
+
+func GetWatchlists() ([]Watchlist, error) {
+	var lists []Watchlist
+	err := db.Order("name").Find(&lists).Error
+	return lists, err
+}
+
+// GetWatchlist returns the list's last load, an empty one when never loaded
+func GetWatchlist(name string) (*Watchlist, error) {
+	var list Watchlist
+	err := db.Where("name = ?", name).First(&list).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &Watchlist{Name: name}, nil
+	}
+	return &list, err
+}
+
+func GetWatchlistEntries() ([]WatchlistEntry, error) {
+	var entries []WatchlistEntry
+	err := db.Order("id").Find(&entries).Error
+	return entries, err
+}
+
+func (s *Screening) CreateScreening() error {
+	return db.Create(s).Error
+}
+
+func GetScreening(id uint) (*Screening, error) {
+	var screening Screening
+	err := db.Preload("Matches").First(&screening, id).Error
+	return &screening, err
+}
+
+// GetBlockingScreening returns the subject's screening awaiting review or
+// confirmed as a match, nil when nothing blocks it
+func GetBlockingScreening(subjectKey string) (*Screening, error) {
+	var screening Screening
+	err := db.Preload("Matches").
+		Where("subject_key = ? AND status IN ?", subjectKey, []string{ScreeningReview, ScreeningConfirmed}).
+		Order("id desc").First(&screening).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &screening, err
+}
+
+// GetClearedMatches returns the keys of the entries an admin found were not
+// the subject
+func GetClearedMatches(subjectKey string) (map[string]bool, error) {
+	var matches []ScreeningMatch
+	err := db.Joins("JOIN screenings ON screenings.id = screening_matches.screening_id").
+		Where("screenings.subject_key = ? AND screenings.status = ? AND screenings.deleted_at IS NULL", subjectKey, ScreeningCleared).
+		Find(&matches).Error
+	cleared := make(map[string]bool, len(matches))
+	for _, match := range matches {
+		cleared[match.Key()] = true
+	}
+	return cleared, err
+}
+
+// Resolve records the admin's decision on a screening under review
+func (s *Screening) Resolve(adminID uint, decision, note string) error {
+	if s.Status != ScreeningReview {
+		return ErrScreeningResolved
+	}
+	now := time.Now()
+	s.Status = decision
+	s.ReviewNote = note
+	s.ReviewedByID = &adminID
+	s.ReviewedAt = &now
+	return db.Omit("Matches").Save(s).Error
+}
+
+var screeningListSpec = ListSpec{
+	Sorts: map[string]SortField{
+		"created_at": timeSort("created_at", func(row interface{}) time.Time { return row.(Screening).CreatedAt }),
+		"updated_at": timeSort("updated_at", func(row interface{}) time.Time { return row.(Screening).UpdatedAt }),
+	},
+	DefaultSort:   "-created_at",
+	SearchColumns: []string{"name"},
+	id:            func(row interface{}) uint { return row.(Screening).ID },
+}
+
+// FilterScreenings lists screenings with their matches, userID 0 for every
+// user
+func FilterScreenings(userID uint, context string, lq ListQuery) ([]Screening, PageInfo, error) {
+	query := db.Model(&Screening{}).Preload("Matches")
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	if context != "" {
+		query = query.Where("context = ?", context)
+	}
+	return paginate[Screening](query, screeningListSpec, lq)
+}
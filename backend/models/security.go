@@ -0,0 +1,48 @@
+package models
+
+import (
+	"log/slog"
+
+	"gorm.io/gorm"
+)
+
+// Security event types recorded in the security log
+const (
+	EventLoginFailed            = "login_failed"
+	EventLoginSucceeded         = "login_succeeded"
+	EventLoginBlocked           = "login_blocked"
+	EventAccountLocked          = "account_locked"
+	EventAccountUnlocked        = "account_unlocked"
+	EventPasswordResetRequested = "password_reset_requested"
+	EventPasswordReset          = "password_reset"
+	EventStatusChanged          = "status_changed"
+	EventBeneficiaryAdded       = "beneficiary_added"
+	EventBeneficiaryConfirmed   = "beneficiary_confirmed"
+	EventBeneficiaryRemoved     = "beneficiary_removed"
+	EventAllowlistChanged       = "allowlist_changed"
+)
+
+type SecurityEvent struct {
+	gorm.Model
+	UserID    *uint  `gorm:"index" json:"user_id"`
+	Email     string `gorm:"index" json:"email"`
+	Event     string `gorm:"index" json:"event"`
+	IPAddress string `json:"ip_address"`
+	UserAgent string `json:"user_agent"`
+	Detail    string `json:"detail"`
+}
+
+// LogSecurityEvent persists the event, failures are only logged so they never
+// block the request that triggered them
+func LogSecurityEvent(event SecurityEvent) {
+	slog.Warn("security event", "event", event.Event, "email", event.Email, "ip", event.IPAddress, "detail", event.Detail)
+	if err := db.Create(&event).Error; err != nil {
+		slog.Error("security event not stored", "event", event.Event, "error", err)
+	}
+}
+
+func GetSecurityEventsByUserID(userID uint, limit int) ([]SecurityEvent, error) {
+	var events []SecurityEvent
+	err := db.Where("user_id = ?", userID).Order("id DESC").Limit(limit).Find(&events).Error
+	return events, err
+}
@@ -33,10 +33,7 @@ func NewDB(cfg DBConfig) (*gorm.DB, error) {
 	var err error
 
 	if !cfg.UseSQLite { // Changed from GIN_MODE to a config flag
-		dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=%s",
-			cfg.Host, cfg.User, cfg.Password, cfg.DBName, cfg.Port, cfg.TimeZone)
-
-		db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+		db, err = gorm.Open(postgres.Open(cfg.DSN()), &gorm.Config{})
 		if err != nil {
 			return nil, fmt.Errorf("failed to connect to PostgreSQL database: %w", err)
 		}
@@ -52,6 +49,12 @@ func NewDB(cfg DBConfig) (*gorm.DB, error) {
 	return db, nil
 }
 
+// DSN is the PostgreSQL connection string for the config
+func (cfg DBConfig) DSN() string {
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=%s",
+		cfg.Host, cfg.User, cfg.Password, cfg.DBName, cfg.Port, cfg.TimeZone)
+}
+
 // LoadDBConfigFromEnv loads database configuration from environment variables.
 // This function would typically be in a 'config' package or main.
 func LoadDBConfigFromEnv() DBConfig {
@@ -81,6 +84,64 @@ func LoadDBConfigFromEnv() DBConfig {
 	return cfg
 }
 
+// Tables lists a value of every model stored in the database, in migration
+// order
+func Tables() []interface{} {
+	return []interface{}{
+		&User{},
+		&Token{},
+		&Transaction{},
+		&XlmPublic{},
+		&MasterWallet{},
+		&WalletAddress{},
+		&DepositRequest{},
+		&WithdrawalRequest{},
+		&HurupayRequest{},
+		&BorderlessRequest{},
+		&KYC{},
+		&KYCData{},
+		&UserAccounts{},
+		&Bank{},
+		&SecurityEvent{},
+		&Partner{},
+		&PartnerAPIKey{},
+		&PartnerRequestNonce{},
+		&Tenant{},
+		&TenantFeeRule{},
+		&WebhookEndpoint{},
+		&WebhookDelivery{},
+		&WebhookAttempt{},
+		&Notification{},
+		&NotificationPreference{},
+		&NotificationChannelPreference{},
+		&OutboxMail{},
+		&StatementExport{},
+		&ReconciliationRun{},
+		&ReconciliationItem{},
+		&LimitRule{},
+		&LimitOverride{},
+		&LimitReservation{},
+		&KYCLevel{},
+		&KYCDocument{},
+		&KYCDocumentAccess{},
+		&Watchlist{},
+		&WatchlistEntry{},
+		&Screening{},
+		&ScreeningMatch{},
+		&ComplianceCase{},
+		&CaseNote{},
+		&CaseAttachment{},
+		&UserStatusChange{},
+		&AccountClosure{},
+		&MonitoringRule{},
+		&MonitoringAlert{},
+		&MonitoringCursor{},
+		&Beneficiary{},
+		&IdempotencyKey{},
+		&RateLimitCounter{},
+	}
+}
+
 // Migrate performs database migrations for the given models.
 // It takes a list of models to migrate, making it more flexible.
 func Migrate(db *gorm.DB, modelsToMigrate ...interface{}) error {
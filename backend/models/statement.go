@@ -0,0 +1,453 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Statement file formats
+const (
+	StatementCSV  = "csv"
+	StatementXLSX = "xlsx"
+	StatementPDF  = "pdf"
+)
+
+type StatementStatus string
+
+const (
+	StatementPending   StatementStatus = "Pending"
+	StatementCompleted StatementStatus = "Completed"
+	StatementFailed    StatementStatus = "Failed"
+	StatementExpired   StatementStatus = "Expired"
+)
+
+// Export jobs are retried like mails, finished files can be downloaded for
+// StatementRetention
+const (
+	StatementMaxAttempts  = 5
+	StatementBaseBackoff  = time.Minute
+	StatementMaxBackoff   = 30 * time.Minute
+	StatementRetention    = 7 * 24 * time.Hour
+	statementClaimLease   = 10 * time.Minute
+	statementErrorMaxSize = 1000
+)
+
+// StatementExport is a statement file job. A nil UserID exports every user
+// of the tenant, a nil TenantID then means the whole platform.
+type StatementExport struct {
+	gorm.Model
+	RequestedByID uint            `gorm:"index" json:"requested_by_id"`
+	UserID        *uint           `gorm:"index" json:"user_id"`
+	TenantID      *uint           `gorm:"index" json:"tenant_id,omitempty"`
+	Format        string          `json:"format"`
+	From          time.Time       `json:"from"`
+	To            time.Time       `json:"to"`
+	Status        StatementStatus `gorm:"default:Pending;index" json:"status"`
+	Attempts      int             `gorm:"default:0" json:"attempts"`
+	NextAttemptAt time.Time       `gorm:"index" json:"-"`
+	LastError     string          `json:"last_error,omitempty"`
+	FileName      string          `json:"file_name,omitempty"`
+	FilePath      string          `json:"-"`
+	Size          int64           `json:"size,omitempty"`
+	DownloadToken string          `gorm:"index" json:"-"`
+	CompletedAt   *time.Time      `json:"completed_at"`
+	ExpiresAt     *time.Time      `json:"expires_at"`
+}
+
+func (s *StatementExport) CreateStatementExport() error {
+	s.Status = StatementPending
+	s.NextAttemptAt = time.Now()
+	return db.Create(s).Error
+}
+
+func GetStatementExport(id uint) (*StatementExport, error) {
+	var export StatementExport
+	err := db.First(&export, id).Error
+	return &export, err
+}
+
+// GetStatementExportByToken finds an unexpired export by its download token
+func GetStatementExportByToken(token string) (*StatementExport, error) {
+	var export StatementExport
+	err := db.Where("download_token = ? AND status = ? AND expires_at > ?", token, StatementCompleted, time.Now()).
+		First(&export).Error
+	return &export, err
+}
+
+// GetStatementExports lists exports newest first, of one requester or, with
+// requestedBy 0, of the tenant
+func GetStatementExports(requestedBy, tenantID uint, limit int) ([]StatementExport, error) {
+	var exports []StatementExport
+	query := db.Order("id desc").Limit(limit)
+	if requestedBy != 0 {
+		query = query.Where("requested_by_id = ?", requestedBy)
+	} else if tenantID != 0 {
+		query = query.Where("tenant_id = ?", tenantID)
+	}
+	err := query.Find(&exports).Error
+	return exports, err
+}
+
+// GetDueStatementExports returns pending exports whose next attempt is due
+func GetDueStatementExports(limit int) ([]StatementExport, error) {
+	var exports []StatementExport
+	err := db.Where("status = ? AND next_attempt_at <= ?", StatementPending, time.Now()).
+		Order("next_attempt_at asc").Limit(limit).Find(&exports).Error
+	return exports, err
+}
+
+// GetExpiredStatementExports returns completed exports past their retention
+func GetExpiredStatementExports(limit int) ([]StatementExport, error) {
+	var exports []StatementExport
+	err := db.Where("status = ? AND expires_at <= ?", StatementCompleted, time.Now()).
+		Limit(limit).Find(&exports).Error
+	return exports, err
+}
+
+// Claim leases the export to the caller so concurrent workers never build
+// the same file twice
+func (s *StatementExport) Claim() bool {
+	lease := time.Now().Add(statementClaimLease)
+	result := db.Model(&StatementExport{}).
+		Where("id = ? AND status = ? AND next_attempt_at = ?", s.ID, StatementPending, s.NextAttemptAt).
+		Update("next_attempt_at", lease)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false
+	}
+	s.NextAttemptAt = lease
+	return true
+}
+
+func (s *StatementExport) MarkCompleted(fileName, filePath string, size int64) error {
+	token, err := randString(48)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	expires := now.Add(StatementRetention)
+	s.Attempts++
+	s.Status = StatementCompleted
+	s.FileName = fileName
+	s.FilePath = filePath
+	s.Size = size
+	s.DownloadToken = token
+	s.CompletedAt = &now
+	s.ExpiresAt = &expires
+	s.LastError = ""
+	return db.Save(s).Error
+}
+
+// RecordFailure schedules the next attempt or gives up on the export
+func (s *StatementExport) RecordFailure(buildErr error) error {
+	s.Attempts++
+	s.LastError = buildErr.Error()
+	if len(s.LastError) > statementErrorMaxSize {
+		s.LastError = s.LastError[:statementErrorMaxSize]
+	}
+	if s.Attempts >= StatementMaxAttempts {
+		s.Status = StatementFailed
+	} else {
+		s.NextAttemptAt = time.Now().Add(exponentialBackoff(StatementBaseBackoff, StatementMaxBackoff, s.Attempts))
+	}
+	return db.Save(s).Error
+}
+
+func (s *StatementExport) MarkExpired() error {
+	s.Status = StatementExpired
+	s.FilePath = ""
+	s.DownloadToken = ""
+	return db.Save(s).Error
+}
+
+// StatementEntry is one line of a statement. Wallet transactions move the
+// balance, ramp requests are listed alongside with their fiat side and fees.
+type StatementEntry struct {
+	Date        time.Time
+	UserID      uint
+	Email       string
+	Kind        string
+	Reference   string
+	Description string
+	Status      string
+	Asset       string
+	Amount      float64 // negative for money leaving the wallet
+	Fee         float64
+	FeeAsset    string
+	Balance     *float64 // running wallet balance, nil for ramp requests
+}
+
+// StatementBalance is one user's wallet balance in one asset over the period
+type StatementBalance struct {
+	UserID  uint
+	Email   string
+	Asset   string
+	Opening float64
+	Closing float64
+}
+
+// StatementFee totals the fees charged in one asset or currency
+type StatementFee struct {
+	Asset string
+	Total float64
+}
+
+type Statement struct {
+	From      time.Time
+	To        time.Time
+	AllUsers  bool
+	Generated time.Time
+	Entries   []StatementEntry
+	Balances  []StatementBalance
+	Fees      []StatementFee
+}
+
+// Statement entry kinds
+const (
+	EntryTransaction = "transaction"
+	EntryDeposit     = "deposit"
+	EntryWithdrawal  = "withdrawal"
+	EntryMobileMoney = "mobile_money"
+	EntryBorderless  = "borderless"
+)
+
+func parseAmount(amount string) float64 {
+	value, _ := strconv.ParseFloat(strings.TrimSpace(amount), 64)
+	return value
+}
+
+func isCompleted(status string) bool {
+	for _, completed := range completedStatuses {
+		if strings.EqualFold(status, completed) {
+			return true
+		}
+	}
+	return false
+}
+
+// signedAmount is negative for transactions leaving the wallet
+func (t Transaction) signedAmount() float64 {
+	amount := parseAmount(t.Amount)
+	if strings.EqualFold(t.TransactionSubType, "Withdrawal") {
+		return -amount
+	}
+	return amount
+}
+
+// BuildStatement collects the statement of one user, or with a nil userID of
+// every user in the tenant, for transactions created in [from, to)
+func BuildStatement(userID *uint, tenantID uint, from, to time.Time) (*Statement, error) {
+	scope := func(query *gorm.DB) *gorm.DB {
+		if userID != nil {
+			return query.Where("user_id = ?", *userID)
+		}
+		return query.Scopes(ScopeTenantUsers(tenantID))
+	}
+	return buildStatement(scope, userID == nil, from, to)
+}
+
+// BuildUsersStatement is the statement of a batch of users, laid out like
+// one of every user
+func BuildUsersStatement(userIDs []uint, from, to time.Time) (*Statement, error) {
+	scope := func(query *gorm.DB) *gorm.DB {
+		return query.Where("user_id IN ?", userIDs)
+	}
+	return buildStatement(scope, true, from, to)
+}
+
+// EachTenantUserBatch calls fn with the IDs of the tenant's users, of every
+// user with tenantID 0, size at a time in ID order
+func EachTenantUserBatch(tenantID uint, size int, fn func(userIDs []uint) error) error {
+	var users []User
+	query := db.Model(&User{}).Select("id")
+	if tenantID != 0 {
+		query = query.Where("tenant_id = ?", tenantID)
+	}
+	return query.FindInBatches(&users, size, func(tx *gorm.DB, batch int) error {
+		ids := make([]uint, len(users))
+		for i, user := range users {
+			ids[i] = user.ID
+		}
+		return fn(ids)
+	}).Error
+}
+
+func buildStatement(scope func(*gorm.DB) *gorm.DB, allUsers bool, from, to time.Time) (*Statement, error) {
+	statement := &Statement{From: from, To: to, AllUsers: allUsers, Generated: time.Now()}
+
+	type balanceKey struct {
+		userID uint
+		asset  string
+	}
+	balances := map[balanceKey]*StatementBalance{}
+	balanceOf := func(userID uint, asset string) *StatementBalance {
+		key := balanceKey{userID, asset}
+		if balances[key] == nil {
+			balances[key] = &StatementBalance{UserID: userID, Asset: asset}
+		}
+		return balances[key]
+	}
+
+	// opening balances from every completed transaction before the period
+	var earlier []Transaction
+	err := db.Scopes(scope).Select("id", "user_id", "asset", "amount", "status", "transaction_sub_type").
+		Where("created_at < ?", from).FindInBatches(&earlier, 1000, func(tx *gorm.DB, batch int) error {
+		for _, t := range earlier {
+			if isCompleted(t.Status) {
+				balanceOf(t.UserID, t.Asset).Opening += t.signedAmount()
+			}
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, balance := range balances {
+		balance.Closing = balance.Opening
+	}
+
+	var transactions []Transaction
+	if err := db.Scopes(scope).Where("created_at >= ? AND created_at < ?", from, to).
+		Order("created_at asc, id asc").Find(&transactions).Error; err != nil {
+		return nil, err
+	}
+	for _, t := range transactions {
+		entry := StatementEntry{
+			Date:        t.CreatedAt,
+			UserID:      t.UserID,
+			Kind:        EntryTransaction,
+			Reference:   t.Hash,
+			Description: strings.TrimSpace(fmt.Sprintf("%s %s %s", t.TransactionSubType, t.Chain, t.Description)),
+			Status:      t.Status,
+			Asset:       t.Asset,
+			Amount:      t.signedAmount(),
+			Fee:         t.TransFee,
+			FeeAsset:    t.Chain,
+		}
+		balance := balanceOf(t.UserID, t.Asset)
+		if isCompleted(t.Status) {
+			balance.Closing += entry.Amount
+		}
+		running := balance.Closing
+		entry.Balance = &running
+		statement.Entries = append(statement.Entries, entry)
+	}
+
+	var deposits []DepositRequest
+	if err := db.Scopes(scope).Where("created_at >= ? AND created_at < ?", from, to).Find(&deposits).Error; err != nil {
+		return nil, err
+	}
+	for _, d := range deposits {
+		statement.Entries = append(statement.Entries, StatementEntry{
+			Date: d.CreatedAt, UserID: d.UserID, Kind: EntryDeposit, Reference: d.Ref,
+			Description: fmt.Sprintf("Bank deposit via %s for %s %s", d.DepositBank, d.AssetEquivalent, d.ProposedAsset),
+			Status:      d.Status, Asset: d.Currency, Amount: parseAmount(d.FiatAmount),
+		})
+	}
+
+	var withdrawals []WithdrawalRequest
+	if err := db.Scopes(scope).Where("created_at >= ? AND created_at < ?", from, to).Find(&withdrawals).Error; err != nil {
+		return nil, err
+	}
+	for _, w := range withdrawals {
+		statement.Entries = append(statement.Entries, StatementEntry{
+			Date: w.CreatedAt, UserID: w.UserID, Kind: EntryWithdrawal, Reference: w.BankRef,
+			Description: fmt.Sprintf("Bank withdrawal of %s %s to %s %s", w.CryptoAmount, w.Asset, w.BankName, w.AccountNumber),
+			Status:      w.Status, Asset: w.FiatCurrency, Amount: -parseAmount(w.EquivalentFiat),
+		})
+	}
+
+	var mobile []HurupayRequest
+	if err := db.Scopes(scope).Where("created_at >= ? AND created_at < ?", from, to).Find(&mobile).Error; err != nil {
+		return nil, err
+	}
+	for _, h := range mobile {
+		amount := parseAmount(h.Amount)
+		description := fmt.Sprintf("Mobile money collection from %s", h.MobileNumber)
+		if h.RequestType == OffRamp {
+			amount = -amount
+			description = fmt.Sprintf("Mobile money payout to %s", h.MobileNumber)
+		}
+		statement.Entries = append(statement.Entries, StatementEntry{
+			Date: h.CreatedAt, UserID: uint(h.UserId), Kind: EntryMobileMoney, Reference: h.RequestId,
+			Description: description, Status: h.Status, Asset: h.CountryCurrency, Amount: amount,
+			Fee: parseAmount(h.DeveloperFee), FeeAsset: h.Token,
+		})
+	}
+
+	var borderless []BorderlessRequest
+	if err := db.Scopes(scope).Where("created_at >= ? AND created_at < ?", from, to).Find(&borderless).Error; err != nil {
+		return nil, err
+	}
+	for _, b := range borderless {
+		amount := parseAmount(b.FiatAmount)
+		description := fmt.Sprintf("Borderless deposit from %s", b.Country)
+		if b.PaymentInstructionId != nil {
+			amount = -amount
+			description = fmt.Sprintf("Borderless withdrawal to %s", b.Country)
+		}
+		statement.Entries = append(statement.Entries, StatementEntry{
+			Date: b.CreatedAt, UserID: b.UserId, Kind: EntryBorderless, Reference: b.TxId,
+			Description: description, Status: b.Status, Asset: b.Asset, Amount: amount,
+			Fee: parseAmount(b.FeeAmount), FeeAsset: b.Asset,
+		})
+	}
+
+	sort.SliceStable(statement.Entries, func(i, j int) bool {
+		return statement.Entries[i].Date.Before(statement.Entries[j].Date)
+	})
+
+	fees := map[string]float64{}
+	userIDs := []uint{}
+	seen := map[uint]bool{}
+	for _, entry := range statement.Entries {
+		if entry.Fee != 0 && isCompleted(entry.Status) {
+			fees[entry.FeeAsset] += entry.Fee
+		}
+		if !seen[entry.UserID] {
+			seen[entry.UserID] = true
+			userIDs = append(userIDs, entry.UserID)
+		}
+	}
+	for _, balance := range balances {
+		if !seen[balance.UserID] {
+			seen[balance.UserID] = true
+			userIDs = append(userIDs, balance.UserID)
+		}
+	}
+
+	users, err := userSummaries(userIDs)
+	if err != nil {
+		return nil, err
+	}
+	email := func(id uint) string {
+		if user := users[id]; user != nil {
+			return user.Email
+		}
+		return ""
+	}
+	for i := range statement.Entries {
+		statement.Entries[i].Email = email(statement.Entries[i].UserID)
+	}
+	for _, balance := range balances {
+		balance.Email = email(balance.UserID)
+		statement.Balances = append(statement.Balances, *balance)
+	}
+	sort.Slice(statement.Balances, func(i, j int) bool {
+		a, b := statement.Balances[i], statement.Balances[j]
+		if a.Email != b.Email {
+			return a.Email < b.Email
+		}
+		return a.Asset < b.Asset
+	})
+	for asset, total := range fees {
+		statement.Fees = append(statement.Fees, StatementFee{Asset: asset, Total: total})
+	}
+	sort.Slice(statement.Fees, func(i, j int) bool { return statement.Fees[i].Asset < statement.Fees[j].Asset })
+
+	return statement, nil
+}
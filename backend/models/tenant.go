@@ -0,0 +1,243 @@
+package models
+
+import (
+	"errors"
+	"net"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"gorm.io/gorm"
+)
+
+type TenantStatus string
+
+const (
+	TenantActive    TenantStatus = "Active"
+	TenantSuspended TenantStatus = "Suspended"
+)
+
+// Payment rails a tenant can enable
+const (
+	RailBank        = "bank"
+	RailMobileMoney = "mobile_money"
+	RailBorderless  = "borderless"
+)
+
+var AllRails = []string{RailBank, RailMobileMoney, RailBorderless}
+
+// DefaultDeveloperFee applies when a tenant has no fee rule for a rail
+const DefaultDeveloperFee = "0.25"
+
+// Tenant is a white-label brand. Users, partners and webhook endpoints belong
+// to a tenant, a nil TenantID means the GreyBox platform itself. List fields
+// are stored comma separated and an empty list means "platform default".
+type Tenant struct {
+	gorm.Model
+	Name             string          `gorm:"not null" json:"name"`
+	Slug             string          `gorm:"uniqueIndex;not null" json:"slug"`
+	Status           TenantStatus    `gorm:"default:Active" json:"status"`
+	Domains          string          `json:"domains"`
+	AllowedOrigins   string          `json:"allowed_origins"`
+	EnabledChains    string          `json:"enabled_chains"`
+	EnabledRails     string          `json:"enabled_rails"`
+	BlockedCountries string          `json:"blocked_countries"`
+	EmailSenderName  string          `json:"email_sender_name"`
+	EmailReplyTo     string          `json:"email_reply_to"`
+	EmailTemplateSet string          `json:"email_template_set"`
+	FeeRules         []TenantFeeRule `gorm:"foreignKey:TenantID" json:"fee_rules,omitempty"`
+}
+
+type TenantFeeRule struct {
+	gorm.Model
+	TenantID     uint   `gorm:"uniqueIndex:idx_tenant_rail" json:"tenant_id"`
+	Rail         string `gorm:"uniqueIndex:idx_tenant_rail" json:"rail"`
+	DeveloperFee string `json:"developer_fee"`
+}
+
+// tenantCache keeps the domain lookups done on every request off the database
+var tenantCache = cache.New(time.Minute, 5*time.Minute)
+
+const tenantListCacheKey = "tenants:active"
+
+// templateSetPattern keeps template set names usable as a directory name
+var templateSetPattern = regexp.MustCompile(`^[a-z0-9_-]*$`)
+
+func (t *Tenant) CreateTenant() error {
+	if err := t.validate(); err != nil {
+		return err
+	}
+	defer tenantCache.Flush()
+	return db.Create(t).Error
+}
+
+func (t *Tenant) UpdateTenant() error {
+	if err := t.validate(); err != nil {
+		return err
+	}
+	defer tenantCache.Flush()
+	return db.Omit("FeeRules").Save(t).Error
+}
+
+func (t *Tenant) validate() error {
+	for _, rail := range splitList(t.EnabledRails) {
+		if !slices.Contains(AllRails, rail) {
+			return errors.New("unknown rail: " + rail)
+		}
+	}
+	if !templateSetPattern.MatchString(t.EmailTemplateSet) {
+		return errors.New("email template set may only contain lowercase letters, digits, - and _")
+	}
+	for _, domain := range splitList(t.Domains) {
+		if strings.ContainsAny(domain, "/ ") {
+			return errors.New("domains must be bare host names: " + domain)
+		}
+	}
+	return nil
+}
+
+func (t *Tenant) IsActive() bool {
+	return t.Status == TenantActive
+}
+
+// SetFeeRule creates or replaces the developer fee for a rail
+func (t *Tenant) SetFeeRule(rail, fee string) error {
+	if !slices.Contains(AllRails, rail) {
+		return errors.New("unknown rail: " + rail)
+	}
+	defer tenantCache.Flush()
+
+	var rule TenantFeeRule
+	err := db.Where("tenant_id = ? AND rail = ?", t.ID, rail).First(&rule).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	rule.TenantID = t.ID
+	rule.Rail = rail
+	rule.DeveloperFee = fee
+	return db.Save(&rule).Error
+}
+
+// ChainEnabled reports whether users of the tenant may hold wallets on chain
+func (t *Tenant) ChainEnabled(chain string) bool {
+	chains := splitList(t.EnabledChains)
+	return len(chains) == 0 || slices.Contains(chains, strings.ToUpper(chain))
+}
+
+func (t *Tenant) RailEnabled(rail string) bool {
+	rails := splitList(t.EnabledRails)
+	return len(rails) == 0 || slices.Contains(rails, rail)
+}
+
+// CountryBlocked reports whether the tenant blocks the country on top of the
+// platform list
+func (t *Tenant) CountryBlocked(alpha2Code string) bool {
+	return slices.Contains(splitList(t.BlockedCountries), strings.ToUpper(alpha2Code))
+}
+
+func (t *Tenant) DeveloperFee(rail string) string {
+	for _, rule := range t.FeeRules {
+		if rule.Rail == rail && rule.DeveloperFee != "" {
+			return rule.DeveloperFee
+		}
+	}
+	return DefaultDeveloperFee
+}
+
+func (t *Tenant) OwnsDomain(host string) bool {
+	return slices.Contains(splitList(t.Domains), normalizeHost(host))
+}
+
+func GetTenantByID(id uint) (*Tenant, error) {
+	var tenant Tenant
+	err := db.Preload("FeeRules").First(&tenant, id).Error
+	return &tenant, err
+}
+
+func GetTenants() ([]Tenant, error) {
+	var tenants []Tenant
+	err := db.Preload("FeeRules").Find(&tenants).Error
+	return tenants, err
+}
+
+// GetCachedTenant is GetTenantByID behind the tenant cache, used on the hot
+// request path
+func GetCachedTenant(id uint) (*Tenant, error) {
+	for _, tenant := range activeTenants() {
+		if tenant.ID == id {
+			return &tenant, nil
+		}
+	}
+	return nil, errors.New("tenant not found or inactive")
+}
+
+// GetTenantByDomain resolves the active tenant serving host
+func GetTenantByDomain(host string) (*Tenant, bool) {
+	for _, tenant := range activeTenants() {
+		if tenant.OwnsDomain(host) {
+			return &tenant, true
+		}
+	}
+	return nil, false
+}
+
+// TenantOrigins lists the CORS origins of every active tenant
+func TenantOrigins() []string {
+	var origins []string
+	for _, tenant := range activeTenants() {
+		origins = append(origins, splitList(tenant.AllowedOrigins)...)
+	}
+	return origins
+}
+
+// TenantHosts lists the host names of every active tenant
+func TenantHosts() []string {
+	var hosts []string
+	for _, tenant := range activeTenants() {
+		hosts = append(hosts, splitList(tenant.Domains)...)
+	}
+	return hosts
+}
+
+func activeTenants() []Tenant {
+	if cached, found := tenantCache.Get(tenantListCacheKey); found {
+		return cached.([]Tenant)
+	}
+
+	var tenants []Tenant
+	if err := db.Preload("FeeRules").Where("status = ?", TenantActive).Find(&tenants).Error; err != nil {
+		return nil
+	}
+	tenantCache.Set(tenantListCacheKey, tenants, cache.DefaultExpiration)
+	return tenants
+}
+
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
+
+// ScopeTenantUsers limits a query on a table with a user_id column to rows
+// owned by users of the tenant. Tenant 0 is the platform and sees everything.
+func ScopeTenantUsers(tenantID uint) func(*gorm.DB) *gorm.DB {
+	return func(query *gorm.DB) *gorm.DB {
+		if tenantID == 0 {
+			return query
+		}
+		return query.Where("user_id IN (?)", db.Model(&User{}).Select("id").Where("tenant_id = ?", tenantID))
+	}
+}
+
+// UserInTenant reports whether the user may be seen by admins of the tenant
+func UserInTenant(userID, tenantID uint) bool {
+	if tenantID == 0 {
+		return true
+	}
+	var count int64
+	db.Model(&User{}).Where("id = ? AND tenant_id = ?", userID, tenantID).Count(&count)
+	return count > 0
+}
@@ -9,23 +9,49 @@ import (
 	"gorm.io/gorm"
 )
 
+// Token purposes
+const (
+	TokenPurposePasswordReset = "password_reset"
+	TokenPurposeAccountUnlock = "account_unlock"
+	TokenPurposeBeneficiary   = "beneficiary_confirmation"
+)
+
 type Token struct {
 	gorm.Model
 	UserID     uint      `json:"user_id"`
 	Token      string    `json:"token"`
+	Purpose    string    `gorm:"default:password_reset;index" json:"purpose"`
 	ExpireAt   time.Time `json:"expired_at"`
 	HasExpired bool      `gorm:"default:false" json:"has_expired"`
+	// Record the token acts on, e.g. the beneficiary it confirms
+	SubjectID uint `gorm:"default:0" json:"subject_id,omitempty"`
 }
 
 func SaveToken(token *Token) error {
 	return db.Create(&token).Error
 }
 
-func GenerateRecoveryToken(userID uint) (Token, error) {
+// generateToken expires any outstanding token of the same purpose so that
+// only the most recently mailed link works
+func generateToken(userID uint, purpose string, ttl time.Duration) (Token, error) {
+	return generateSubjectToken(userID, purpose, 0, ttl)
+}
+
+// generateSubjectToken is generateToken for a token acting on one record,
+// tokens of the user's other records stay valid
+func generateSubjectToken(userID uint, purpose string, subjectID uint, ttl time.Duration) (Token, error) {
 	token := Token{
-		UserID:   userID,
-		Token:    uuid.NewString(),
-		ExpireAt: time.Now().Add(time.Hour), // Token expires in 1 hour
+		UserID:    userID,
+		Token:     uuid.NewString(),
+		Purpose:   purpose,
+		ExpireAt:  time.Now().Add(ttl),
+		SubjectID: subjectID,
+	}
+
+	if err := db.Model(&Token{}).
+		Where("user_id = ? AND purpose = ? AND subject_id = ? AND has_expired = ?", userID, purpose, subjectID, false).
+		Update("has_expired", true).Error; err != nil {
+		return token, err
 	}
 
 	if err := SaveToken(&token); err != nil {
@@ -34,10 +60,22 @@ func GenerateRecoveryToken(userID uint) (Token, error) {
 	return token, nil
 }
 
-func CheckTokenValid(token string) (Token, error) {
+func GenerateRecoveryToken(userID uint) (Token, error) {
+	return generateToken(userID, TokenPurposePasswordReset, time.Hour) // Token expires in 1 hour
+}
+
+func GenerateUnlockToken(userID uint) (Token, error) {
+	return generateToken(userID, TokenPurposeAccountUnlock, 24*time.Hour)
+}
+
+func GenerateBeneficiaryToken(userID, beneficiaryID uint) (Token, error) {
+	return generateSubjectToken(userID, TokenPurposeBeneficiary, beneficiaryID, 24*time.Hour)
+}
+
+func CheckTokenValid(token, purpose string) (Token, error) {
 	var Rectoken Token
 
-	if err := db.Where("token = ?", token).Where("has_expired = ?", false).First(&Rectoken).Error; err != nil {
+	if err := db.Where("token = ? AND purpose = ?", token, purpose).Where("has_expired = ?", false).First(&Rectoken).Error; err != nil {
 		return Rectoken, err
 	}
 
@@ -48,6 +86,21 @@ func CheckTokenValid(token string) (Token, error) {
 	return Rectoken, nil
 }
 
+// Consume marks the token as used, it fails if the token was already used
+func (u *Token) Consume() error {
+	result := db.Model(&Token{}).
+		Where("id = ? AND has_expired = ?", u.ID, false).
+		Update("has_expired", true)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("the token has already been used")
+	}
+	u.HasExpired = true
+	return nil
+}
+
 func (u *Token) UpdateToken() error {
 
 	db.Save(&u)
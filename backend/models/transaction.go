@@ -94,6 +94,7 @@ type HurupayRequest struct {
 	CountryCode     string      `json:"country_code"`
 	MobileNumber    string      `json:"mobile_number"`
 	RequestType     RequestType `json:"request_type"`
+	DeveloperFee    string      `json:"developer_fee"`
 }
 
 func (h *HurupayRequest) SaveHurupayRequest() error {
@@ -167,24 +168,49 @@ func GetTransactionByHash(hash, chain string) (*Transaction, error) {
 	return &transaction, nil
 }
 
-func GetTransactionsByUserID(userId uint) ([]*Transaction, error) {
-	var transactions []*Transaction
+var transactionListSpec = ListSpec{
+	Sorts: map[string]SortField{
+		"created_at": timeSort("created_at", func(row interface{}) time.Time { return row.(Transaction).CreatedAt }),
+		"updated_at": timeSort("updated_at", func(row interface{}) time.Time { return row.(Transaction).UpdatedAt }),
+		"amount":     amountSort("amount", func(row interface{}) string { return row.(Transaction).Amount }),
+		"status":     stringSort("status", func(row interface{}) string { return row.(Transaction).Status }),
+	},
+	DefaultSort:   "-created_at",
+	Amount:        "amount",
+	SearchColumns: []string{"hash", "address", "counter_address", "asset", "description", "request_id"},
+	id:            func(row interface{}) uint { return row.(Transaction).ID },
+}
 
-	err := db.Preload("User").Where("user_id = ?", userId).Find(&transactions).Error
-	if err != nil {
-		return nil, err
-	}
-	return transactions, nil
+// ListUserTransactions returns a page of the user's own transactions
+func ListUserTransactions(userId uint, lq ListQuery) ([]Transaction, PageInfo, error) {
+	return paginate[Transaction](db.Model(&Transaction{}).Where("user_id = ?", userId), transactionListSpec, lq)
 }
 
 func GenerateRequestReference() string {
 	return uuid.New().String()
 }
 
-func FilterDepositRequests(ref, currency, fiatAmount, accountNumber, status, countryCode, cryptoAsset string) ([]DepositRequest, error) {
-	var depositRequests []DepositRequest
+// DepositRequestItem is a deposit request as list endpoints return it
+type DepositRequestItem struct {
+	DepositRequest
+	User *UserSummary `json:"user,omitempty"`
+}
+
+var depositListSpec = ListSpec{
+	Sorts: map[string]SortField{
+		"created_at": timeSort("created_at", func(row interface{}) time.Time { return row.(DepositRequest).CreatedAt }),
+		"updated_at": timeSort("updated_at", func(row interface{}) time.Time { return row.(DepositRequest).UpdatedAt }),
+		"amount":     amountSort("fiat_amount", func(row interface{}) string { return row.(DepositRequest).FiatAmount }),
+		"status":     stringSort("status", func(row interface{}) string { return row.(DepositRequest).Status }),
+	},
+	DefaultSort:   "-created_at",
+	Amount:        "fiat_amount",
+	SearchColumns: []string{"ref", "account_number", "account_name", "deposit_bank"},
+	id:            func(row interface{}) uint { return row.(DepositRequest).ID },
+}
 
-	query := db.Model(&DepositRequest{})
+func FilterDepositRequests(tenantID uint, ref, currency, accountNumber, countryCode, cryptoAsset string, lq ListQuery) ([]DepositRequestItem, PageInfo, error) {
+	query := db.Model(&DepositRequest{}).Scopes(ScopeTenantUsers(tenantID))
 
 	if ref != "" {
 		query = query.Where("ref = ?", ref)
@@ -192,15 +218,9 @@ func FilterDepositRequests(ref, currency, fiatAmount, accountNumber, status, cou
 	if currency != "" {
 		query = query.Where("currency = ?", currency)
 	}
-	if fiatAmount != "" {
-		query = query.Where("fiat_amount = ?", fiatAmount)
-	}
 	if accountNumber != "" {
 		query = query.Where("account_number = ?", accountNumber)
 	}
-	if status != "" {
-		query = query.Where("status = ?", status)
-	}
 	if countryCode != "" {
 		query = query.Where("country_code = ?", countryCode)
 	}
@@ -208,22 +228,48 @@ func FilterDepositRequests(ref, currency, fiatAmount, accountNumber, status, cou
 		query = query.Where("proposed_asset = ?", cryptoAsset)
 	}
 
-	err := query.Preload("User").Find(&depositRequests).Error
+	rows, info, err := paginate[DepositRequest](query, depositListSpec, lq)
 	if err != nil {
-		return nil, err
+		return nil, info, err
 	}
 
-	return depositRequests, nil
+	userIDs := make([]uint, len(rows))
+	for i, row := range rows {
+		userIDs[i] = row.UserID
+	}
+	users, err := userSummaries(userIDs)
+	if err != nil {
+		return nil, info, err
+	}
+	items := make([]DepositRequestItem, len(rows))
+	for i, row := range rows {
+		items[i] = DepositRequestItem{DepositRequest: row, User: users[row.UserID]}
+	}
+	return items, info, nil
 }
 
-func FilterWithdrawalRequests(status, chain, hash, address, accountNumber string) ([]WithdrawalRequest, error) {
-	var withdrawalRequests []WithdrawalRequest
+// WithdrawalRequestItem is a withdrawal request as list endpoints return it
+type WithdrawalRequestItem struct {
+	WithdrawalRequest
+	User *UserSummary `json:"user,omitempty"`
+}
 
-	query := db.Model(&WithdrawalRequest{})
+var withdrawalListSpec = ListSpec{
+	Sorts: map[string]SortField{
+		"created_at": timeSort("created_at", func(row interface{}) time.Time { return row.(WithdrawalRequest).CreatedAt }),
+		"updated_at": timeSort("updated_at", func(row interface{}) time.Time { return row.(WithdrawalRequest).UpdatedAt }),
+		"amount":     amountSort("equivalent_fiat", func(row interface{}) string { return row.(WithdrawalRequest).EquivalentFiat }),
+		"status":     stringSort("status", func(row interface{}) string { return row.(WithdrawalRequest).Status }),
+	},
+	DefaultSort:   "-created_at",
+	Amount:        "equivalent_fiat",
+	SearchColumns: []string{"hash", "address", "bank_name", "account_name", "account_number", "bank_ref"},
+	id:            func(row interface{}) uint { return row.(WithdrawalRequest).ID },
+}
+
+func FilterWithdrawalRequests(tenantID uint, chain, hash, address, accountNumber string, lq ListQuery) ([]WithdrawalRequestItem, PageInfo, error) {
+	query := db.Model(&WithdrawalRequest{}).Scopes(ScopeTenantUsers(tenantID))
 
-	if status != "" {
-		query = query.Where("status = ?", status)
-	}
 	if chain != "" {
 		query = query.Where("chain = ?", chain)
 	}
@@ -237,12 +283,24 @@ func FilterWithdrawalRequests(status, chain, hash, address, accountNumber string
 		query = query.Where("account_number = ?", accountNumber)
 	}
 
-	err := query.Preload("User").Find(&withdrawalRequests).Error
+	rows, info, err := paginate[WithdrawalRequest](query, withdrawalListSpec, lq)
 	if err != nil {
-		return nil, err
+		return nil, info, err
 	}
 
-	return withdrawalRequests, nil
+	userIDs := make([]uint, len(rows))
+	for i, row := range rows {
+		userIDs[i] = row.UserID
+	}
+	users, err := userSummaries(userIDs)
+	if err != nil {
+		return nil, info, err
+	}
+	items := make([]WithdrawalRequestItem, len(rows))
+	for i, row := range rows {
+		items[i] = WithdrawalRequestItem{WithdrawalRequest: row, User: users[row.UserID]}
+	}
+	return items, info, nil
 }
 
 func GetDepositRequest(id int) (*DepositRequest, error) {
@@ -272,6 +330,18 @@ func GetHurupayRequestRequestId(requestId string) (*HurupayRequest, error) {
 	return &hurupayRequest, nil
 }
 
+// GetLatestCollectionNumber returns the most recent mobile money number the
+// user collected from, which is the user's own wallet
+func GetLatestCollectionNumber(userID uint) (*HurupayRequest, error) {
+	var hurupayRequest HurupayRequest
+	err := db.Where("user_id = ? AND request_type = ? AND mobile_number <> ''", userID, OnRamp).
+		Order("id desc").First(&hurupayRequest).Error
+	if err != nil {
+		return nil, err
+	}
+	return &hurupayRequest, nil
+}
+
 func GetTransactionByRequestId(requestId string) (*Transaction, error) {
 	var transaction Transaction
 	err := db.Preload("User").Where("request_id = ?", requestId).First(&transaction).Error
@@ -281,13 +351,32 @@ func GetTransactionByRequestId(requestId string) (*Transaction, error) {
 	return &transaction, nil
 }
 
-func GetHurupayRequest() ([]*HurupayRequest, error) {
-	var hurupayRequests []*HurupayRequest
-	err := db.Preload("User").Find(&hurupayRequests).Error
-	if err != nil {
-		return nil, err
+var hurupayListSpec = ListSpec{
+	Sorts: map[string]SortField{
+		"created_at": timeSort("created_at", func(row interface{}) time.Time { return row.(*HurupayRequest).CreatedAt }),
+		"updated_at": timeSort("updated_at", func(row interface{}) time.Time { return row.(*HurupayRequest).UpdatedAt }),
+		"amount":     amountSort("amount", func(row interface{}) string { return row.(*HurupayRequest).Amount }),
+		"status":     stringSort("status", func(row interface{}) string { return row.(*HurupayRequest).Status }),
+	},
+	DefaultSort:   "-created_at",
+	Amount:        "amount",
+	SearchColumns: []string{"request_id", "mobile_number", "account_number", "mobile_network"},
+	id:            func(row interface{}) uint { return row.(*HurupayRequest).ID },
+}
+
+// hurupayUserColumns are the user fields HurupayRequestSerializer shows
+func hurupayUserColumns(tx *gorm.DB) *gorm.DB {
+	return tx.Select("id", "first_name", "last_name", "email", "account_address", "crypto_currency", "country_code")
+}
+
+// GetHurupayRequest returns a page of the tenant's mobile money requests,
+// optionally of one request type
+func GetHurupayRequest(tenantID uint, requestType RequestType, lq ListQuery) ([]*HurupayRequest, PageInfo, error) {
+	query := db.Model(&HurupayRequest{}).Scopes(ScopeTenantUsers(tenantID)).Preload("User", hurupayUserColumns)
+	if requestType != "" {
+		query = query.Where("request_type = ?", requestType)
 	}
-	return hurupayRequests, nil
+	return paginate[*HurupayRequest](query, hurupayListSpec, lq)
 }
 
 func GetHurupayRequestById(id int) (*HurupayRequest, error) {
@@ -299,75 +388,50 @@ func GetHurupayRequestById(id int) (*HurupayRequest, error) {
 	return &hurupayRequest, nil
 }
 
-func GetHurupayStats() (map[string]int64, error) {
-	stats := make(map[string]int64)
-
-	var totalRequests int64
-	if err := db.Model(&HurupayRequest{}).Count(&totalRequests).Error; err != nil {
-		return nil, err
-	}
-	stats["total_requests"] = totalRequests
-
-	// Total successful requests with request type "on-ramp"
-	var successfulOnRamp int64
-	if err := db.Model(&HurupayRequest{}).
-		Where("status = ? AND request_type = ?", "Completed", OnRamp).
-		Count(&successfulOnRamp).Error; err != nil {
-		return nil, err
-	}
-	stats["successful_on_ramp"] = successfulOnRamp
-
-	// Total failed requests with request type "on-ramp"
-	var failedOnRamp int64
-	if err := db.Model(&HurupayRequest{}).
-		Where("status = ? AND request_type = ?", "CANCELLED", OnRamp).
-		Count(&failedOnRamp).Error; err != nil {
-		return nil, err
-	}
-	stats["failed_on_ramp"] = failedOnRamp
-
-	// Total pending requests for "off-ramp"
-	var pendingOffRamp int64
-	if err := db.Model(&HurupayRequest{}).
-		Where("status = ? AND request_type = ?", "Pending", OffRamp).
-		Count(&pendingOffRamp).Error; err != nil {
-		return nil, err
-	}
-	stats["pending_off_ramp"] = pendingOffRamp
+// hurupayStatKeys names the status and request type pairs GetHurupayStats
+// reports on
+var hurupayStatKeys = []struct {
+	key         string
+	status      string
+	requestType RequestType
+}{
+	{"successful_on_ramp", "Completed", OnRamp},
+	{"failed_on_ramp", "CANCELLED", OnRamp},
+	{"pending_off_ramp", "Pending", OffRamp},
+	{"pending_on_ramp", "Pending", OnRamp},
+	{"successful_off_ramp", "Completed", OffRamp},
+	{"failed_off_ramp", "declined", OffRamp},
+	{"created_onramp", "CREATED", OnRamp},
+}
 
-	var pendingOnRamp int64
-	if err := db.Model(&HurupayRequest{}).
-		Where("status = ? AND request_type = ?", "Pending", OnRamp).
-		Count(&pendingOnRamp).Error; err != nil {
-		return nil, err
-	}
-	stats["pending_on_ramp"] = pendingOnRamp
-	// Total successful requests with request type "off-ramp"
-	var successfulOffRamp int64
-	if err := db.Model(&HurupayRequest{}).
-		Where("status = ? AND request_type = ?", "Completed", OffRamp).
-		Count(&successfulOffRamp).Error; err != nil {
+// GetHurupayStats counts the tenant's mobile money requests by status and
+// request type in one grouped query
+func GetHurupayStats(tenantID uint) (map[string]int64, error) {
+	var rows []struct {
+		Status      string
+		RequestType RequestType
+		Count       int64
+	}
+	err := db.Model(&HurupayRequest{}).Scopes(ScopeTenantUsers(tenantID)).
+		Select("status, request_type, COUNT(*) AS count").
+		Group("status, request_type").
+		Scan(&rows).Error
+	if err != nil {
 		return nil, err
 	}
-	stats["successful_off_ramp"] = successfulOffRamp
 
-	var failedOffRamp int64
-	if err := db.Model(&HurupayRequest{}).
-		Where("status = ? AND request_type = ?", "declined", OffRamp).
-		Count(&failedOffRamp).Error; err != nil {
-		return nil, err
+	stats := map[string]int64{"total_requests": 0}
+	for _, stat := range hurupayStatKeys {
+		stats[stat.key] = 0
 	}
-	stats["failed_off_ramp"] = failedOffRamp
-
-	var CreatedOnramp int64
-
-	if err := db.Model(&HurupayRequest{}).
-		Where("status = ? AND request_type = ?", "CREATED", OnRamp).
-		Count(&CreatedOnramp).Error; err != nil {
-		return nil, err
+	for _, row := range rows {
+		stats["total_requests"] += row.Count
+		for _, stat := range hurupayStatKeys {
+			if row.Status == stat.status && row.RequestType == stat.requestType {
+				stats[stat.key] = row.Count
+			}
+		}
 	}
-	stats["created_onramp"] = CreatedOnramp
-
 	return stats, nil
 }
 
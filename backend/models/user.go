@@ -3,14 +3,13 @@ package models
 import (
 	"backend/serializers"
 	"backend/state"
-	"backend/utils/tokens"
 	"crypto/rand"
 	"errors"
 	"fmt"
 	"html"
-	"log"
 	"math/big"
 	"net/mail"
+	"slices"
 	"strings"
 	"time"
 	"unicode"
@@ -19,6 +18,7 @@ import (
 
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type User struct {
@@ -43,6 +43,71 @@ type User struct {
 	PreviousBalance float32        `json:"-"`
 	Role            string         `gorm:"default:Customer" json:"role"`
 	UserAccounts    []UserAccounts `gorm:"foreignKey:UserId" json:"user_accounts"`
+
+	// Email verification
+	EmailVerified      bool       `gorm:"default:false" json:"email_verified"`
+	EmailVerifiedAt    *time.Time `json:"email_verified_at"`
+	VerificationSentAt *time.Time `json:"-"`
+	// WalletClaimedAt is when a verification request started provisioning
+	// the wallet, other requests wait for it until WalletClaimTTL
+	WalletClaimedAt *time.Time `json:"-"`
+
+	// Login protection
+	FailedLoginAttempts int        `gorm:"default:0" json:"-"`
+	LockoutCount        int        `gorm:"default:0" json:"-"`
+	LockedUntil         *time.Time `json:"-"`
+	SessionsValidFrom   *time.Time `json:"-"`
+
+	// Set when the user was onboarded by a partner through the partner API
+	PartnerID *uint `gorm:"index" json:"partner_id,omitempty"`
+
+	// White-label tenant owning the user, nil for platform users
+	TenantID *uint `gorm:"index" json:"tenant_id,omitempty"`
+
+	// Language of mails and notifications, one of SupportedLocales
+	Locale string `gorm:"default:en" json:"locale"`
+	// Lifecycle status, one of the User* statuses, with the reason of the
+	// last change
+	Status          string     `gorm:"default:active;index" json:"status"`
+	StatusReason    string     `json:"-"`
+	StatusChangedAt *time.Time `json:"status_changed_at,omitempty"`
+
+	// Payouts only go to confirmed beneficiaries past their cooling period
+	// while AllowlistOnly is set or AllowlistReleaseAt has not passed
+	AllowlistOnly      bool       `gorm:"default:false" json:"allowlist_only"`
+	AllowlistReleaseAt *time.Time `json:"allowlist_release_at,omitempty"`
+}
+
+// Locales users can receive mail in
+const (
+	LocaleEnglish = "en"
+	LocaleFrench  = "fr"
+	LocaleSwahili = "sw"
+)
+
+var SupportedLocales = []string{LocaleEnglish, LocaleFrench, LocaleSwahili}
+
+// localeByCountry picks the default locale for countries not served in English
+var localeByCountry = map[string]string{
+	"TZ": LocaleSwahili, "KE": LocaleSwahili,
+	"BJ": LocaleFrench, "BF": LocaleFrench, "BI": LocaleFrench, "CD": LocaleFrench,
+	"CF": LocaleFrench, "CG": LocaleFrench, "CI": LocaleFrench, "CM": LocaleFrench,
+	"DJ": LocaleFrench, "FR": LocaleFrench, "GA": LocaleFrench, "GN": LocaleFrench,
+	"KM": LocaleFrench, "MG": LocaleFrench, "ML": LocaleFrench, "NE": LocaleFrench,
+	"SN": LocaleFrench, "TD": LocaleFrench, "TG": LocaleFrench,
+}
+
+// ResolveLocale returns locale when supported, otherwise the default for
+// the user's country
+func ResolveLocale(locale, alpha2Code string) string {
+	locale = strings.ToLower(locale)
+	if slices.Contains(SupportedLocales, locale) {
+		return locale
+	}
+	if fallback, ok := localeByCountry[strings.ToUpper(alpha2Code)]; ok {
+		return fallback
+	}
+	return LocaleEnglish
 }
 
 type UserAccounts struct {
@@ -59,6 +124,21 @@ type UserAccounts struct {
 
 const maxRetry = 5
 
+// VerificationResendCooldown is the minimum time between two verification mails
+const VerificationResendCooldown = 2 * time.Minute
+
+// Lockout policy, every consecutive lockout doubles the lock duration
+const (
+	MaxFailedLogins     = 5
+	baseLockoutDuration = 15 * time.Minute
+	maxLockoutDuration  = 24 * time.Hour
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrAccountLocked      = errors.New("account is temporarily locked due to too many failed login attempts, check your email to unlock it")
+)
+
 // generateAccountCode generates a new account code
 func GenerateAccountCode(prefix string) string {
 	// Increment counter atomically and format it
@@ -157,28 +237,23 @@ func VerifyPassword(password, hashedPassword string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 }
 
-func LoginCheck(email, password string) (string, error) {
-	u := User{}
-	//var err error
-
-	err := db.Model(User{}).Where("email=?", email).Take(&u).Error
-	if err != nil {
-		return "", err
+// Authenticate checks the credentials against the stored hash. The user is
+// returned whenever the email matched so the caller can track failed attempts.
+func Authenticate(email, password string) (User, error) {
+	u, found := FindUserByEmail(email)
+	if !found {
+		return u, ErrInvalidCredentials
 	}
 
-	err = VerifyPassword(password, u.Password)
-
-	if err != nil && err == bcrypt.ErrMismatchedHashAndPassword {
-		return "", errors.New("Invalid password")
+	if u.IsLocked() {
+		return u, ErrAccountLocked
 	}
-	token, err := tokens.GenerateToken(u.ID)
-	//fmt.Print("token: ", token)
-	if err != nil {
-		//fmt.Print("err: ", err)
-		return "", err
+
+	if err := VerifyPassword(password, u.Password); err != nil {
+		return u, ErrInvalidCredentials
 	}
 
-	return token, nil
+	return u, nil
 }
 
 func AlreadyExists(id string) bool {
@@ -192,7 +267,6 @@ func AlreadyExists(id string) bool {
 }
 
 func AccountNumberExists(id string) bool {
-	log.Println("id: ", id)
 	var user User
 
 	result := db.Where("account_number = ?", id).First(&user)
@@ -216,6 +290,12 @@ func (u *User) SaveUser() error {
 }
 
 func (u *User) UpdateUser() {
+	// users loaded through GetUserByID have their password stripped, never
+	// write that blank hash back
+	if u.Password == "" {
+		db.Omit("password").Save(&u)
+		return
+	}
 	db.Save(&u)
 }
 
@@ -267,23 +347,6 @@ func GetUserByID(uid uint) (User, error) {
 
 }
 
-func DeleteUserByID(userID uint) error {
-	// Find the user by ID
-	var user User
-	result := db.First(&user, userID)
-	if result.Error != nil {
-		return result.Error
-	}
-
-	// Delete the user
-	result = db.Delete(&user)
-	if result.Error != nil {
-		return result.Error
-	}
-
-	return nil
-}
-
 func FindUserByEmail(email string) (User, bool) {
 	var user User
 
@@ -305,6 +368,187 @@ func FindUserByAddress(address string) (User, error) {
 	return user, nil
 }
 
+// CanResendVerification reports whether a new verification mail may be sent
+func (u *User) CanResendVerification() bool {
+	if u.VerificationSentAt == nil {
+		return true
+	}
+	return time.Since(*u.VerificationSentAt) >= VerificationResendCooldown
+}
+
+// TouchVerificationSent records when the last verification mail went out
+func (u *User) TouchVerificationSent() error {
+	now := time.Now()
+	u.VerificationSentAt = &now
+	return db.Model(&User{}).Where("id = ?", u.ID).Update("verification_sent_at", now).Error
+}
+
+// IsLocked reports whether the account is currently locked out
+func (u *User) IsLocked() bool {
+	return u.LockedUntil != nil && u.LockedUntil.After(time.Now())
+}
+
+// RegisterFailedLogin counts a failed attempt and locks the account once
+// MaxFailedLogins is reached. It reports whether this attempt locked it. The
+// row is locked while counting so concurrent attempts each see the previous
+// count and exactly one of them trips the lockout.
+func (u *User) RegisterFailedLogin() (bool, error) {
+	locked := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var current User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "failed_login_attempts", "lockout_count", "locked_until").
+			First(&current, u.ID).Error; err != nil {
+			return err
+		}
+
+		current.FailedLoginAttempts++
+		updates := map[string]interface{}{"failed_login_attempts": current.FailedLoginAttempts}
+		if current.FailedLoginAttempts >= MaxFailedLogins {
+			current.LockoutCount++
+			duration := baseLockoutDuration << (current.LockoutCount - 1)
+			if duration > maxLockoutDuration || duration <= 0 {
+				duration = maxLockoutDuration
+			}
+			until := time.Now().Add(duration)
+			current.LockedUntil = &until
+			current.FailedLoginAttempts = 0
+
+			updates["failed_login_attempts"] = 0
+			updates["lockout_count"] = current.LockoutCount
+			updates["locked_until"] = until
+			locked = true
+		}
+		if err := tx.Model(&User{}).Where("id = ?", u.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		u.FailedLoginAttempts, u.LockoutCount, u.LockedUntil = current.FailedLoginAttempts, current.LockoutCount, current.LockedUntil
+		return nil
+	})
+	return locked, err
+}
+
+// ResetFailedLogins clears the lockout state after a successful login
+func (u *User) ResetFailedLogins() error {
+	if u.FailedLoginAttempts == 0 && u.LockoutCount == 0 && u.LockedUntil == nil {
+		return nil
+	}
+	return u.Unlock()
+}
+
+// Unlock lifts any active lockout and resets the progression
+func (u *User) Unlock() error {
+	u.FailedLoginAttempts = 0
+	u.LockoutCount = 0
+	u.LockedUntil = nil
+	return db.Model(&User{}).Where("id = ?", u.ID).Updates(map[string]interface{}{
+		"failed_login_attempts": 0,
+		"lockout_count":         0,
+		"locked_until":          nil,
+	}).Error
+}
+
+// RevokeSessions invalidates every access token issued before now
+func (u *User) RevokeSessions() error {
+	now := time.Now()
+	u.SessionsValidFrom = &now
+	return db.Model(&User{}).Where("id = ?", u.ID).Update("sessions_valid_from", now).Error
+}
+
+// SessionValid reports whether a token issued at issuedAt (unix seconds) has
+// not been revoked
+func (u *User) SessionValid(issuedAt int64) bool {
+	if u.SessionsValidFrom == nil {
+		return true
+	}
+	return issuedAt >= u.SessionsValidFrom.Unix()
+}
+
+// ChangePassword validates and stores a new password, then revokes all
+// existing sessions
+func (u *User) ChangePassword(password string) error {
+	if !ValidatePassword(password) {
+		return errors.New("password must be at least 8 characters long, contain a digit, and an uppercase letter")
+	}
+	u.Password = password
+	if err := u.HashPassword(); err != nil {
+		return err
+	}
+	if err := db.Model(&User{}).Where("id = ?", u.ID).Update("password", u.Password).Error; err != nil {
+		return err
+	}
+	u.PrepareGive()
+	return u.RevokeSessions()
+}
+
+// HasWallet reports whether a wallet has been provisioned for the user
+func (u *User) HasWallet() bool {
+	return u.AccountAddress != ""
+}
+
+// WalletClaimTTL is how long a wallet provisioning claim holds, a request
+// that died while provisioning does not keep the user waiting past it
+const WalletClaimTTL = 5 * time.Minute
+
+// ClaimWalletProvisioning reserves provisioning the user's wallet for this
+// request, reporting false while another request holds the claim or once
+// the wallet exists
+func (u *User) ClaimWalletProvisioning() (bool, error) {
+	now := time.Now()
+	result := db.Model(&User{}).
+		Where("id = ? AND (account_address IS NULL OR account_address = '')", u.ID).
+		Where("wallet_claimed_at IS NULL OR wallet_claimed_at < ?", now.Add(-WalletClaimTTL)).
+		Update("wallet_claimed_at", now)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	u.WalletClaimedAt = &now
+	return true, nil
+}
+
+// ReleaseWalletProvisioning drops the claim when the wallet could not be
+// provisioned, so the link can be retried right away
+func (u *User) ReleaseWalletProvisioning() error {
+	u.WalletClaimedAt = nil
+	return db.Model(&User{}).Where("id = ?", u.ID).Update("wallet_claimed_at", nil).Error
+}
+
+// CompleteEmailVerification stores the wallet provisioned for the user and
+// marks the email verified in the same update, so a user is never verified
+// without a wallet
+func (u *User) CompleteEmailVerification() error {
+	now := time.Now()
+	err := db.Model(&User{}).Where("id = ? AND (account_address IS NULL OR account_address = '')", u.ID).
+		Updates(map[string]interface{}{
+			"account_address":   u.AccountAddress,
+			"private_key":       u.PrivateKey,
+			"mnemonic":          u.Mnemonic,
+			"xpub":              u.Xpub,
+			"email_verified":    true,
+			"email_verified_at": now,
+			"wallet_claimed_at": nil,
+		}).Error
+	if err != nil {
+		return err
+	}
+	u.EmailVerified = true
+	u.EmailVerifiedAt = &now
+	u.WalletClaimedAt = nil
+	return nil
+}
+
+// MarkEmailVerified verifies the email of a user who already has a wallet
+func (u *User) MarkEmailVerified() error {
+	now := time.Now()
+	u.EmailVerified = true
+	u.EmailVerifiedAt = &now
+	return db.Model(&User{}).Where("id = ?", u.ID).Updates(map[string]interface{}{
+		"email_verified":    true,
+		"email_verified_at": now,
+	}).Error
+}
+
 func FindAdmins() ([]User, error) {
 	var users []User
 	if err := db.Where("role = ?", "Admin").Find(&users).Error; err != nil {
@@ -349,9 +593,18 @@ func GetUserAccountById(id uint) (UserAccounts, error) {
 	return userAccount, nil
 }
 
-func FilterUserAccounts(filter serializers.UserAccountsFilter) ([]UserAccounts, error) {
-	var userAccounts []UserAccounts
-	query := db
+var userAccountListSpec = ListSpec{
+	Sorts: map[string]SortField{
+		"created_at": timeSort("created_at", func(row interface{}) time.Time { return row.(UserAccounts).CreatedAt }),
+		"updated_at": timeSort("updated_at", func(row interface{}) time.Time { return row.(UserAccounts).UpdatedAt }),
+	},
+	DefaultSort:   "-created_at",
+	SearchColumns: []string{"account_id", "virtual_account_id"},
+	id:            func(row interface{}) uint { return row.(UserAccounts).ID },
+}
+
+func FilterUserAccounts(tenantID uint, filter serializers.UserAccountsFilter, lq ListQuery) ([]UserAccounts, PageInfo, error) {
+	query := db.Model(&UserAccounts{}).Scopes(ScopeTenantUsers(tenantID))
 
 	// Build the query dynamically based on provided filters
 	if filter.UserId != nil {
@@ -370,7 +623,5 @@ func FilterUserAccounts(filter serializers.UserAccountsFilter) ([]UserAccounts,
 		query = query.Where("fiat = ?", filter.Fiat)
 	}
 
-	// Execute the query
-	err := query.Find(&userAccounts).Error
-	return userAccounts, err
+	return paginate[UserAccounts](query, userAccountListSpec, lq)
 }
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// User statuses. Frozen users may sign in but not move money, suspended and
+// closed users may not sign in at all. Closed is final.
+const (
+	UserActive    = "active"
+	UserFrozen    = "frozen"
+	UserSuspended = "suspended"
+	UserClosed    = "closed"
+)
+
+var ErrUserClosed = errors.New("account is closed")
+
+// UserStatusChange records every status transition with its reason
+type UserStatusChange struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	UserID      uint      `gorm:"index" json:"user_id"`
+	From        string    `json:"from"`
+	To          string    `json:"to"`
+	Reason      string    `json:"reason"`
+	ChangedByID *uint     `json:"changed_by_id"` // nil when changed by the system
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// AccountClosure is kept with the closed user until RetainUntil, along with
+// where the remaining wallet balance was swept
+type AccountClosure struct {
+	gorm.Model
+	UserID       uint      `gorm:"uniqueIndex" json:"user_id"`
+	Reason       string    `json:"reason"`
+	ClosedByID   uint      `json:"closed_by_id"`
+	Chain        string    `json:"chain"`
+	SweptAmount  float32   `json:"swept_amount"`
+	SweepAddress string    `json:"sweep_address,omitempty"`
+	SweepTxHash  string    `json:"sweep_tx_hash,omitempty"`
+	RetainUntil  time.Time `json:"retain_until"`
+}
+
+// CurrentStatus treats users from before statuses existed as active
+func (u *User) CurrentStatus() string {
+	if u.Status == "" {
+		return UserActive
+	}
+	return u.Status
+}
+
+// CanSignIn reports whether the user may hold a session
+func (u *User) CanSignIn() bool {
+	status := u.CurrentStatus()
+	return status == UserActive || status == UserFrozen
+}
+
+// SetStatus moves the user to the status and records why. Closing goes
+// through Close instead.
+func (u *User) SetStatus(status, reason string, changedByID *uint) error {
+	from := u.CurrentStatus()
+	if from == UserClosed {
+		return ErrUserClosed
+	}
+	if from == status {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		return setStatus(tx, u, from, status, reason, changedByID)
+	})
+}
+
+// Close closes the user for good, keeping the closure record until the
+// retention period runs out, and revokes every session
+func (u *User) Close(closure *AccountClosure) error {
+	from := u.CurrentStatus()
+	if from == UserClosed {
+		return ErrUserClosed
+	}
+	closure.UserID = u.ID
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(closure).Error; err != nil {
+			return err
+		}
+		return setStatus(tx, u, from, UserClosed, closure.Reason, &closure.ClosedByID)
+	})
+	if err != nil {
+		return err
+	}
+	return u.RevokeSessions()
+}
+
+func setStatus(tx *gorm.DB, u *User, from, status, reason string, changedByID *uint) error {
+	now := time.Now()
+	err := tx.Model(&User{}).Where("id = ?", u.ID).Updates(map[string]interface{}{
+		"status":            status,
+		"status_reason":     reason,
+		"status_changed_at": now,
+	}).Error
+	if err != nil {
+		return err
+	}
+	u.Status, u.StatusReason, u.StatusChangedAt = status, reason, &now
+	return tx.Create(&UserStatusChange{UserID: u.ID, From: from, To: status, Reason: reason, ChangedByID: changedByID}).Error
+}
+
+func GetUserStatusChanges(userID uint) ([]UserStatusChange, error) {
+	var changes []UserStatusChange
+	err := db.Where("user_id = ?", userID).Order("id desc").Find(&changes).Error
+	return changes, err
+}
+
+// GetAccountClosure returns the user's closure, nil when the user is not
+// closed
+func GetAccountClosure(userID uint) (*AccountClosure, error) {
+	var closure AccountClosure
+	err := db.Where("user_id = ?", userID).First(&closure).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &closure, err
+}
@@ -0,0 +1,364 @@
+package models
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Outbound webhook event types
+const (
+	WebhookDepositApproved     = "deposit.approved"
+	WebhookDepositRejected     = "deposit.rejected"
+	WebhookWithdrawalSubmitted = "withdrawal.submitted"
+	WebhookWithdrawalCompleted = "withdrawal.completed"
+	WebhookKYCApproved         = "kyc.approved"
+	WebhookKYCRejected         = "kyc.rejected"
+	WebhookCollectionCompleted = "hurupay.collection.completed"
+	WebhookCollectionFailed    = "hurupay.collection.failed"
+	WebhookPayoutCompleted     = "hurupay.payout.completed"
+	WebhookPayoutFailed        = "hurupay.payout.failed"
+	WebhookBorderlessUpdated   = "borderless.transaction.updated"
+	WebhookBorderlessCompleted = "borderless.transaction.completed"
+)
+
+var AllWebhookEvents = []string{
+	WebhookDepositApproved, WebhookDepositRejected,
+	WebhookWithdrawalSubmitted, WebhookWithdrawalCompleted,
+	WebhookKYCApproved, WebhookKYCRejected,
+	WebhookCollectionCompleted, WebhookCollectionFailed,
+	WebhookPayoutCompleted, WebhookPayoutFailed,
+	WebhookBorderlessUpdated, WebhookBorderlessCompleted,
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	DeliveryPending   WebhookDeliveryStatus = "Pending"
+	DeliverySucceeded WebhookDeliveryStatus = "Succeeded"
+	DeliveryFailed    WebhookDeliveryStatus = "Failed"
+)
+
+// Retry policy, attempt n waits WebhookBaseBackoff * 2^(n-1) capped at
+// WebhookMaxBackoff. Endpoints are disabled after WebhookDisableThreshold
+// consecutive failed attempts.
+const (
+	WebhookMaxAttempts      = 12
+	WebhookBaseBackoff      = 30 * time.Second
+	WebhookMaxBackoff       = 6 * time.Hour
+	WebhookDisableThreshold = 25
+	webhookClaimLease       = 2 * time.Minute
+)
+
+// WebhookEndpoint belongs to exactly one owner: a user, a partner (events of
+// its sub-users), a tenant (events of its users) or the platform (all events)
+// when every owner field is nil.
+type WebhookEndpoint struct {
+	gorm.Model
+	UserID              *uint      `gorm:"index" json:"user_id,omitempty"`
+	PartnerID           *uint      `gorm:"index" json:"partner_id,omitempty"`
+	TenantID            *uint      `gorm:"index" json:"tenant_id,omitempty"`
+	URL                 string     `gorm:"not null" json:"url"`
+	Description         string     `json:"description"`
+	Secret              string     `json:"-"`
+	EventTypes          string     `json:"event_types"`
+	Active              bool       `gorm:"default:true" json:"active"`
+	ConsecutiveFailures int        `gorm:"default:0" json:"consecutive_failures"`
+	DisabledAt          *time.Time `json:"disabled_at"`
+	DisabledReason      string     `json:"disabled_reason"`
+}
+
+type WebhookDelivery struct {
+	gorm.Model
+	EndpointID     uint                  `gorm:"index" json:"endpoint_id"`
+	EventID        string                `gorm:"index" json:"event_id"`
+	EventType      string                `json:"event_type"`
+	Payload        string                `gorm:"type:text" json:"payload"`
+	Status         WebhookDeliveryStatus `gorm:"default:Pending;index" json:"status"`
+	Attempts       int                   `gorm:"default:0" json:"attempts"`
+	NextAttemptAt  time.Time             `gorm:"index" json:"next_attempt_at"`
+	LastStatusCode int                   `json:"last_status_code"`
+	LastError      string                `json:"last_error"`
+	DeliveredAt    *time.Time            `json:"delivered_at"`
+}
+
+type WebhookAttempt struct {
+	gorm.Model
+	DeliveryID uint   `gorm:"index" json:"delivery_id"`
+	StatusCode int    `json:"status_code"`
+	Error      string `json:"error"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// WebhookOwner identifies whose endpoints a request manages
+type WebhookOwner struct {
+	UserID    *uint
+	PartnerID *uint
+	TenantID  *uint
+}
+
+func (o WebhookOwner) scope(query *gorm.DB) *gorm.DB {
+	switch {
+	case o.UserID != nil:
+		return query.Where("user_id = ?", *o.UserID)
+	case o.PartnerID != nil:
+		return query.Where("partner_id = ?", *o.PartnerID)
+	case o.TenantID != nil:
+		return query.Where("tenant_id = ? AND user_id IS NULL AND partner_id IS NULL", *o.TenantID)
+	default:
+		return query.Where("tenant_id IS NULL AND user_id IS NULL AND partner_id IS NULL")
+	}
+}
+
+// ValidateWebhookEvents makes sure every subscribed event type is known
+func ValidateWebhookEvents(events []string) error {
+	for _, event := range events {
+		if event != "*" && !slices.Contains(AllWebhookEvents, event) {
+			return errors.New("unknown event type: " + event)
+		}
+	}
+	return nil
+}
+
+func (e *WebhookEndpoint) CreateWebhookEndpoint(owner WebhookOwner) error {
+	e.UserID = owner.UserID
+	e.PartnerID = owner.PartnerID
+	e.TenantID = owner.TenantID
+	e.Active = true
+	return db.Create(e).Error
+}
+
+func (e *WebhookEndpoint) UpdateWebhookEndpoint() error {
+	return db.Save(e).Error
+}
+
+func (e *WebhookEndpoint) DeleteWebhookEndpoint() error {
+	return db.Delete(e).Error
+}
+
+// Subscribes reports whether the endpoint wants the event, no event types
+// or "*" subscribes to everything
+func (e *WebhookEndpoint) Subscribes(eventType string) bool {
+	events := splitList(e.EventTypes)
+	return len(events) == 0 || slices.Contains(events, "*") || slices.Contains(events, eventType)
+}
+
+// Enable re-activates an endpoint and clears its failure streak
+func (e *WebhookEndpoint) Enable() {
+	e.Active = true
+	e.ConsecutiveFailures = 0
+	e.DisabledAt = nil
+	e.DisabledReason = ""
+}
+
+func GetWebhookEndpoints(owner WebhookOwner) ([]WebhookEndpoint, error) {
+	var endpoints []WebhookEndpoint
+	err := db.Scopes(owner.scope).Order("id desc").Find(&endpoints).Error
+	return endpoints, err
+}
+
+func GetWebhookEndpoint(owner WebhookOwner, id uint) (*WebhookEndpoint, error) {
+	var endpoint WebhookEndpoint
+	err := db.Scopes(owner.scope).First(&endpoint, id).Error
+	return &endpoint, err
+}
+
+func GetWebhookEndpointByID(id uint) (*WebhookEndpoint, error) {
+	var endpoint WebhookEndpoint
+	err := db.First(&endpoint, id).Error
+	return &endpoint, err
+}
+
+// GetWebhookEndpointsForUser returns every active endpoint that should see
+// events about the user
+func GetWebhookEndpointsForUser(user User) ([]WebhookEndpoint, error) {
+	var endpoints []WebhookEndpoint
+
+	query := db.Where("active = ?", true).Where(
+		db.Where("user_id = ?", user.ID).
+			Or("user_id IS NULL AND partner_id IS NULL AND tenant_id IS NULL"),
+	)
+	if user.PartnerID != nil {
+		query = query.Or("active = ? AND partner_id = ?", true, *user.PartnerID)
+	}
+	if user.TenantID != nil {
+		query = query.Or("active = ? AND tenant_id = ? AND user_id IS NULL AND partner_id IS NULL", true, *user.TenantID)
+	}
+
+	err := query.Find(&endpoints).Error
+	return endpoints, err
+}
+
+func (d *WebhookDelivery) CreateWebhookDelivery() error {
+	return db.Create(d).Error
+}
+
+func GetWebhookDeliveries(endpointID uint, status string, limit int) ([]WebhookDelivery, error) {
+	var deliveries []WebhookDelivery
+	query := db.Where("endpoint_id = ?", endpointID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Order("id desc").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+func GetWebhookDelivery(endpointID, id uint) (*WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	err := db.Where("endpoint_id = ?", endpointID).First(&delivery, id).Error
+	return &delivery, err
+}
+
+func GetWebhookDeliveryByID(id uint) (*WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	err := db.First(&delivery, id).Error
+	return &delivery, err
+}
+
+func GetWebhookAttempts(deliveryID uint) ([]WebhookAttempt, error) {
+	var attempts []WebhookAttempt
+	err := db.Where("delivery_id = ?", deliveryID).Order("id asc").Find(&attempts).Error
+	return attempts, err
+}
+
+// GetDueWebhookDeliveries returns pending deliveries whose next attempt is due
+func GetDueWebhookDeliveries(limit int) ([]WebhookDelivery, error) {
+	var deliveries []WebhookDelivery
+	err := db.Where("status = ? AND next_attempt_at <= ?", DeliveryPending, time.Now()).
+		Order("next_attempt_at asc").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+// Claim leases the delivery to the caller by pushing its next attempt out, so
+// concurrent workers never send the same attempt twice
+func (d *WebhookDelivery) Claim() bool {
+	result := db.Model(&WebhookDelivery{}).
+		Where("id = ? AND status = ? AND next_attempt_at = ?", d.ID, DeliveryPending, d.NextAttemptAt).
+		Update("next_attempt_at", time.Now().Add(webhookClaimLease))
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+// RecordAttempt stores the outcome of one HTTP attempt and schedules the
+// next one or settles the delivery
+func (d *WebhookDelivery) RecordAttempt(attempt WebhookAttempt, success bool) error {
+	attempt.DeliveryID = d.ID
+	if err := db.Create(&attempt).Error; err != nil {
+		return err
+	}
+
+	d.Attempts++
+	d.LastStatusCode = attempt.StatusCode
+	d.LastError = attempt.Error
+	switch {
+	case success:
+		now := time.Now()
+		d.Status = DeliverySucceeded
+		d.DeliveredAt = &now
+	case d.Attempts >= WebhookMaxAttempts:
+		d.Status = DeliveryFailed
+	default:
+		d.NextAttemptAt = time.Now().Add(WebhookBackoff(d.Attempts))
+	}
+	return db.Save(d).Error
+}
+
+// Abandon settles the delivery as failed without sending it
+func (d *WebhookDelivery) Abandon(reason string) error {
+	d.Status = DeliveryFailed
+	d.LastError = reason
+	return db.Save(d).Error
+}
+
+// WebhookBackoff is the wait after the given number of failed attempts
+func WebhookBackoff(attempts int) time.Duration {
+	return exponentialBackoff(WebhookBaseBackoff, WebhookMaxBackoff, attempts)
+}
+
+// exponentialBackoff doubles base for every attempt after the first, capped at max
+func exponentialBackoff(base, max time.Duration, attempts int) time.Duration {
+	backoff := base
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= max {
+			return max
+		}
+	}
+	return backoff
+}
+
+// RecordEndpointResult tracks the endpoint's failure streak and disables it
+// once the streak reaches WebhookDisableThreshold. It reports whether the
+// endpoint was disabled by this call.
+func RecordEndpointResult(endpointID uint, success bool) (bool, error) {
+	if success {
+		return false, db.Model(&WebhookEndpoint{}).Where("id = ?", endpointID).
+			Update("consecutive_failures", 0).Error
+	}
+
+	if err := db.Model(&WebhookEndpoint{}).Where("id = ?", endpointID).
+		Update("consecutive_failures", gorm.Expr("consecutive_failures + 1")).Error; err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	result := db.Model(&WebhookEndpoint{}).
+		Where("id = ? AND active = ? AND consecutive_failures >= ?", endpointID, true, WebhookDisableThreshold).
+		Updates(map[string]interface{}{
+			"active":          false,
+			"disabled_at":     now,
+			"disabled_reason": "disabled after repeated delivery failures",
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+// Redeliver queues a fresh delivery of the same event, keeping the event ID
+// so receivers can de-duplicate
+func (d *WebhookDelivery) Redeliver() (*WebhookDelivery, error) {
+	redelivery := WebhookDelivery{
+		EndpointID:    d.EndpointID,
+		EventID:       d.EventID,
+		EventType:     d.EventType,
+		Payload:       d.Payload,
+		Status:        DeliveryPending,
+		NextAttemptAt: time.Now(),
+	}
+	err := redelivery.CreateWebhookDelivery()
+	return &redelivery, err
+}
+
+// SetEventTypes validates and stores the subscribed event types
+func (e *WebhookEndpoint) SetEventTypes(events []string) error {
+	if err := ValidateWebhookEvents(events); err != nil {
+		return err
+	}
+	e.EventTypes = strings.Join(events, ",")
+	return nil
+}
+
+// NewWebhookSecret generates a signing secret, callers store it encrypted
+func NewWebhookSecret() (string, error) {
+	s, err := randString(40)
+	if err != nil {
+		return "", err
+	}
+	return "whsec_" + s, nil
+}
+
+// HurupayEventType maps a Hurupay event such as "payouts.failed" onto our
+// hurupay.payout.failed, successful events become *.completed
+func HurupayEventType(hurupayEvent string) string {
+	kind, outcome, _ := strings.Cut(hurupayEvent, ".")
+	kind = strings.TrimSuffix(kind, "s")
+	switch outcome {
+	case "successful":
+		outcome = "completed"
+	case "failed", "cancelled", "declined", "expired":
+		outcome = "failed"
+	default:
+		outcome = strings.ToLower(outcome)
+	}
+	return "hurupay." + kind + "." + outcome
+}
@@ -0,0 +1,28 @@
+package serializers
+
+// Beneficiary is a payout destination to save, the fields of its kind are
+// required
+type Beneficiary struct {
+	Kind  string `json:"kind" binding:"required,oneof=crypto bank mobile_money"`
+	Label string `json:"label" binding:"max=100"`
+
+	Chain   string `json:"chain"`
+	Address string `json:"address"`
+
+	BankID        *uint  `json:"bank_id"`
+	AccountNumber string `json:"account_number"`
+	AccountName   string `json:"account_name"`
+	AccountType   string `json:"account_type"`
+
+	CountryCode   string `json:"country_code"`
+	MobileNetwork string `json:"mobile_network"`
+	PhoneNumber   string `json:"phone_number"`
+}
+
+type ConfirmBeneficiary struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type Allowlist struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
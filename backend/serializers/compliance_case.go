@@ -0,0 +1,30 @@
+package serializers
+
+// CreateCase opens a case by hand, or from a reconciliation break when
+// reconciliation_item_id is given. Cases block the user unless blocking is
+// false.
+type CreateCase struct {
+	UserID               *uint  `json:"user_id"`
+	TransactionID        *uint  `json:"transaction_id"`
+	ReconciliationItemID *uint  `json:"reconciliation_item_id"`
+	Title                string `json:"title" binding:"required_without=ReconciliationItemID,max=200"`
+	Description          string `json:"description" binding:"max=5000"`
+	Priority             string `json:"priority" binding:"omitempty,oneof=low medium high critical"`
+	Blocking             *bool  `json:"blocking"`
+}
+
+type AssignCase struct {
+	AssigneeID uint `json:"assignee_id" binding:"required"`
+}
+
+type AddCaseNote struct {
+	Body string `json:"body" binding:"required,max=5000"`
+}
+
+// DecideCase closes a case, a report decision carries the regulator's
+// reference
+type DecideCase struct {
+	Decision        string `json:"decision" binding:"required,oneof=clear freeze report"`
+	Note            string `json:"note" binding:"required,max=5000"`
+	ReportReference string `json:"report_reference" binding:"required_if=Decision report,max=200"`
+}
@@ -35,6 +35,8 @@ type KYCFilterRequest struct {
 	UserID          *uint      `form:"user_id,omitempty"`
 	Email           *string    `form:"user_email,omitempty"`
 	Status          *KYCStatus `form:"status,omitempty"`
+	Tier            *int       `form:"tier,omitempty"`
+	TierStatus      *KYCStatus `form:"tier_status,omitempty"`
 	RejectionReason *string    `form:"rejection_reason,omitempty"`
 	CreatedAt       *time.Time `form:"created_at,omitempty"`
 	ApprovedAt      *time.Time `form:"approved_at,omitempty"`
@@ -42,26 +44,58 @@ type KYCFilterRequest struct {
 }
 
 type KYC struct {
-	ID                   uint      `json:"id"`
-	IDType               string    `json:"id_type"`
-	TaxId                string    `json:"tax_id"`
-	IdNumber             string    `json:"id_number"`
-	DateOfBirth          string    `json:"date_of_birth"`
-	IssueDate            string    `json:"issue_date"`
-	ExpiryDate           string    `json:"expiry_date"`
-	FrontPhoto           string    `json:"front_photo"`
-	BackPhoto            string    `json:"back_photo"`
-	Status               KYCStatus `gorm:"default:Pending" json:"status"`
-	Phone                string    `json:"phone"`
-	StreetAddress        string    `json:"street_address"`
-	BorderlessIdentityId string    `json:"borderless_identity_id"`
-	City                 string    `json:"city"`
-	State                string    `json:"state"`
-	PostalCode           string    `json:"postal_code"`
-	Country              string    `json:"country"`
-	RejectionReason      string    `json:"rejection_reason"`
-	CreatedAt            time.Time `json:"created_at"`
-	ApprovedAt           time.Time `json:"approved_at"`
-	RejectedAt           time.Time `json:"rejected_at"`
-	UpdatedAt            time.Time `json:"updated_at"`
+	ID                    uint       `json:"id"`
+	IDType                string     `json:"id_type"`
+	TaxId                 string     `json:"tax_id"`
+	IdNumber              string     `json:"id_number"`
+	DateOfBirth           string     `json:"date_of_birth"`
+	IssueDate             string     `json:"issue_date"`
+	ExpiryDate            string     `json:"expiry_date"`
+	FrontPhoto            string     `json:"front_photo"`
+	BackPhoto             string     `json:"back_photo"`
+	Status                KYCStatus  `gorm:"default:Pending" json:"status"`
+	Phone                 string     `json:"phone"`
+	StreetAddress         string     `json:"street_address"`
+	BorderlessIdentityId  string     `json:"borderless_identity_id"`
+	City                  string     `json:"city"`
+	State                 string     `json:"state"`
+	PostalCode            string     `json:"postal_code"`
+	Country               string     `json:"country"`
+	SourceOfFunds         string     `json:"source_of_funds"`
+	Selfie                string     `json:"selfie,omitempty"`
+	ProofOfAddress        string     `json:"proof_of_address,omitempty"`
+	SourceOfFundsDocument string     `json:"source_of_funds_document,omitempty"`
+	Tier                  int        `json:"tier"`
+	Levels                []KYCLevel `json:"levels"`
+	RejectionReason       string     `json:"rejection_reason"`
+	CreatedAt             time.Time  `json:"created_at"`
+	ApprovedAt            time.Time  `json:"approved_at"`
+	RejectedAt            time.Time  `json:"rejected_at"`
+	UpdatedAt             time.Time  `json:"updated_at"`
+}
+
+// KYCLevel is the review of one tier
+type KYCLevel struct {
+	Tier            int        `json:"tier"`
+	Status          KYCStatus  `json:"status"`
+	RejectionReason string     `json:"rejection_reason,omitempty"`
+	SubmittedAt     time.Time  `json:"submitted_at"`
+	ApprovedAt      *time.Time `json:"approved_at"`
+	RejectedAt      *time.Time `json:"rejected_at"`
+}
+
+// KYCTier is a tier's requirements and where the user stands on it
+type KYCTier struct {
+	Tier            int        `json:"tier"`
+	Name            string     `json:"name"`
+	Fields          []string   `json:"fields"`
+	Documents       []string   `json:"documents"`
+	Status          *KYCStatus `json:"status"` // nil until submitted
+	RejectionReason string     `json:"rejection_reason,omitempty"`
+	Missing         []string   `json:"missing"`
+}
+
+// RejectKYC gives the reason shown to the user
+type RejectKYC struct {
+	RejectionReason string `json:"rejection_reason"`
 }
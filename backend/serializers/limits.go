@@ -0,0 +1,28 @@
+package serializers
+
+import "time"
+
+// LimitAmounts are USD amounts, omitted periods are left to the next level
+type LimitAmounts struct {
+	PerTransaction *float64 `json:"per_transaction" binding:"omitempty,min=0"`
+	Daily          *float64 `json:"daily" binding:"omitempty,min=0"`
+	Monthly        *float64 `json:"monthly" binding:"omitempty,min=0"`
+	Lifetime       *float64 `json:"lifetime" binding:"omitempty,min=0"`
+}
+
+// LimitRule scopes are optional, an empty scope matches every value
+type LimitRule struct {
+	Tier      *int   `json:"tier" binding:"omitempty,min=0,max=3"`
+	Country   string `json:"country" binding:"omitempty,len=2"`
+	Rail      string `json:"rail" binding:"omitempty,oneof=bank mobile_money borderless onchain"`
+	Direction string `json:"direction" binding:"omitempty,oneof=on_ramp off_ramp"`
+	LimitAmounts
+}
+
+type LimitOverride struct {
+	Rail      string     `json:"rail" binding:"omitempty,oneof=bank mobile_money borderless onchain"`
+	Direction string     `json:"direction" binding:"omitempty,oneof=on_ramp off_ramp"`
+	Reason    string     `json:"reason" binding:"required,max=500"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	LimitAmounts
+}
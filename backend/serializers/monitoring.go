@@ -0,0 +1,31 @@
+package serializers
+
+// MonitoringParams are a rule's thresholds, amounts in USD
+type MonitoringParams struct {
+	WindowHours int      `json:"window_hours" binding:"min=0,max=720"`
+	MinAmount   float64  `json:"min_amount" binding:"min=0"`
+	Threshold   float64  `json:"threshold" binding:"min=0"`
+	Margin      float64  `json:"margin" binding:"min=0,max=1"`
+	MinCount    int      `json:"min_count" binding:"min=0"`
+	Ratio       float64  `json:"ratio" binding:"min=0,max=1"`
+	Countries   []string `json:"countries" binding:"max=100,dive,len=2"`
+}
+
+// MonitoringRule creates or replaces a rule, enabled defaults to true
+type MonitoringRule struct {
+	Name        string           `json:"name" binding:"required,max=100"`
+	Type        string           `json:"type" binding:"required,oneof=structuring rapid_in_out many_beneficiaries new_device_high_value high_risk_corridor"`
+	Enabled     *bool            `json:"enabled"`
+	Priority    string           `json:"priority" binding:"required,oneof=low medium high critical"`
+	Blocking    bool             `json:"blocking"`
+	Description string           `json:"description" binding:"max=500"`
+	Params      MonitoringParams `json:"params"`
+}
+
+// Backtest runs a stored rule, or the rule given, over past requests. Dates
+// are YYYY-MM-DD and to is inclusive.
+type Backtest struct {
+	From string          `json:"from" binding:"required,datetime=2006-01-02"`
+	To   string          `json:"to" binding:"required,datetime=2006-01-02"`
+	Rule *MonitoringRule `json:"rule"`
+}
@@ -53,3 +53,12 @@ type Event struct {
 	EventObject    EventObject `json:"event_object"`
 	EventCreatedAt string      `json:"event_created_at"`
 }
+
+// NotificationPreferences updates the per-event setting and the channel
+// order, either may be left out. Email is the per-event setting under its
+// old name, kept for clients that still send it.
+type NotificationPreferences struct {
+	Events   map[string]bool `json:"events"`
+	Email    map[string]bool `json:"email"`
+	Channels []string        `json:"channels" binding:"omitempty,min=1,unique,dive,oneof=email sms whatsapp"`
+}
@@ -0,0 +1,33 @@
+package serializers
+
+type CreatePartner struct {
+	Name         string `json:"name" binding:"required"`
+	ContactEmail string `json:"contact_email" binding:"required,email"`
+	TenantID     *uint  `json:"tenant_id"`
+}
+
+type UpdatePartnerStatus struct {
+	Status string `json:"status" binding:"required,oneof=Active Suspended"`
+}
+
+type PartnerAPIKeyRequest struct {
+	Name       string   `json:"name" binding:"required"`
+	Scopes     []string `json:"scopes" binding:"required"`
+	AllowedIPs []string `json:"allowed_ips"`
+}
+
+type PartnerAPIKeyAccess struct {
+	Scopes     []string `json:"scopes" binding:"required"`
+	AllowedIPs []string `json:"allowed_ips"`
+}
+
+type PartnerUser struct {
+	FirstName   string `json:"first_name" binding:"required"`
+	LastName    string `json:"last_name" binding:"required"`
+	Email       string `json:"email" binding:"required,email"`
+	Currency    string `json:"currency" binding:"required"`
+	Country     string `json:"country" binding:"required"`
+	CountryCode string `json:"country_code" binding:"required"`
+	Chain       string `json:"chain" binding:"required"`
+	Locale      string `json:"locale"`
+}
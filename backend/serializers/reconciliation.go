@@ -0,0 +1,14 @@
+package serializers
+
+// StartReconciliation reconciles one source over a window, dates are
+// YYYY-MM-DD and to is inclusive
+type StartReconciliation struct {
+	Source string `json:"source" binding:"required,oneof=borderless hurupay chain"`
+	From   string `json:"from" binding:"required,datetime=2006-01-02"`
+	To     string `json:"to" binding:"required,datetime=2006-01-02"`
+}
+
+type ResolveReconciliationItem struct {
+	Resolution string `json:"resolution" binding:"required,oneof=corrected accepted false_positive"`
+	Note       string `json:"note" binding:"required,max=1000"`
+}
@@ -0,0 +1,14 @@
+package serializers
+
+// ResolveScreening closes a screening under review, cleared lets the flow go
+// on and confirmed keeps it blocked
+type ResolveScreening struct {
+	Decision string `json:"decision" binding:"required,oneof=cleared confirmed"`
+	Note     string `json:"note" binding:"required,max=1000"`
+}
+
+// CheckScreening matches a name against the lists without recording it
+type CheckScreening struct {
+	Name        string `json:"name" binding:"required,max=200"`
+	DateOfBirth string `json:"date_of_birth"`
+}
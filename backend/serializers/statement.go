@@ -0,0 +1,17 @@
+package serializers
+
+// CreateStatement requests a statement export, dates are YYYY-MM-DD and to is
+// inclusive
+type CreateStatement struct {
+	Format string `json:"format" binding:"required,oneof=csv xlsx pdf"`
+	From   string `json:"from" binding:"required,datetime=2006-01-02"`
+	To     string `json:"to" binding:"required,datetime=2006-01-02"`
+}
+
+// CreateAdminStatement exports the statement of one user or, with
+// all_users, of every user the admin can see
+type CreateAdminStatement struct {
+	CreateStatement
+	UserID   *uint `json:"user_id"`
+	AllUsers bool  `json:"all_users"`
+}
@@ -0,0 +1,20 @@
+package serializers
+
+type Tenant struct {
+	Name             string   `json:"name" binding:"required"`
+	Slug             string   `json:"slug" binding:"required"`
+	Status           string   `json:"status" binding:"omitempty,oneof=Active Suspended"`
+	Domains          []string `json:"domains"`
+	AllowedOrigins   []string `json:"allowed_origins"`
+	EnabledChains    []string `json:"enabled_chains"`
+	EnabledRails     []string `json:"enabled_rails"`
+	BlockedCountries []string `json:"blocked_countries"`
+	EmailSenderName  string   `json:"email_sender_name"`
+	EmailReplyTo     string   `json:"email_reply_to" binding:"omitempty,email"`
+	EmailTemplateSet string   `json:"email_template_set"`
+}
+
+type TenantFeeRule struct {
+	Rail         string `json:"rail" binding:"required"`
+	DeveloperFee string `json:"developer_fee" binding:"required,numeric"`
+}
@@ -8,6 +8,7 @@ type OffRampForm struct {
 	Amount         string `json:"amount"`
 	AccountAddress string `json:"account_address"`
 	Chain          string `json:"chain"`
+	BeneficiaryID  *uint  `json:"beneficiary_id,omitempty"`
 }
 
 type TransferXLM struct {
@@ -108,6 +109,7 @@ type OffRamp struct {
 	AccountNumber  string `json:"accountNumber"`
 	AccountName    string `json:"accountName"`
 	CurrencyCode   string `json:"currencyCode"`
+	BeneficiaryID  *uint  `json:"beneficiaryId,omitempty"`
 }
 
 type Collection struct {
@@ -148,6 +150,7 @@ type MobileOffRamp struct {
 	PhoneNumber    string `json:"phoneNumber"`
 	CountryCode    string `json:"countryCode"`
 	MobileProvider string `json:"mobileProvider"`
+	BeneficiaryID  *uint  `json:"beneficiaryId,omitempty"`
 }
 
 type TransactionDetails struct {
@@ -185,4 +188,5 @@ type MakeWithdrawalBorderless struct {
 	Asset             string `json:"asset,omitempty"`
 	MasterWallet      string `json:"master_wallet,omitempty"`
 	AccountId         string `json:"account_id,omitempty"`
+	BeneficiaryID     *uint  `json:"beneficiary_id,omitempty"`
 }
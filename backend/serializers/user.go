@@ -1,6 +1,9 @@
 package serializers
 
-import "time"
+import (
+	"regexp"
+	"time"
+)
 
 type Chain struct {
 	Celo    string `json:"celo"`
@@ -20,6 +23,18 @@ var Chains = Chain{
 	Polygon: ChainPolygon,
 }
 
+var addressFormats = map[string]*regexp.Regexp{
+	ChainCelo:    regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`),
+	ChainPolygon: regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`),
+	ChainStellar: regexp.MustCompile(`^G[A-Z2-7]{55}$`),
+}
+
+// ValidAddress reports whether the address is well formed on the chain
+func ValidAddress(chain, address string) bool {
+	format := addressFormats[chain]
+	return format != nil && format.MatchString(address)
+}
+
 type User struct {
 	FirstName   string `json:"first_name" binding:"required"`
 	LastName    string `json:"last_name" binding:"required"`
@@ -29,6 +44,11 @@ type User struct {
 	Country     string `json:"country" binding:"required"`
 	CountryCode string `json:"country_code" binding:"required"`
 	Chain       string `json:"chain"`
+	Locale      string `json:"locale"`
+}
+
+type UpdateLocale struct {
+	Locale string `json:"locale" binding:"required,oneof=en fr sw"`
 }
 
 type LoginSerializer struct {
@@ -90,8 +110,51 @@ type UserAccounts struct {
 }
 
 type UserAccountsFilter struct {
-	UserId  *string `json:"user_id"`
-	Asset   *string `json:"asset"`
-	Fiat    *string `json:"fiat"`
-	Country *string `json:"country"`
+	UserId  *string `json:"user_id" form:"user_id"`
+	Asset   *string `json:"asset" form:"asset"`
+	Fiat    *string `json:"fiat" form:"fiat"`
+	Country *string `json:"country" form:"country"`
+}
+
+type VerifyEmail struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ResendVerification struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ForgetPassword struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPassword struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type UnlockAccount struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// UpdateUserStatus moves a user between active, frozen and suspended, closing
+// has its own flow
+type UpdateUserStatus struct {
+	Status string `json:"status" binding:"required,oneof=active frozen suspended"`
+	Reason string `json:"reason" binding:"required,max=1000"`
+}
+
+// Where a closed account's balance is swept
+const (
+	SweepToPlatform    = "platform"
+	SweepToBeneficiary = "beneficiary"
+)
+
+// CloseUser closes the account. A remaining wallet balance is swept to the
+// platform's master wallet of the user's chain, or to BeneficiaryID, one of
+// the user's confirmed crypto beneficiaries on that chain.
+type CloseUser struct {
+	Reason        string `json:"reason" binding:"required,max=1000"`
+	SweepTo       string `json:"sweep_to" binding:"omitempty,oneof=platform beneficiary"`
+	BeneficiaryID uint   `json:"beneficiary_id"`
 }
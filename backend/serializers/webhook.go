@@ -0,0 +1,14 @@
+package serializers
+
+type CreateWebhookEndpoint struct {
+	URL         string   `json:"url" binding:"required,url"`
+	Description string   `json:"description"`
+	EventTypes  []string `json:"event_types"`
+}
+
+type UpdateWebhookEndpoint struct {
+	URL         string   `json:"url" binding:"omitempty,url"`
+	Description *string  `json:"description"`
+	EventTypes  []string `json:"event_types"`
+	Active      *bool    `json:"active"`
+}
@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -27,6 +28,17 @@ type Config struct {
 	// Mail Config
 	EmailUser     string
 	EmailPassword string
+	MailTransport string // smtp, file or console
+	SMTPHost      string
+	SMTPPort      string
+	MailSinkDir   string
+
+	// SMS and WhatsApp Config, channels without credentials are logged
+	// outside production and disabled in it
+	TwilioAccountSid   string
+	TwilioAuthToken    string
+	TwilioSMSFrom      string
+	TwilioWhatsAppFrom string
 
 	// Borderless Config
 	BorderlessClientId         string
@@ -64,8 +76,77 @@ type Config struct {
 
 	// PasswordReset
 	PasswordResetLink string
+
+	// EmailVerification
+	EmailVerificationLink string
+
+	// AccountUnlock
+	AccountUnlockLink string
+
+	// New payout beneficiaries are confirmed through BeneficiaryConfirmLink
+	// and can be paid BeneficiaryCoolingHours after confirmation
+	BeneficiaryConfirmLink  string
+	BeneficiaryCoolingHours int
+
+	// Partner API key signing secrets are kept sealed under PartnerKeySecret,
+	// it must differ from ApiSecret
+	PartnerKeySecret string
+
+	// Statement exports are written to StatementDir and downloaded through
+	// StatementDownloadLink/<token>
+	StatementDir          string
+	StatementDownloadLink string
+
+	// KYC documents are encrypted with DocumentEncryptionKey and kept on the
+	// local filesystem under DocumentDir or in an S3 compatible bucket,
+	// DocumentLink/<id> serves them through URLs signed with
+	// DocumentSigningKey
+	DocumentStorage       string // local or s3
+	DocumentDir           string
+	DocumentEncryptionKey string
+	DocumentSigningKey    string
+	DocumentLink          string
+	S3Endpoint            string
+	S3Region              string
+	S3Bucket              string
+	S3AccessKey           string
+	S3SecretKey           string
+
+	// Sanctions and PEP lists are loaded from files in SanctionsDir. Names
+	// scoring ScreeningThreshold (0 to 1) or more are hits unless both dates
+	// of birth are known and more than ScreeningDOBTolerance years apart.
+	SanctionsDir          string
+	ScreeningThreshold    float64
+	ScreeningDOBTolerance int
+
+	// Records of closed accounts are kept for RecordRetentionYears
+	RecordRetentionYears int
+
+	// Money moving requests without an Idempotency-Key header are refused
+	// when set
+	IdempotencyKeyRequired bool
+
+	// OpenAPIContract set to "log" checks success responses against the
+	// OpenAPI spec and logs drift. Request bodies are always checked.
+	OpenAPIContract string
+
+	// Platform CORS origins and API hosts, tenants add their own in the
+	// database
+	AllowedOrigins []string
+	AllowedHosts   []string
+
+	// TrustedProxies are the proxy addresses or CIDRs whose X-Forwarded-For
+	// is believed, none are trusted by default
+	TrustedProxies []string
+
+	// LogLevel is debug, info, warn or error, LogFormat json or text
+	LogLevel  string
+	LogFormat string
 }
 
+// ContractLog is the OpenAPIContract mode that logs response drift
+const ContractLog = "log"
+
 var AppConfig *Config
 
 // ApiSecret for Jwt signing and validation
@@ -98,6 +179,14 @@ func LoadEnv() {
 		DBTimezone:                 os.Getenv("DB_TIMEZONE"),
 		EmailUser:                  mustGetEnv("EMAIL_USER"),
 		EmailPassword:              mustGetEnv("EMAIL_PASSWORD"),
+		MailTransport:              getEnvOrDefault("MAIL_TRANSPORT", "smtp"),
+		SMTPHost:                   getEnvOrDefault("SMTP_HOST", "smtp.gmail.com"),
+		SMTPPort:                   getEnvOrDefault("SMTP_PORT", "465"),
+		MailSinkDir:                getEnvOrDefault("MAIL_SINK_DIR", "mail-sink"),
+		TwilioAccountSid:           os.Getenv("TWILIO_ACCOUNT_SID"),
+		TwilioAuthToken:            os.Getenv("TWILIO_AUTH_TOKEN"),
+		TwilioSMSFrom:              os.Getenv("TWILIO_SMS_FROM"),
+		TwilioWhatsAppFrom:         os.Getenv("TWILIO_WHATSAPP_FROM"),
 		BorderlessClientId:         mustGetEnv("BORDERLESS_CLIENT_ID"),
 		BorderlessClientSecret:     mustGetEnv("BORDERLESS_CLIENT_SECRET"),
 		BorderlessAccountId:        mustGetEnv("BORDERLESS_ACCOUNT_ID"),
@@ -117,6 +206,41 @@ func LoadEnv() {
 		TokenExpirationInMinutes:   mustGetEnvAsInt("TOKEN_EXPIRATION_IN_MINUTES"),
 		EncryptionKey:              mustGetEnv("ENCRYPTION_KEY"),
 		PasswordResetLink:          mustGetEnv("PASSWORD_RESET_LINK"),
+		EmailVerificationLink:      getEnvOrDefault("EMAIL_VERIFICATION_LINK", "https://wallet.greyboxpay.com/verify-email"),
+		AccountUnlockLink:          getEnvOrDefault("ACCOUNT_UNLOCK_LINK", "https://wallet.greyboxpay.com/unlock-account"),
+		BeneficiaryConfirmLink:     getEnvOrDefault("BENEFICIARY_CONFIRM_LINK", "https://wallet.greyboxpay.com/confirm-beneficiary"),
+		BeneficiaryCoolingHours:    getEnvAsIntOrDefault("BENEFICIARY_COOLING_HOURS", 24),
+		PartnerKeySecret:           mustGetEnv("PARTNER_KEY_SECRET"),
+		StatementDir:               getEnvOrDefault("STATEMENT_DIR", "statements"),
+		StatementDownloadLink:      getEnvOrDefault("STATEMENT_DOWNLOAD_LINK", "https://apis.greyboxpay.com/api/v1/statement-downloads"),
+		DocumentStorage:            getEnvOrDefault("DOCUMENT_STORAGE", "local"),
+		DocumentDir:                getEnvOrDefault("DOCUMENT_DIR", "documents"),
+		DocumentEncryptionKey:      mustGetEnv("DOCUMENT_ENCRYPTION_KEY"),
+		DocumentSigningKey:         mustGetEnv("DOCUMENT_SIGNING_KEY"),
+		DocumentLink:               getEnvOrDefault("DOCUMENT_LINK", "https://apis.greyboxpay.com/api/v2/kyc-documents"),
+		S3Endpoint:                 getEnvOrDefault("S3_ENDPOINT", "https://s3.amazonaws.com"),
+		S3Region:                   getEnvOrDefault("S3_REGION", "us-east-1"),
+		S3Bucket:                   os.Getenv("S3_BUCKET"),
+		S3AccessKey:                os.Getenv("S3_ACCESS_KEY"),
+		S3SecretKey:                os.Getenv("S3_SECRET_KEY"),
+		SanctionsDir:               getEnvOrDefault("SANCTIONS_DIR", "sanctions"),
+		ScreeningThreshold:         getEnvAsFloatOrDefault("SCREENING_THRESHOLD", 0.88),
+		ScreeningDOBTolerance:      getEnvAsIntOrDefault("SCREENING_DOB_TOLERANCE_YEARS", 1),
+		RecordRetentionYears:       getEnvAsIntOrDefault("RECORD_RETENTION_YEARS", 7),
+		IdempotencyKeyRequired:     getEnvOrDefault("IDEMPOTENCY_KEY_REQUIRED", "false") == "true",
+		OpenAPIContract:            os.Getenv("OPENAPI_CONTRACT"),
+		AllowedOrigins:             getEnvAsListOrDefault("ALLOWED_ORIGINS", "http://localhost:3000,https://wallet.greyboxpay.com,https://apis.greyboxpay.com"),
+		AllowedHosts:               getEnvAsListOrDefault("ALLOWED_HOSTS", "localhost:3000,localhost:8080,34.227.150.136,apis.greyboxpay.com,wallet.greyboxpay.com"),
+		TrustedProxies:             getEnvAsListOrDefault("TRUSTED_PROXIES", ""),
+		LogLevel:                   getEnvOrDefault("LOG_LEVEL", "info"),
+		LogFormat:                  getEnvOrDefault("LOG_FORMAT", "json"),
+	}
+
+	if AppConfig.PartnerKeySecret == AppConfig.ApiSecret {
+		log.Fatal("PARTNER_KEY_SECRET must not reuse API_SECRET")
+	}
+	if AppConfig.DocumentSigningKey == AppConfig.ApiSecret {
+		log.Fatal("DOCUMENT_SIGNING_KEY must not reuse API_SECRET")
 	}
 
 	ApiSecret = []byte(AppConfig.ApiSecret)
@@ -130,6 +254,48 @@ func mustGetEnv(key string) string {
 	return value
 }
 
+func getEnvOrDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getEnvAsListOrDefault splits a comma separated variable
+func getEnvAsListOrDefault(key, fallback string) []string {
+	var list []string
+	for _, item := range strings.Split(getEnvOrDefault(key, fallback), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func getEnvAsIntOrDefault(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsedValue, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("Invalid %s value. Error during conversion: %v", key, err)
+	}
+	return parsedValue
+}
+
+func getEnvAsFloatOrDefault(key string, fallback float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsedValue, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Fatalf("Invalid %s value. Error during conversion: %v", key, err)
+	}
+	return parsedValue
+}
+
 func mustGetEnvAsInt(key string) int {
 	value := os.Getenv(key)
 	parsedValue, err := strconv.Atoi(value)
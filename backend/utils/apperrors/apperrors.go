@@ -0,0 +1,251 @@
+// Package apperrors is the error model of the API. Every error response
+// carries a stable code the frontend can branch on, a message that is safe
+// to show and the request ID to quote to support:
+//
+//	{"errors": true, "code": "LIMIT_EXCEEDED", "error": "...", "reason": "daily_limit_exceeded", "data": {...}, "request_id": "..."}
+package apperrors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+// Code is a stable, machine readable error code
+type Code string
+
+const (
+	CodeBadRequest          Code = "BAD_REQUEST"
+	CodeValidationFailed    Code = "VALIDATION_FAILED"
+	CodeUnauthorized        Code = "UNAUTHORIZED"
+	CodeForbidden           Code = "FORBIDDEN"
+	CodeNotFound            Code = "NOT_FOUND"
+	CodeConflict            Code = "CONFLICT"
+	CodeRateLimited         Code = "RATE_LIMITED"
+	CodeKYCRequired         Code = "KYC_REQUIRED"
+	CodeLimitExceeded       Code = "LIMIT_EXCEEDED"
+	CodeAccountRestricted   Code = "ACCOUNT_RESTRICTED"
+	CodeComplianceHold      Code = "COMPLIANCE_HOLD"
+	CodeBeneficiaryRefused  Code = "BENEFICIARY_NOT_ALLOWED"
+	CodeInsufficientFunds   Code = "INSUFFICIENT_FUNDS"
+	CodeQuoteExpired        Code = "QUOTE_EXPIRED"
+	CodeIdempotencyKeyReuse Code = "IDEMPOTENCY_KEY_REUSED"
+	CodeRequestInProgress   Code = "REQUEST_IN_PROGRESS"
+	CodeProviderRejected    Code = "PROVIDER_REJECTED"
+	CodeProviderUnavailable Code = "PROVIDER_UNAVAILABLE"
+	CodeServiceUnavailable  Code = "SERVICE_UNAVAILABLE"
+	CodeInternal            Code = "INTERNAL"
+)
+
+// codes maps each code to its HTTP status and the message shown when the
+// error has none
+var codes = map[Code]struct {
+	status  int
+	message string
+}{
+	CodeBadRequest:          {http.StatusBadRequest, "The request is invalid"},
+	CodeValidationFailed:    {http.StatusBadRequest, "Some fields are missing or invalid"},
+	CodeUnauthorized:        {http.StatusUnauthorized, "Unauthorized"},
+	CodeForbidden:           {http.StatusForbidden, "You don't have permission to perform this action"},
+	CodeNotFound:            {http.StatusNotFound, "record not found"},
+	CodeConflict:            {http.StatusConflict, "The request conflicts with the current state"},
+	CodeRateLimited:         {http.StatusTooManyRequests, "Too many requests, please try again later"},
+	CodeKYCRequired:         {http.StatusForbidden, "Please complete verification to continue"},
+	CodeLimitExceeded:       {http.StatusForbidden, "The amount is over your transaction limit"},
+	CodeAccountRestricted:   {http.StatusForbidden, "Your account cannot perform this action, please contact support"},
+	CodeComplianceHold:      {http.StatusForbidden, "Your account is under compliance review, payments are paused"},
+	CodeBeneficiaryRefused:  {http.StatusForbidden, "Payouts to this beneficiary are not allowed"},
+	CodeInsufficientFunds:   {http.StatusUnprocessableEntity, "Insufficient funds for this transaction"},
+	CodeQuoteExpired:        {http.StatusConflict, "The quote has expired, please request a new one"},
+	CodeIdempotencyKeyReuse: {http.StatusUnprocessableEntity, "The Idempotency-Key was already used with a different request"},
+	CodeRequestInProgress:   {http.StatusConflict, "A request with this Idempotency-Key is still being processed"},
+	CodeProviderRejected:    {http.StatusBadGateway, "Our payment partner refused the request"},
+	CodeProviderUnavailable: {http.StatusServiceUnavailable, "Our payment partner is unavailable, please try again later"},
+	CodeServiceUnavailable:  {http.StatusServiceUnavailable, "The service is temporarily unavailable, please try again later"},
+	CodeInternal:            {http.StatusInternalServerError, "Something went wrong, please try again later"},
+}
+
+// Status is the HTTP status of the code
+func (c Code) Status() int {
+	if info, ok := codes[c]; ok {
+		return info.status
+	}
+	return http.StatusInternalServerError
+}
+
+// Known reports whether the code is one of the codes above
+func (c Code) Known() bool {
+	_, ok := codes[c]
+	return ok
+}
+
+// Codes lists every code, sorted
+func Codes() []Code {
+	list := make([]Code, 0, len(codes))
+	for code := range codes {
+		list = append(list, code)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i] < list[j] })
+	return list
+}
+
+// CodeForStatus is the code of a response that only has a status
+func CodeForStatus(status int) Code {
+	switch {
+	case status == http.StatusBadRequest:
+		return CodeBadRequest
+	case status == http.StatusUnauthorized:
+		return CodeUnauthorized
+	case status == http.StatusForbidden:
+		return CodeForbidden
+	case status == http.StatusNotFound:
+		return CodeNotFound
+	case status == http.StatusConflict:
+		return CodeConflict
+	case status == http.StatusUnprocessableEntity:
+		return CodeValidationFailed
+	case status == http.StatusTooManyRequests:
+		return CodeRateLimited
+	case status == http.StatusBadGateway:
+		return CodeProviderRejected
+	case status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout:
+		return CodeServiceUnavailable
+	case status >= 500:
+		return CodeInternal
+	}
+	return CodeBadRequest
+}
+
+// Error is an API error. Message is shown to the user, Err is the cause and
+// is only logged.
+type Error struct {
+	Code    Code
+	Message string
+	// Reason refines the code, e.g. the limit or the account status
+	Reason string
+	// Detail is shown next to the message, e.g. which field is invalid
+	Detail string
+	Data   interface{}
+	Err    error
+	status int
+	// Provider is the upstream the error came from
+	Provider string
+}
+
+func (e *Error) Error() string {
+	msg := string(e.Code) + ": " + e.Message
+	if e.Provider != "" {
+		msg = e.Provider + ": " + msg
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Status is the HTTP status of the error
+func (e *Error) Status() int {
+	if e.status != 0 {
+		return e.status
+	}
+	return e.Code.Status()
+}
+
+// New is an error with the code and a message, the code's message when empty
+func New(code Code, message string) *Error {
+	if message == "" {
+		message = codes[code].message
+	}
+	return &Error{Code: code, Message: message}
+}
+
+// Wrap is New with the cause, which is logged but not shown
+func Wrap(code Code, err error, message string) *Error {
+	e := New(code, message)
+	e.Err = err
+	return e
+}
+
+// Invalid is a bad request showing the cause as detail, for errors about the
+// request itself such as binding errors
+func Invalid(err error, message string) *Error {
+	e := Wrap(CodeBadRequest, err, message)
+	if err != nil && err.Error() != message {
+		e.Detail = err.Error()
+	}
+	return e
+}
+
+func (e *Error) WithReason(reason string) *Error {
+	e.Reason = reason
+	return e
+}
+
+func (e *Error) WithData(data interface{}) *Error {
+	e.Data = data
+	return e
+}
+
+// WithStatus overrides the code's HTTP status
+func (e *Error) WithStatus(status int) *Error {
+	e.status = status
+	return e
+}
+
+// Upstream providers
+const (
+	ProviderTatum      = "tatum"
+	ProviderBorderless = "borderless"
+	ProviderHurupay    = "hurupay"
+)
+
+// Provider wraps an error of an upstream call. status is the upstream's HTTP
+// status, 0 when no response came back. Timeouts, network errors and 5xx
+// make the provider unavailable, as does a 401 since our credentials are
+// at fault, other statuses are a refusal of the request.
+func Provider(provider string, status int, err error) *Error {
+	var e *Error
+	var netErr net.Error
+	switch {
+	case errors.As(err, &e):
+		if e.Provider == "" {
+			e.Provider = provider
+		}
+		return e
+	case status == 0, status >= 500, status == http.StatusTooManyRequests, status == http.StatusUnauthorized,
+		errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr):
+		e = Wrap(CodeProviderUnavailable, err, "")
+	default:
+		e = Wrap(CodeProviderRejected, err, "")
+		e.Reason = fmt.Sprintf("upstream_status_%d", status)
+	}
+	e.Provider = provider
+	return e
+}
+
+// InsufficientFunds is a provider refusing a transfer for lack of funds
+func InsufficientFunds(provider string, err error) *Error {
+	e := Wrap(CodeInsufficientFunds, err, "")
+	e.Provider = provider
+	return e
+}
+
+// From turns any error into an Error. Errors that are not already one are
+// internal, their message is not shown, except records that do not exist.
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Wrap(CodeNotFound, err, "")
+	}
+	return Wrap(CodeInternal, err, "")
+}
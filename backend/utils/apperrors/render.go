@@ -0,0 +1,58 @@
+package apperrors
+
+import (
+	"backend/utils/requestid"
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Respond answers the request with the error and aborts it. Errors that are
+// not already an Error are internal, their cause is logged with the request
+// ID and not shown.
+func Respond(c *gin.Context, err error) {
+	e := From(err)
+	_ = c.Error(e)
+	logError(c, e)
+	c.AbortWithStatusJSON(e.Status(), Body(c, e))
+}
+
+// Body is the response body of the error
+func Body(c *gin.Context, e *Error) gin.H {
+	body := gin.H{
+		"errors":     true,
+		"code":       e.Code,
+		"error":      e.Message,
+		"request_id": requestid.Get(c),
+	}
+	if e.Reason != "" {
+		body["reason"] = e.Reason
+	}
+	if e.Detail != "" {
+		body["detail"] = e.Detail
+	}
+	if e.Data != nil {
+		body["data"] = e.Data
+	}
+	return body
+}
+
+// logError logs server side and upstream failures, the user only sees their
+// request ID
+func logError(c *gin.Context, e *Error) {
+	if e.Status() < 500 && e.Provider == "" {
+		return
+	}
+	level := slog.LevelWarn
+	if e.Status() >= 500 {
+		level = slog.LevelError
+	}
+	attrs := []interface{}{"method", c.Request.Method, "path", c.Request.URL.Path, "status", e.Status(), "code", e.Code}
+	// provider errors carry the upstream body, which can hold customer data
+	if e.Provider != "" {
+		attrs = append(attrs, "provider", e.Provider)
+	} else {
+		attrs = append(attrs, "error", e.Error())
+	}
+	slog.Log(c.Request.Context(), level, "request failed", attrs...)
+}
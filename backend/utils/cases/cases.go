@@ -0,0 +1,188 @@
+package cases
+
+import (
+	"backend/models"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StartSLAWatcher stamps cases whose SLA ran out every interval
+func StartSLAWatcher(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		breached, err := models.MarkSLABreaches()
+		if err != nil {
+			slog.Error("case SLAs not checked", "error", err)
+			continue
+		}
+		for _, cc := range breached {
+			slog.Warn("case past its SLA", "case_id", cc.ID, "priority", cc.Priority, "due_at", cc.DueAt)
+		}
+	}
+}
+
+// FromScreening opens a blocking case for a screening under review, once per
+// screening
+func FromScreening(screening *models.Screening) (*models.ComplianceCase, error) {
+	ref := strconv.FormatUint(uint64(screening.ID), 10)
+	if existing, err := models.GetOpenCaseFor(models.CaseScreening, ref, nil); err != nil || existing != nil {
+		return existing, err
+	}
+
+	lists := make([]string, 0, len(screening.Matches))
+	for _, match := range screening.Matches {
+		lists = append(lists, fmt.Sprintf("%s %s (%s, %.2f)", match.List, match.Reference, match.MatchedName, match.Score))
+	}
+	priority := models.CasePriorityHigh
+	if screening.Subject == models.ScreeningSubjectUser {
+		priority = models.CasePriorityMedium
+	}
+	cc := &models.ComplianceCase{
+		UserID:      &screening.UserID,
+		Source:      models.CaseScreening,
+		SourceRef:   ref,
+		Title:       fmt.Sprintf("Watchlist hit on %s %q", screening.Subject, screening.Name),
+		Description: fmt.Sprintf("Screening %d during %s hit: %s", screening.ID, screening.Context, strings.Join(lists, "; ")),
+		Priority:    priority,
+		Blocking:    true,
+	}
+	_, err := cc.OpenOnce()
+	return cc, err
+}
+
+// FromLimitBreach notes a refused amount on the user's open case for the
+// limit, opening one on the first refusal. Limit cases are for review and do
+// not block the user, the limit already does.
+func FromLimitBreach(userID uint, code, detail string) error {
+	cc := &models.ComplianceCase{
+		UserID:      &userID,
+		Source:      models.CaseLimitBreach,
+		SourceRef:   code,
+		Title:       "Transaction limit breached: " + strings.ReplaceAll(code, "_", " "),
+		Description: detail,
+		Priority:    models.CasePriorityLow,
+	}
+	opened, err := cc.OpenOnce()
+	if err != nil || opened {
+		return err
+	}
+	_, err = cc.AddNote(nil, detail)
+	return err
+}
+
+// FromMonitoringAlert adds the alert to the user's open case for the rule,
+// opening one of the rule's priority on the first alert
+func FromMonitoringAlert(alert *models.MonitoringAlert, rule *models.MonitoringRule) (*models.ComplianceCase, error) {
+	ref := strconv.FormatUint(uint64(rule.ID), 10)
+	detail := fmt.Sprintf("Alert %d on %s %d: %s", alert.ID, alert.Source, alert.SourceID, alert.Detail)
+	cc := &models.ComplianceCase{
+		UserID:      &alert.UserID,
+		Source:      models.CaseMonitoring,
+		SourceRef:   ref,
+		Title:       "Monitoring alert: " + rule.Name,
+		Description: detail,
+		Priority:    rule.Priority,
+		Blocking:    rule.Blocking,
+	}
+	if alert.Source == "transactions" {
+		cc.TransactionID = &alert.SourceID
+	}
+	opened, err := cc.OpenOnce()
+	if err != nil || opened {
+		return cc, err
+	}
+	_, err = cc.AddNote(nil, detail)
+	return cc, err
+}
+
+// FromReconciliationItem opens a case for a reconciliation break, linked to
+// the wallet transaction when the break is about one
+func FromReconciliationItem(item *models.ReconciliationItem, openedBy uint, priority string, blocking bool) (*models.ComplianceCase, error) {
+	ref := strconv.FormatUint(uint64(item.ID), 10)
+	if existing, err := models.GetOpenCaseFor(models.CaseReconciliation, ref, nil); err != nil || existing != nil {
+		return existing, err
+	}
+	cc := &models.ComplianceCase{
+		UserID:     item.UserID,
+		Source:     models.CaseReconciliation,
+		SourceRef:  ref,
+		Title:      fmt.Sprintf("Reconciliation break %s on %s", strings.ReplaceAll(item.Classification, "_", " "), item.Reference),
+		Priority:   priority,
+		Blocking:   blocking,
+		OpenedByID: &openedBy,
+		Description: fmt.Sprintf("%s item %d: local %s %s, remote %s %s. %s", item.Source, item.ID,
+			item.LocalAmount, item.LocalStatus, item.RemoteAmount, item.RemoteStatus, item.Detail),
+	}
+	if item.LocalTable == "transactions" {
+		cc.TransactionID = item.LocalID
+	}
+	_, err := cc.OpenOnce()
+	return cc, err
+}
+
+// Decide closes the case. A screening case decides its screening too, clear
+// clears the hits and anything else confirms them.
+func Decide(cc *models.ComplianceCase, adminID uint, decision, note, reportReference string) error {
+	if err := closeCase(cc, adminID, decision, note, reportReference); err != nil {
+		return err
+	}
+	if cc.Source != models.CaseScreening {
+		return nil
+	}
+	id, err := strconv.ParseUint(cc.SourceRef, 10, 64)
+	if err != nil {
+		return err
+	}
+	screening, err := models.GetScreening(uint(id))
+	if err != nil {
+		return err
+	}
+	resolution := models.ScreeningConfirmed
+	if decision == models.DecisionClear {
+		resolution = models.ScreeningCleared
+	}
+	if err := screening.Resolve(adminID, resolution, note); err != nil && err != models.ErrScreeningResolved {
+		return err
+	}
+	return nil
+}
+
+// ScreeningResolved closes the case of a screening resolved on its own
+func ScreeningResolved(screening *models.Screening, adminID uint) error {
+	cc, err := models.GetOpenCaseFor(models.CaseScreening, strconv.FormatUint(uint64(screening.ID), 10), nil)
+	if err != nil || cc == nil {
+		return err
+	}
+	// a confirmed user is frozen, a confirmed beneficiary is reported
+	decision := models.DecisionClear
+	if screening.Status == models.ScreeningConfirmed {
+		decision = models.DecisionReport
+		if screening.Subject == models.ScreeningSubjectUser {
+			decision = models.DecisionFreeze
+		}
+	}
+	return closeCase(cc, adminID, decision, "Screening resolved: "+screening.ReviewNote, "")
+}
+
+// closeCase decides the case, a freeze decision freezes the user
+func closeCase(cc *models.ComplianceCase, adminID uint, decision, note, reportReference string) error {
+	if err := cc.Decide(adminID, decision, note, reportReference); err != nil {
+		return err
+	}
+	if decision != models.DecisionFreeze || cc.UserID == nil {
+		return nil
+	}
+	user, err := models.GetUserByID(*cc.UserID)
+	if err != nil {
+		return err
+	}
+	err = user.SetStatus(models.UserFrozen, fmt.Sprintf("Compliance case %d: %s", cc.ID, note), &adminID)
+	if err != nil && err != models.ErrUserClosed {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package documents
+
+import (
+	"backend/models"
+	"backend/state"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+var ErrIntegrity = errors.New("document failed its integrity check")
+
+// Blob is encrypted content held by a storage backend, the hash is of the
+// plaintext
+type Blob struct {
+	Backend string
+	Key     string
+	Size    int64
+	SHA256  string
+}
+
+// Put encrypts the content into the configured storage under the prefix
+func Put(prefix string, content []byte) (Blob, error) {
+	backend := state.AppConfig.DocumentStorage
+	s, err := store(backend)
+	if err != nil {
+		return Blob{}, err
+	}
+	sealed, err := seal(content)
+	if err != nil {
+		return Blob{}, err
+	}
+	name := make([]byte, 16)
+	if _, err := rand.Read(name); err != nil {
+		return Blob{}, err
+	}
+
+	blob := Blob{
+		Backend: backend,
+		Key:     prefix + "/" + hex.EncodeToString(name),
+		Size:    int64(len(content)),
+		SHA256:  sha256Hex(content),
+	}
+	return blob, s.Put(blob.Key, sealed)
+}
+
+// Get returns the decrypted content, failing when it does not match the
+// hash taken when it was put
+func Get(blob Blob) ([]byte, error) {
+	s, err := store(blob.Backend)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := s.Get(blob.Key)
+	if err != nil {
+		return nil, err
+	}
+	content, err := open(sealed)
+	if err != nil {
+		return nil, ErrIntegrity
+	}
+	if sha256Hex(content) != blob.SHA256 {
+		return nil, ErrIntegrity
+	}
+	return content, nil
+}
+
+// Remove deletes the blob from its storage
+func Remove(blob Blob) error {
+	s, err := store(blob.Backend)
+	if err != nil {
+		return err
+	}
+	return s.Delete(blob.Key)
+}
+
+// Stage encrypts the content into the configured storage and returns the
+// document to record for it. Discard it when recording fails.
+func Stage(userID uint, documentType string, content []byte, contentType string) (*models.KYCDocument, error) {
+	blob, err := Put(fmt.Sprintf("kyc/%d/%s", userID, documentType), content)
+	if err != nil {
+		return nil, err
+	}
+	return &models.KYCDocument{
+		UserID:      userID,
+		Type:        documentType,
+		Backend:     blob.Backend,
+		StorageKey:  blob.Key,
+		ContentType: contentType,
+		Size:        blob.Size,
+		SHA256:      blob.SHA256,
+	}, nil
+}
+
+// Save stages the content and records it as the user's document of the
+// type, removing the content of the document it replaces
+func Save(userID uint, documentType string, content []byte, contentType string) (*models.KYCDocument, error) {
+	document, err := Stage(userID, documentType, content, contentType)
+	if err != nil {
+		return nil, err
+	}
+	replaced, err := document.CreateKYCDocument()
+	if err != nil {
+		Discard(document)
+		return nil, err
+	}
+	Purge(replaced)
+	return document, nil
+}
+
+// Discard removes the content of staged documents that were never recorded
+func Discard(staged ...*models.KYCDocument) {
+	for _, document := range staged {
+		purge(document)
+	}
+}
+
+// Purge removes the content of documents whose rows are deleted. A failure
+// only leaves an unreadable blob behind, so it is logged.
+func Purge(deleted []models.KYCDocument) {
+	for i := range deleted {
+		purge(&deleted[i])
+	}
+}
+
+func purge(document *models.KYCDocument) {
+	if err := Remove(Blob{Backend: document.Backend, Key: document.StorageKey}); err != nil {
+		slog.Warn("kyc document content not removed", "document_id", document.ID, "user_id", document.UserID, "error", err)
+	}
+}
+
+// DeleteUserDocuments deletes every document of the user with its content
+func DeleteUserDocuments(userID uint) error {
+	deleted, err := models.DeleteUserKYCDocuments(userID)
+	if err != nil {
+		return err
+	}
+	Purge(deleted)
+	return nil
+}
+
+// Read returns the decrypted content of the document
+func Read(document *models.KYCDocument) ([]byte, error) {
+	return Get(Blob{Backend: document.Backend, Key: document.StorageKey, Size: document.Size, SHA256: document.SHA256})
+}
+
+// KYCImage returns the user's document for the KYC form field as a data URL,
+// from storage or from the KYCData columns before they are migrated
+func KYCImage(userID uint, field string) (string, error) {
+	document, err := models.GetUserKYCDocument(userID, models.KYCDocumentFields[field])
+	if err == nil {
+		content, err := Read(document)
+		if err != nil {
+			return "", err
+		}
+		return DataURL(document.ContentType, content), nil
+	}
+
+	kycData, err := models.GetKYCDataByUserId(userID)
+	if err != nil {
+		return "", err
+	}
+	if value := kycData.Document(field); value != nil && *value != "" {
+		return *value, nil
+	}
+	return "", fmt.Errorf("no %s document", field)
+}
+
+func DataURL(contentType string, content []byte) string {
+	return fmt.Sprintf("data:%s;base64,%s", contentType, base64.StdEncoding.EncodeToString(content))
+}
+
+// ParseDataURL splits a base64 data URL into its content type and content
+func ParseDataURL(value string) (string, []byte, error) {
+	header, data, found := strings.Cut(value, ",")
+	if !found || !strings.HasPrefix(header, "data:") || !strings.HasSuffix(header, ";base64") {
+		return "", nil, errors.New("not a base64 data URL")
+	}
+	content, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return "", nil, err
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(header, "data:"), ";base64"), content, nil
+}
+
+// seal encrypts with AES-GCM under DocumentEncryptionKey, the nonce leads
+// the ciphertext
+func seal(content []byte) ([]byte, error) {
+	aead, err := documentCipher()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, content, nil), nil
+}
+
+func open(sealed []byte) ([]byte, error) {
+	aead, err := documentCipher()
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
+
+func documentCipher() (cipher.AEAD, error) {
+	key, err := base64.StdEncoding.DecodeString(state.AppConfig.DocumentEncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid document encryption key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
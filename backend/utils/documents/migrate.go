@@ -0,0 +1,52 @@
+package documents
+
+import (
+	"backend/models"
+	"fmt"
+	"log"
+	"sort"
+)
+
+// MigrateKYCData moves the documents still held in KYCData columns into
+// document storage, clearing each column once its document is saved. It
+// can be run again after a failure.
+func MigrateKYCData() (int, error) {
+	rows, err := models.GetLegacyKYCData()
+	if err != nil {
+		return 0, err
+	}
+
+	fields := make([]string, 0, len(models.KYCDocumentFields))
+	for field := range models.KYCDocumentFields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	moved := 0
+	for i := range rows {
+		row := &rows[i]
+		for _, field := range fields {
+			value := row.Document(field)
+			if value == nil || *value == "" {
+				continue
+			}
+
+			// a newer upload already in storage wins over the old column
+			if _, err := models.GetUserKYCDocument(row.UserID, models.KYCDocumentFields[field]); err != nil {
+				contentType, content, err := ParseDataURL(*value)
+				if err != nil {
+					log.Printf("documents: skipping %s of user %d: %v", field, row.UserID, err)
+					continue
+				}
+				if _, err := Save(row.UserID, models.KYCDocumentFields[field], content, contentType); err != nil {
+					return moved, fmt.Errorf("moving %s of user %d: %w", field, row.UserID, err)
+				}
+				moved++
+			}
+			if err := row.ClearKYCDataDocument(field); err != nil {
+				return moved, err
+			}
+		}
+	}
+	return moved, nil
+}
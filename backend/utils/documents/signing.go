@@ -0,0 +1,118 @@
+package documents
+
+import (
+	"backend/models"
+	"backend/state"
+	"crypto/hmac"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// URLTTL is how long a signed document URL works
+const URLTTL = 5 * time.Minute
+
+var (
+	ErrInvalidSignature = errors.New("invalid document signature")
+	ErrURLExpired       = errors.New("document link has expired")
+)
+
+// SignedURL is a short lived link to the document for the viewer
+type SignedURL struct {
+	DocumentID  uint      `json:"document_id"`
+	Type        string    `json:"type"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	URL         string    `json:"url"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// Access identifies who an URL was issued to or used by, for the log
+type Access struct {
+	ViewerID  uint
+	IPAddress string
+	UserAgent string
+}
+
+func signature(documentID, viewerID uint, expires int64) string {
+	message := fmt.Sprintf("kyc-document:%d:%d:%d", documentID, viewerID, expires)
+	return hex.EncodeToString(hmacSHA256([]byte(state.AppConfig.DocumentSigningKey), message))
+}
+
+// Sign issues a signed URL for the document and logs it
+func Sign(document *models.KYCDocument, access Access) (SignedURL, error) {
+	expiresAt := time.Now().Add(URLTTL)
+	query := url.Values{}
+	query.Set("viewer", strconv.FormatUint(uint64(access.ViewerID), 10))
+	query.Set("expires", strconv.FormatInt(expiresAt.Unix(), 10))
+	query.Set("signature", signature(document.ID, access.ViewerID, expiresAt.Unix()))
+
+	err := models.LogKYCDocumentAccess(&models.KYCDocumentAccess{
+		DocumentID: document.ID, ViewerID: access.ViewerID, Action: models.DocumentURLIssued,
+		IPAddress: access.IPAddress, UserAgent: access.UserAgent,
+	})
+	if err != nil {
+		return SignedURL{}, err
+	}
+	return SignedURL{
+		DocumentID:  document.ID,
+		Type:        document.Type,
+		ContentType: document.ContentType,
+		Size:        document.Size,
+		SHA256:      document.SHA256,
+		URL:         fmt.Sprintf("%s/%d?%s", state.AppConfig.DocumentLink, document.ID, query.Encode()),
+		ExpiresAt:   expiresAt,
+	}, nil
+}
+
+// SignAll issues signed URLs for every current document of the user
+func SignAll(userID uint, access Access) ([]SignedURL, error) {
+	documents, err := models.GetUserKYCDocuments(userID)
+	if err != nil {
+		return nil, err
+	}
+	urls := make([]SignedURL, 0, len(documents))
+	for i := range documents {
+		signed, err := Sign(&documents[i], access)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, signed)
+	}
+	return urls, nil
+}
+
+// Verify checks a signed URL's query and logs its use, denied or not. The
+// viewer in access is filled from the query.
+func Verify(documentID uint, viewer, expires, sig string, access Access) error {
+	viewerID, viewerErr := strconv.ParseUint(viewer, 10, 64)
+	expiresAt, expiresErr := strconv.ParseInt(expires, 10, 64)
+	access.ViewerID = uint(viewerID)
+
+	err := ErrInvalidSignature
+	switch {
+	case viewerErr != nil || expiresErr != nil:
+	case !hmac.Equal([]byte(sig), []byte(signature(documentID, access.ViewerID, expiresAt))):
+	case time.Now().Unix() > expiresAt:
+		err = ErrURLExpired
+	default:
+		err = nil
+	}
+
+	action := models.DocumentViewed
+	if err != nil {
+		action = models.DocumentDenied
+	}
+	logErr := models.LogKYCDocumentAccess(&models.KYCDocumentAccess{
+		DocumentID: documentID, ViewerID: access.ViewerID, Action: action,
+		IPAddress: access.IPAddress, UserAgent: access.UserAgent,
+	})
+	if err != nil {
+		return err
+	}
+	return logErr
+}
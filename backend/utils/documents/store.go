@@ -0,0 +1,87 @@
+package documents
+
+import (
+	"backend/state"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Storage backends
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+)
+
+// Store keeps document blobs by key, blobs are already encrypted
+type Store interface {
+	Put(key string, data []byte) error
+	Get(key string) ([]byte, error)
+	Delete(key string) error
+}
+
+// store returns the backend a document was written to
+func store(backend string) (Store, error) {
+	switch backend {
+	case BackendLocal:
+		return localStore{root: state.AppConfig.DocumentDir}, nil
+	case BackendS3:
+		config := state.AppConfig
+		if config.S3Bucket == "" || config.S3AccessKey == "" || config.S3SecretKey == "" {
+			return nil, errors.New("S3 document storage is not configured")
+		}
+		return s3Store{
+			endpoint:  strings.TrimRight(config.S3Endpoint, "/"),
+			region:    config.S3Region,
+			bucket:    config.S3Bucket,
+			accessKey: config.S3AccessKey,
+			secretKey: config.S3SecretKey,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown document storage %q", backend)
+}
+
+// localStore keeps documents under a directory only the service can read
+type localStore struct {
+	root string
+}
+
+func (s localStore) path(key string) (string, error) {
+	path := filepath.Join(s.root, filepath.FromSlash(key))
+	if !strings.HasPrefix(path, filepath.Clean(s.root)+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid document key %q", key)
+	}
+	return path, nil
+}
+
+func (s localStore) Put(key string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+func (s localStore) Get(key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+func (s localStore) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
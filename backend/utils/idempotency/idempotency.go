@@ -0,0 +1,84 @@
+package idempotency
+
+import (
+	"backend/models"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// TTL is how long a key is remembered, a retry after that runs again
+const TTL = 24 * time.Hour
+
+// contextKey holds the user's Idempotency-Key on the gin context
+const contextKey = "idempotency_key"
+
+// Set records the user's key of the request for ProviderKey
+func Set(c *gin.Context, userID uint, key string) {
+	c.Set(contextKey, fmt.Sprintf("%d:%s", userID, key))
+}
+
+// submittedKey is set on the gin context once the request handed money to
+// a provider
+const submittedKey = "idempotency_submitted"
+
+// MarkSubmitted records that the request is about to submit a transfer or
+// payout to a provider that takes no idempotency key. A failure after it may
+// have moved money, so the key is kept failed rather than freed for a retry.
+func MarkSubmitted(c *gin.Context) {
+	c.Set(submittedKey, true)
+}
+
+// Submitted reports whether the request reached MarkSubmitted
+func Submitted(c *gin.Context) bool {
+	return c.GetBool(submittedKey)
+}
+
+// ProviderKey is the idempotency key to send a provider for the request. It
+// is derived from the client's key and user, so a retry sends the provider
+// the same key. Requests without a key get a fresh one.
+func ProviderKey(c *gin.Context) string {
+	if key := c.GetString(contextKey); key != "" {
+		return uuid.NewSHA1(uuid.NameSpaceURL, []byte("greybox:idempotency:"+key)).String()
+	}
+	return uuid.NewString()
+}
+
+// RequestHash identifies a request's method, path and body. JSON bodies are
+// compared by value, so the order of their fields does not matter.
+func RequestHash(method, path string, body []byte) string {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err == nil {
+		if canonical, err := json.Marshal(value); err == nil {
+			body = canonical
+		}
+	} else {
+		body = bytes.TrimSpace(body)
+	}
+	sum := sha256.New()
+	sum.Write([]byte(method + " " + path + "\n"))
+	sum.Write(body)
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// StartCleaner removes expired keys, and the partner request nonces kept
+// for the same replay protection, every interval
+func StartCleaner(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if _, err := models.DeleteExpiredIdempotencyKeys(); err != nil {
+			slog.Error("expired idempotency keys not removed", "error", err)
+		}
+		if _, err := models.DeleteExpiredPartnerNonces(); err != nil {
+			slog.Error("expired partner nonces not removed", "error", err)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package limits
+
+import (
+	"backend/models"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Error codes returned when a request is over a limit
+const (
+	CodeKYCRequired            = "kyc_required"
+	CodePerTransactionExceeded = "per_transaction_limit_exceeded"
+	CodeDailyExceeded          = "daily_limit_exceeded"
+	CodeMonthlyExceeded        = "monthly_limit_exceeded"
+	CodeLifetimeExceeded       = "lifetime_limit_exceeded"
+)
+
+var exceededCodes = map[string]string{
+	models.LimitPerTransaction: CodePerTransactionExceeded,
+	models.LimitDaily:          CodeDailyExceeded,
+	models.LimitMonthly:        CodeMonthlyExceeded,
+	models.LimitLifetime:       CodeLifetimeExceeded,
+}
+
+// Where an effective limit comes from
+const (
+	SourceOverride = "override"
+	SourceRule     = "rule"
+	SourceDefault  = "default"
+)
+
+func amount(v float64) *float64 {
+	return &v
+}
+
+// tierDefaults apply to periods no rule or override sets. Users without
+// approved KYC cannot move money until an admin allows it.
+var tierDefaults = map[int]models.LimitAmounts{
+	models.KYCTier0: {PerTransaction: amount(0)},
+	models.KYCTier1: {PerTransaction: amount(1000), Daily: amount(2000), Monthly: amount(10000)},
+	models.KYCTier2: {PerTransaction: amount(10000), Daily: amount(25000), Monthly: amount(100000)},
+	models.KYCTier3: {PerTransaction: amount(50000), Daily: amount(100000), Monthly: amount(500000)},
+}
+
+// Limit is one period of a user's limits on a rail and direction, amounts
+// are in USD and a nil Limit means unlimited
+type Limit struct {
+	Period    string     `json:"period"`
+	Limit     *float64   `json:"limit"`
+	Used      float64    `json:"used"`
+	Remaining *float64   `json:"remaining"`
+	Source    string     `json:"source,omitempty"`
+	ResetsAt  *time.Time `json:"resets_at,omitempty"`
+}
+
+// Status is a user's limits on one rail and direction
+type Status struct {
+	Rail      string  `json:"rail"`
+	Direction string  `json:"direction"`
+	Tier      int     `json:"tier"`
+	Limits    []Limit `json:"limits"`
+}
+
+// Error is a request over one of the user's limits
+type Error struct {
+	Code      string  `json:"code"`
+	Period    string  `json:"period"`
+	Limit     float64 `json:"limit"`
+	Used      float64 `json:"used"`
+	Remaining float64 `json:"remaining"`
+	Requested float64 `json:"requested"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == CodeKYCRequired {
+		return "Complete KYC verification to use this payment method"
+	}
+	return fmt.Sprintf("This request of %.2f USD exceeds your %s limit, %.2f USD remaining",
+		e.Requested, periodName(e.Period), e.Remaining)
+}
+
+func periodName(period string) string {
+	if period == models.LimitPerTransaction {
+		return "per transaction"
+	}
+	return period
+}
+
+// Scopes are the rail and direction pairs limits are reported for
+func Scopes() [][2]string {
+	var scopes [][2]string
+	for _, rail := range models.LimitRails {
+		for _, direction := range models.LimitDirections {
+			if rail == models.RailOnchain && direction == models.LimitOnRamp {
+				continue
+			}
+			scopes = append(scopes, [2]string{rail, direction})
+		}
+	}
+	return scopes
+}
+
+type effective struct {
+	limit  *float64
+	source string
+}
+
+// resolve picks each period's limit: the most specific override, else the
+// platform limit
+func resolve(user models.User, tier int, rail, direction string) (map[string]effective, error) {
+	overrides, err := models.GetMatchingLimitOverrides(user.ID, rail, direction)
+	if err != nil {
+		return nil, err
+	}
+	result, err := platform(user, tier, rail, direction)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, period := range models.LimitPeriods {
+		best, bestRank := (*float64)(nil), -1
+		for _, o := range overrides {
+			if value := o.Get(period); value != nil && o.Specificity() > bestRank {
+				best, bestRank = value, o.Specificity()
+			}
+		}
+		if best != nil {
+			result[period] = effective{best, SourceOverride}
+		}
+	}
+	return result, nil
+}
+
+// platform picks each period's limit without the user's overrides: the most
+// specific rule with ties going to the lower limit, else the tier default
+func platform(user models.User, tier int, rail, direction string) (map[string]effective, error) {
+	rules, err := models.GetMatchingLimitRules(tier, user.CountryCode, rail, direction)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]effective, len(models.LimitPeriods))
+	for _, period := range models.LimitPeriods {
+		best, bestRank := (*float64)(nil), -1
+		for _, r := range rules {
+			value := r.Get(period)
+			if value == nil {
+				continue
+			}
+			if rank := r.Specificity(); rank > bestRank || (rank == bestRank && *value < *best) {
+				best, bestRank = value, rank
+			}
+		}
+		if best != nil {
+			result[period] = effective{best, SourceRule}
+			continue
+		}
+
+		if value := tierDefaults[tier].Get(period); value != nil {
+			result[period] = effective{value, SourceDefault}
+		}
+	}
+	return result, nil
+}
+
+// windowStart is when the period's usage starts counting, zero for lifetime
+func windowStart(period string, now time.Time) time.Time {
+	switch period {
+	case models.LimitDaily:
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	case models.LimitMonthly:
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	}
+	return time.Time{}
+}
+
+func resetsAt(period string, now time.Time) *time.Time {
+	var next time.Time
+	switch period {
+	case models.LimitDaily:
+		next = windowStart(period, now).AddDate(0, 0, 1)
+	case models.LimitMonthly:
+		next = windowStart(period, now).AddDate(0, 1, 0)
+	default:
+		return nil
+	}
+	return &next
+}
+
+// usage sums the user's requests in the period's window in USD
+func usage(userID uint, rail, direction, period string, now time.Time) (float64, error) {
+	totals, err := models.LimitUsage(userID, rail, direction, windowStart(period, now))
+	if err != nil {
+		return 0, err
+	}
+	var used float64
+	for _, total := range totals {
+		usd, err := ToUSD(total.Total, total.Currency)
+		if err != nil {
+			return 0, err
+		}
+		used += usd
+	}
+	return used, nil
+}
+
+// GetStatus reports the user's limits, usage and what remains on the rail
+// and direction
+func GetStatus(user models.User, rail, direction string) (*Status, error) {
+	tier := models.KYCTier(user.ID)
+	limits, err := resolve(user, tier, rail, direction)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	status := &Status{Rail: rail, Direction: direction, Tier: tier}
+	for _, period := range models.LimitPeriods {
+		l := Limit{Period: period, Limit: limits[period].limit, Source: limits[period].source}
+		if period != models.LimitPerTransaction {
+			if l.Used, err = usage(user.ID, rail, direction, period, now); err != nil {
+				return nil, err
+			}
+			l.ResetsAt = resetsAt(period, now)
+		}
+		if l.Limit != nil {
+			remaining := max(*l.Limit-l.Used, 0)
+			l.Remaining = &remaining
+		}
+		status.Limits = append(status.Limits, l)
+	}
+	return status, nil
+}
+
+// Reservation holds the amount of a request that passed Check against the
+// user's limits until it is released or expires
+type Reservation struct {
+	id uint
+}
+
+// Release lets the reserved amount go once the request is recorded, where
+// it counts on its own, or has failed
+func (r *Reservation) Release() {
+	if r == nil {
+		return
+	}
+	if err := models.ReleaseLimitReservation(r.id); err != nil {
+		slog.Error("limit reservation not released", "reservation_id", r.id, "error", err)
+	}
+}
+
+// Check returns an *Error when a new request of the amount would take the
+// user over a limit on the rail and direction, other errors mean the limits
+// could not be worked out. A request within the limits is reserved, so the
+// user's concurrent requests count it until the reservation is released.
+func Check(user models.User, rail, direction string, requested float64, currency string) (*Reservation, error) {
+	usd, err := ToUSD(requested, currency)
+	if err != nil {
+		return nil, err
+	}
+	reservation := &models.LimitReservation{UserID: user.ID, Rail: rail, Direction: direction, AmountUSD: usd}
+	err = models.ReserveLimit(reservation, func(reserved float64) error {
+		status, err := GetStatus(user, rail, direction)
+		if err != nil {
+			return err
+		}
+		return exceeded(status, usd, reserved)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Reservation{id: reservation.ID}, nil
+}
+
+// exceeded returns the first limit of the status the amount goes over, with
+// the reserved amount counted as used
+func exceeded(status *Status, usd, reserved float64) error {
+	for _, l := range status.Limits {
+		if l.Limit == nil {
+			continue
+		}
+		used := l.Used
+		if l.Period != models.LimitPerTransaction {
+			used += reserved
+		}
+		remaining := max(*l.Limit-used, 0)
+		if usd <= remaining {
+			continue
+		}
+		code := exceededCodes[l.Period]
+		if *l.Limit == 0 && status.Tier == models.KYCTier0 {
+			code = CodeKYCRequired
+		}
+		return &Error{
+			Code: code, Period: l.Period, Limit: *l.Limit, Used: used, Remaining: remaining, Requested: usd,
+		}
+	}
+	return nil
+}
+
+// CapOverride returns an error when the override sets a limit above the
+// platform limit of the user on any rail and direction it covers, tenant
+// admins may only lower limits
+func CapOverride(user models.User, o models.LimitOverride) error {
+	tier := models.KYCTier(user.ID)
+	for _, scope := range Scopes() {
+		if (o.Rail != "" && scope[0] != o.Rail) || (o.Direction != "" && scope[1] != o.Direction) {
+			continue
+		}
+		limits, err := platform(user, tier, scope[0], scope[1])
+		if err != nil {
+			return err
+		}
+		for _, period := range models.LimitPeriods {
+			value, limit := o.Get(period), limits[period].limit
+			if value != nil && limit != nil && *value > *limit {
+				return fmt.Errorf("the %s limit on %s %s cannot exceed the platform limit of %.2f USD",
+					periodName(period), scope[0], scope[1], *limit)
+			}
+		}
+	}
+	return nil
+}
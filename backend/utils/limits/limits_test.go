@@ -0,0 +1,446 @@
+package limits
+
+import (
+	"backend/models"
+	"backend/state"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// setupLimitsDB runs the models on SQLite in a temporary directory with a
+// Nigerian user who has tier 1 approved
+func setupLimitsDB(t *testing.T) (*gorm.DB, models.User) {
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	state.AppConfig = &state.Config{AppEnv: "test"}
+	db := models.InitializeDB()
+	if err := models.Migrate(db, models.Tables()...); err != nil {
+		t.Fatal(err)
+	}
+
+	user := models.User{Email: "ada@example.com", CountryCode: "NG", Status: models.UserActive}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	level := models.KYCLevel{UserID: user.ID, Tier: models.KYCTier1, Status: models.Approved, SubmittedAt: time.Now()}
+	if err := db.Create(&level).Error; err != nil {
+		t.Fatal(err)
+	}
+	return db, user
+}
+
+func tierOf(tier int) *int {
+	return &tier
+}
+
+// limitsOf flattens effective limits to amounts and sources, -1 for none
+func limitsOf(result map[string]effective) (map[string]float64, map[string]string) {
+	amounts, sources := map[string]float64{}, map[string]string{}
+	for _, period := range models.LimitPeriods {
+		amounts[period], sources[period] = -1, ""
+		if e, ok := result[period]; ok && e.limit != nil {
+			amounts[period], sources[period] = *e.limit, e.source
+		}
+	}
+	return amounts, sources
+}
+
+func TestPlatform(t *testing.T) {
+	db, user := setupLimitsDB(t)
+
+	cases := []struct {
+		name   string
+		rules  []models.LimitRule
+		tier   int
+		period string
+		want   float64
+		source string
+	}{
+		{
+			name:   "tier default without rules",
+			tier:   models.KYCTier1,
+			period: models.LimitDaily,
+			want:   2000,
+			source: SourceDefault,
+		},
+		{
+			name:   "tier 0 cannot move money",
+			tier:   models.KYCTier0,
+			period: models.LimitPerTransaction,
+			want:   0,
+			source: SourceDefault,
+		},
+		{
+			name:   "rule replaces the default",
+			rules:  []models.LimitRule{{LimitAmounts: models.LimitAmounts{Daily: amount(500)}}},
+			tier:   models.KYCTier1,
+			period: models.LimitDaily,
+			want:   500,
+			source: SourceRule,
+		},
+		{
+			name: "more specific rule wins even when higher",
+			rules: []models.LimitRule{
+				{LimitAmounts: models.LimitAmounts{Daily: amount(500)}},
+				{Rail: models.RailBank, LimitAmounts: models.LimitAmounts{Daily: amount(5000)}},
+			},
+			tier:   models.KYCTier1,
+			period: models.LimitDaily,
+			want:   5000,
+			source: SourceRule,
+		},
+		{
+			name: "tie goes to the lower limit",
+			rules: []models.LimitRule{
+				{Country: "NG", LimitAmounts: models.LimitAmounts{Daily: amount(700)}},
+				{Rail: models.RailBank, LimitAmounts: models.LimitAmounts{Daily: amount(300)}},
+				{Tier: tierOf(models.KYCTier1), LimitAmounts: models.LimitAmounts{Daily: amount(900)}},
+			},
+			tier:   models.KYCTier1,
+			period: models.LimitDaily,
+			want:   300,
+			source: SourceRule,
+		},
+		{
+			name: "rules of another tier, country or rail do not apply",
+			rules: []models.LimitRule{
+				{Tier: tierOf(models.KYCTier2), LimitAmounts: models.LimitAmounts{Daily: amount(10)}},
+				{Country: "KE", LimitAmounts: models.LimitAmounts{Daily: amount(20)}},
+				{Rail: models.RailMobileMoney, LimitAmounts: models.LimitAmounts{Daily: amount(30)}},
+				{Direction: models.LimitOnRamp, LimitAmounts: models.LimitAmounts{Daily: amount(40)}},
+			},
+			tier:   models.KYCTier1,
+			period: models.LimitDaily,
+			want:   2000,
+			source: SourceDefault,
+		},
+		{
+			name:   "rule without the period leaves the default",
+			rules:  []models.LimitRule{{Rail: models.RailBank, LimitAmounts: models.LimitAmounts{Daily: amount(100)}}},
+			tier:   models.KYCTier1,
+			period: models.LimitMonthly,
+			want:   10000,
+			source: SourceDefault,
+		},
+		{
+			name:   "no default and no rule is unlimited",
+			tier:   models.KYCTier1,
+			period: models.LimitLifetime,
+			want:   -1,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := db.Unscoped().Where("1 = 1").Delete(&models.LimitRule{}).Error; err != nil {
+				t.Fatal(err)
+			}
+			for _, rule := range tc.rules {
+				if err := rule.CreateLimitRule(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			result, err := platform(user, tc.tier, models.RailBank, models.LimitOffRamp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			amounts, sources := limitsOf(result)
+			if amounts[tc.period] != tc.want || sources[tc.period] != tc.source {
+				t.Errorf("%s limit = %v from %q, want %v from %q", tc.period, amounts[tc.period], sources[tc.period], tc.want, tc.source)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	db, user := setupLimitsDB(t)
+	if err := (&models.LimitRule{Rail: models.RailBank, LimitAmounts: models.LimitAmounts{Daily: amount(800), Monthly: amount(3000)}}).CreateLimitRule(); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Hour)
+
+	cases := []struct {
+		name      string
+		overrides []models.LimitOverride
+		period    string
+		want      float64
+		source    string
+	}{
+		{
+			name:   "rule without overrides",
+			period: models.LimitDaily,
+			want:   800,
+			source: SourceRule,
+		},
+		{
+			name:      "override replaces the rule",
+			overrides: []models.LimitOverride{{LimitAmounts: models.LimitAmounts{Daily: amount(50)}}},
+			period:    models.LimitDaily,
+			want:      50,
+			source:    SourceOverride,
+		},
+		{
+			name:      "override may raise the limit",
+			overrides: []models.LimitOverride{{LimitAmounts: models.LimitAmounts{Daily: amount(9000)}}},
+			period:    models.LimitDaily,
+			want:      9000,
+			source:    SourceOverride,
+		},
+		{
+			name:      "periods the override leaves out keep the rule",
+			overrides: []models.LimitOverride{{LimitAmounts: models.LimitAmounts{Daily: amount(50)}}},
+			period:    models.LimitMonthly,
+			want:      3000,
+			source:    SourceRule,
+		},
+		{
+			name: "more specific override wins",
+			overrides: []models.LimitOverride{
+				{LimitAmounts: models.LimitAmounts{Daily: amount(50)}},
+				{Rail: models.RailBank, LimitAmounts: models.LimitAmounts{Daily: amount(600)}},
+				{Rail: models.RailBank, Direction: models.LimitOffRamp, LimitAmounts: models.LimitAmounts{Daily: amount(700)}},
+			},
+			period: models.LimitDaily,
+			want:   700,
+			source: SourceOverride,
+		},
+		{
+			name:      "override of another rail does not apply",
+			overrides: []models.LimitOverride{{Rail: models.RailMobileMoney, LimitAmounts: models.LimitAmounts{Daily: amount(50)}}},
+			period:    models.LimitDaily,
+			want:      800,
+			source:    SourceRule,
+		},
+		{
+			name:      "expired override does not apply",
+			overrides: []models.LimitOverride{{ExpiresAt: &past, LimitAmounts: models.LimitAmounts{Daily: amount(50)}}},
+			period:    models.LimitDaily,
+			want:      800,
+			source:    SourceRule,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := db.Unscoped().Where("1 = 1").Delete(&models.LimitOverride{}).Error; err != nil {
+				t.Fatal(err)
+			}
+			for _, override := range tc.overrides {
+				override.UserID = user.ID
+				if err := override.SaveLimitOverride(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			result, err := resolve(user, models.KYCTier1, models.RailBank, models.LimitOffRamp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			amounts, sources := limitsOf(result)
+			if amounts[tc.period] != tc.want || sources[tc.period] != tc.source {
+				t.Errorf("%s limit = %v from %q, want %v from %q", tc.period, amounts[tc.period], sources[tc.period], tc.want, tc.source)
+			}
+		})
+	}
+}
+
+func TestExceeded(t *testing.T) {
+	status := func(tier int, limits ...Limit) *Status {
+		return &Status{Rail: models.RailBank, Direction: models.LimitOffRamp, Tier: tier, Limits: limits}
+	}
+
+	cases := []struct {
+		name     string
+		status   *Status
+		usd      float64
+		reserved float64
+		code     string
+		used     float64
+	}{
+		{
+			name:   "within every limit",
+			status: status(models.KYCTier1, Limit{Period: models.LimitPerTransaction, Limit: amount(1000)}, Limit{Period: models.LimitDaily, Limit: amount(2000), Used: 500}),
+			usd:    1000,
+		},
+		{
+			name:   "over the per transaction limit",
+			status: status(models.KYCTier1, Limit{Period: models.LimitPerTransaction, Limit: amount(1000)}),
+			usd:    1000.01,
+			code:   CodePerTransactionExceeded,
+		},
+		{
+			name:   "over the daily limit with usage",
+			status: status(models.KYCTier1, Limit{Period: models.LimitDaily, Limit: amount(2000), Used: 1500}),
+			usd:    600,
+			code:   CodeDailyExceeded,
+			used:   1500,
+		},
+		{
+			name:     "reserved amounts count as used",
+			status:   status(models.KYCTier1, Limit{Period: models.LimitMonthly, Limit: amount(2000), Used: 500}),
+			usd:      600,
+			reserved: 1000,
+			code:     CodeMonthlyExceeded,
+			used:     1500,
+		},
+		{
+			name:     "reserved amounts do not count per transaction",
+			status:   status(models.KYCTier1, Limit{Period: models.LimitPerTransaction, Limit: amount(1000)}),
+			usd:      1000,
+			reserved: 5000,
+		},
+		{
+			name:   "usage over the limit leaves nothing",
+			status: status(models.KYCTier1, Limit{Period: models.LimitLifetime, Limit: amount(100), Used: 300}),
+			usd:    1,
+			code:   CodeLifetimeExceeded,
+			used:   300,
+		},
+		{
+			name:   "unlimited periods are skipped",
+			status: status(models.KYCTier1, Limit{Period: models.LimitDaily, Used: 1e9}),
+			usd:    1e6,
+		},
+		{
+			name:   "tier 0 needs KYC",
+			status: status(models.KYCTier0, Limit{Period: models.LimitPerTransaction, Limit: amount(0)}),
+			usd:    1,
+			code:   CodeKYCRequired,
+		},
+		{
+			name:   "a zero limit above tier 0 is exceeded",
+			status: status(models.KYCTier1, Limit{Period: models.LimitPerTransaction, Limit: amount(0)}),
+			usd:    1,
+			code:   CodePerTransactionExceeded,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := exceeded(tc.status, tc.usd, tc.reserved)
+			if tc.code == "" {
+				if err != nil {
+					t.Fatalf("exceeded = %v, want nil", err)
+				}
+				return
+			}
+			var limitErr *Error
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("exceeded = %v, want code %s", err, tc.code)
+			}
+			if limitErr.Code != tc.code || limitErr.Used != tc.used || limitErr.Requested != tc.usd {
+				t.Errorf("exceeded = %+v, want code %s with %v used", limitErr, tc.code, tc.used)
+			}
+		})
+	}
+}
+
+func TestCheckCountsReservations(t *testing.T) {
+	_, user := setupLimitsDB(t)
+
+	// tier 1 allows 1000 USD per transaction and 2000 USD a day
+	first, err := Check(user, models.RailBank, models.LimitOffRamp, 900, "USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Check(user, models.RailBank, models.LimitOffRamp, 900, "USD"); err != nil {
+		t.Fatal(err)
+	}
+	var limitErr *Error
+	if _, err := Check(user, models.RailBank, models.LimitOffRamp, 900, "USD"); !errors.As(err, &limitErr) || limitErr.Code != CodeDailyExceeded {
+		t.Fatalf("third check = %v, want %s", err, CodeDailyExceeded)
+	}
+	// other rails and directions are reserved apart
+	if _, err := Check(user, models.RailBank, models.LimitOnRamp, 900, "USD"); err != nil {
+		t.Fatal(err)
+	}
+
+	first.Release()
+	if _, err := Check(user, models.RailBank, models.LimitOffRamp, 900, "USD"); err != nil {
+		t.Fatalf("check after release = %v", err)
+	}
+}
+
+func TestPeriodWindows(t *testing.T) {
+	wat := time.FixedZone("WAT", 3600)
+	now := time.Date(2026, time.January, 31, 22, 30, 0, 0, wat)
+
+	cases := []struct {
+		period string
+		start  time.Time
+		resets *time.Time
+	}{
+		{models.LimitPerTransaction, time.Time{}, nil},
+		{models.LimitDaily, time.Date(2026, time.January, 31, 0, 0, 0, 0, wat), ptrTime(time.Date(2026, time.February, 1, 0, 0, 0, 0, wat))},
+		{models.LimitMonthly, time.Date(2026, time.January, 1, 0, 0, 0, 0, wat), ptrTime(time.Date(2026, time.February, 1, 0, 0, 0, 0, wat))},
+		{models.LimitLifetime, time.Time{}, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.period, func(t *testing.T) {
+			if start := windowStart(tc.period, now); !start.Equal(tc.start) {
+				t.Errorf("windowStart = %v, want %v", start, tc.start)
+			}
+			resets := resetsAt(tc.period, now)
+			if (resets == nil) != (tc.resets == nil) || (resets != nil && !resets.Equal(*tc.resets)) {
+				t.Errorf("resetsAt = %v, want %v", resets, tc.resets)
+			}
+		})
+	}
+}
+
+func ptrTime(v time.Time) *time.Time {
+	return &v
+}
+
+func TestCapOverride(t *testing.T) {
+	db, user := setupLimitsDB(t)
+	// bank off-ramps may go to 5000 USD a day, other rails keep the 2000 USD
+	// tier 1 default
+	rule := models.LimitRule{Rail: models.RailBank, Direction: models.LimitOffRamp, LimitAmounts: models.LimitAmounts{Daily: amount(5000)}}
+	if err := db.Create(&rule).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name     string
+		override models.LimitOverride
+		ok       bool
+	}{
+		{
+			name:     "lower than every platform limit",
+			override: models.LimitOverride{LimitAmounts: models.LimitAmounts{Daily: amount(1000)}},
+			ok:       true,
+		},
+		{
+			name:     "up to the rule on the rail it covers",
+			override: models.LimitOverride{Rail: models.RailBank, Direction: models.LimitOffRamp, LimitAmounts: models.LimitAmounts{Daily: amount(5000)}},
+			ok:       true,
+		},
+		{
+			name:     "over the default on rails the rule does not cover",
+			override: models.LimitOverride{Rail: models.RailBank, LimitAmounts: models.LimitAmounts{Daily: amount(4000)}},
+		},
+		{
+			name:     "over the rule",
+			override: models.LimitOverride{Rail: models.RailBank, Direction: models.LimitOffRamp, LimitAmounts: models.LimitAmounts{Daily: amount(5001)}},
+		},
+		{
+			name:     "a period the platform leaves unlimited",
+			override: models.LimitOverride{LimitAmounts: models.LimitAmounts{Lifetime: amount(1e9)}},
+			ok:       true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := CapOverride(user, tc.override)
+			if (err == nil) != tc.ok {
+				t.Errorf("CapOverride = %v, want ok %v", err, tc.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package limits
+
+import (
+	"backend/apis"
+	"backend/serializers"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+)
+
+// rates caches USD conversion rates, limits only need them roughly
+var rates = cache.New(time.Hour, 2*time.Hour)
+
+const rateTimeout = 5 * time.Second
+
+// ErrStaleRate is returned when the cached rate expired and no fresh one came
+// back in time
+var ErrStaleRate = errors.New("no fresh exchange rate")
+
+// nativeAssets are priced in USD, every other non-dollar currency is fiat
+var nativeAssets = map[string]bool{"CELO": true, "XLM": true, "MATIC": true, "POL": true, "ETH": true, "BTC": true}
+
+var (
+	countryCurrenciesOnce sync.Once
+	countryCurrencies     map[string]string // alpha-2 country code to currency, e.g. KE: KES
+)
+
+// loadCountryCurrencies reads the mobile money countries, collections store
+// the country code where the currency is expected
+func loadCountryCurrencies() {
+	countryCurrencies = map[string]string{}
+
+	root, _ := os.Getwd()
+	jsonData, err := os.ReadFile(filepath.Join(root, "templates", "network.json"))
+	if err != nil {
+		log.Printf("limits: reading network.json: %v", err)
+		return
+	}
+	var networks []serializers.NetworkData
+	if err := json.Unmarshal(jsonData, &networks); err != nil {
+		log.Printf("limits: parsing network.json: %v", err)
+		return
+	}
+	for _, network := range networks {
+		countryCurrencies[strings.ToUpper(network.CountryCode)] = strings.ToUpper(network.CurrencyCode)
+	}
+}
+
+// ToUSD converts an amount of a currency or asset to US dollars. Dollar
+// stablecoins count one to one, blank currencies are taken as dollars.
+func ToUSD(amount float64, currency string) (float64, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if amount == 0 || currency == "" || strings.Contains(currency, "USD") {
+		return amount, nil
+	}
+	if len(currency) == 2 {
+		countryCurrenciesOnce.Do(loadCountryCurrencies)
+		if mapped, ok := countryCurrencies[currency]; ok {
+			currency = mapped
+		}
+	}
+
+	if nativeAssets[currency] {
+		price, err := rate("USD", currency)
+		if err != nil {
+			return 0, err
+		}
+		return amount * price, nil
+	}
+	// fiat is priced through USDT, the rate is the fiat price of one dollar
+	perDollar, err := rate(currency, "USDT")
+	if err != nil {
+		return 0, err
+	}
+	if perDollar == 0 {
+		return 0, fmt.Errorf("no exchange rate for %s", currency)
+	}
+	return amount / perDollar, nil
+}
+
+// rate is the price of one asset in the fiat currency
+func rate(fiat, asset string) (float64, error) {
+	key := fiat + ":" + asset
+	if cached, ok := rates.Get(key); ok {
+		return cached.(float64), nil
+	}
+
+	resultChan := make(chan string, 1)
+	errChan := make(chan error, 1)
+	go apis.GetExchangeRate(fiat, asset, resultChan, errChan)
+
+	select {
+	case value := <-resultChan:
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse exchange rate: %w", err)
+		}
+		rates.Set(key, parsed, cache.DefaultExpiration)
+		return parsed, nil
+	case err := <-errChan:
+		return 0, err
+	case <-time.After(rateTimeout):
+		return 0, fmt.Errorf("%w: timeout fetching the %s rate in %s", ErrStaleRate, asset, fiat)
+	}
+}
@@ -0,0 +1,98 @@
+package logging
+
+import (
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Redacted replaces the value of a sensitive field
+const Redacted = "[REDACTED]"
+
+// sensitiveFields are the names of fields never logged, compared lower case
+// without underscores or dashes so tax_id, taxId and Tax-ID all match
+var sensitiveFields = map[string]bool{
+	"fromprivatekey": true,
+	"privatekey":     true,
+	"fromsecret":     true,
+	"secret":         true,
+	"mnemonic":       true,
+	"password":       true,
+	"phone":          true,
+	"phonenumber":    true,
+	"taxid":          true,
+}
+
+// Sensitive reports whether a field of the name is redacted
+func Sensitive(name string) bool {
+	name = strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(name))
+	return sensitiveFields[name]
+}
+
+// redactAttr hides sensitive attributes, and sensitive fields of the maps,
+// structs and slices logged as attributes
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	if Sensitive(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+	if a.Value.Kind() == slog.KindAny {
+		a.Value = slog.AnyValue(Redact(a.Value.Any()))
+	}
+	return a
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Redact returns the value as its JSON form with sensitive fields replaced,
+// at any depth. Scalars, errors and values that do not encode are returned
+// as they are.
+func Redact(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	if _, ok := v.(error); ok {
+		return v
+	}
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Map, reflect.Struct, reflect.Slice, reflect.Array:
+	default:
+		return v
+	}
+	if t == timeType {
+		return v
+	}
+
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		return v
+	}
+	return redactValue(decoded)
+}
+
+func redactValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if Sensitive(key) {
+				value[key] = Redacted
+			} else {
+				value[key] = redactValue(field)
+			}
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = redactValue(item)
+		}
+	}
+	return v
+}
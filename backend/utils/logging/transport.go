@@ -0,0 +1,46 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// transport logs each outbound call with its latency and status. Only the
+// host and path are logged, bodies and query strings can hold secrets.
+type transport struct {
+	base http.RoundTripper
+}
+
+// Transport wraps base so every call made through it is logged with the
+// request ID of the request's context
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if _, ok := base.(transport); ok {
+		return base
+	}
+	return transport{base: base}
+}
+
+func (t transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	attrs := []any{
+		slog.String("host", req.URL.Host),
+		slog.String("method", req.Method),
+		slog.String("path", req.URL.Path),
+		slog.Int64("latency_ms", time.Since(start).Milliseconds()),
+	}
+	if err != nil {
+		slog.WarnContext(req.Context(), "upstream call failed", append(attrs, slog.Any("error", err))...)
+		return resp, err
+	}
+	level := slog.LevelInfo
+	if resp.StatusCode >= 400 {
+		level = slog.LevelWarn
+	}
+	slog.Log(req.Context(), level, "upstream call", append(attrs, slog.Int("status", resp.StatusCode))...)
+	return resp, nil
+}
@@ -0,0 +1,40 @@
+package mails
+
+import "fmt"
+
+// messages holds the strings shared by every template through the t function,
+// template specific copy lives in the per-locale template files
+var messages = map[string]map[string]string{
+	"en": {
+		"greeting": "Dear %s,",
+		"regards":  "Best regards,",
+		"team":     "The %s team",
+		"footer":   "You are receiving this email because you have an account with %s.",
+	},
+	"fr": {
+		"greeting": "Bonjour %s,",
+		"regards":  "Cordialement,",
+		"team":     "L'équipe %s",
+		"footer":   "Vous recevez cet e-mail car vous avez un compte chez %s.",
+	},
+	"sw": {
+		"greeting": "Habari %s,",
+		"regards":  "Wako,",
+		"team":     "Timu ya %s",
+		"footer":   "Unapokea barua pepe hii kwa sababu una akaunti na %s.",
+	},
+}
+
+func translate(locale, key string, args ...interface{}) string {
+	format, ok := messages[locale][key]
+	if !ok {
+		format, ok = messages[defaultLocale][key]
+	}
+	if !ok {
+		return key
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
@@ -0,0 +1,94 @@
+package mails
+
+import (
+	"backend/models"
+	"backend/state"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+var transport Transport
+
+// StartOutbox sets up the configured transport and retries due mails every
+// interval. Mails queued before it runs wait in the outbox.
+func StartOutbox(interval time.Duration) error {
+	t, err := NewTransport(state.AppConfig)
+	if err != nil {
+		return err
+	}
+	transport = t
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			mails, err := models.GetDueOutboxMails(50)
+			if err != nil {
+				slog.Error("outbox mails not loaded", "error", err)
+				continue
+			}
+			for _, mail := range mails {
+				deliver(mail)
+			}
+		}
+	}()
+	return nil
+}
+
+// enqueue stores the rendered mail and makes a first attempt right away, the
+// outbox worker retries it on failure
+func enqueue(template, locale string, msg Message) error {
+	if len(msg.To) == 0 {
+		return errors.New("mail has no recipients")
+	}
+	mail := models.OutboxMail{
+		Recipients:    strings.Join(msg.To, ","),
+		FromName:      msg.FromName,
+		ReplyTo:       msg.ReplyTo,
+		Subject:       msg.Subject,
+		HTMLBody:      msg.HTML,
+		TextBody:      msg.Text,
+		Template:      template,
+		Locale:        locale,
+		Status:        models.MailPending,
+		NextAttemptAt: time.Now(),
+	}
+	if err := mail.CreateOutboxMail(); err != nil {
+		return err
+	}
+	go func() {
+		stored, err := models.GetOutboxMailByID(mail.ID)
+		if err != nil {
+			return
+		}
+		deliver(*stored)
+	}()
+	return nil
+}
+
+func deliver(mail models.OutboxMail) {
+	if transport == nil || !mail.Claim() {
+		return
+	}
+
+	err := transport.Send(Message{
+		To:       mail.RecipientList(),
+		FromName: mail.FromName,
+		ReplyTo:  mail.ReplyTo,
+		Subject:  mail.Subject,
+		HTML:     mail.HTMLBody,
+		Text:     mail.TextBody,
+	})
+	if err != nil {
+		slog.Warn("mail not sent", "mail_id", mail.ID, "template", mail.Template, "attempt", mail.Attempts+1, "error", err)
+		if err := mail.RecordFailure(err); err != nil {
+			slog.Error("mail failure not recorded", "mail_id", mail.ID, "error", err)
+		}
+		return
+	}
+	if err := mail.MarkSent(); err != nil {
+		slog.Error("mail not marked sent", "mail_id", mail.ID, "error", err)
+	}
+}
@@ -0,0 +1,162 @@
+package mails
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+)
+
+// Templates live in templates/<locale>/<name>.tmpl and define "subject",
+// "content" (HTML) and "text" (plain text). They are wrapped in
+// layouts/base.html and layouts/base.txt, which pull in the partials.
+//
+//go:embed templates
+var embedded embed.FS
+
+const defaultLocale = "en"
+
+// tenantTemplateDir holds white-label overrides on disk, laid out as
+// <set>/<locale>/<name>.tmpl
+const tenantTemplateDir = "templates/tenants"
+
+// view is the data every template executes with
+type view struct {
+	Locale  string
+	Sender  string
+	Name    string
+	Subject string
+	Data    interface{}
+}
+
+type compiled struct {
+	locale string
+	html   *htmltemplate.Template
+	text   *texttemplate.Template
+}
+
+// cache holds compiled templates by template set, locale and name
+var cache sync.Map
+
+// render executes the named template in the best matching locale and returns
+// the subject, HTML and text bodies and the locale actually used
+func render(name, locale, templateSet string, v view) (subject, html, text, usedLocale string, err error) {
+	tmpl, err := load(name, locale, templateSet)
+	if err != nil {
+		return "", "", "", "", err
+	}
+	v.Locale = tmpl.locale
+
+	var buf bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&buf, "subject", v); err != nil {
+		return "", "", "", "", fmt.Errorf("rendering %s subject: %w", name, err)
+	}
+	v.Subject = strings.TrimSpace(buf.String())
+
+	buf.Reset()
+	if err := tmpl.html.ExecuteTemplate(&buf, "base.html", v); err != nil {
+		return "", "", "", "", fmt.Errorf("rendering %s html: %w", name, err)
+	}
+	html = buf.String()
+
+	buf.Reset()
+	if err := tmpl.text.ExecuteTemplate(&buf, "base.txt", v); err != nil {
+		return "", "", "", "", fmt.Errorf("rendering %s text: %w", name, err)
+	}
+	return v.Subject, html, strings.TrimSpace(buf.String()) + "\n", tmpl.locale, nil
+}
+
+func load(name, locale, templateSet string) (*compiled, error) {
+	key := templateSet + "|" + locale + "|" + name
+	if tmpl, ok := cache.Load(key); ok {
+		return tmpl.(*compiled), nil
+	}
+
+	source, usedLocale, content, err := findContent(name, locale, templateSet)
+	if err != nil {
+		return nil, err
+	}
+
+	funcs := templateFuncs(usedLocale)
+	html, err := htmltemplate.New("base.html").Funcs(htmltemplate.FuncMap(funcs)).
+		ParseFS(embedded, "templates/layouts/base.html", "templates/partials/partials.html")
+	if err != nil {
+		return nil, err
+	}
+	if html, err = html.New(source).Parse(content); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", source, err)
+	}
+
+	text, err := texttemplate.New("base.txt").Funcs(texttemplate.FuncMap(funcs)).
+		ParseFS(embedded, "templates/layouts/base.txt", "templates/partials/partials.txt")
+	if err != nil {
+		return nil, err
+	}
+	if text, err = text.New(source).Parse(content); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", source, err)
+	}
+
+	tmpl := &compiled{locale: usedLocale, html: html, text: text}
+	cache.Store(key, tmpl)
+	return tmpl, nil
+}
+
+// findContent prefers the tenant's override, then the embedded template in
+// the requested locale, then the default locale
+func findContent(name, locale, templateSet string) (source, usedLocale, content string, err error) {
+	locales := []string{locale}
+	if locale != defaultLocale {
+		locales = append(locales, defaultLocale)
+	}
+
+	for _, l := range locales {
+		file := path.Join(l, name+".tmpl")
+		if templateSet != "" {
+			override := filepath.Join(tenantTemplateDir, templateSet, filepath.FromSlash(file))
+			if b, err := os.ReadFile(override); err == nil {
+				return override, l, string(b), nil
+			}
+		}
+		if b, err := fs.ReadFile(embedded, path.Join("templates", file)); err == nil {
+			return file, l, string(b), nil
+		}
+	}
+	return "", "", "", errors.New("mail template not found: " + name)
+}
+
+type linkData struct {
+	URL   string
+	Label string
+}
+
+type row struct {
+	Label string
+	Value interface{}
+}
+
+func templateFuncs(locale string) map[string]interface{} {
+	return map[string]interface{}{
+		"t": func(key string, args ...interface{}) string {
+			return translate(locale, key, args...)
+		},
+		"link": func(url, label string) linkData {
+			return linkData{URL: url, Label: label}
+		},
+		// rows pairs up label, value arguments for the "details" partial
+		"rows": func(pairs ...interface{}) []row {
+			rows := make([]row, 0, len(pairs)/2)
+			for i := 0; i+1 < len(pairs); i += 2 {
+				rows = append(rows, row{Label: fmt.Sprint(pairs[i]), Value: pairs[i+1]})
+			}
+			return rows
+		},
+	}
+}
@@ -3,111 +3,127 @@ package mails
 import (
 	"backend/serializers"
 	"backend/state"
-	"bytes"
-	"crypto/tls"
 	"fmt"
-	"html/template"
-	"log"
-	"os"
-
-	"gopkg.in/gomail.v2"
+	"time"
 )
 
-func SendMail(header string, message *gomail.Message, receiver []string) error {
-	from := state.AppConfig.EmailUser
-	password := state.AppConfig.EmailPassword
-	message.SetHeader("From", from)
-	message.SetHeader("To", receiver...)
-	message.SetHeader("Subject", header)
-	// Create a new dialer
-	dialer := gomail.NewDialer("smtp.gmail.com", 465, from, password)
-	dialer.TLSConfig = &tls.Config{InsecureSkipVerify: true}
-
-	// Send the email
-	if err := dialer.DialAndSend(message); err != nil {
-		log.Println(err)
-		return err
-	}
-	log.Println("Email Sent Successfully!")
-	return nil
+// Branding carries a tenant's white-label mail overrides, the zero value is
+// the GreyBox platform branding
+type Branding struct {
+	SenderName  string
+	ReplyTo     string
+	TemplateSet string
+}
+
+// Recipient is who a mail goes to and how it is presented to them
+type Recipient struct {
+	Email  string
+	Name   string
+	Locale string
+	Brand  Branding
+}
+
+const platformSender = "GreyBox"
+
+// send renders the template for the recipient and queues it in the outbox
+func send(template string, to Recipient, data interface{}) error {
+	return sendTo(template, []string{to.Email}, to.Name, to.Locale, to.Brand, data)
 }
 
-func SendForgetPasswordMail(receiver []string, name, token string) error {
+func sendTo(template string, receiver []string, name, locale string, brand Branding, data interface{}) error {
+	sender := brand.SenderName
+	if sender == "" {
+		sender = platformSender
+	}
+	if locale == "" {
+		locale = defaultLocale
+	}
 
-	message := gomail.NewMessage()
-	dir, _ := os.Getwd()
-	t, err := template.ParseFiles(dir + "/templates/resetpassword.html")
+	subject, html, text, usedLocale, err := render(template, locale, brand.TemplateSet, view{
+		Sender: sender,
+		Name:   name,
+		Data:   data,
+	})
 	if err != nil {
 		return err
 	}
-	var body bytes.Buffer
-	t.Execute(&body, struct {
-		Name              string
-		PasswordResetLink string
-	}{
-		Name:              name,
-		PasswordResetLink: fmt.Sprintf("%s?token=%s", state.AppConfig.PasswordResetLink, token),
+
+	return enqueue(template, usedLocale, Message{
+		To:       receiver,
+		FromName: brand.SenderName,
+		ReplyTo:  brand.ReplyTo,
+		Subject:  subject,
+		HTML:     html,
+		Text:     text,
 	})
+}
 
-	// Attach the HTML body to the email
-	message.SetBody("text/html", body.String())
-	if err := SendMail("Password Reset Request", message, receiver); err != nil {
-		return err
-	}
+type linkMail struct {
+	Link        string
+	LockedUntil string
+}
 
-	return nil
+func SendForgetPasswordMail(to Recipient, token string) error {
+	return send("reset-password", to, linkMail{
+		Link: fmt.Sprintf("%s?token=%s", state.AppConfig.PasswordResetLink, token),
+	})
 }
 
-func AdminOnRampMail(receiver []string, data serializers.AdminOnRampSerializer) error {
-	message := gomail.NewMessage()
-	dir, _ := os.Getwd()
-	t, err := template.ParseFiles(dir + "/templates/confirm-onramp.html")
-	if err != nil {
-		return err
-	}
-	var body bytes.Buffer
-	t.Execute(&body, data)
+func SendEmailVerificationMail(to Recipient, token string) error {
+	return send("verify-email", to, linkMail{
+		Link: fmt.Sprintf("%s?token=%s", state.AppConfig.EmailVerificationLink, token),
+	})
+}
 
-	message.SetBody("text/html", body.String())
-	if err := SendMail("OnRamp Request", message, receiver); err != nil {
-		return err
-	}
+func SendAccountUnlockMail(to Recipient, token string, lockedUntil time.Time) error {
+	return send("unlock-account", to, linkMail{
+		Link:        fmt.Sprintf("%s?token=%s", state.AppConfig.AccountUnlockLink, token),
+		LockedUntil: lockedUntil.Format("02 Jan 2006 15:04 MST"),
+	})
+}
 
-	return nil
+// SendBeneficiaryConfirmMail asks the user to confirm a newly saved payout
+// beneficiary
+func SendBeneficiaryConfirmMail(to Recipient, token, beneficiary string, coolingHours int) error {
+	return send("confirm-beneficiary", to, struct {
+		Link         string
+		Beneficiary  string
+		CoolingHours int
+	}{
+		Link:         fmt.Sprintf("%s?token=%s", state.AppConfig.BeneficiaryConfirmLink, token),
+		Beneficiary:  beneficiary,
+		CoolingHours: coolingHours,
+	})
 }
 
-func AdminOffRampMail(receiver []string, data serializers.AdminOffRampSerializer) error {
-	message := gomail.NewMessage()
-	dir, _ := os.Getwd()
-	t, err := template.ParseFiles(dir + "/templates/verify-offramp.html")
-	if err != nil {
-		return err
-	}
-	var body bytes.Buffer
-	t.Execute(&body, data)
+func SendNotificationMail(to Recipient, title, body string) error {
+	return send("notification", to, struct {
+		Title string
+		Body  string
+	}{Title: title, Body: body})
+}
 
-	message.SetBody("text/html", body.String())
-	if err := SendMail("OffRamp Confirmation", message, receiver); err != nil {
-		return err
-	}
+// SendStatementReadyMail tells the requester their statement export can be
+// downloaded
+func SendStatementReadyMail(to Recipient, link, period, format string, expiresAt time.Time) error {
+	return send("statement-ready", to, struct {
+		Link      string
+		Period    string
+		Format    string
+		ExpiresAt string
+	}{Link: link, Period: period, Format: format, ExpiresAt: expiresAt.Format("02 Jan 2006 15:04 MST")})
+}
 
-	return nil
+func UserOffRampMail(to Recipient, data serializers.UserOffRampMail) error {
+	return send("user-offramp", to, data)
 }
 
-func UserOffRampMail(receiver []string, data serializers.UserOffRampMail) error {
-	message := gomail.NewMessage()
-	dir, _ := os.Getwd()
-	t, err := template.ParseFiles(dir + "/templates/user-offramp.html")
-	if err != nil {
-		return err
-	}
-	var body bytes.Buffer
-	t.Execute(&body, data)
+// Admin mails go out in English under the platform branding
 
-	message.SetBody("text/html", body.String())
-	if err := SendMail("OffRamp Notification", message, receiver); err != nil {
-		return err
-	}
+func AdminOnRampMail(receiver []string, data serializers.AdminOnRampSerializer) error {
+	return sendTo("admin-onramp", receiver, data.Name, defaultLocale, Branding{}, data)
+}
 
-	return nil
+func AdminOffRampMail(receiver []string, data serializers.AdminOffRampSerializer) error {
+	return sendTo("admin-offramp", receiver, "Admin", defaultLocale, Branding{}, data)
 }
@@ -0,0 +1,127 @@
+package mails
+
+import (
+	"backend/state"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"gopkg.in/gomail.v2"
+)
+
+// Message is a rendered mail ready for a transport
+type Message struct {
+	To       []string
+	FromName string
+	ReplyTo  string
+	Subject  string
+	HTML     string
+	Text     string
+}
+
+// Transport delivers a rendered mail
+type Transport interface {
+	Send(msg Message) error
+}
+
+// NewTransport builds the transport named by MAIL_TRANSPORT
+func NewTransport(cfg *state.Config) (Transport, error) {
+	switch cfg.MailTransport {
+	case "", "smtp":
+		port, err := strconv.Atoi(cfg.SMTPPort)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SMTP_PORT %q: %w", cfg.SMTPPort, err)
+		}
+		return &SMTPTransport{Host: cfg.SMTPHost, Port: port, Username: cfg.EmailUser, Password: cfg.EmailPassword}, nil
+	case "file":
+		return &FileTransport{Dir: cfg.MailSinkDir, From: cfg.EmailUser}, nil
+	case "console":
+		return ConsoleTransport{}, nil
+	default:
+		return nil, fmt.Errorf("unknown MAIL_TRANSPORT %q", cfg.MailTransport)
+	}
+}
+
+// SMTPTransport sends through an SMTP server, implicitly over TLS on port 465
+// and upgrading with STARTTLS otherwise. Certificates are always verified.
+type SMTPTransport struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+}
+
+func (t *SMTPTransport) Send(msg Message) error {
+	dialer := gomail.NewDialer(t.Host, t.Port, t.Username, t.Password)
+	dialer.TLSConfig = &tls.Config{ServerName: t.Host, MinVersion: tls.VersionTLS12}
+	return dialer.DialAndSend(buildMessage(t.Username, msg))
+}
+
+// FileTransport writes every mail as an .eml file, for development
+type FileTransport struct {
+	Dir  string
+	From string
+}
+
+func (t *FileTransport) Send(msg Message) error {
+	if err := os.MkdirAll(t.Dir, 0o750); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405.000000"), slug(msg.Subject))
+	file, err := os.Create(filepath.Join(t.Dir, name))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = buildMessage(t.From, msg).WriteTo(file)
+	return err
+}
+
+// ConsoleTransport logs the plain-text version of every mail, for development
+type ConsoleTransport struct{}
+
+func (ConsoleTransport) Send(msg Message) error {
+	log.Printf("mail to %v: %s\n%s", msg.To, msg.Subject, msg.Text)
+	return nil
+}
+
+func buildMessage(from string, msg Message) *gomail.Message {
+	message := gomail.NewMessage()
+	if msg.FromName != "" {
+		message.SetAddressHeader("From", from, msg.FromName)
+	} else {
+		message.SetHeader("From", from)
+	}
+	if msg.ReplyTo != "" {
+		message.SetHeader("Reply-To", msg.ReplyTo)
+	}
+	message.SetHeader("To", msg.To...)
+	message.SetHeader("Subject", msg.Subject)
+	message.SetBody("text/plain", msg.Text)
+	message.AddAlternative("text/html", msg.HTML)
+	return message
+}
+
+func slug(s string) string {
+	out := make([]rune, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			out = append(out, r)
+		case r >= 'A' && r <= 'Z':
+			out = append(out, r+'a'-'A')
+		default:
+			if len(out) > 0 && out[len(out)-1] != '-' {
+				out = append(out, '-')
+			}
+		}
+	}
+	if len(out) > 40 {
+		out = out[:40]
+	}
+	return string(out)
+}
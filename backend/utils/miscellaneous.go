@@ -2,10 +2,9 @@ package utils
 
 import (
 	"backend/apis"
+	"backend/utils/apperrors"
 	"fmt"
-	"log"
 	"math"
-	"net/http"
 	"strconv"
 	"strings"
 	"time"
@@ -21,7 +20,6 @@ func ConvertTokenToNative(currentNativeUsdEquivalent, TokenAmount string) string
 	// Perform the conversion
 	nativeAmount := cusdAmountFloat / currentCeloUsdPriceFloat
 	nativeAmount = math.Round(nativeAmount*100) / 100
-	log.Println("nativeAmount: ", nativeAmount)
 
 	// Convert the result back to a string
 	nativeAmountStr := strconv.FormatFloat(nativeAmount, 'f', -1, 64)
@@ -137,10 +135,7 @@ func LastPart(url, sep string) string {
 }
 
 func BadRequest(c *gin.Context, err error, msg string) {
-	c.JSON(http.StatusBadRequest, gin.H{
-		"error":   err.Error(),
-		"message": msg,
-	})
+	apperrors.Respond(c, apperrors.Invalid(err, msg))
 }
 
 // Valid and Invalid Countries
@@ -0,0 +1,207 @@
+package monitoring
+
+import (
+	"backend/models"
+	"backend/utils/cases"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// batchSize is how many requests of a table one run evaluates at most
+const batchSize = 200
+
+// overlap is how many requests before the cursor each run reads again, a
+// request committed after a newer one is still evaluated. The unique alert
+// index keeps requests read twice from alerting twice.
+const overlap = 20
+
+// maxBacktestEvents keeps a backtest to what can be answered in a request
+const maxBacktestEvents = 20000
+
+var ErrBacktestTooLarge = fmt.Errorf("more than %d requests in range, narrow it", maxBacktestEvents)
+
+// DefaultRules are stored on the first start, compliance tunes them from
+// there
+var DefaultRules = []models.MonitoringRule{
+	{
+		Name: "Structuring under the per transaction limit", Type: models.RuleStructuring, Enabled: true,
+		Priority:    models.CasePriorityMedium,
+		Params:      models.MonitoringParams{Margin: 0.1, MinCount: 3, WindowHours: 24},
+		Description: "Three or more requests within 10% under the user's per transaction limit in a day",
+	},
+	{
+		Name: "Rapid in and out", Type: models.RuleRapidInOut, Enabled: true,
+		Priority:    models.CasePriorityMedium,
+		Params:      models.MonitoringParams{MinAmount: 1000, Ratio: 0.8, WindowHours: 24},
+		Description: "80% or more of at least 1000 USD on-ramped is off-ramped again within a day",
+	},
+	{
+		Name: "Many payout beneficiaries", Type: models.RuleManyBeneficiaries, Enabled: true,
+		Priority:    models.CasePriorityMedium,
+		Params:      models.MonitoringParams{MinCount: 5, WindowHours: 72},
+		Description: "Payouts to five or more distinct beneficiaries within three days",
+	},
+	{
+		Name: "High value from a new device", Type: models.RuleNewDeviceHighValue, Enabled: true,
+		Priority:    models.CasePriorityHigh,
+		Params:      models.MonitoringParams{MinAmount: 2000, WindowHours: 24},
+		Description: "2000 USD or more within a day of first signing in from a new device",
+	},
+	{
+		Name: "High-risk corridor", Type: models.RuleHighRiskCorridor, Enabled: true,
+		Priority:    models.CasePriorityHigh,
+		Params:      models.MonitoringParams{Countries: []string{"IR", "KP", "MM"}},
+		Description: "Requests from or to a country under a FATF call for action",
+	},
+}
+
+// StartWorker stores the default rules if there are none, then evaluates
+// new requests every interval
+func StartWorker(interval time.Duration) {
+	if err := models.SeedMonitoringRules(DefaultRules); err != nil {
+		slog.Error("monitoring rules not seeded", "error", err)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := Run(); err != nil {
+			slog.Error("monitoring run failed", "error", err)
+		}
+	}
+}
+
+// Run evaluates the enabled rules on the requests created since the last
+// run. A table is picked up from its newest request on the first run, older
+// requests are for Backtest.
+func Run() error {
+	rules, err := models.GetEnabledMonitoringRules()
+	if err != nil {
+		return err
+	}
+	e := newEvaluator()
+	var errs []error
+	for _, source := range models.MonitoredSources() {
+		if err := runSource(e, rules, source); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", source, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func runSource(e *evaluator, rules []models.MonitoringRule, source string) error {
+	cursor, err := models.GetMonitoringCursor(source)
+	if err != nil {
+		return err
+	}
+	if cursor == nil {
+		latest, err := models.LatestMonitoredID(source)
+		if err != nil {
+			return err
+		}
+		cursor = &models.MonitoringCursor{Source: source, LastID: latest}
+		return cursor.SaveMonitoringCursor()
+	}
+
+	events, err := models.MonitoredEventsAfter(source, cursor.LastID-min(cursor.LastID, overlap), batchSize)
+	if err != nil || len(events) == 0 {
+		return err
+	}
+	for _, event := range events {
+		if err := evaluateEvent(e, rules, event); err != nil {
+			// the cursor stays before the request so the next run retries it
+			if saveErr := cursor.SaveMonitoringCursor(); saveErr != nil {
+				return errors.Join(err, saveErr)
+			}
+			return fmt.Errorf("request %d: %w", event.ID, err)
+		}
+		cursor.LastID = max(cursor.LastID, event.ID)
+	}
+	return cursor.SaveMonitoringCursor()
+}
+
+// evaluateEvent raises every alert of the rules hit by the request
+func evaluateEvent(e *evaluator, rules []models.MonitoringRule, event models.MonitoredEvent) error {
+	hits, err := e.evaluate(rules, event)
+	if err != nil {
+		return err
+	}
+	for _, hit := range hits {
+		if err := raise(hit); err != nil {
+			return fmt.Errorf("alert of rule %d: %w", hit.Rule.ID, err)
+		}
+	}
+	return nil
+}
+
+// raise stores the alert and adds it to case management
+func raise(hit Hit) error {
+	alert := &models.MonitoringAlert{
+		RuleID:    hit.Rule.ID,
+		RuleType:  hit.Rule.Type,
+		UserID:    hit.Event.UserID,
+		Source:    hit.Event.Source,
+		SourceID:  hit.Event.ID,
+		AmountUSD: hit.AmountUSD,
+		Detail:    hit.Detail,
+	}
+	created, err := alert.CreateMonitoringAlert()
+	if err != nil {
+		return err
+	}
+	if !created && alert.CaseID != nil {
+		// raised when the request was read before
+		return nil
+	}
+	if created {
+		slog.Warn("monitoring rule hit", "rule", hit.Rule.Name, "source", alert.Source, "source_id", alert.SourceID, "user_id", alert.UserID, "detail", alert.Detail)
+	}
+	cc, err := cases.FromMonitoringAlert(alert, hit.Rule)
+	if err != nil {
+		return err
+	}
+	return alert.LinkCase(cc.ID)
+}
+
+// BacktestResult is what a rule would have raised over past requests
+type BacktestResult struct {
+	Rule      models.MonitoringRule `json:"rule"`
+	From      time.Time             `json:"from"`
+	To        time.Time             `json:"to"`
+	Evaluated int                   `json:"evaluated"`
+	Users     int                   `json:"users"`
+	Errors    int                   `json:"errors"`
+	Hits      []Hit                 `json:"hits"`
+}
+
+// Backtest runs the rule, stored or not, over the requests created in
+// [from, to) of the tenant's users, or every user when tenantID is 0. No
+// alert or case is raised.
+func Backtest(rule models.MonitoringRule, from, to time.Time, tenantID uint) (*BacktestResult, error) {
+	events, err := models.MonitoredEventsBetween(from, to, tenantID, maxBacktestEvents+1)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) > maxBacktestEvents {
+		return nil, ErrBacktestTooLarge
+	}
+
+	result := &BacktestResult{Rule: rule, From: from, To: to, Evaluated: len(events), Hits: []Hit{}}
+	rules := []models.MonitoringRule{rule}
+	users := map[uint]bool{}
+	e := newEvaluator()
+	for _, event := range events {
+		hits, err := e.evaluate(rules, event)
+		if err != nil {
+			result.Errors++
+			continue
+		}
+		for _, hit := range hits {
+			users[hit.Event.UserID] = true
+			result.Hits = append(result.Hits, hit)
+		}
+	}
+	result.Users = len(users)
+	return result, nil
+}
@@ -0,0 +1,212 @@
+package monitoring
+
+import (
+	"backend/models"
+	"backend/utils/limits"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Hit is a rule matching a request
+type Hit struct {
+	Rule      *models.MonitoringRule `json:"-"`
+	Event     models.MonitoredEvent  `json:"event"`
+	AmountUSD float64                `json:"amount_usd"`
+	Detail    string                 `json:"detail"`
+}
+
+// evaluator runs rules over requests, caching what does not change between
+// the requests of one run
+type evaluator struct {
+	thresholds map[string]*float64
+}
+
+func newEvaluator() *evaluator {
+	return &evaluator{thresholds: map[string]*float64{}}
+}
+
+// evaluate runs the rules on the request against the user's requests before
+// it
+func (e *evaluator) evaluate(rules []models.MonitoringRule, event models.MonitoredEvent) ([]Hit, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	usd, err := limits.ToUSD(event.Amount, event.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("%s %d: %w", event.Source, event.ID, err)
+	}
+	var window time.Duration
+	for _, rule := range rules {
+		window = max(window, rule.Params.Window())
+	}
+	history, err := models.UserMonitoredEvents(event.UserID, event.CreatedAt.Add(-window), event.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	// a rule that fails does not keep the others from running
+	var hits []Hit
+	var errs []error
+	for i := range rules {
+		rule := &rules[i]
+		detail, err := e.check(rule, event, usd, history)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rule %d on %s %d: %w", rule.ID, event.Source, event.ID, err))
+			continue
+		}
+		if detail != "" {
+			hits = append(hits, Hit{Rule: rule, Event: event, AmountUSD: usd, Detail: detail})
+		}
+	}
+	return hits, errors.Join(errs...)
+}
+
+// check returns why the rule matches the request, empty when it does not
+func (e *evaluator) check(rule *models.MonitoringRule, event models.MonitoredEvent, usd float64, history []models.MonitoredEvent) (string, error) {
+	p := rule.Params
+	since := event.CreatedAt.Add(-p.Window())
+	switch rule.Type {
+	case models.RuleStructuring:
+		threshold := p.Threshold
+		if threshold == 0 {
+			limit, err := e.perTransactionLimit(event)
+			if err != nil || limit == nil || *limit == 0 {
+				return "", err
+			}
+			threshold = *limit
+		}
+		low := threshold * (1 - p.Margin)
+		if usd < low || usd >= threshold {
+			return "", nil
+		}
+		count := 0
+		for _, h := range within(history, since) {
+			if amount, err := limits.ToUSD(h.Amount, h.Currency); err == nil && amount >= low && amount < threshold {
+				count++
+			}
+		}
+		if count < p.MinCount {
+			return "", nil
+		}
+		return fmt.Sprintf("%d requests between %.2f and %.2f USD within %d hours", count, low, threshold, hours(p)), nil
+
+	case models.RuleRapidInOut:
+		if event.Direction != models.LimitOffRamp || usd < p.MinAmount {
+			return "", nil
+		}
+		var in float64
+		for _, h := range within(history, since) {
+			if h.Direction == models.LimitOnRamp {
+				amount, _ := limits.ToUSD(h.Amount, h.Currency)
+				in += amount
+			}
+		}
+		if in < p.MinAmount || usd < p.Ratio*in {
+			return "", nil
+		}
+		return fmt.Sprintf("%.2f USD off-ramped on %s within %d hours of %.2f USD on-ramped", usd, event.Rail, hours(p), in), nil
+
+	case models.RuleManyBeneficiaries:
+		beneficiary := beneficiaryKey(event)
+		if event.Direction != models.LimitOffRamp || beneficiary == "" {
+			return "", nil
+		}
+		seen := map[string]bool{}
+		for _, h := range within(history, since) {
+			if h.Direction == models.LimitOffRamp && !sameEvent(h, event) {
+				if key := beneficiaryKey(h); key != "" {
+					seen[key] = true
+				}
+			}
+		}
+		// only a new beneficiary raises the count
+		if seen[beneficiary] || len(seen)+1 < p.MinCount {
+			return "", nil
+		}
+		return fmt.Sprintf("payouts to %d distinct beneficiaries within %d hours", len(seen)+1, hours(p)), nil
+
+	case models.RuleNewDeviceHighValue:
+		if usd < p.MinAmount {
+			return "", nil
+		}
+		login, err := models.LastLoginBefore(event.UserID, event.CreatedAt)
+		if err != nil || login == nil || login.UserAgent == "" {
+			return "", err
+		}
+		firstSeen, hadOther, err := models.FirstLoginFrom(event.UserID, login.UserAgent)
+		if err != nil || !hadOther || event.CreatedAt.Sub(firstSeen) > p.Window() {
+			return "", err
+		}
+		return fmt.Sprintf("%.2f USD %s %s after first signing in from a new device (%s, %s)",
+			usd, strings.ReplaceAll(event.Direction, "_", "-"), event.CreatedAt.Sub(firstSeen).Round(time.Minute), login.IPAddress, login.UserAgent), nil
+
+	case models.RuleHighRiskCorridor:
+		if usd < p.MinAmount {
+			return "", nil
+		}
+		for _, country := range p.Countries {
+			if country == event.Country || country == event.UserCountry {
+				return fmt.Sprintf("%.2f USD %s between %s and %s on %s", usd, strings.ReplaceAll(event.Direction, "_", "-"),
+					orUnknown(event.UserCountry), orUnknown(event.Country), event.Rail), nil
+			}
+		}
+		return "", nil
+	}
+	return "", fmt.Errorf("unknown rule type %s", rule.Type)
+}
+
+// perTransactionLimit is the user's current per transaction limit on the
+// request's rail and direction, nil when there is none
+func (e *evaluator) perTransactionLimit(event models.MonitoredEvent) (*float64, error) {
+	key := fmt.Sprintf("%d:%s:%s", event.UserID, event.Rail, event.Direction)
+	if limit, ok := e.thresholds[key]; ok {
+		return limit, nil
+	}
+	user, err := models.GetUserByID(event.UserID)
+	if err != nil {
+		return nil, err
+	}
+	status, err := limits.GetStatus(user, event.Rail, event.Direction)
+	if err != nil {
+		return nil, err
+	}
+	var limit *float64
+	for _, l := range status.Limits {
+		if l.Period == models.LimitPerTransaction {
+			limit = l.Limit
+		}
+	}
+	e.thresholds[key] = limit
+	return limit, nil
+}
+
+func within(history []models.MonitoredEvent, since time.Time) []models.MonitoredEvent {
+	var result []models.MonitoredEvent
+	for _, h := range history {
+		if !h.CreatedAt.Before(since) {
+			result = append(result, h)
+		}
+	}
+	return result
+}
+
+func sameEvent(a, b models.MonitoredEvent) bool {
+	return a.Source == b.Source && a.ID == b.ID
+}
+
+func beneficiaryKey(event models.MonitoredEvent) string {
+	return strings.ToLower(strings.Join(strings.Fields(event.Beneficiary), ""))
+}
+
+func hours(p models.MonitoringParams) int {
+	return int(p.Window().Hours())
+}
+
+func orUnknown(country string) string {
+	if country == "" {
+		return "unknown"
+	}
+	return country
+}
@@ -0,0 +1,147 @@
+package notifications
+
+import (
+	"backend/models"
+	"backend/serializers"
+	"backend/utils"
+	"backend/utils/mails"
+	"backend/utils/tenancy"
+	"fmt"
+	"log/slog"
+	"strconv"
+)
+
+// Links into the wallet frontend
+const (
+	transactionsLink = "/transactions"
+	kycLink          = "/kyc"
+)
+
+const platformSender = "GreyBox"
+
+// notify stores the in-app notification and, when the user wants the event
+// outside the app, sends it on the first of the user's channels that reaches
+// them, falling back to the next one on failure
+func notify(userID uint, eventType string, d details, link string, mail func(user *models.User) error) {
+	user, err := models.GetUserByID(userID)
+	if err != nil {
+		slog.Error("notification user not found", "event_type", eventType, "user_id", userID, "error", err)
+		return
+	}
+
+	d.Sender = tenancy.MailRecipient(&user).Brand.SenderName
+	if d.Sender == "" {
+		d.Sender = platformSender
+	}
+	msg, err := render(eventType, user.Locale, d)
+	if err != nil {
+		slog.Error("notification not rendered", "event_type", eventType, "user_id", user.ID, "error", err)
+		return
+	}
+	msg.Link = link
+	msg.mail = mail
+
+	notification := models.Notification{
+		UserID: user.ID,
+		Type:   eventType,
+		Title:  msg.Title,
+		Body:   msg.Body,
+		Link:   link,
+	}
+	if err := notification.CreateNotification(); err != nil {
+		slog.Error("notification not stored", "event_type", eventType, "user_id", user.ID, "error", err)
+	}
+
+	if !models.NotificationEnabled(user.ID, eventType) {
+		return
+	}
+
+	contact := Contact{User: &user}
+	channels := models.GetNotificationChannels(user.ID)
+	phoneLooked := false
+	for _, channel := range channels {
+		if channel != models.ChannelEmail && !phoneLooked {
+			contact.Phone = contactPhone(&user)
+			phoneLooked = true
+		}
+		notifier, ok := notifiers[channel]
+		if !ok || !notifier.Reaches(contact) {
+			continue
+		}
+		if err := notifier.Send(contact, msg); err != nil {
+			slog.Warn("notification not sent", "event_type", eventType, "channel", channel, "user_id", user.ID, "error", err)
+			continue
+		}
+		return
+	}
+	slog.Warn("notification reached no channel", "event_type", eventType, "channels", channels, "user_id", user.ID)
+}
+
+func Deposit(eventType string, deposit *models.DepositRequest) {
+	d := details{
+		Amount: fmt.Sprintf("%s %s", deposit.FiatAmount, deposit.Currency),
+		Crypto: fmt.Sprintf("%s %s", deposit.AssetEquivalent, deposit.ProposedAsset),
+		Ref:    deposit.Ref,
+	}
+	switch eventType {
+	case models.WebhookDepositApproved, models.WebhookDepositRejected:
+		notify(deposit.UserID, eventType, d, transactionsLink, nil)
+	}
+}
+
+func Withdrawal(eventType string, withdrawal *models.WithdrawalRequest) {
+	d := details{
+		Amount:  fmt.Sprintf("%s %s", withdrawal.EquivalentFiat, withdrawal.FiatCurrency),
+		Crypto:  fmt.Sprintf("%s %s", withdrawal.CryptoAmount, withdrawal.Asset),
+		Bank:    withdrawal.BankName,
+		Account: withdrawal.AccountNumber,
+	}
+	switch eventType {
+	case models.WebhookWithdrawalSubmitted:
+		notify(withdrawal.UserID, eventType, d, transactionsLink, nil)
+	case models.WebhookWithdrawalCompleted:
+		notify(withdrawal.UserID, eventType, d, transactionsLink, func(user *models.User) error {
+			return offRampMail(user, withdrawal)
+		})
+	}
+}
+
+func KYC(eventType string, kyc *models.KYC) {
+	switch eventType {
+	case models.WebhookKYCApproved, models.WebhookKYCRejected:
+		notify(kyc.UserID, eventType, details{Reason: kyc.RejectionReason}, kycLink, nil)
+	}
+}
+
+// Hurupay notifies about mobile money collections and payouts once they
+// settle, intermediate provider events are skipped
+func Hurupay(hurupayEvent string, request *models.HurupayRequest) {
+	eventType := models.HurupayEventType(hurupayEvent)
+	d := details{
+		Amount: fmt.Sprintf("%s %s", request.Amount, request.CountryCurrency),
+		Mobile: request.MobileNumber,
+	}
+
+	switch eventType {
+	case models.WebhookCollectionCompleted, models.WebhookCollectionFailed,
+		models.WebhookPayoutCompleted, models.WebhookPayoutFailed:
+		notify(uint(request.UserId), eventType, d, transactionsLink, nil)
+	}
+}
+
+func offRampMail(user *models.User, withdrawal *models.WithdrawalRequest) error {
+	floatAmount, err := strconv.ParseFloat(withdrawal.EquivalentFiat, 64)
+	if err != nil {
+		return err
+	}
+	data := serializers.UserOffRampMail{
+		Name:          fmt.Sprintf("%s %s", user.LastName, user.FirstName),
+		Amount:        utils.FormatAmountWithCommas(floatAmount),
+		Currency:      withdrawal.FiatCurrency,
+		Ref:           withdrawal.BankRef,
+		BankName:      withdrawal.BankName,
+		AccountNumber: withdrawal.AccountNumber,
+		AccountName:   withdrawal.AccountName,
+	}
+	return mails.UserOffRampMail(tenancy.MailRecipient(user), data)
+}
@@ -0,0 +1,116 @@
+package notifications
+
+import (
+	"log/slog"
+
+	"backend/apis"
+	"backend/models"
+	"backend/state"
+	"backend/utils/mails"
+	"backend/utils/tenancy"
+)
+
+// Message is one event rendered in the recipient's locale
+type Message struct {
+	EventType string
+	Title     string
+	Body      string
+	Text      string // short form for SMS and WhatsApp
+	Link      string
+
+	// mail replaces the generic notification mail for events with a richer one
+	mail func(user *models.User) error
+}
+
+// Contact is where a user can be reached outside the app
+type Contact struct {
+	User  *models.User
+	Phone string // E.164, empty when unknown
+}
+
+// Notifier delivers messages over one channel
+type Notifier interface {
+	Channel() string
+	// Reaches reports whether the contact has an address on the channel
+	Reaches(to Contact) bool
+	Send(to Contact, msg Message) error
+}
+
+// notifiers holds the enabled channels, channels missing here are skipped
+var notifiers = map[string]Notifier{}
+
+// Register enables a channel, replacing its current notifier
+func Register(n Notifier) {
+	notifiers[n.Channel()] = n
+}
+
+// Setup registers the notifiers the configuration allows. SMS and WhatsApp
+// without Twilio credentials are logged outside production and disabled in it.
+func Setup() {
+	Register(EmailNotifier{})
+
+	cfg := state.AppConfig
+	configured := cfg.TwilioAccountSid != "" && cfg.TwilioAuthToken != ""
+	for channel, from := range map[string]string{
+		models.ChannelSMS:      cfg.TwilioSMSFrom,
+		models.ChannelWhatsApp: cfg.TwilioWhatsAppFrom,
+	} {
+		switch {
+		case configured && from != "":
+			Register(TwilioNotifier{channel: channel, from: from})
+		case cfg.AppEnv != "production":
+			Register(LogNotifier{Name: channel})
+		}
+	}
+}
+
+// EmailNotifier sends messages through the mail outbox
+type EmailNotifier struct{}
+
+func (EmailNotifier) Channel() string { return models.ChannelEmail }
+
+func (EmailNotifier) Reaches(to Contact) bool { return to.User.Email != "" }
+
+func (EmailNotifier) Send(to Contact, msg Message) error {
+	if msg.mail != nil {
+		return msg.mail(to.User)
+	}
+	return mails.SendNotificationMail(tenancy.MailRecipient(to.User), msg.Title, msg.Body)
+}
+
+// TwilioNotifier sends the short text as an SMS or WhatsApp message
+type TwilioNotifier struct {
+	channel string
+	from    string
+}
+
+func (n TwilioNotifier) Channel() string { return n.channel }
+
+func (n TwilioNotifier) Reaches(to Contact) bool { return to.Phone != "" }
+
+func (n TwilioNotifier) Send(to Contact, msg Message) error {
+	from, phone := n.from, to.Phone
+	if n.channel == models.ChannelWhatsApp {
+		from, phone = "whatsapp:"+from, "whatsapp:"+phone
+	}
+	return apis.SendTwilioMessage(from, phone, msg.Text)
+}
+
+// LogNotifier only logs what it would send, for local runs and tests
+type LogNotifier struct {
+	Name string
+}
+
+func (n LogNotifier) Channel() string { return n.Name }
+
+func (n LogNotifier) Reaches(to Contact) bool {
+	if n.Name == models.ChannelEmail {
+		return to.User.Email != ""
+	}
+	return to.Phone != ""
+}
+
+func (n LogNotifier) Send(to Contact, msg Message) error {
+	slog.Info("notification", "channel", n.Name, "event", msg.EventType, "user_id", to.User.ID, "text", msg.Text)
+	return nil
+}
@@ -0,0 +1,98 @@
+package notifications
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"backend/models"
+	"backend/serializers"
+)
+
+var (
+	dialCodesOnce sync.Once
+	dialCodes     map[string]string // alpha-2 country code to digits, e.g. GH: 233
+)
+
+// loadDialCodes reads the mobile money countries from templates/network.json
+func loadDialCodes() {
+	dialCodes = map[string]string{}
+
+	root, _ := os.Getwd()
+	jsonData, err := os.ReadFile(filepath.Join(root, "templates", "network.json"))
+	if err != nil {
+		slog.Error("network.json not read", "error", err)
+		return
+	}
+	var networks []serializers.NetworkData
+	if err := json.Unmarshal(jsonData, &networks); err != nil {
+		slog.Error("network.json not parsed", "error", err)
+		return
+	}
+	for _, network := range networks {
+		dialCodes[strings.ToUpper(network.CountryCode)] = strings.TrimPrefix(network.MobileCode, "+")
+	}
+}
+
+// NormalizePhone formats a phone number as E.164. Local numbers, with or
+// without the trunk 0, take the dial code of the alpha-2 country code.
+func NormalizePhone(raw, countryCode string) (string, error) {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, raw)
+	trimmed := strings.TrimSpace(raw)
+
+	dialCodesOnce.Do(loadDialCodes)
+	dialCode := dialCodes[strings.ToUpper(countryCode)]
+
+	switch {
+	case strings.HasPrefix(trimmed, "+"):
+	case strings.HasPrefix(digits, "00"):
+		digits = digits[2:]
+	case dialCode != "" && strings.HasPrefix(digits, dialCode) && len(digits) > len(dialCode)+8:
+		// already international without the plus sign
+	case dialCode != "":
+		digits = dialCode + strings.TrimPrefix(digits, "0")
+	default:
+		return "", errors.New("cannot normalize local phone number without a supported country")
+	}
+
+	if len(digits) < 8 || len(digits) > 15 || digits[0] == '0' {
+		return "", errors.New("invalid phone number: " + raw)
+	}
+	return "+" + digits, nil
+}
+
+// contactPhone finds the user's phone number, preferring the verified KYC
+// phone over the last mobile money wallet the user collected from
+func contactPhone(user *models.User) string {
+	if kyc, err := models.GetKYCByUserID(user.ID); err == nil && kyc.Phone != "" {
+		if phone, err := NormalizePhone(kyc.Phone, user.CountryCode); err == nil {
+			return phone
+		}
+	}
+
+	request, err := models.GetLatestCollectionNumber(user.ID)
+	if err != nil {
+		return ""
+	}
+	countryCode := request.CountryCode
+	if countryCode == "" {
+		countryCode = request.CountryCurrency
+	}
+	if countryCode == "" {
+		countryCode = user.CountryCode
+	}
+	phone, err := NormalizePhone(request.MobileNumber, countryCode)
+	if err != nil {
+		return ""
+	}
+	return phone
+}
@@ -0,0 +1,85 @@
+package notifications
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+
+	"backend/models"
+)
+
+// Message templates live in templates/<locale>.tmpl and define
+// "<event>.title", "<event>.body" and "<event>.text" for every event type
+//
+//go:embed templates
+var embedded embed.FS
+
+const defaultLocale = models.LocaleEnglish
+
+var (
+	parseOnce sync.Once
+	parsed    map[string]*template.Template
+	parseErr  error
+)
+
+// details is the data every message template executes with
+type details struct {
+	Sender  string // brand name, prefixes the short texts
+	Amount  string // fiat amount with currency
+	Crypto  string // crypto amount with asset
+	Bank    string
+	Account string
+	Ref     string
+	Reason  string
+	Mobile  string
+}
+
+// render builds the event's message in the locale, parts missing from the
+// locale fall back to English
+func render(eventType, locale string, d details) (Message, error) {
+	parseOnce.Do(parseTemplates)
+	if parseErr != nil {
+		return Message{}, parseErr
+	}
+
+	msg := Message{EventType: eventType}
+	for _, part := range []struct {
+		name string
+		dst  *string
+	}{
+		{"title", &msg.Title},
+		{"body", &msg.Body},
+		{"text", &msg.Text},
+	} {
+		name := eventType + "." + part.name
+		tmpl := parsed[locale]
+		if tmpl == nil || tmpl.Lookup(name) == nil {
+			tmpl = parsed[defaultLocale]
+		}
+		if tmpl.Lookup(name) == nil {
+			return Message{}, fmt.Errorf("notification template not found: %s", name)
+		}
+
+		var buf bytes.Buffer
+		if err := tmpl.ExecuteTemplate(&buf, name, d); err != nil {
+			return Message{}, fmt.Errorf("rendering %s: %w", name, err)
+		}
+		*part.dst = strings.TrimSpace(buf.String())
+	}
+	return msg, nil
+}
+
+func parseTemplates() {
+	parsed = make(map[string]*template.Template, len(models.SupportedLocales))
+	for _, locale := range models.SupportedLocales {
+		tmpl, err := template.ParseFS(embedded, "templates/"+locale+".tmpl")
+		if err != nil {
+			parseErr = err
+			return
+		}
+		parsed[locale] = tmpl
+	}
+}
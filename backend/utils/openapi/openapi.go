@@ -0,0 +1,563 @@
+// Package openapi builds the OpenAPI 3 specification of the API from the
+// registered gin routes and the annotations of their handlers. Request and
+// response schemas come from the serializer and model types through
+// reflection, so the spec follows the code. The same spec validates request
+// bodies and checks responses for drift, see middlewares.Contract.
+package openapi
+
+import (
+	"backend/models"
+	"backend/utils/apperrors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Security schemes of the API
+const (
+	AuthBearer    = "bearerAuth"
+	AuthPartner   = "partnerSignature"
+	AuthSignature = "webhookSignature"
+	AuthNone      = "none"
+)
+
+// Operation annotates a handler
+type Operation struct {
+	Summary     string
+	Description string
+	// Auth is the security scheme, AuthBearer when empty. Routes under
+	// /api/partner use AuthPartner whatever the handler's annotation.
+	Auth string
+	// Query lists the query parameters, QueryStruct adds the form fields of a
+	// filter struct bound with ShouldBind
+	Query       []Param
+	QueryStruct interface{}
+	// Request is a value of the JSON body's type, Form marks a multipart
+	// upload instead
+	Request interface{}
+	Form    []Param
+	// Response is a value of the type of the success response's "data"
+	Response interface{}
+	// List marks cursor paginated lists, they take the list parameters and
+	// answer with "meta"
+	List bool
+	// Idempotent routes accept an Idempotency-Key header
+	Idempotent bool
+	// Status is the success status, 200 when zero
+	Status int
+	// Produces is the content type of a success response that is not JSON,
+	// such as a file download or an event stream
+	Produces string
+	Hidden   bool
+}
+
+// Param is a query, header or form parameter
+type Param struct {
+	Name        string
+	Description string
+	Required    bool
+	Type        string
+}
+
+// Q is an optional string query parameter
+func Q(name, description string) Param {
+	return Param{Name: name, Description: description, Type: "string"}
+}
+
+// Annotation is the Operation of a handler
+type Annotation struct {
+	handler   string
+	operation Operation
+}
+
+// Annotations of every handler of the API
+type Annotations []Annotation
+
+// For annotates the handler
+func For(handler gin.HandlerFunc, op Operation) Annotation {
+	return Annotation{handler: handlerName(handler), operation: op}
+}
+
+// Lookup is the operation of the handler, named as gin reports it
+func (a Annotations) Lookup(handler string) (Operation, bool) {
+	for _, annotation := range a {
+		if annotation.handler == handler {
+			return annotation.operation, true
+		}
+	}
+	return Operation{}, false
+}
+
+// Unrouted lists the annotated handlers no route serves, their annotation is
+// stale
+func (a Annotations) Unrouted(routes gin.RoutesInfo) []string {
+	routed := map[string]bool{}
+	for _, route := range routes {
+		routed[route.Handler] = true
+	}
+	var unrouted []string
+	for _, annotation := range a {
+		if !routed[annotation.handler] {
+			unrouted = append(unrouted, annotation.handler)
+		}
+	}
+	return unrouted
+}
+
+// handlerName is the name gin reports for a handler in RoutesInfo
+func handlerName(handler gin.HandlerFunc) string {
+	return runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
+}
+
+// Document is an OpenAPI 3.0 document
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Servers    []Server            `json:"servers,omitempty"`
+	Tags       []Tag               `json:"tags,omitempty"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+
+	routes map[string]*Route
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type Server struct {
+	URL string `json:"url"`
+}
+
+type Tag struct {
+	Name string `json:"name"`
+}
+
+// PathItem maps lower case methods to their operation
+type PathItem map[string]*OperationObject
+
+type OperationObject struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Description  string `json:"description,omitempty"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+}
+
+// Route is what the contract checks of a route need
+type Route struct {
+	// Request is the JSON body's schema, nil when the body is not checked
+	Request *Schema
+	// Response is the success response's schema, nil when it is not JSON
+	Response *Schema
+	Status   int
+}
+
+const (
+	jsonContent    = "application/json"
+	errorComponent = "Error"
+)
+
+var listParams = []Param{
+	Q("cursor", "opaque cursor from meta.next_cursor or meta.prev_cursor"),
+	{Name: "limit", Description: "page size", Type: "integer"},
+	Q("sort", "sort field, prefixed with - for descending"),
+	Q("from", "YYYY-MM-DD, inclusive"),
+	Q("to", "YYYY-MM-DD, inclusive"),
+	{Name: "min_amount", Type: "number"},
+	{Name: "max_amount", Type: "number"},
+	Q("status", "comma separated or repeated"),
+	Q("q", "search text"),
+}
+
+var current atomic.Pointer[Document]
+
+// Current is the document loaded at startup, nil before Load
+func Current() *Document {
+	return current.Load()
+}
+
+// Load builds the document of the routes and makes it current. It returns
+// the routes whose handler has no annotation, they are documented with
+// generic schemas.
+func Load(routes gin.RoutesInfo, annotations Annotations) []string {
+	doc, undocumented := Build(routes, annotations)
+	current.Store(doc)
+	return undocumented
+}
+
+// Build builds the document of the routes
+func Build(routes gin.RoutesInfo, annotations Annotations) (*Document, []string) {
+	byHandler := map[string]Operation{}
+	for _, a := range annotations {
+		byHandler[a.handler] = a.operation
+	}
+
+	g := newGenerator()
+	rg := g.requests()
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info: Info{
+			Title:       "GreyBox API",
+			Description: "Errors answer with the Error schema, its code is stable and safe to branch on.",
+			Version:     "1.0.0",
+		},
+		Servers: []Server{{URL: "https://apis.greyboxpay.com"}},
+		Paths:   map[string]PathItem{},
+		routes:  map[string]*Route{},
+	}
+	g.schemas[errorComponent] = errorSchema()
+
+	var undocumented []string
+	var bodies []requestBody
+	operationIDs := map[string]bool{}
+	tags := map[string]bool{}
+	sorted := append(gin.RoutesInfo(nil), routes...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Path != sorted[j].Path {
+			return sorted[i].Path < sorted[j].Path
+		}
+		return sorted[i].Method < sorted[j].Method
+	})
+	for _, route := range sorted {
+		op, ok := byHandler[route.Handler]
+		if !ok {
+			undocumented = append(undocumented, route.Method+" "+route.Path+" ("+route.Handler+")")
+		}
+		if op.Hidden {
+			continue
+		}
+
+		tag := pathTag(route.Path)
+		tags[tag] = true
+		operation := &OperationObject{
+			OperationID: operationID(route, operationIDs),
+			Summary:     op.Summary,
+			Description: op.Description,
+			Tags:        []string{tag},
+			Responses:   map[string]*Response{},
+		}
+		operation.Parameters = parameters(g, route.Path, op)
+		if auth := routeAuth(route.Path, op); auth != AuthNone {
+			operation.Security = []map[string][]string{{auth: {}}}
+		}
+
+		compiled := &Route{Status: op.Status}
+		if compiled.Status == 0 {
+			compiled.Status = http.StatusOK
+		}
+		if op.Request != nil {
+			bodies = append(bodies, requestBody{operation, compiled, op.Request})
+		} else if len(op.Form) > 0 {
+			operation.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{"multipart/form-data": {Schema: formSchema(op.Form)}}}
+		}
+
+		success := &Response{Description: http.StatusText(compiled.Status)}
+		switch {
+		case compiled.Status == http.StatusSwitchingProtocols:
+		case op.Produces != "":
+			success.Content = map[string]MediaType{op.Produces: {}}
+		default:
+			compiled.Response = envelope(g, op)
+			success.Content = map[string]MediaType{jsonContent: {Schema: compiled.Response}}
+		}
+		operation.Responses[strconv.Itoa(compiled.Status)] = success
+		operation.Responses["default"] = &Response{
+			Description: "Error",
+			Content:     map[string]MediaType{jsonContent: {Schema: &Schema{Ref: refPrefix + errorComponent}}},
+		}
+
+		path := specPath(route.Path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = PathItem{}
+		}
+		doc.Paths[path][strings.ToLower(route.Method)] = operation
+		doc.routes[route.Method+" "+route.Path] = compiled
+	}
+
+	// request schemas last, a type that is also a response keeps the plain
+	// component name for the response
+	for _, body := range bodies {
+		body.route.Request = rg.of(body.value)
+		body.operation.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{jsonContent: {Schema: body.route.Request}}}
+	}
+
+	for tag := range tags {
+		doc.Tags = append(doc.Tags, Tag{Name: tag})
+	}
+	sort.Slice(doc.Tags, func(i, j int) bool { return doc.Tags[i].Name < doc.Tags[j].Name })
+	doc.Components = Components{Schemas: g.schemas, SecuritySchemes: securitySchemes()}
+	return doc, undocumented
+}
+
+// requestBody is a JSON request body whose schema is still to be built
+type requestBody struct {
+	operation *OperationObject
+	route     *Route
+	value     interface{}
+}
+
+// Lookup is the route of the method and gin path, nil when undocumented
+func (d *Document) Lookup(method, path string) *Route {
+	return d.routes[method+" "+path]
+}
+
+// envelope is the success response: errors, status and data, with meta for
+// lists. Handlers without a Response type may answer anything in data.
+func envelope(g *generator, op Operation) *Schema {
+	s := &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"errors": {Type: "boolean"},
+			"status": {Type: "string"},
+			"data":   {},
+		},
+	}
+	if op.Response != nil {
+		s.Properties["data"] = g.of(op.Response)
+		s.Required = append(s.Required, "data")
+	}
+	if op.List {
+		s.Properties["meta"] = g.of(models.PageInfo{})
+		s.Required = append(s.Required, "meta")
+	}
+	return s
+}
+
+func errorSchema() *Schema {
+	var codes []interface{}
+	for _, code := range apperrors.Codes() {
+		codes = append(codes, string(code))
+	}
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"errors":     {Type: "boolean", Enum: []interface{}{true}},
+			"code":       {Type: "string", Enum: codes, Description: "stable error code"},
+			"error":      {Type: "string", Description: "message safe to show to the user"},
+			"reason":     {Type: "string", Description: "refines the code, e.g. the limit or account status"},
+			"detail":     {Description: "what was invalid in the request"},
+			"data":       {Description: "details of the refusal, such as limits or dates"},
+			"request_id": {Type: "string", Description: "quote it to support, also in the X-Request-ID header"},
+		},
+		Required: []string{"errors", "code", "error", "request_id"},
+	}
+}
+
+func securitySchemes() map[string]SecurityScheme {
+	return map[string]SecurityScheme{
+		AuthBearer: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+		AuthPartner: {
+			Type: "apiKey", In: "header", Name: "X-Api-Key",
+			Description: "Partner API key. Requests also carry X-Timestamp (unix seconds) and X-Signature, the base64 HMAC-SHA256 of the request with the key's secret.",
+		},
+		AuthSignature: {
+			Type: "apiKey", In: "header", Name: "x-signature",
+			Description: "RSA signature of the body by the provider",
+		},
+	}
+}
+
+func routeAuth(path string, op Operation) string {
+	if strings.HasPrefix(path, "/api/partner/") {
+		return AuthPartner
+	}
+	if op.Auth == "" {
+		return AuthBearer
+	}
+	return op.Auth
+}
+
+// onBehalfPaths are the partner routes acting for a sub-user
+var onBehalfPaths = regexp.MustCompile(`^/api/partner/v1/(accounts|transactions|on-ramp|off-ramp)`)
+
+func parameters(g *generator, path string, op Operation) []Parameter {
+	var params []Parameter
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			params = append(params, Parameter{Name: segment[1:], In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+	}
+	query := op.Query
+	if op.List {
+		query = append(append([]Param(nil), query...), listParams...)
+	}
+	if op.QueryStruct != nil {
+		query = append(append([]Param(nil), query...), structParams(op.QueryStruct)...)
+	}
+	for _, p := range query {
+		params = append(params, Parameter{Name: p.Name, In: "query", Description: p.Description, Required: p.Required, Schema: paramSchema(p)})
+	}
+	if op.Idempotent {
+		params = append(params, Parameter{
+			Name: "Idempotency-Key", In: "header", Schema: &Schema{Type: "string"},
+			Description: "retries with the same key and body replay the first response for a day",
+		})
+	}
+	if onBehalfPaths.MatchString(path) {
+		params = append(params, Parameter{
+			Name: "X-On-Behalf-Of", In: "header", Required: true, Schema: &Schema{Type: "integer"},
+			Description: "ID of the partner's sub-user the request acts for",
+		})
+	}
+	return params
+}
+
+func paramSchema(p Param) *Schema {
+	if p.Type == "" {
+		return &Schema{Type: "string"}
+	}
+	return &Schema{Type: p.Type}
+}
+
+// structParams lists the form fields of a filter struct as query parameters
+func structParams(value interface{}) []Param {
+	t := reflect.TypeOf(value)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	var params []Param
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, _, _ := strings.Cut(sf.Tag.Get("form"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		p := Param{Name: name, Required: bindingRequired(sf.Tag.Get("binding")), Type: "string"}
+		switch sf.Type.Kind() {
+		case reflect.Bool:
+			p.Type = "boolean"
+		case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+			p.Type = "integer"
+		case reflect.Float64:
+			p.Type = "number"
+		}
+		params = append(params, p)
+	}
+	return params
+}
+
+func formSchema(fields []Param) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for _, f := range fields {
+		prop := paramSchema(f)
+		if f.Type == "file" {
+			prop = &Schema{Type: "string", Format: "binary"}
+		}
+		prop.Description = f.Description
+		s.Properties[f.Name] = prop
+		if f.Required {
+			s.Required = append(s.Required, f.Name)
+		}
+	}
+	return s
+}
+
+// specPath turns gin's :id and *path parameters into {id} and {path}
+func specPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// tagAliases group paths under the sections the frontend knows
+var tagAliases = map[string]string{
+	"auth":                "user",
+	"kyc-documents":       "kyc",
+	"notification":        "webhook",
+	"statement-downloads": "statements",
+	"master-wallet":       "wallets",
+	"master-wallets":      "wallets",
+	"exchange-rate":       "rates",
+}
+
+// pathTag groups a route by the first segment after the API version, admin
+// routes by the segment after admin
+func pathTag(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) >= 2 && segments[0] == "api" && segments[1] == "partner" {
+		return "partner"
+	}
+	if len(segments) < 3 {
+		return "api"
+	}
+	tag := segments[2]
+	if tag == "admin" && len(segments) > 3 {
+		tag = "admin " + segments[3]
+	}
+	if alias, ok := tagAliases[tag]; ok {
+		return alias
+	}
+	return tag
+}
+
+// operationID is the handler's name, with the tag when the handler serves
+// several routes
+func operationID(route gin.RouteInfo, taken map[string]bool) string {
+	name := route.Handler
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	id := name
+	if taken[id] {
+		id = name + "_" + strings.ReplaceAll(pathTag(route.Path), " ", "_")
+	}
+	for n := 2; taken[id]; n++ {
+		id = fmt.Sprintf("%s_%d", name, n)
+	}
+	taken[id] = true
+	return id
+}
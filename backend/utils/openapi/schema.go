@@ -0,0 +1,355 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Schema is an OpenAPI 3.0 schema object
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     bool               `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     bool               `json:"exclusiveMaximum,omitempty"`
+}
+
+const refPrefix = "#/components/schemas/"
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	deletedAtType   = reflect.TypeOf(gorm.DeletedAt{})
+	rawMessageType  = reflect.TypeOf(json.RawMessage{})
+	marshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// generator turns Go types into schemas the way encoding/json and gin's
+// binding see them, named types become components. A request generator
+// describes what a client may send, structs are open and only their binding
+// rules are enforced.
+type generator struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+	request bool
+}
+
+func newGenerator() *generator {
+	return &generator{schemas: map[string]*Schema{}, names: map[reflect.Type]string{}}
+}
+
+// requests is a request generator adding its components to the same document
+func (g *generator) requests() *generator {
+	return &generator{schemas: g.schemas, names: map[reflect.Type]string{}, request: true}
+}
+
+// of is the schema of the value's type, nil for a nil value
+func (g *generator) of(value interface{}) *Schema {
+	if value == nil {
+		return nil
+	}
+	return g.schema(reflect.TypeOf(value))
+}
+
+func (g *generator) schema(t reflect.Type) *Schema {
+	nullable := false
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		nullable = true
+	}
+	s := g.schemaOf(t)
+	if nullable {
+		return nullableSchema(s)
+	}
+	return s
+}
+
+func nullableSchema(s *Schema) *Schema {
+	if s.Ref != "" {
+		return &Schema{AllOf: []*Schema{s}, Nullable: true}
+	}
+	copied := *s
+	copied.Nullable = true
+	return &copied
+}
+
+func (g *generator) schemaOf(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case deletedAtType:
+		return &Schema{Type: "string", Format: "date-time", Nullable: true}
+	case rawMessageType:
+		return &Schema{}
+	}
+	if implements(t, marshalerType) || g.request && implements(t, unmarshalerType) {
+		// a custom encoding can be anything
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte", Nullable: true}
+		}
+		// a nil slice is encoded as null
+		return &Schema{Type: "array", Items: g.schema(t.Elem()), Nullable: true}
+	case reflect.Array:
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem()), Nullable: true}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		return g.ref(t)
+	}
+	return &Schema{}
+}
+
+// ref registers the named struct as a component, named after its package and
+// type, e.g. models.Beneficiary
+func (g *generator) ref(t reflect.Type) *Schema {
+	name, ok := g.names[t]
+	if !ok {
+		name = componentName(t)
+		if _, taken := g.schemas[name]; taken && g.request {
+			// the type is also a response, whose schema is stricter
+			name += "Input"
+		}
+		g.names[t] = name
+		// registered before it is built so recursive types end on the ref
+		g.schemas[name] = &Schema{}
+		*g.schemas[name] = *g.structSchema(t)
+	}
+	return &Schema{Ref: refPrefix + name}
+}
+
+func implements(t, iface reflect.Type) bool {
+	return t.Implements(iface) || reflect.PointerTo(t).Implements(iface)
+}
+
+func componentName(t reflect.Type) string {
+	pkg := t.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	name := strings.NewReplacer("[", "_", "]", "", "*", "", "/", "_", " ", "").Replace(t.Name())
+	if pkg == "" {
+		return name
+	}
+	return pkg + "." + name
+}
+
+// field is a struct field as encoding/json sees it
+type field struct {
+	name      string
+	typ       reflect.Type
+	omitEmpty bool
+	asString  bool
+	binding   string
+}
+
+// structSchema builds the object schema of the struct. In a request the
+// required fields are the binding's. In a response every field without
+// omitempty is always present and no other field is.
+func (g *generator) structSchema(t reflect.Type) *Schema {
+	fields := jsonFields(t)
+	request := g.request
+
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for _, f := range fields {
+		prop := g.schema(f.typ)
+		if f.asString {
+			prop = &Schema{Type: "string"}
+		}
+		if f.binding != "" {
+			prop = withBinding(prop, f.binding)
+		}
+		s.Properties[f.name] = prop
+		if request && bindingRequired(f.binding) || !request && !f.omitEmpty {
+			s.Required = append(s.Required, f.name)
+		}
+	}
+	if !request {
+		s.AdditionalProperties = false
+	}
+	return s
+}
+
+// jsonFields lists the fields encoding/json writes, fields of embedded
+// structs are promoted unless a shallower field has the same name
+func jsonFields(t reflect.Type) []field {
+	var fields []field
+	seen := map[string]bool{}
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		ft := sf.Type
+		if sf.Anonymous && name == "" {
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded = append(embedded, ft)
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		f := field{name: name, typ: sf.Type, binding: sf.Tag.Get("binding")}
+		for _, opt := range strings.Split(opts, ",") {
+			switch opt {
+			case "omitempty":
+				f.omitEmpty = true
+			case "string":
+				f.asString = isScalar(sf.Type)
+			}
+		}
+		seen[name] = true
+		fields = append(fields, f)
+	}
+	for _, et := range embedded {
+		for _, f := range jsonFields(et) {
+			if !seen[f.name] {
+				seen[f.name] = true
+				fields = append(fields, f)
+			}
+		}
+	}
+	return fields
+}
+
+func isScalar(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.String:
+		return true
+	}
+	return false
+}
+
+func bindingRequired(binding string) bool {
+	for _, rule := range strings.Split(binding, ",") {
+		if rule == "required" {
+			return true
+		}
+	}
+	return false
+}
+
+// withBinding adds the binding rules of a field that a schema can express:
+// oneof, min, max, len, gt, gte, lt, lte and email. With omitempty the zero
+// value passes whatever the bounds, so they are left out.
+func withBinding(s *Schema, binding string) *Schema {
+	if s.Ref != "" || len(s.AllOf) > 0 {
+		return s
+	}
+	copied := *s
+	s = &copied
+	rules := strings.Split(binding, ",")
+	omitEmpty := false
+	for _, rule := range rules {
+		if rule == "dive" {
+			break
+		}
+		omitEmpty = omitEmpty || rule == "omitempty"
+	}
+	for _, rule := range rules {
+		if rule == "dive" {
+			// the rules after dive are for the items
+			break
+		}
+		name, arg, _ := strings.Cut(rule, "=")
+		switch name {
+		case "email":
+			s.Format = "email"
+		case "oneof":
+			for _, value := range strings.Fields(arg) {
+				if s.Type == "string" {
+					s.Enum = append(s.Enum, value)
+				} else if n, err := strconv.ParseFloat(value, 64); err == nil {
+					s.Enum = append(s.Enum, n)
+				}
+			}
+			if omitEmpty {
+				if s.Type == "string" {
+					s.Enum = append(s.Enum, "")
+				} else {
+					s.Enum = append(s.Enum, 0.0)
+				}
+			}
+		case "min", "max", "len", "gt", "gte", "lt", "lte":
+			n, err := strconv.ParseFloat(arg, 64)
+			if err != nil || omitEmpty {
+				continue
+			}
+			applyBound(s, name, n)
+		}
+	}
+	return s
+}
+
+// applyBound sets a length bound on strings and a value bound on numbers
+func applyBound(s *Schema, rule string, n float64) {
+	switch s.Type {
+	case "string":
+		length := int(n)
+		switch rule {
+		case "min", "gte":
+			s.MinLength = &length
+		case "max", "lte":
+			s.MaxLength = &length
+		case "len":
+			s.MinLength, s.MaxLength = &length, &length
+		}
+	case "integer", "number":
+		switch rule {
+		case "min", "gte":
+			s.Minimum = &n
+		case "gt":
+			s.Minimum, s.ExclusiveMinimum = &n, true
+		case "max", "lte":
+			s.Maximum = &n
+		case "lt":
+			s.Maximum, s.ExclusiveMaximum = &n, true
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package openapi
+
+import (
+	"bytes"
+	"html/template"
+)
+
+// uiPage loads Swagger UI from its CDN and points it at the spec
+var uiPage = template.Must(template.New("docs").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>GreyBox API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.onload = () => {
+      window.ui = SwaggerUIBundle({url: {{.}}, dom_id: "#swagger-ui", persistAuthorization: true});
+    };
+  </script>
+</body>
+</html>
+`))
+
+// UI is the Swagger UI page of the spec served at specURL
+func UI(specURL string) ([]byte, error) {
+	var page bytes.Buffer
+	if err := uiPage.Execute(&page, specURL); err != nil {
+		return nil, err
+	}
+	return page.Bytes(), nil
+}
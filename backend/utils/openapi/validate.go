@@ -0,0 +1,194 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxProblems caps the problems reported for one value
+const maxProblems = 20
+
+// ValidateJSON checks a JSON document against the schema and returns the
+// problems found, each prefixed with its location such as body.data.amount
+func (d *Document) ValidateJSON(s *Schema, raw []byte, root string) []string {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return []string{root + ": is not valid JSON"}
+	}
+	var problems []string
+	d.validate(s, value, root, &problems)
+	return problems
+}
+
+// Validate checks a JSON response of the route, given by method and gin path,
+// against the spec: a success response against the route's status and
+// schema, an error response against the Error schema. Responses that are not
+// JSON, and routes that are not documented, are not checked.
+func (d *Document) Validate(method, path string, status int, contentType string, body []byte) []string {
+	route := d.Lookup(method, path)
+	if route == nil || !strings.HasPrefix(contentType, jsonContent) {
+		return nil
+	}
+	if status >= 400 {
+		return d.ValidateJSON(&Schema{Ref: refPrefix + errorComponent}, body, "body")
+	}
+	if route.Response == nil {
+		return nil
+	}
+	var problems []string
+	if status != route.Status {
+		problems = append(problems, fmt.Sprintf("status %d is not the documented %d", status, route.Status))
+	}
+	return append(problems, d.ValidateJSON(route.Response, body, "body")...)
+}
+
+func (d *Document) resolve(s *Schema) *Schema {
+	for s != nil && s.Ref != "" {
+		s = d.Components.Schemas[strings.TrimPrefix(s.Ref, refPrefix)]
+	}
+	return s
+}
+
+func (d *Document) validate(s *Schema, value interface{}, path string, problems *[]string) {
+	if len(*problems) >= maxProblems {
+		return
+	}
+	s = d.resolve(s)
+	if s == nil {
+		return
+	}
+	if value == nil {
+		if !s.Nullable && (s.Type != "" || len(s.AllOf) > 0) {
+			*problems = append(*problems, path+": must not be null")
+		}
+		return
+	}
+	for _, sub := range s.AllOf {
+		d.validate(sub, value, path, problems)
+	}
+	if len(s.Enum) > 0 && !inEnum(s.Enum, value) {
+		*problems = append(*problems, fmt.Sprintf("%s: must be one of %v", path, s.Enum))
+	}
+
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			*problems = append(*problems, path+": must be an object")
+			return
+		}
+		d.validateObject(s, object, path, problems)
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			*problems = append(*problems, path+": must be an array")
+			return
+		}
+		for i, item := range items {
+			d.validate(s.Items, item, fmt.Sprintf("%s[%d]", path, i), problems)
+		}
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			*problems = append(*problems, path+": must be a string")
+			return
+		}
+		validateString(s, text, path, problems)
+	case "integer", "number":
+		number, ok := value.(json.Number)
+		if !ok {
+			*problems = append(*problems, path+": must be a number")
+			return
+		}
+		validateNumber(s, number, path, problems)
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			*problems = append(*problems, path+": must be a boolean")
+		}
+	}
+}
+
+func (d *Document) validateObject(s *Schema, object map[string]interface{}, path string, problems *[]string) {
+	for _, name := range s.Required {
+		if _, ok := object[name]; !ok {
+			*problems = append(*problems, path+"."+name+": is required")
+		}
+	}
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if prop, ok := s.Properties[name]; ok {
+			d.validate(prop, object[name], path+"."+name, problems)
+			continue
+		}
+		switch extra := s.AdditionalProperties.(type) {
+		case bool:
+			if !extra {
+				*problems = append(*problems, path+"."+name+": is not in the specification")
+			}
+		case *Schema:
+			d.validate(extra, object[name], path+"."+name, problems)
+		}
+	}
+}
+
+func validateString(s *Schema, text, path string, problems *[]string) {
+	length := utf8.RuneCountInString(text)
+	if s.MinLength != nil && length < *s.MinLength {
+		*problems = append(*problems, fmt.Sprintf("%s: must be at least %d characters", path, *s.MinLength))
+	}
+	if s.MaxLength != nil && length > *s.MaxLength {
+		*problems = append(*problems, fmt.Sprintf("%s: must be at most %d characters", path, *s.MaxLength))
+	}
+	if s.Format == "date-time" {
+		if _, err := time.Parse(time.RFC3339Nano, text); err != nil {
+			*problems = append(*problems, path+": must be an RFC 3339 date-time")
+		}
+	}
+}
+
+func validateNumber(s *Schema, number json.Number, path string, problems *[]string) {
+	if s.Type == "integer" && strings.ContainsAny(number.String(), ".eE") {
+		*problems = append(*problems, path+": must be an integer")
+		return
+	}
+	value, err := number.Float64()
+	if err != nil {
+		*problems = append(*problems, path+": must be a number")
+		return
+	}
+	if s.Minimum != nil && (value < *s.Minimum || s.ExclusiveMinimum && value == *s.Minimum) {
+		*problems = append(*problems, fmt.Sprintf("%s: must be more than %v", path, *s.Minimum))
+	}
+	if s.Maximum != nil && (value > *s.Maximum || s.ExclusiveMaximum && value == *s.Maximum) {
+		*problems = append(*problems, fmt.Sprintf("%s: must be less than %v", path, *s.Maximum))
+	}
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, allowed := range enum {
+		switch a := allowed.(type) {
+		case float64:
+			if n, ok := value.(json.Number); ok {
+				if f, err := n.Float64(); err == nil && f == a {
+					return true
+				}
+			}
+		default:
+			if allowed == value {
+				return true
+			}
+		}
+	}
+	return false
+}
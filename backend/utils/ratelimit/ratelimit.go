@@ -0,0 +1,43 @@
+package ratelimit
+
+import (
+	"backend/models"
+	"log/slog"
+	"time"
+)
+
+// Allow counts a hit against key, a limiter scope such as
+// "login:ip:1.2.3.4", and reports whether it is still within limit for the
+// current window. When the limit is exceeded it also returns how long until
+// the window resets. The counters live in the database so the limit holds
+// across instances; when it cannot be reached the hit is allowed and the
+// account lockout still applies.
+func Allow(key string, limit int, window time.Duration) (bool, time.Duration) {
+	count, expiresAt, err := models.HitRateLimit(key, window)
+	if err != nil {
+		slog.Error("rate limit not counted", "key", key, "error", err)
+		return true, 0
+	}
+	if count <= limit {
+		return true, 0
+	}
+	return false, time.Until(expiresAt)
+}
+
+// Reset clears the counter for key
+func Reset(key string) {
+	if err := models.ResetRateLimit(key); err != nil {
+		slog.Error("rate limit not reset", "key", key, "error", err)
+	}
+}
+
+// StartCleaner removes counters whose window ended every interval
+func StartCleaner(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if _, err := models.DeleteExpiredRateLimitCounters(); err != nil {
+			slog.Error("expired rate limit counters not removed", "error", err)
+		}
+	}
+}
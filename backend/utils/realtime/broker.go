@@ -0,0 +1,118 @@
+package realtime
+
+import (
+	"backend/models"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Event is streamed to the user it belongs to. Type is the kind of record
+// that changed (see models.ChangeTransaction and friends).
+type Event struct {
+	ID        string                 `json:"id"`
+	Type      string                 `json:"type"`
+	UserID    uint                   `json:"user_id"`
+	CreatedAt time.Time              `json:"created_at"`
+	Data      map[string]interface{} `json:"data"`
+}
+
+// Backend carries published events to every instance of the API, including
+// the one that published them. Events are published through the transaction
+// saving the record and must not reach clients before it commits.
+type Backend interface {
+	Publish(tx *gorm.DB, event Event) error
+	// Run delivers every published event to deliver until the process exits
+	Run(deliver func(Event))
+}
+
+// subscriberBuffer is how many events a slow client may fall behind before
+// further events are dropped for it
+const subscriberBuffer = 32
+
+// Subscription receives the events of one user until it is closed
+type Subscription struct {
+	C      chan Event
+	userID uint
+}
+
+var (
+	mu          sync.RWMutex
+	subscribers = map[uint]map[*Subscription]struct{}{}
+	backend     Backend
+)
+
+// Start wires saved model changes into the broker. With a nil backend events
+// only reach clients connected to this instance.
+func Start(b Backend) {
+	if b == nil {
+		b = memoryBackend{}
+	}
+	backend = b
+	go backend.Run(dispatch)
+
+	models.OnChange(Publish)
+}
+
+// Publish sends the change to every connected client of the user once tx
+// commits
+func Publish(tx *gorm.DB, change models.Change) error {
+	if backend == nil {
+		return nil
+	}
+	event := Event{
+		ID:        "evt_" + uuid.New().String(),
+		Type:      change.Kind,
+		UserID:    change.UserID,
+		CreatedAt: time.Now().UTC(),
+		Data:      change.Data,
+	}
+	return backend.Publish(tx, event)
+}
+
+func Subscribe(userID uint) *Subscription {
+	sub := &Subscription{C: make(chan Event, subscriberBuffer), userID: userID}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if subscribers[userID] == nil {
+		subscribers[userID] = map[*Subscription]struct{}{}
+	}
+	subscribers[userID][sub] = struct{}{}
+	return sub
+}
+
+func (s *Subscription) Close() {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(subscribers[s.userID], s)
+	if len(subscribers[s.userID]) == 0 {
+		delete(subscribers, s.userID)
+	}
+}
+
+// dispatch hands an event to the local subscribers of its user without
+// blocking on slow clients
+func dispatch(event Event) {
+	mu.RLock()
+	defer mu.RUnlock()
+	for sub := range subscribers[event.UserID] {
+		select {
+		case sub.C <- event:
+		default:
+		}
+	}
+}
+
+// memoryBackend keeps events inside this process. It cannot wait for the
+// transaction to commit, so it only suits a single instance in development.
+type memoryBackend struct{}
+
+func (memoryBackend) Publish(tx *gorm.DB, event Event) error {
+	dispatch(event)
+	return nil
+}
+
+func (memoryBackend) Run(func(Event)) {}
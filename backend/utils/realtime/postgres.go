@@ -0,0 +1,81 @@
+package realtime
+
+import (
+	"backend/models"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+)
+
+// notifyChannel is the LISTEN/NOTIFY channel shared by every API instance
+const notifyChannel = "greybox_events"
+
+// maxNotifyPayload stays under PostgreSQL's 8000 byte NOTIFY payload limit
+const maxNotifyPayload = 7900
+
+// PostgresBackend fans events out to every instance through LISTEN/NOTIFY
+type PostgresBackend struct {
+	DSN string
+}
+
+// Publish queues a NOTIFY on the saving transaction, PostgreSQL delivers it
+// on commit and drops it on rollback
+func (p *PostgresBackend) Publish(tx *gorm.DB, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if len(payload) > maxNotifyPayload {
+		// clients refetch the record when the details do not fit
+		event.Data = map[string]interface{}{"id": event.Data["id"], "truncated": true}
+		if payload, err = json.Marshal(event); err != nil {
+			return err
+		}
+	}
+	return models.Notify(tx, notifyChannel, string(payload))
+}
+
+// Run listens on a dedicated connection and reconnects with backoff when it
+// drops, events sent while disconnected are lost
+func (p *PostgresBackend) Run(deliver func(Event)) {
+	backoff := time.Second
+	for {
+		err := p.listen(deliver, func() { backoff = time.Second })
+		slog.Warn("realtime listener stopped", "retry_in", backoff, "error", err)
+		time.Sleep(backoff)
+		if backoff < time.Minute {
+			backoff *= 2
+		}
+	}
+}
+
+func (p *PostgresBackend) listen(deliver func(Event), connected func()) error {
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, p.DSN)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	if _, err := conn.Exec(ctx, "LISTEN "+notifyChannel); err != nil {
+		return err
+	}
+	connected()
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var event Event
+		if err := json.Unmarshal([]byte(notification.Payload), &event); err != nil {
+			slog.Warn("malformed realtime event dropped", "error", err)
+			continue
+		}
+		deliver(event)
+	}
+}
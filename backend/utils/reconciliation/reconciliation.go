@@ -0,0 +1,218 @@
+package reconciliation
+
+import (
+	"backend/apis"
+	"backend/models"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// settleDelay keeps scheduled runs off records providers may still be
+// settling
+const settleDelay = time.Hour
+
+// firstWindow is how far back a source is reconciled the first time
+const firstWindow = 24 * time.Hour
+
+// remote is what a provider or the chain reports for one record
+type remote struct {
+	Amount string
+	Status string
+	Detail string
+	// amountKnown is false when the remote record carries no comparable
+	// amount, existence is all that is checked then
+	amountKnown bool
+}
+
+// unknown is a remote record with no local row
+type unknown struct {
+	Reference string
+	UserID    uint
+	Amount    string
+	Status    string
+	Detail    string
+}
+
+// source compares one kind of local row with its remote records
+type source interface {
+	records(from, to time.Time) ([]models.ReconciliationRecord, error)
+	// fetch returns apis.ErrNotFound when the remote has no such record
+	fetch(record models.ReconciliationRecord) (remote, error)
+	// discover lists remote records in the window that have no local row
+	discover(from, to time.Time) ([]unknown, error)
+}
+
+var sources = map[string]source{
+	models.ReconcileBorderless: borderlessSource{},
+	models.ReconcileHurupay:    hurupaySource{},
+	models.ReconcileChain:      chainSource{},
+}
+
+// StartScheduler reconciles every source every interval, each run picking up
+// where the last scheduled run of the source ended
+func StartScheduler(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		to := time.Now().Add(-settleDelay).Truncate(time.Hour)
+		for _, name := range models.ReconciliationSources {
+			from, err := models.LastReconciledUntil(name)
+			if err != nil {
+				slog.Error("last reconciliation run not loaded", "source", name, "error", err)
+				continue
+			}
+			if from.IsZero() {
+				from = to.Add(-firstWindow)
+			}
+			if !to.After(from) {
+				continue
+			}
+			run := &models.ReconciliationRun{Source: name, From: from, To: to}
+			if err := run.CreateReconciliationRun(); err != nil {
+				slog.Error("reconciliation run not created", "source", name, "error", err)
+				continue
+			}
+			execute(run)
+		}
+	}
+}
+
+// Start creates an admin requested run and reconciles it in the background
+func Start(name string, from, to time.Time, requestedBy uint) (*models.ReconciliationRun, error) {
+	if _, ok := sources[name]; !ok {
+		return nil, fmt.Errorf("unknown reconciliation source: %s", name)
+	}
+	run := &models.ReconciliationRun{Source: name, From: from, To: to, RequestedByID: &requestedBy}
+	if err := run.CreateReconciliationRun(); err != nil {
+		return nil, err
+	}
+	go execute(run)
+	return run, nil
+}
+
+// execute classifies every local row of the window and every remote record
+// missing locally, then closes the run
+func execute(run *models.ReconciliationRun) {
+	err := reconcile(run, sources[run.Source])
+	if err != nil {
+		slog.Error("reconciliation run failed", "run_id", run.ID, "source", run.Source, "error", err)
+	}
+	if err := run.Finish(err); err != nil {
+		slog.Error("reconciliation run not finished", "run_id", run.ID, "error", err)
+	}
+}
+
+func reconcile(run *models.ReconciliationRun, s source) error {
+	records, err := s.records(run.From, run.To)
+	if err != nil {
+		return err
+	}
+
+	// items are kept until the run has them all, a failure on the way
+	// stores none and the next run of the window starts clean
+	var items []*models.ReconciliationItem
+	var lastErr error
+	for _, record := range records {
+		found, err := s.fetch(record)
+		item := &models.ReconciliationItem{
+			Reference:   record.Reference,
+			LocalTable:  record.Table,
+			LocalID:     &record.ID,
+			UserID:      &record.UserID,
+			LocalAmount: record.Amount,
+			LocalStatus: record.Status,
+		}
+		switch {
+		case errors.Is(err, apis.ErrNotFound):
+			item.Classification = models.ReconMissingRemote
+		case err != nil:
+			// an unreachable provider says nothing about the record
+			run.Unchecked++
+			lastErr = fmt.Errorf("%s %s: %w", record.Table, record.Reference, err)
+			continue
+		default:
+			item.RemoteAmount = found.Amount
+			item.RemoteStatus = found.Status
+			item.Detail = found.Detail
+			item.Classification = models.ReconMatched
+			if found.amountKnown && !sameAmount(record.Amount, found.Amount) {
+				item.Classification = models.ReconAmountMismatch
+			}
+		}
+		items = append(items, item)
+	}
+
+	missing, err := s.discover(run.From, run.To)
+	if err != nil {
+		return err
+	}
+	for _, m := range missing {
+		item := &models.ReconciliationItem{
+			Classification: models.ReconMissingLocal,
+			Reference:      m.Reference,
+			RemoteAmount:   m.Amount,
+			RemoteStatus:   m.Status,
+			Detail:         m.Detail,
+		}
+		// provider records of the platform account name no user
+		if m.UserID != 0 {
+			userID := m.UserID
+			item.UserID = &userID
+		}
+		items = append(items, item)
+	}
+	if err := run.AddItems(items); err != nil {
+		return err
+	}
+
+	if lastErr != nil {
+		run.LastError = lastErr.Error()
+	}
+	return nil
+}
+
+// sameAmount compares amounts as numbers, providers format them differently
+// and sign outgoing transfers
+func sameAmount(local, remote string) bool {
+	a, errA := strconv.ParseFloat(strings.TrimSpace(local), 64)
+	b, errB := strconv.ParseFloat(strings.TrimSpace(remote), 64)
+	if errA != nil || errB != nil {
+		return strings.TrimSpace(local) == strings.TrimSpace(remote)
+	}
+	a, b = math.Abs(a), math.Abs(b)
+	return math.Abs(a-b) <= 1e-6*math.Max(1, math.Max(a, b))
+}
+
+// lookup reads a nested field of a provider response, keys are matched
+// case-insensitively since providers are not consistent about them
+func lookup(data map[string]interface{}, path ...string) string {
+	var current interface{} = data
+	for _, key := range path {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		current = nil
+		for k, v := range m {
+			if strings.EqualFold(k, key) {
+				current = v
+				break
+			}
+		}
+	}
+	switch v := current.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,316 @@
+package reconciliation
+
+import (
+	"backend/apis"
+	"backend/apis/borderless"
+	"backend/models"
+	"backend/serializers"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// listPageSize is how many records are asked of a provider at a time,
+// tatumPageSize is the most Tatum's data API returns. A window needing more
+// than maxListPages pages fails the run rather than looping on a provider
+// that ignores paging.
+const (
+	listPageSize  = 100
+	tatumPageSize = 50
+	maxListPages  = 500
+)
+
+var errTooManyPages = fmt.Errorf("provider listed more than %d pages for the window", maxListPages)
+
+// Borderless transactions are looked up by the ID stored as TxId, the source
+// amount is the fiat amount the request was made for
+type borderlessSource struct{}
+
+func (borderlessSource) records(from, to time.Time) ([]models.ReconciliationRecord, error) {
+	return models.GetBorderlessRecords(from, to)
+}
+
+func (borderlessSource) fetch(record models.ReconciliationRecord) (remote, error) {
+	data, err := borderless.NewBorderless().GetTransaction(record.Reference)
+	if err != nil {
+		return remote{}, err
+	}
+	return remote{Amount: lookup(data, "source", "amount"), Status: lookup(data, "status"), amountKnown: true}, nil
+}
+
+// discover pages through the account's transactions, newest first, until
+// it passes the start of the window
+func (borderlessSource) discover(from, to time.Time) ([]unknown, error) {
+	client := borderless.NewBorderless()
+	var inWindow []map[string]interface{}
+	var ids []string
+	after := ""
+	for pages := 0; ; pages++ {
+		if pages == maxListPages {
+			return nil, errTooManyPages
+		}
+		page, more, err := client.ListTransactions(after, listPageSize)
+		if err != nil {
+			return nil, err
+		}
+		passed := false
+		for _, entry := range page {
+			at, ok := remoteTime(entry, "createdAt")
+			if !ok || !at.Before(to) {
+				continue
+			}
+			if at.Before(from) {
+				passed = true
+				break
+			}
+			inWindow = append(inWindow, entry)
+			ids = append(ids, lookup(entry, "id"))
+		}
+		if passed || !more || len(page) == 0 {
+			break
+		}
+		after = lookup(page[len(page)-1], "id")
+	}
+
+	known, err := models.KnownBorderlessTxIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	var missing []unknown
+	for _, entry := range inWindow {
+		id := lookup(entry, "id")
+		if known[id] {
+			continue
+		}
+		missing = append(missing, unknown{
+			Reference: id,
+			Amount:    lookup(entry, "source", "amount"),
+			Status:    lookup(entry, "status"),
+			Detail:    strings.TrimSpace("borderless " + lookup(entry, "type")),
+		})
+	}
+	return missing, nil
+}
+
+// Hurupay collections and payouts are looked up by the request ID Hurupay
+// returned when they were initialized
+type hurupaySource struct{}
+
+func (hurupaySource) records(from, to time.Time) ([]models.ReconciliationRecord, error) {
+	return models.GetHurupayRecords(from, to)
+}
+
+func (hurupaySource) fetch(record models.ReconciliationRecord) (remote, error) {
+	data, err := apis.GetMobileMoneyTransaction(context.Background(), record.Payout, record.Reference)
+	if err != nil {
+		return remote{}, err
+	}
+	amount := lookup(data, "amount")
+	return remote{Amount: amount, Status: lookup(data, "status"), amountKnown: amount != ""}, nil
+}
+
+// discover lists the collections and payouts Hurupay created in the window
+func (hurupaySource) discover(from, to time.Time) ([]unknown, error) {
+	var missing []unknown
+	for _, payout := range []bool{false, true} {
+		var inWindow []map[string]interface{}
+		var ids []string
+		for page := 1; ; page++ {
+			if page > maxListPages {
+				return nil, errTooManyPages
+			}
+			entries, more, err := apis.ListMobileMoneyTransactions(context.Background(), payout, from, to, page, listPageSize)
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range entries {
+				inWindow = append(inWindow, entry)
+				ids = append(ids, hurupayRequestID(entry))
+			}
+			if !more {
+				break
+			}
+		}
+
+		known, err := models.KnownHurupayRequestIDs(ids)
+		if err != nil {
+			return nil, err
+		}
+		kind := "collection"
+		if payout {
+			kind = "payout"
+		}
+		for _, entry := range inWindow {
+			id := hurupayRequestID(entry)
+			if known[id] {
+				continue
+			}
+			missing = append(missing, unknown{
+				Reference: id,
+				Amount:    lookup(entry, "amount"),
+				Status:    lookup(entry, "status"),
+				Detail:    "hurupay mobile money " + kind,
+			})
+		}
+	}
+	return missing, nil
+}
+
+// hurupayRequestID is the ID requests are stored and fetched by
+func hurupayRequestID(entry map[string]interface{}) string {
+	if id := lookup(entry, "requestId"); id != "" {
+		return id
+	}
+	return lookup(entry, "id")
+}
+
+// On-chain transactions are looked up by hash on the chain they were
+// recorded on. Celo and Polygon wallets are also scanned for transfers we
+// never recorded.
+type chainSource struct{}
+
+// tatumChains maps our chain names to Tatum's data API names
+var tatumChains = map[string]string{
+	serializers.ChainCelo:    "celo",
+	serializers.ChainPolygon: "polygon",
+}
+
+func (chainSource) records(from, to time.Time) ([]models.ReconciliationRecord, error) {
+	return models.GetChainRecords(from, to)
+}
+
+func (chainSource) fetch(record models.ReconciliationRecord) (remote, error) {
+	switch strings.ToUpper(record.Chain) {
+	case serializers.ChainStellar:
+		tx, err := apis.GetTransactionByHashXLM(record.Reference)
+		if err != nil {
+			return remote{}, err
+		}
+		return remote{Status: chainStatus(tx.Successful), Detail: "stellar amounts are not compared"}, nil
+	case serializers.ChainPolygon:
+		tx, err := apis.NewTatumPolygon().GetTransaction(record.Reference)
+		if err != nil {
+			return remote{}, err
+		}
+		if tx.TransactionHash == "" {
+			return remote{}, apis.NotFound(errors.New("transaction not found on polygon"))
+		}
+		return remote{Status: chainStatus(tx.Status), Detail: "token amounts are not compared on polygon"}, nil
+	default:
+		entries, err := apis.GetTransactionByHash(tatumChains[serializers.ChainCelo], record.Reference)
+		if err != nil {
+			return remote{}, err
+		}
+		if len(entries) == 0 {
+			return remote{}, apis.NotFound(errors.New("transaction not found on celo"))
+		}
+		// a transfer has an entry per address it touched, the wallet's
+		// entry carries the amount it moved
+		found := remote{Amount: lookup(entries[0], "amount"), Status: chainStatus(true), amountKnown: true}
+		for _, entry := range entries {
+			if strings.EqualFold(lookup(entry, "address"), record.Address) {
+				found.Amount = lookup(entry, "amount")
+				break
+			}
+		}
+		return found, nil
+	}
+}
+
+func (chainSource) discover(from, to time.Time) ([]unknown, error) {
+	var missing []unknown
+	for chain, tatumChain := range tatumChains {
+		wallets, err := models.GetChainWallets(chain)
+		if err != nil {
+			return nil, err
+		}
+		for _, wallet := range wallets {
+			found, err := walletTransfers(tatumChain, wallet, from, to)
+			if err != nil {
+				return nil, fmt.Errorf("%s wallet of user %d: %w", chain, wallet.UserID, err)
+			}
+			missing = append(missing, found...)
+		}
+	}
+	return missing, nil
+}
+
+// walletTransfers returns the transfers of the wallet in the window that have
+// no local transaction, paging through them newest first until it passes
+// the start of the window
+func walletTransfers(tatumChain string, wallet models.ChainWallet, from, to time.Time) ([]unknown, error) {
+	var inWindow []map[string]interface{}
+	var hashes []string
+	for offset := uint64(0); ; offset += tatumPageSize {
+		if offset == maxListPages*tatumPageSize {
+			return nil, errTooManyPages
+		}
+		response, err := apis.GetUserTransactions(tatumChain, wallet.Address, "", offset, tatumPageSize)
+		if err != nil {
+			return nil, err
+		}
+		results, _ := response["result"].([]interface{})
+
+		passed := false
+		for _, result := range results {
+			entry, ok := result.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			at, ok := remoteTime(entry, "timestamp")
+			if !ok || !at.Before(to) {
+				continue
+			}
+			if at.Before(from) {
+				passed = true
+				continue
+			}
+			inWindow = append(inWindow, entry)
+			hashes = append(hashes, lookup(entry, "hash"))
+		}
+		if passed || len(results) < tatumPageSize {
+			break
+		}
+	}
+
+	known, err := models.KnownTransactionHashes(hashes)
+	if err != nil {
+		return nil, err
+	}
+	var missing []unknown
+	for _, entry := range inWindow {
+		hash := lookup(entry, "hash")
+		if known[hash] {
+			continue
+		}
+		missing = append(missing, unknown{
+			Reference: hash,
+			UserID:    wallet.UserID,
+			Amount:    lookup(entry, "amount"),
+			Status:    chainStatus(true),
+			Detail:    strings.Join(strings.Fields(fmt.Sprintf("%s %s transfer on %s", lookup(entry, "transactionSubtype"), lookup(entry, "tokenAddress"), tatumChain)), " "),
+		})
+	}
+	return missing, nil
+}
+
+// remoteTime reads a provider timestamp given in milliseconds or RFC 3339
+func remoteTime(entry map[string]interface{}, key string) (time.Time, bool) {
+	switch v := entry[key].(type) {
+	case float64:
+		return time.UnixMilli(int64(v)), true
+	case string:
+		at, err := time.Parse(time.RFC3339, v)
+		return at, err == nil
+	}
+	return time.Time{}, false
+}
+
+func chainStatus(successful bool) string {
+	if successful {
+		return "confirmed"
+	}
+	return "failed"
+}
@@ -0,0 +1,37 @@
+package requestid
+
+import (
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Header carries the request ID in and out, a client or proxy may set it
+const Header = "X-Request-ID"
+
+// ContextKey is where the request's ID is kept on the gin context
+const ContextKey = "request_id"
+
+// clientIDFormat is what a client supplied ID must look like to be kept
+var clientIDFormat = regexp.MustCompile(`^[A-Za-z0-9._:-]{8,128}$`)
+
+// Set takes the client's request ID when it is well formed, or makes one,
+// and returns it
+func Set(c *gin.Context) string {
+	id := c.GetHeader(Header)
+	if !clientIDFormat.MatchString(id) {
+		id = uuid.NewString()
+	}
+	c.Set(ContextKey, id)
+	c.Header(Header, id)
+	return id
+}
+
+// Get returns the request's ID, empty outside of a request
+func Get(c *gin.Context) string {
+	if c == nil {
+		return ""
+	}
+	return c.GetString(ContextKey)
+}
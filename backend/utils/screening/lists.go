@@ -0,0 +1,418 @@
+package screening
+
+import (
+	"backend/models"
+	"backend/state"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// listFiles are the files each list is read from in SanctionsDir, the first
+// is required and the rest are optional
+var listFiles = map[string][]string{
+	models.WatchlistOFAC: {"sdn.csv", "alt.csv"}, // SDN.CSV and ALT.CSV from the Treasury
+	models.WatchlistUN:   {"un.xml"},             // consolidated.xml from the Security Council
+	models.WatchlistEU:   {"eu.xml"},             // the FSF full sanctions list, XML 1.1
+	models.WatchlistPEP:  {"pep.csv"},            // name, aliases, date_of_birth, country, position
+}
+
+var parsers = map[string]func(files [][]byte) ([]models.WatchlistEntry, error){
+	models.WatchlistOFAC: parseOFAC,
+	models.WatchlistUN:   parseUN,
+	models.WatchlistEU:   parseEU,
+	models.WatchlistPEP:  parsePEP,
+}
+
+// LoadResult is the outcome of loading one list
+type LoadResult struct {
+	List    string `json:"list"`
+	Loaded  bool   `json:"loaded"` // false when the file is missing or unchanged
+	Entries int    `json:"entries"`
+	Error   string `json:"error,omitempty"`
+}
+
+// StartScheduler loads the lists now and again every interval, lists whose
+// files did not change are left as they are
+func StartScheduler(interval time.Duration) {
+	LoadLists()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		LoadLists()
+	}
+}
+
+// LoadLists reloads every list whose files changed since its last load
+func LoadLists() []LoadResult {
+	results := make([]LoadResult, 0, len(models.Watchlists))
+	for _, name := range models.Watchlists {
+		result, err := loadList(name)
+		if err != nil {
+			slog.Error("watchlist not loaded", "list", name, "error", err)
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	invalidateIndex()
+	return results
+}
+
+func loadList(name string) (LoadResult, error) {
+	result := LoadResult{List: name}
+	files := make([][]byte, len(listFiles[name]))
+	hash := sha256.New()
+	for i, file := range listFiles[name] {
+		content, err := os.ReadFile(filepath.Join(state.AppConfig.SanctionsDir, file))
+		if errors.Is(err, os.ErrNotExist) && i > 0 {
+			continue
+		}
+		if errors.Is(err, os.ErrNotExist) {
+			return result, nil
+		}
+		if err != nil {
+			return result, err
+		}
+		files[i] = content
+		hash.Write(content)
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+
+	list, err := models.GetWatchlist(name)
+	if err != nil {
+		return result, err
+	}
+	result.Entries = list.Entries
+	if list.SHA256 == sum {
+		return result, nil
+	}
+
+	entries, err := parsers[name](files)
+	if err != nil {
+		return result, err
+	}
+	if len(entries) == 0 {
+		return result, errors.New("no entries found, keeping the previous load")
+	}
+	list.File = listFiles[name][0]
+	list.SHA256 = sum
+	list.Entries = len(entries)
+	list.LoadedAt = time.Now()
+	if err := models.ReplaceWatchlist(list, entries); err != nil {
+		return result, err
+	}
+	result.Loaded = true
+	result.Entries = len(entries)
+	return result, nil
+}
+
+func readCSV(content []byte) ([][]string, error) {
+	reader := csv.NewReader(strings.NewReader(string(content)))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	var rows [][]string
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+}
+
+// ofacValue drops the "-0-" OFAC writes for empty fields
+func ofacValue(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "-0-" {
+		return ""
+	}
+	return value
+}
+
+var ofacDOB = regexp.MustCompile(`DOB ([^;]+)`)
+
+// parseOFAC reads SDN.CSV (ent_num, name, type, program, title, call sign,
+// vessel fields, remarks) and the aliases in ALT.CSV (ent_num, alt_num,
+// type, name, remarks)
+func parseOFAC(files [][]byte) ([]models.WatchlistEntry, error) {
+	rows, err := readCSV(files[0])
+	if err != nil {
+		return nil, err
+	}
+	aliases := map[string][]string{}
+	if files[1] != nil {
+		altRows, err := readCSV(files[1])
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range altRows {
+			if len(row) >= 4 && ofacValue(row[3]) != "" {
+				aliases[row[0]] = append(aliases[row[0]], ofacValue(row[3]))
+			}
+		}
+	}
+
+	var entries []models.WatchlistEntry
+	for _, row := range rows {
+		if len(row) < 4 || ofacValue(row[1]) == "" {
+			continue
+		}
+		entry := models.WatchlistEntry{
+			List:      models.WatchlistOFAC,
+			Reference: strings.TrimSpace(row[0]),
+			Kind:      ofacValue(row[2]),
+			Name:      ofacValue(row[1]),
+			Aliases:   strings.Join(aliases[strings.TrimSpace(row[0])], "|"),
+			Programs:  ofacValue(row[3]),
+		}
+		if entry.Kind == "" {
+			entry.Kind = "entity"
+		}
+		if len(row) >= 12 {
+			var dates []string
+			for _, found := range ofacDOB.FindAllStringSubmatch(row[11], -1) {
+				dates = append(dates, parseDates(found[1])...)
+			}
+			entry.DatesOfBirth = strings.Join(dates, "|")
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+type unAlias struct {
+	Name string `xml:"ALIAS_NAME"`
+}
+
+type unDate struct {
+	Date string `xml:"DATE"`
+	Year string `xml:"YEAR"`
+	From string `xml:"FROM_YEAR"`
+	To   string `xml:"TO_YEAR"`
+}
+
+type unParty struct {
+	DataID      string    `xml:"DATAID"`
+	FirstName   string    `xml:"FIRST_NAME"`
+	SecondName  string    `xml:"SECOND_NAME"`
+	ThirdName   string    `xml:"THIRD_NAME"`
+	FourthName  string    `xml:"FOURTH_NAME"`
+	ListType    string    `xml:"UN_LIST_TYPE"`
+	Reference   string    `xml:"REFERENCE_NUMBER"`
+	Aliases     []unAlias `xml:"INDIVIDUAL_ALIAS"`
+	EntityAlias []unAlias `xml:"ENTITY_ALIAS"`
+	Births      []unDate  `xml:"INDIVIDUAL_DATE_OF_BIRTH"`
+}
+
+func (p unParty) entry(kind string) models.WatchlistEntry {
+	var names []string
+	for _, name := range []string{p.FirstName, p.SecondName, p.ThirdName, p.FourthName} {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	var aliases []string
+	for _, alias := range append(p.Aliases, p.EntityAlias...) {
+		if name := strings.TrimSpace(alias.Name); name != "" {
+			aliases = append(aliases, name)
+		}
+	}
+	var dates []string
+	for _, birth := range p.Births {
+		for _, value := range []string{birth.Date, birth.Year, birth.From, birth.To} {
+			dates = append(dates, parseDates(value)...)
+		}
+	}
+	reference := strings.TrimSpace(p.Reference)
+	if reference == "" {
+		reference = strings.TrimSpace(p.DataID)
+	}
+	return models.WatchlistEntry{
+		List:         models.WatchlistUN,
+		Reference:    reference,
+		Kind:         kind,
+		Name:         strings.Join(names, " "),
+		Aliases:      strings.Join(aliases, "|"),
+		DatesOfBirth: strings.Join(dates, "|"),
+		Programs:     strings.TrimSpace(p.ListType),
+	}
+}
+
+// parseUN reads the Security Council consolidated list XML
+func parseUN(files [][]byte) ([]models.WatchlistEntry, error) {
+	var list struct {
+		Individuals []unParty `xml:"INDIVIDUALS>INDIVIDUAL"`
+		Entities    []unParty `xml:"ENTITIES>ENTITY"`
+	}
+	if err := xml.Unmarshal(files[0], &list); err != nil {
+		return nil, err
+	}
+	entries := make([]models.WatchlistEntry, 0, len(list.Individuals)+len(list.Entities))
+	for _, party := range list.Individuals {
+		entries = append(entries, party.entry("individual"))
+	}
+	for _, party := range list.Entities {
+		entries = append(entries, party.entry("entity"))
+	}
+	return entries, nil
+}
+
+// parseEU reads the EU financial sanctions files XML, the first name alias
+// of an entity is its name
+func parseEU(files [][]byte) ([]models.WatchlistEntry, error) {
+	var list struct {
+		Entities []struct {
+			LogicalID   string `xml:"logicalId,attr"`
+			Reference   string `xml:"euReferenceNumber,attr"`
+			Regulations []struct {
+				Programme string `xml:"programme,attr"`
+			} `xml:"regulation"`
+			SubjectType struct {
+				Code string `xml:"code,attr"`
+			} `xml:"subjectType"`
+			Names []struct {
+				WholeName string `xml:"wholeName,attr"`
+			} `xml:"nameAlias"`
+			Births []struct {
+				Date string `xml:"birthdate,attr"`
+				Year string `xml:"year,attr"`
+			} `xml:"birthdate"`
+		} `xml:"sanctionEntity"`
+	}
+	if err := xml.Unmarshal(files[0], &list); err != nil {
+		return nil, err
+	}
+
+	entries := make([]models.WatchlistEntry, 0, len(list.Entities))
+	for _, entity := range list.Entities {
+		var names []string
+		for _, name := range entity.Names {
+			if value := strings.TrimSpace(name.WholeName); value != "" {
+				names = append(names, value)
+			}
+		}
+		if len(names) == 0 {
+			continue
+		}
+		var dates []string
+		for _, birth := range entity.Births {
+			found := parseDates(birth.Date)
+			if len(found) == 0 {
+				found = parseDates(birth.Year)
+			}
+			dates = append(dates, found...)
+		}
+		var programmes []string
+		for _, regulation := range entity.Regulations {
+			if regulation.Programme != "" {
+				programmes = append(programmes, regulation.Programme)
+			}
+		}
+		kind := "entity"
+		if entity.SubjectType.Code == "person" {
+			kind = "individual"
+		}
+		reference := entity.Reference
+		if reference == "" {
+			reference = entity.LogicalID
+		}
+		entries = append(entries, models.WatchlistEntry{
+			List:         models.WatchlistEU,
+			Reference:    reference,
+			Kind:         kind,
+			Name:         names[0],
+			Aliases:      strings.Join(names[1:], "|"),
+			DatesOfBirth: strings.Join(dates, "|"),
+			Programs:     strings.Join(programmes, ", "),
+		})
+	}
+	return entries, nil
+}
+
+// parsePEP reads a CSV with a header naming at least a name column, aliases
+// are separated by "|" or ";"
+func parsePEP(files [][]byte) ([]models.WatchlistEntry, error) {
+	rows, err := readCSV(files[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	columns := map[string]int{}
+	for i, header := range rows[0] {
+		columns[strings.ToLower(strings.TrimSpace(header))] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, errors.New("pep list has no name column")
+	}
+	value := func(row []string, column string) string {
+		if i, ok := columns[column]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	var entries []models.WatchlistEntry
+	for i, row := range rows[1:] {
+		name := value(row, "name")
+		if name == "" {
+			continue
+		}
+		reference := value(row, "id")
+		if reference == "" {
+			reference = strconv.Itoa(i + 1)
+		}
+		aliases := strings.FieldsFunc(value(row, "aliases"), func(r rune) bool { return r == '|' || r == ';' })
+		for j := range aliases {
+			aliases[j] = strings.TrimSpace(aliases[j])
+		}
+		programs := value(row, "position")
+		if country := value(row, "country"); country != "" {
+			programs = strings.TrimSpace(programs + " (" + country + ")")
+		}
+		entries = append(entries, models.WatchlistEntry{
+			List:         models.WatchlistPEP,
+			Reference:    reference,
+			Kind:         "individual",
+			Name:         name,
+			Aliases:      strings.Join(aliases, "|"),
+			DatesOfBirth: strings.Join(parseDates(value(row, "date_of_birth")), "|"),
+			Programs:     programs,
+		})
+	}
+	return entries, nil
+}
+
+var dateLayouts = []string{"2006-01-02", "02 Jan 2006", "2 Jan 2006", "02/01/2006", "2006/01/02"}
+
+var yearPattern = regexp.MustCompile(`\b(1[89]|20)\d{2}\b`)
+
+// parseDates returns the dates in a list's date of birth as YYYY-MM-DD, or
+// the years it names when it is not a full date ("circa 1960", "1958 to
+// 1962")
+func parseDates(value string) []string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	for _, layout := range dateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return []string{date.Format("2006-01-02")}
+		}
+	}
+	return yearPattern.FindAllString(value, -1)
+}
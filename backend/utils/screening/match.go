@@ -0,0 +1,265 @@
+package screening
+
+import (
+	"backend/models"
+	"backend/state"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// indexTTL is how long an instance matches against the entries it loaded
+// before reading them again, other instances may have reloaded the lists
+const indexTTL = 5 * time.Minute
+
+// maxMatches keeps a common name from filling a review with weak hits
+const maxMatches = 20
+
+type candidate struct {
+	entry models.WatchlistEntry
+	names []string   // the name then the aliases, as listed
+	terms [][]string // the normalised tokens of each name
+	dates []string
+}
+
+var index struct {
+	sync.Mutex
+	candidates []candidate
+	loadedAt   time.Time
+}
+
+func invalidateIndex() {
+	index.Lock()
+	index.loadedAt = time.Time{}
+	index.Unlock()
+}
+
+// ErrNoWatchlists fails a screening while no lists are loaded
+var ErrNoWatchlists = errors.New("no watchlists loaded")
+
+func candidates() ([]candidate, error) {
+	index.Lock()
+	defer index.Unlock()
+	if time.Since(index.loadedAt) < indexTTL {
+		return index.candidates, nil
+	}
+
+	entries, err := models.GetWatchlistEntries()
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		// with nothing to match, every name would screen clear
+		return nil, fmt.Errorf("%w from %s", ErrNoWatchlists, state.AppConfig.SanctionsDir)
+	}
+	loaded := make([]candidate, 0, len(entries))
+	for _, entry := range entries {
+		c := candidate{entry: entry, names: []string{entry.Name}}
+		if entry.Aliases != "" {
+			c.names = append(c.names, strings.Split(entry.Aliases, "|")...)
+		}
+		for _, name := range c.names {
+			c.terms = append(c.terms, tokens(name))
+		}
+		if entry.DatesOfBirth != "" {
+			c.dates = strings.Split(entry.DatesOfBirth, "|")
+		}
+		loaded = append(loaded, c)
+	}
+	index.candidates = loaded
+	index.loadedAt = time.Now()
+	return loaded, nil
+}
+
+// Match returns the entries whose name or an alias scores the threshold
+// against the name, best first. Entries whose known dates of birth are all
+// further than the tolerance from a known date of birth are left out.
+func Match(name, dateOfBirth string) ([]models.ScreeningMatch, error) {
+	all, err := candidates()
+	if err != nil {
+		return nil, err
+	}
+	subject := tokens(name)
+	if len(subject) == 0 {
+		return nil, nil
+	}
+	birth := parseDates(dateOfBirth)
+
+	var matches []models.ScreeningMatch
+	for _, c := range all {
+		best, bestName := 0.0, ""
+		for i, terms := range c.terms {
+			if score := nameScore(subject, terms); score > best {
+				best, bestName = score, c.names[i]
+			}
+		}
+		if best < state.AppConfig.ScreeningThreshold {
+			continue
+		}
+		known, compatible := datesCompatible(birth, c.dates)
+		if known && !compatible {
+			continue
+		}
+		matches = append(matches, models.ScreeningMatch{
+			List:             c.entry.List,
+			Reference:        c.entry.Reference,
+			Kind:             c.entry.Kind,
+			MatchedName:      bestName,
+			Score:            float64(int(best*1000)) / 1000,
+			DateOfBirthMatch: known,
+			Programs:         c.entry.Programs,
+		})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	if len(matches) > maxMatches {
+		matches = matches[:maxMatches]
+	}
+	return matches, nil
+}
+
+var foldings = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ä", "a", "ã", "a", "å", "a", "ā", "a",
+	"é", "e", "è", "e", "ê", "e", "ë", "e", "ē", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i", "ī", "i",
+	"ó", "o", "ò", "o", "ô", "o", "ö", "o", "õ", "o", "ø", "o", "ō", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u", "ū", "u",
+	"ç", "c", "ñ", "n", "ý", "y", "ÿ", "y", "ß", "ss", "æ", "ae", "œ", "oe",
+	"'", "", "’", "", "`", "",
+)
+
+// tokens lowercases the name, folds accents and splits it into words, so
+// "AL-BAGHDADI, Abu Bakr" and "abu bakr al baghdadi" compare equal
+func tokens(name string) []string {
+	name = foldings.Replace(strings.ToLower(name))
+	return strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// nameScore compares two tokenised names regardless of word order. Names of
+// two words or more also score by how well each word of the shorter name
+// finds a word in the longer, so a missing middle name still matches.
+func nameScore(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	sortedA, sortedB := append([]string(nil), a...), append([]string(nil), b...)
+	sort.Strings(sortedA)
+	sort.Strings(sortedB)
+	score := jaroWinkler(strings.Join(sortedA, " "), strings.Join(sortedB, " "))
+
+	short, long := a, b
+	if len(short) > len(long) {
+		short, long = long, short
+	}
+	if len(short) < 2 {
+		return score
+	}
+	total := 0.0
+	for _, word := range short {
+		best := 0.0
+		for _, other := range long {
+			if s := jaroWinkler(word, other); s > best {
+				best = s
+			}
+		}
+		total += best
+	}
+	if wordScore := total / float64(len(short)); wordScore > score {
+		return wordScore
+	}
+	return score
+}
+
+// jaroWinkler is the Jaro-Winkler similarity of two strings, 1 when equal
+func jaroWinkler(a, b string) float64 {
+	s, t := []rune(a), []rune(b)
+	if len(s) == 0 && len(t) == 0 {
+		return 1
+	}
+	if len(s) == 0 || len(t) == 0 {
+		return 0
+	}
+
+	window := max(len(s), len(t))/2 - 1
+	if window < 0 {
+		window = 0
+	}
+	sMatched, tMatched := make([]bool, len(s)), make([]bool, len(t))
+	matches := 0
+	for i := range s {
+		for j := max(0, i-window); j < min(len(t), i+window+1); j++ {
+			if !tMatched[j] && s[i] == t[j] {
+				sMatched[i], tMatched[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+
+	transpositions, j := 0, 0
+	for i := range s {
+		if !sMatched[i] {
+			continue
+		}
+		for !tMatched[j] {
+			j++
+		}
+		if s[i] != t[j] {
+			transpositions++
+		}
+		j++
+	}
+	m := float64(matches)
+	jaro := (m/float64(len(s)) + m/float64(len(t)) + (m-float64(transpositions)/2)/m) / 3
+
+	prefix := 0
+	for prefix < min(4, len(s), len(t)) && s[prefix] == t[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
+
+// datesCompatible reports whether both sides have a date of birth and, if
+// so, whether any pair is within the tolerance in years. Full dates on both
+// sides must be equal.
+func datesCompatible(subject, entry []string) (known bool, compatible bool) {
+	if len(subject) == 0 || len(entry) == 0 {
+		return false, false
+	}
+	tolerance := state.AppConfig.ScreeningDOBTolerance
+	for _, a := range subject {
+		for _, b := range entry {
+			if len(a) == 10 && len(b) == 10 {
+				if a == b {
+					return true, true
+				}
+				continue
+			}
+			diff := year(a) - year(b)
+			if diff < 0 {
+				diff = -diff
+			}
+			if diff <= tolerance {
+				return true, true
+			}
+		}
+	}
+	return true, false
+}
+
+func year(date string) int {
+	value := 0
+	for _, r := range date[:min(4, len(date))] {
+		value = value*10 + int(r-'0')
+	}
+	return value
+}
@@ -0,0 +1,97 @@
+package screening
+
+import (
+	"backend/models"
+	"backend/utils/cases"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// Subject is the person or business a screening checks
+type Subject struct {
+	UserID      uint
+	Kind        string // models.ScreeningSubject*
+	Name        string
+	DateOfBirth string
+	Rail        string
+}
+
+// UserSubject is the user as verified through KYC
+func UserSubject(user models.User, kyc *models.KYC) Subject {
+	subject := Subject{
+		UserID: user.ID,
+		Kind:   models.ScreeningSubjectUser,
+		Name:   strings.TrimSpace(user.FirstName + " " + user.LastName),
+	}
+	if kyc != nil {
+		subject.DateOfBirth = kyc.DateOfBirth
+	}
+	return subject
+}
+
+// BeneficiarySubject is someone the user pays out to
+func BeneficiarySubject(user models.User, name, rail string) Subject {
+	return Subject{UserID: user.ID, Kind: models.ScreeningSubjectBeneficiary, Name: strings.TrimSpace(name), Rail: rail}
+}
+
+// key keeps review decisions to the subject, a beneficiary is only the same
+// beneficiary for the same user
+func (s Subject) key() string {
+	if s.Kind == models.ScreeningSubjectBeneficiary {
+		return fmt.Sprintf("beneficiary:%d:%s", s.UserID, strings.Join(tokens(s.Name), " "))
+	}
+	return fmt.Sprintf("user:%d", s.UserID)
+}
+
+// Screen checks the subject against the watchlists and records the
+// screening. A subject with a screening under review or confirmed gets that
+// screening back without a new check, hits an admin cleared for the subject
+// are not raised again. The flow may go on only when the status is clear.
+func Screen(subject Subject, context string) (*models.Screening, error) {
+	key := subject.key()
+	blocking, err := models.GetBlockingScreening(key)
+	if err != nil || blocking != nil {
+		return blocking, err
+	}
+
+	matches, err := Match(subject.Name, subject.DateOfBirth)
+	if err != nil {
+		return nil, err
+	}
+	cleared, err := models.GetClearedMatches(key)
+	if err != nil {
+		return nil, err
+	}
+	raised := matches[:0]
+	for _, match := range matches {
+		if !cleared[match.Key()] {
+			raised = append(raised, match)
+		}
+	}
+
+	screening := &models.Screening{
+		UserID:      subject.UserID,
+		Subject:     subject.Kind,
+		SubjectKey:  key,
+		Context:     context,
+		Rail:        subject.Rail,
+		Name:        subject.Name,
+		DateOfBirth: subject.DateOfBirth,
+		Status:      models.ScreeningClear,
+		Matches:     raised,
+	}
+	if len(raised) > 0 {
+		screening.Status = models.ScreeningReview
+		slog.Warn("screening hit watchlist entries", "kind", subject.Kind, "hits", len(raised), "during", context)
+	}
+	if err := screening.CreateScreening(); err != nil {
+		return nil, err
+	}
+	if screening.Status == models.ScreeningReview {
+		if _, err := cases.FromScreening(screening); err != nil {
+			slog.Error("screening case not opened", "screening_id", screening.ID, "error", err)
+		}
+	}
+	return screening, nil
+}
@@ -0,0 +1,34 @@
+package signing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// PartnerStringToSign builds the canonical string a partner signs:
+// METHOD\nPATH?QUERY\nTIMESTAMP\nhex(sha256(body))
+func PartnerStringToSign(method, path, timestamp string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	return strings.Join([]string{
+		strings.ToUpper(method),
+		path,
+		timestamp,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+}
+
+// SignPartnerRequest returns the base64 HMAC-SHA256 signature of a partner request
+func SignPartnerRequest(secret, method, path, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(PartnerStringToSign(method, path, timestamp, body)))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyPartnerSignature compares the signature in constant time
+func VerifyPartnerSignature(secret, method, path, timestamp string, body []byte, signature string) bool {
+	expected := SignPartnerRequest(secret, method, path, timestamp, body)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
@@ -0,0 +1,57 @@
+package statements
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+// renderCSV writes each section as a titled block separated by a blank line
+func renderCSV(w io.Writer, doc *document) error {
+	cw := csv.NewWriter(w)
+
+	records := [][]string{
+		{csvCell(doc.Title)},
+		{"Generated", doc.Generated.Format("2006-01-02 15:04:05 MST")},
+		{},
+	}
+	for _, record := range records {
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	for _, s := range doc.Sections {
+		if err := cw.Write([]string{s.Title}); err != nil {
+			return err
+		}
+		if err := cw.Write(s.Header); err != nil {
+			return err
+		}
+		err := s.each(func(row []interface{}) error {
+			record := make([]string, len(row))
+			for i, cell := range row {
+				record[i] = csvCell(cell)
+			}
+			return cw.Write(record)
+		})
+		if err != nil {
+			return err
+		}
+		if err := cw.Write([]string{}); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvCell keeps spreadsheets from reading text such as a description or an
+// email as a formula. Amounts are numbers we format and are left as they are.
+func csvCell(cell interface{}) string {
+	value := text(cell)
+	if _, ok := cell.(string); ok && value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,147 @@
+package statements
+
+import (
+	"backend/models"
+	"backend/state"
+	"backend/utils/mails"
+	"backend/utils/tenancy"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// StartWorker builds due statement exports and removes expired files every
+// interval, exports are stored first so they survive restarts
+func StartWorker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		exports, err := models.GetDueStatementExports(10)
+		if err != nil {
+			log.Println("statement worker: failed to load exports: ", err)
+			continue
+		}
+		for _, export := range exports {
+			process(export)
+		}
+		purgeExpired()
+	}
+}
+
+// Enqueue builds a freshly created export right away, the worker retries it
+// on failure
+func Enqueue(export *models.StatementExport) {
+	go func() {
+		stored, err := models.GetStatementExport(export.ID)
+		if err != nil {
+			return
+		}
+		process(*stored)
+	}()
+}
+
+func process(export models.StatementExport) {
+	if !export.Claim() {
+		return
+	}
+
+	fileName, path, size, err := build(&export)
+	if err != nil {
+		log.Printf("statement export %d: %v", export.ID, err)
+		if err := export.RecordFailure(err); err != nil {
+			log.Printf("statement export %d: failed to record failure: %v", export.ID, err)
+		}
+		return
+	}
+	if err := export.MarkCompleted(fileName, path, size); err != nil {
+		log.Printf("statement export %d: failed to mark completed: %v", export.ID, err)
+		return
+	}
+	notify(&export)
+}
+
+// build renders the statement straight into a file of the statement
+// directory
+func build(export *models.StatementExport) (string, string, int64, error) {
+	if err := os.MkdirAll(state.AppConfig.StatementDir, 0o700); err != nil {
+		return "", "", 0, err
+	}
+	fileName := fmt.Sprintf("statement-%s-%s.%s",
+		export.From.Format(dateLayout), export.To.AddDate(0, 0, -1).Format(dateLayout), export.Format)
+	path := filepath.Join(state.AppConfig.StatementDir, fmt.Sprintf("%d-%s", export.ID, fileName))
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return "", "", 0, err
+	}
+	err = write(file, export)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", "", 0, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", "", 0, err
+	}
+	return fileName, path, info.Size(), nil
+}
+
+func write(w io.Writer, export *models.StatementExport) error {
+	tenantID := uint(0)
+	if export.TenantID != nil {
+		tenantID = *export.TenantID
+	}
+	if export.UserID != nil {
+		statement, err := models.BuildStatement(export.UserID, tenantID, export.From, export.To)
+		if err != nil {
+			return err
+		}
+		return render(w, newDocument(statement), export.Format)
+	}
+
+	doc, cleanup, err := allUsersDocument(export, tenantID, state.AppConfig.StatementDir)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	return render(w, doc, export.Format)
+}
+
+// notify mails the requester the download link
+func notify(export *models.StatementExport) {
+	user, err := models.GetUserByID(export.RequestedByID)
+	if err != nil {
+		log.Printf("statement export %d: requester not found: %v", export.ID, err)
+		return
+	}
+	link := state.AppConfig.StatementDownloadLink + "/" + export.DownloadToken
+	err = mails.SendStatementReadyMail(tenancy.MailRecipient(&user), link, Period(export.From, export.To), export.Format, *export.ExpiresAt)
+	if err != nil {
+		log.Printf("statement export %d: failed to send mail: %v", export.ID, err)
+	}
+}
+
+// purgeExpired deletes files past their retention, the export rows stay as
+// a record of what was exported
+func purgeExpired() {
+	exports, err := models.GetExpiredStatementExports(100)
+	if err != nil {
+		log.Println("statement worker: failed to load expired exports: ", err)
+		return
+	}
+	for _, export := range exports {
+		if err := os.Remove(export.FilePath); err != nil && !os.IsNotExist(err) {
+			log.Printf("statement export %d: failed to remove file: %v", export.ID, err)
+			continue
+		}
+		if err := export.MarkExpired(); err != nil {
+			log.Printf("statement export %d: failed to mark expired: %v", export.ID, err)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package statements
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Landscape A4 set in 7pt Courier, a monospaced font lines columns up
+// without measuring text
+const (
+	pdfWidth     = 842
+	pdfHeight    = 595
+	pdfMargin    = 36
+	pdfFontSize  = 7
+	pdfLeading   = 9
+	pdfLineChars = (pdfWidth - 2*pdfMargin) * 10 / (pdfFontSize * 6) // Courier glyphs are 0.6em wide
+	pdfPageLines = (pdfHeight - 2*pdfMargin) / pdfLeading
+	pdfMaxColumn = 40
+)
+
+// renderPDF lays the sections out as fixed width text lines over as many
+// pages as needed. Columns are measured in a first pass over the rows so
+// pages can be written as they fill.
+func renderPDF(w io.Writer, doc *document) error {
+	tables := make([]table, len(doc.Sections))
+	total := 3
+	for i, s := range doc.Sections {
+		t, err := measure(s)
+		if err != nil {
+			return err
+		}
+		tables[i] = t
+		total += t.rows + 3 // title, header and the blank line after
+	}
+
+	out := newPDF(w, (total+pdfPageLines-1)/pdfPageLines)
+	out.line(doc.Title)
+	out.line("Generated " + doc.Generated.Format("2006-01-02 15:04:05 MST"))
+	out.line("")
+	for i, s := range doc.Sections {
+		out.line(s.Title)
+		out.line(tables[i].format(s.Header))
+		err := s.each(func(row []interface{}) error {
+			cells := make([]string, len(row))
+			for c, cell := range row {
+				cells[c] = text(cell)
+			}
+			out.line(tables[i].format(cells))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		out.line("")
+	}
+	return out.close()
+}
+
+// table holds the column widths of a section, none wider than pdfMaxColumn
+type table struct {
+	widths []int
+	rows   int
+}
+
+func measure(s section) (table, error) {
+	t := table{widths: make([]int, len(s.Header))}
+	fit := func(cells []string) {
+		for i, cell := range cells {
+			t.widths[i] = min(max(t.widths[i], utf8.RuneCountInString(cell)), pdfMaxColumn)
+		}
+	}
+	fit(s.Header)
+	err := s.each(func(row []interface{}) error {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = text(cell)
+		}
+		fit(cells)
+		t.rows++
+		return nil
+	})
+	return t, err
+}
+
+// format aligns one row in the table's columns
+func (t table) format(cells []string) string {
+	var b strings.Builder
+	for i, cell := range cells {
+		if i > 0 {
+			b.WriteString("  ")
+		}
+		cell = truncate(cell, t.widths[i])
+		b.WriteString(cell + strings.Repeat(" ", t.widths[i]-utf8.RuneCountInString(cell)))
+	}
+	return truncate(strings.TrimRight(b.String(), " "), pdfLineChars)
+}
+
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:n-1]) + "~"
+}
+
+// pdfString encodes text for the standard fonts, characters outside Latin-1
+// have no glyph there and become question marks
+func pdfString(s string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(byte(r))
+		case r >= 0x20 && r < 0x7f, r >= 0xa0 && r <= 0xff:
+			b.WriteByte(byte(r))
+		default:
+			b.WriteByte('?')
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// pdfWriter writes a PDF of a known page count one page at a time. Objects
+// 1 to 3 are the catalog, page tree and font, each page then takes a page
+// object followed by its content stream.
+type pdfWriter struct {
+	w       *bufio.Writer
+	written int
+	offsets []int
+	pages   int
+	done    int
+	page    []string
+}
+
+func newPDF(w io.Writer, pages int) *pdfWriter {
+	out := &pdfWriter{w: bufio.NewWriter(w), pages: pages, offsets: make([]int, 3+2*pages)}
+	out.write("%PDF-1.4\n")
+
+	kids := make([]string, pages)
+	for i := range kids {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+2*i)
+	}
+	out.object(1, "<< /Type /Catalog /Pages 2 0 R >>")
+	out.object(2, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), pages))
+	out.object(3, "<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+	return out
+}
+
+// write records how far into the file we are, errors are kept by the
+// buffer and returned by close
+func (p *pdfWriter) write(s string) {
+	n, _ := p.w.WriteString(s)
+	p.written += n
+}
+
+func (p *pdfWriter) object(n int, body string) {
+	p.offsets[n-1] = p.written
+	p.write(fmt.Sprintf("%d 0 obj\n%s\nendobj\n", n, body))
+}
+
+func (p *pdfWriter) line(s string) {
+	p.page = append(p.page, s)
+	if len(p.page) == pdfPageLines {
+		p.flushPage()
+	}
+}
+
+func (p *pdfWriter) flushPage() {
+	var content strings.Builder
+	fmt.Fprintf(&content, "BT /F1 %d Tf %d TL %d %d Td\n", pdfFontSize, pdfLeading, pdfMargin, pdfHeight-pdfMargin-pdfFontSize)
+	for _, line := range p.page {
+		fmt.Fprintf(&content, "%s Tj T*\n", pdfString(line))
+	}
+	fmt.Fprintf(&content, "ET\nBT /F1 %d Tf %d %d Td %s Tj ET", pdfFontSize, pdfWidth-pdfMargin-60, pdfMargin/2,
+		pdfString(fmt.Sprintf("Page %d of %d", p.done+1, p.pages)))
+
+	i := p.done
+	p.object(4+2*i, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+		pdfWidth, pdfHeight, 5+2*i))
+	p.object(5+2*i, fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()))
+	p.done++
+	p.page = p.page[:0]
+}
+
+func (p *pdfWriter) close() error {
+	if len(p.page) > 0 {
+		p.flushPage()
+	}
+	if p.done != p.pages {
+		return fmt.Errorf("statement pdf has %d pages, %d were counted", p.done, p.pages)
+	}
+
+	xref := p.written
+	p.write(fmt.Sprintf("xref\n0 %d\n0000000000 65535 f \n", len(p.offsets)+1))
+	for _, offset := range p.offsets {
+		p.write(fmt.Sprintf("%010d 00000 n \n", offset))
+	}
+	p.write(fmt.Sprintf("trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(p.offsets)+1, xref))
+	return p.w.Flush()
+}
@@ -0,0 +1,156 @@
+package statements
+
+import (
+	"backend/models"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+// section is one table of a statement, cells are strings, float64 amounts or
+// nil for blanks so spreadsheets keep numbers numeric. Rows of large exports
+// are read back from a spool instead of being held in Rows.
+type section struct {
+	Title  string
+	Header []string
+	Rows   [][]interface{}
+	spool  *spool
+}
+
+// each calls fn with every row of the section in order
+func (s section) each(fn func(row []interface{}) error) error {
+	if s.spool != nil {
+		return s.spool.each(fn)
+	}
+	for _, row := range s.Rows {
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// document is a statement laid out for rendering
+type document struct {
+	Title     string
+	Generated time.Time
+	Sections  []section
+}
+
+func newDocument(statement *models.Statement) *document {
+	entries := entrySection(statement.AllUsers)
+	for _, e := range statement.Entries {
+		entries.Rows = append(entries.Rows, entryRow(e, statement.AllUsers))
+	}
+	return &document{
+		Title:     title(statement),
+		Generated: statement.Generated,
+		Sections:  []section{balanceSection(statement), feeSection(statement), entries},
+	}
+}
+
+// render writes the document in one of the models.Statement* formats
+func render(w io.Writer, doc *document, format string) error {
+	switch format {
+	case models.StatementCSV:
+		return renderCSV(w, doc)
+	case models.StatementXLSX:
+		return renderXLSX(w, doc)
+	case models.StatementPDF:
+		return renderPDF(w, doc)
+	default:
+		return fmt.Errorf("unsupported statement format: %s", format)
+	}
+}
+
+func ContentType(format string) string {
+	switch format {
+	case models.StatementCSV:
+		return "text/csv"
+	case models.StatementXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case models.StatementPDF:
+		return "application/pdf"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// Period is the inclusive date range a statement covers
+func Period(from, to time.Time) string {
+	return from.Format(dateLayout) + " to " + to.AddDate(0, 0, -1).Format(dateLayout)
+}
+
+func title(statement *models.Statement) string {
+	return "Statement " + Period(statement.From, statement.To)
+}
+
+func balanceSection(statement *models.Statement) section {
+	balances := section{Title: "Balances", Header: []string{"Asset", "Opening Balance", "Closing Balance"}}
+	if statement.AllUsers {
+		balances.Header = append([]string{"User"}, balances.Header...)
+	}
+	for _, b := range statement.Balances {
+		row := []interface{}{b.Asset, b.Opening, b.Closing}
+		if statement.AllUsers {
+			row = append([]interface{}{b.Email}, row...)
+		}
+		balances.Rows = append(balances.Rows, row)
+	}
+	return balances
+}
+
+func feeSection(statement *models.Statement) section {
+	fees := section{Title: "Fees", Header: []string{"Asset", "Total Fees"}}
+	for _, f := range statement.Fees {
+		fees.Rows = append(fees.Rows, []interface{}{f.Asset, f.Total})
+	}
+	return fees
+}
+
+// entrySection is the transactions table without its rows
+func entrySection(allUsers bool) section {
+	entries := section{
+		Title:  "Transactions",
+		Header: []string{"Date", "Type", "Reference", "Description", "Status", "Asset", "Amount", "Fee", "Fee Asset", "Balance"},
+	}
+	if allUsers {
+		entries.Header = append([]string{"User"}, entries.Header...)
+	}
+	return entries
+}
+
+func entryRow(e models.StatementEntry, allUsers bool) []interface{} {
+	var fee, balance interface{}
+	if e.Fee != 0 {
+		fee = e.Fee
+	}
+	if e.Balance != nil {
+		balance = *e.Balance
+	}
+	row := []interface{}{
+		e.Date.Format("2006-01-02 15:04:05"), e.Kind, e.Reference, e.Description,
+		e.Status, e.Asset, e.Amount, fee, e.FeeAsset, balance,
+	}
+	if allUsers {
+		row = append([]interface{}{e.Email}, row...)
+	}
+	return row
+}
+
+// text formats a cell for the text based formats
+func text(cell interface{}) string {
+	switch v := cell.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
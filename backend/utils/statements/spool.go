@@ -0,0 +1,117 @@
+package statements
+
+import (
+	"backend/models"
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// statementBatch is how many users an export of every user builds at once
+const statementBatch = 100
+
+// spool keeps rows in a temporary file, one JSON array per line, so exports
+// of every user never hold all their entries in memory
+type spool struct {
+	file *os.File
+	buf  *bufio.Writer
+	enc  *json.Encoder
+}
+
+func newSpool(dir string) (*spool, error) {
+	file, err := os.CreateTemp(dir, "statement-*.spool")
+	if err != nil {
+		return nil, err
+	}
+	buf := bufio.NewWriter(file)
+	return &spool{file: file, buf: buf, enc: json.NewEncoder(buf)}, nil
+}
+
+func (s *spool) add(row []interface{}) error {
+	return s.enc.Encode(row)
+}
+
+// each reads the rows back, numbers decode as float64 like the amounts
+// they were written from
+func (s *spool) each(fn func(row []interface{}) error) error {
+	if err := s.buf.Flush(); err != nil {
+		return err
+	}
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bufio.NewReader(s.file))
+	for {
+		var row []interface{}
+		if err := dec.Decode(&row); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *spool) close() {
+	s.file.Close()
+	os.Remove(s.file.Name())
+}
+
+// allUsersDocument lays out the export of every user of the tenant. Users
+// are built a batch at a time and their entries spooled, so entries are
+// listed by batch and only balances and fee totals stay in memory. The
+// returned func removes the spool.
+func allUsersDocument(export *models.StatementExport, tenantID uint, dir string) (*document, func(), error) {
+	entries, err := newSpool(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	statement := &models.Statement{From: export.From, To: export.To, AllUsers: true, Generated: time.Now()}
+	fees := map[string]float64{}
+	err = models.EachTenantUserBatch(tenantID, statementBatch, func(userIDs []uint) error {
+		batch, err := models.BuildUsersStatement(userIDs, export.From, export.To)
+		if err != nil {
+			return err
+		}
+		for _, e := range batch.Entries {
+			if err := entries.add(entryRow(e, true)); err != nil {
+				return err
+			}
+		}
+		statement.Balances = append(statement.Balances, batch.Balances...)
+		for _, f := range batch.Fees {
+			fees[f.Asset] += f.Total
+		}
+		return nil
+	})
+	if err != nil {
+		entries.close()
+		return nil, nil, err
+	}
+
+	sort.Slice(statement.Balances, func(i, j int) bool {
+		a, b := statement.Balances[i], statement.Balances[j]
+		if a.Email != b.Email {
+			return a.Email < b.Email
+		}
+		return a.Asset < b.Asset
+	})
+	for asset, total := range fees {
+		statement.Fees = append(statement.Fees, models.StatementFee{Asset: asset, Total: total})
+	}
+	sort.Slice(statement.Fees, func(i, j int) bool { return statement.Fees[i].Asset < statement.Fees[j].Asset })
+
+	transactions := entrySection(true)
+	transactions.spool = entries
+	doc := &document{
+		Title:     title(statement),
+		Generated: statement.Generated,
+		Sections:  []section{balanceSection(statement), feeSection(statement), transactions},
+	}
+	return doc, entries.close, nil
+}
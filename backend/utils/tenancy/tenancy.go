@@ -0,0 +1,108 @@
+package tenancy
+
+import (
+	"backend/models"
+	"backend/utils"
+	"backend/utils/mails"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContextKey holds the resolved tenant ID, absent for platform requests
+const ContextKey = "tenant_id"
+
+// TenantID returns the tenant the request is scoped to, 0 for the platform
+func TenantID(c *gin.Context) uint {
+	return c.GetUint(ContextKey)
+}
+
+// Tenant returns the tenant the request is scoped to, nil for the platform
+func Tenant(c *gin.Context) *models.Tenant {
+	id := TenantID(c)
+	if id == 0 {
+		return nil
+	}
+	tenant, err := models.GetCachedTenant(id)
+	if err != nil {
+		return nil
+	}
+	return tenant
+}
+
+// Set scopes the request to the tenant, a nil tenant puts it on the platform
+func Set(c *gin.Context, tenantID *uint) {
+	if tenantID == nil {
+		c.Set(ContextKey, uint(0))
+		return
+	}
+	c.Set(ContextKey, *tenantID)
+}
+
+// TenantIDPtr returns the tenant ID in the form stored on users and partners
+func TenantIDPtr(c *gin.Context) *uint {
+	id := TenantID(c)
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
+
+// SameTenant reports whether a record owned by tenantID may be used on a
+// request scoped to the current tenant
+func SameTenant(c *gin.Context, tenantID *uint) bool {
+	current := TenantID(c)
+	if tenantID == nil {
+		return current == 0
+	}
+	return *tenantID == current
+}
+
+// ChainEnabled reports whether the request's tenant allows the chain
+func ChainEnabled(c *gin.Context, chain string) bool {
+	tenant := Tenant(c)
+	return tenant == nil || tenant.ChainEnabled(chain)
+}
+
+// DeveloperFee returns the tenant's fee for the rail, or the platform default
+func DeveloperFee(c *gin.Context, rail string) string {
+	if tenant := Tenant(c); tenant != nil {
+		return tenant.DeveloperFee(rail)
+	}
+	return models.DefaultDeveloperFee
+}
+
+// CountryBlocked applies the platform's blocked country list for virtual
+// accounts, which always holds, and the tenant's own list on top of it
+func CountryBlocked(c *gin.Context, alpha2Code string) bool {
+	if utils.IsBlockedCountry(alpha2Code) {
+		return true
+	}
+	tenant := Tenant(c)
+	return tenant != nil && tenant.CountryBlocked(alpha2Code)
+}
+
+// MailRecipient addresses a mail to the user in their locale, under the
+// branding of the tenant owning them
+func MailRecipient(user *models.User) mails.Recipient {
+	return mails.Recipient{
+		Email:  user.Email,
+		Name:   user.FirstName,
+		Locale: user.Locale,
+		Brand:  mailBranding(user),
+	}
+}
+
+func mailBranding(user *models.User) mails.Branding {
+	if user.TenantID == nil {
+		return mails.Branding{}
+	}
+	tenant, err := models.GetCachedTenant(*user.TenantID)
+	if err != nil {
+		return mails.Branding{}
+	}
+	return mails.Branding{
+		SenderName:  tenant.EmailSenderName,
+		ReplyTo:     tenant.EmailReplyTo,
+		TemplateSet: tenant.EmailTemplateSet,
+	}
+}
@@ -58,20 +58,40 @@ func ExtractToken(c *gin.Context) string {
 	return splitToken[1]
 }
 
+// ActingUserKey is set on the context by the partner middleware once it has
+// resolved which of the partner's sub-users a request is made for
+const ActingUserKey = "acting_user_id"
+
 func ExtractUserID(c *gin.Context) (uint, error) {
+	if id, ok := c.Get(ActingUserKey); ok {
+		if userID, ok := id.(uint); ok {
+			return userID, nil
+		}
+	}
+
+	claims, err := ExtractClaims(c)
+	if err != nil {
+		return 0, err
+	}
+
+	return claims.ID, nil
+}
+
+// ExtractClaims parses and validates the bearer token on the request
+func ExtractClaims(c *gin.Context) (*CustomClaims, error) {
 	tokenString := ExtractToken(c)
 	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
 		return state.ApiSecret, nil
 	})
 
 	if err != nil {
-		return 0, fmt.Errorf("failed to parse JWT token: %v", err)
+		return nil, fmt.Errorf("failed to parse JWT token: %v", err)
 	}
 
 	claims, ok := token.Claims.(*CustomClaims)
 	if !ok || !token.Valid {
-		return 0, fmt.Errorf("invalid or expired token")
+		return nil, fmt.Errorf("invalid or expired token")
 	}
 
-	return claims.ID, nil
+	return claims, nil
 }
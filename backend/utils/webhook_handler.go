@@ -3,6 +3,7 @@ package utils
 import (
 	"backend/apis"
 	"backend/models"
+	"backend/utils/webhooks"
 	"time"
 )
 
@@ -61,6 +62,7 @@ func BorderlessWebhookHandler(body WebhookEvent) (bool, error) {
 		if err := borderlessRequest.UpdateBorderlessRequest(); err != nil {
 			return false, err
 		}
+		go webhooks.PublishBorderless(borderlessRequest)
 		return true, nil
 	case "Transaction_Updated":
 		borderlessRequest.Status = body.Data.Status
@@ -96,6 +98,7 @@ func BorderlessWebhookHandler(body WebhookEvent) (bool, error) {
 				return false, err
 			}
 		}
+		go webhooks.PublishBorderless(borderlessRequest)
 	}
 	return true, nil
 }
@@ -0,0 +1,75 @@
+package webhooks
+
+import (
+	"backend/models"
+)
+
+// Payload builders keep webhook bodies to request fields, never the nested
+// user record
+
+func PublishDeposit(eventType string, deposit *models.DepositRequest) {
+	Publish(eventType, deposit.UserID, map[string]interface{}{
+		"id":               deposit.ID,
+		"ref":              deposit.Ref,
+		"status":           deposit.Status,
+		"fiat_amount":      deposit.FiatAmount,
+		"currency":         deposit.Currency,
+		"asset":            deposit.ProposedAsset,
+		"asset_equivalent": deposit.AssetEquivalent,
+		"updated_at":       deposit.UpdatedAt,
+	})
+}
+
+func PublishWithdrawal(eventType string, withdrawal *models.WithdrawalRequest) {
+	Publish(eventType, withdrawal.UserID, map[string]interface{}{
+		"id":              withdrawal.ID,
+		"status":          withdrawal.Status,
+		"crypto_amount":   withdrawal.CryptoAmount,
+		"asset":           withdrawal.Asset,
+		"chain":           withdrawal.Chain,
+		"hash":            withdrawal.Hash,
+		"equivalent_fiat": withdrawal.EquivalentFiat,
+		"fiat_currency":   withdrawal.FiatCurrency,
+		"bank_ref":        withdrawal.BankRef,
+		"updated_at":      withdrawal.UpdatedAt,
+	})
+}
+
+func PublishKYC(eventType string, kyc *models.KYC) {
+	Publish(eventType, kyc.UserID, map[string]interface{}{
+		"id":               kyc.ID,
+		"status":           kyc.Status,
+		"rejection_reason": kyc.RejectionReason,
+		"updated_at":       kyc.UpdatedAt,
+	})
+}
+
+func PublishHurupay(hurupayEvent string, request *models.HurupayRequest) {
+	Publish(models.HurupayEventType(hurupayEvent), uint(request.UserId), map[string]interface{}{
+		"id":             request.ID,
+		"request_id":     request.RequestId,
+		"request_type":   request.RequestType,
+		"status":         request.Status,
+		"amount":         request.Amount,
+		"currency":       request.CountryCurrency,
+		"mobile_network": request.MobileNetwork,
+		"token":          request.Token,
+		"chain":          request.CryptoChain,
+		"provider_event": hurupayEvent,
+	})
+}
+
+func PublishBorderless(request *models.BorderlessRequest) {
+	eventType := models.WebhookBorderlessUpdated
+	if request.Status == "Completed" {
+		eventType = models.WebhookBorderlessCompleted
+	}
+	Publish(eventType, request.UserId, map[string]interface{}{
+		"tx_id":       request.TxId,
+		"status":      request.Status,
+		"fiat_amount": request.FiatAmount,
+		"asset":       request.Asset,
+		"country":     request.Country,
+		"fee_amount":  request.FeeAmount,
+	})
+}
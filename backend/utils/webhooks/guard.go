@@ -0,0 +1,118 @@
+package webhooks
+
+import (
+	"backend/utils/logging"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+var errBlockedAddress = errors.New("url resolves to a private or internal address")
+
+// blockedNetworks are internal ranges net.IP does not flag, carrier-grade NAT
+// among them holds some cloud metadata endpoints
+var blockedNetworks = []*net.IPNet{
+	mustCIDR("0.0.0.0/8"),
+	mustCIDR("100.64.0.0/10"),
+	mustCIDR("192.0.0.0/24"),
+	mustCIDR("198.18.0.0/15"),
+	mustCIDR("64:ff9b::/96"),
+}
+
+func mustCIDR(s string) *net.IPNet {
+	_, network, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// publicIP reports whether ip may receive webhooks. Loopback, private,
+// link-local (which covers the 169.254.169.254 metadata service), multicast
+// and unspecified addresses are refused.
+func publicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateURL resolves the url's host and refuses it when any of its
+// addresses is internal. DNS can change after this check, so deliveries
+// check the address again when dialing.
+func ValidateURL(ctx context.Context, rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Hostname() == "" {
+		return errors.New("url must be absolute")
+	}
+	if parsed.User != nil {
+		return errors.New("url must not carry credentials")
+	}
+
+	host := parsed.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		if !publicIP(ip) {
+			return errBlockedAddress
+		}
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return fmt.Errorf("url host %s does not resolve", host)
+	}
+	for _, addr := range addrs {
+		if !publicIP(addr.IP) {
+			return errBlockedAddress
+		}
+	}
+	return nil
+}
+
+// guardDial runs after the host is resolved and before connecting, so the
+// address actually dialed is the one checked
+func guardDial(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !publicIP(ip) {
+		return errBlockedAddress
+	}
+	return nil
+}
+
+// newClient is the http client deliveries are sent with. It never uses a
+// proxy, as that would hide the endpoint's address from guardDial, and never
+// follows redirects, a public endpoint could otherwise bounce the signed
+// payload to an internal one.
+func newClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: guardDial,
+	}
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: logging.Transport(&http.Transport{
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   5 * time.Second,
+			ResponseHeaderTimeout: 10 * time.Second,
+			MaxIdleConns:          20,
+			IdleConnTimeout:       90 * time.Second,
+		}),
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,168 @@
+package webhooks
+
+import (
+	"backend/apis"
+	"backend/models"
+	"backend/utils/signing"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event is the JSON body posted to webhook endpoints
+type Event struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	UserID    uint        `json:"user_id"`
+	Data      interface{} `json:"data"`
+}
+
+var client = newClient()
+
+// Publish queues the event for every endpoint subscribed to events about the
+// user and makes a first delivery attempt right away. Deliveries are stored
+// before sending so the worker retries them even across restarts.
+func Publish(eventType string, userID uint, data interface{}) {
+	user, err := models.GetUserByID(userID)
+	if err != nil {
+		slog.Error("webhook user not found", "event_type", eventType, "user_id", userID, "error", err)
+		return
+	}
+
+	endpoints, err := models.GetWebhookEndpointsForUser(user)
+	if err != nil {
+		slog.Error("webhook endpoints not loaded", "event_type", eventType, "user_id", userID, "error", err)
+		return
+	}
+
+	event := Event{
+		ID:        "evt_" + uuid.New().String(),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		UserID:    userID,
+		Data:      data,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("webhook payload not encoded", "event_type", eventType, "error", err)
+		return
+	}
+
+	for _, endpoint := range endpoints {
+		if !endpoint.Subscribes(eventType) {
+			continue
+		}
+		delivery := models.WebhookDelivery{
+			EndpointID:    endpoint.ID,
+			EventID:       event.ID,
+			EventType:     eventType,
+			Payload:       string(payload),
+			Status:        models.DeliveryPending,
+			NextAttemptAt: time.Now(),
+		}
+		if err := delivery.CreateWebhookDelivery(); err != nil {
+			slog.Error("webhook delivery not queued", "event_type", eventType, "endpoint_id", endpoint.ID, "error", err)
+			continue
+		}
+		go attempt(delivery.ID)
+	}
+}
+
+// StartWorker retries due deliveries every interval, it never returns
+func StartWorker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		deliveries, err := models.GetDueWebhookDeliveries(100)
+		if err != nil {
+			slog.Error("webhook deliveries not loaded", "error", err)
+			continue
+		}
+		for _, delivery := range deliveries {
+			deliver(delivery)
+		}
+	}
+}
+
+// attempt reloads a freshly queued delivery so the claim matches the stored row
+func attempt(deliveryID uint) {
+	delivery, err := models.GetWebhookDeliveryByID(deliveryID)
+	if err != nil {
+		return
+	}
+	deliver(*delivery)
+}
+
+func deliver(delivery models.WebhookDelivery) {
+	if !delivery.Claim() {
+		return
+	}
+
+	endpoint, err := models.GetWebhookEndpointByID(delivery.EndpointID)
+	if err != nil || !endpoint.Active {
+		// deleted or disabled endpoints keep their log but stop receiving
+		if err := delivery.Abandon("endpoint deleted or disabled"); err != nil {
+			slog.Error("webhook delivery not abandoned", "delivery_id", delivery.ID, "error", err)
+		}
+		return
+	}
+
+	result := send(endpoint, delivery)
+	success := result.Error == "" && result.StatusCode >= 200 && result.StatusCode < 300
+	if err := delivery.RecordAttempt(result, success); err != nil {
+		slog.Error("webhook attempt not recorded", "delivery_id", delivery.ID, "error", err)
+	}
+
+	disabled, err := models.RecordEndpointResult(endpoint.ID, success)
+	if err != nil {
+		slog.Error("webhook endpoint result not recorded", "endpoint_id", endpoint.ID, "error", err)
+	}
+	if disabled {
+		slog.Warn("webhook endpoint disabled", "endpoint_id", endpoint.ID, "consecutive_failures", models.WebhookDisableThreshold)
+	}
+}
+
+func send(endpoint *models.WebhookEndpoint, delivery models.WebhookDelivery) models.WebhookAttempt {
+	secret, err := apis.Decrypt(endpoint.Secret)
+	if err != nil {
+		return models.WebhookAttempt{Error: "failed to load endpoint secret"}
+	}
+
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequest(http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return models.WebhookAttempt{Error: err.Error()}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "GreyBox-Webhooks/1.0")
+	req.Header.Set("X-GreyBox-Event", delivery.EventType)
+	req.Header.Set("X-GreyBox-Event-Id", delivery.EventID)
+	req.Header.Set("X-GreyBox-Delivery", fmt.Sprintf("%d", delivery.ID))
+	req.Header.Set("X-GreyBox-Signature", signing.SignWebhookPayload(secret, time.Now().Unix(), body))
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	duration := time.Since(start).Milliseconds()
+	if err != nil {
+		return models.WebhookAttempt{Error: err.Error(), DurationMs: duration}
+	}
+	// only the status is kept, the body is the endpoint's to log
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+
+	result := models.WebhookAttempt{
+		StatusCode: resp.StatusCode,
+		DurationMs: duration,
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		result.Error = fmt.Sprintf("endpoint responded with status %d", resp.StatusCode)
+	}
+	return result
+}